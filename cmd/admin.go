@@ -0,0 +1,279 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the admin HTTP API, which is used by operators and Nodes to change
+// how the network is scheduled without going through the gRPC comms
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Largest request body accepted by the admin API
+	maxAdminRequestSize = 1 << 16
+
+	// Timeouts for reading requests and writing responses on the admin API
+	adminReadTimeout  = 10 * time.Second
+	adminWriteTimeout = 30 * time.Second
+)
+
+// adminResponse is the JSON body returned by every admin API endpoint.
+type adminResponse struct {
+	Error string `json:",omitempty"`
+}
+
+// newAdminMux returns the handler for every admin API endpoint.
+func (m *RegistrationImpl) newAdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/node/drain", m.drainHandler(drainAction))
+	mux.HandleFunc("/node/ready", m.drainHandler(readyAction))
+	mux.HandleFunc("/scheduling/pause", m.requireAdminToken(m.pauseHandler))
+	mux.HandleFunc("/scheduling/resume", m.requireAdminToken(m.resumeHandler))
+	mux.HandleFunc("/scheduling/status",
+		m.requireAdminToken(m.schedulingStatusHandler))
+	mux.HandleFunc("/whitelist", m.requireAdminToken(m.whitelistListHandler))
+	mux.HandleFunc("/whitelist/add", m.requireAdminToken(m.whitelistAddHandler))
+	mux.HandleFunc("/whitelist/remove",
+		m.requireAdminToken(m.whitelistRemoveHandler))
+	mux.HandleFunc("/applications",
+		m.requireAdminToken(m.applicationsListHandler))
+	mux.HandleFunc("/applications/submit",
+		m.requireAdminToken(m.applicationsSubmitHandler))
+	mux.HandleFunc("/applications/edit",
+		m.requireAdminToken(m.applicationsEditHandler))
+	mux.HandleFunc("/applications/approve",
+		m.requireAdminToken(m.applicationsApproveHandler))
+	mux.HandleFunc("/applications/reject",
+		m.requireAdminToken(m.applicationsRejectHandler))
+	mux.HandleFunc("/applications/directory",
+		m.requireAdminToken(m.applicationsDirectoryHandler))
+	mux.HandleFunc("/rounds/blame", m.requireAdminToken(m.blameHandler))
+	mux.HandleFunc("/clientErrors", m.requireAdminToken(m.clientErrorsHandler))
+	mux.HandleFunc("/clientErrors/summary",
+		m.requireAdminToken(m.clientErrorsSummaryHandler))
+	return mux
+}
+
+// startHttpServer starts serving the named HTTP API on the given address. TLS
+// is used with the given certificate and key unless noTLS is set. The returned
+// server should be closed on shutdown.
+func startHttpServer(name, address, certPath, keyPath string, noTLS bool,
+	writeTimeout time.Duration, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:         address,
+		Handler:      handler,
+		ReadTimeout:  adminReadTimeout,
+		WriteTimeout: writeTimeout,
+	}
+
+	go func() {
+		var err error
+		if noTLS {
+			jww.WARN.Printf("Starting %s on %s without TLS", name, address)
+			err = srv.ListenAndServe()
+		} else {
+			jww.INFO.Printf("Starting %s on %s", name, address)
+			err = srv.ListenAndServeTLS(certPath, keyPath)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			jww.ERROR.Printf("%s stopped: %+v", name, err)
+		}
+	}()
+
+	return srv
+}
+
+// requireAdminToken wraps a handler for operator actions so that it only runs
+// for requests bearing the configured admin token. If no token is configured,
+// operator actions are disabled.
+func (m *RegistrationImpl) requireAdminToken(
+	next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := m.params.adminToken
+		if token == "" {
+			writeAdminResponse(w, http.StatusForbidden,
+				errors.New("operator actions are disabled, no admin token "+
+					"is configured"))
+			return
+		}
+
+		bearer := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			writeAdminResponse(w, http.StatusUnauthorized,
+				errors.New("invalid admin token"))
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// requireMethod checks the request uses the method. On failure, it writes the
+// error response and returns false.
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeAdminResponse(w, http.StatusMethodNotAllowed,
+			errors.Errorf("method %s not allowed", r.Method))
+		return false
+	}
+	return true
+}
+
+// decodeAdminRequest reads the JSON body of a POST request into v. On failure,
+// it writes the error response and returns false.
+func decodeAdminRequest(w http.ResponseWriter, r *http.Request,
+	v interface{}) bool {
+	if !requireMethod(w, r, http.MethodPost) {
+		return false
+	}
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body,
+		maxAdminRequestSize)).Decode(v)
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest,
+			errors.Errorf("could not parse request: %+v", err))
+		return false
+	}
+
+	return true
+}
+
+// newAdminClient returns a client for the admin API and the URL scheme to use.
+// If certPath is set, the server's certificate is verified against it.
+func newAdminClient(certPath string, noTLS bool) (*http.Client, string, error) {
+	client := &http.Client{Timeout: adminWriteTimeout}
+	if noTLS {
+		return client, "http", nil
+	}
+
+	if certPath != "" {
+		certBytes, err := utils.ReadFile(certPath)
+		if err != nil {
+			return nil, "", errors.Errorf("Could not read certificate: %+v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(certBytes) {
+			return nil, "", errors.Errorf("Could not load certificate %q",
+				certPath)
+		}
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+
+	return client, "https", nil
+}
+
+// sendAdminRequest sends the request to the admin API with the body encoded as
+// JSON, if it is not nil, and the admin token, if it is set. If resp is not
+// nil, the response body is decoded into it. Errors with the message returned
+// by the API if the request is not successful.
+func sendAdminRequest(client *http.Client, method, url, token string,
+	body, resp interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.Errorf("Could not marshal request: %+v", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return errors.Errorf("Could not create request: %+v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	httpResp, err := client.Do(req)
+	if err != nil {
+		return errors.Errorf("Request failed: %+v", err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	if httpResp.StatusCode != http.StatusOK {
+		respBody := adminResponse{}
+		_ = json.NewDecoder(httpResp.Body).Decode(&respBody)
+		return errors.Errorf("%s: %s", httpResp.Status, respBody.Error)
+	}
+
+	if resp != nil {
+		if err = json.NewDecoder(httpResp.Body).Decode(resp); err != nil {
+			return errors.Errorf("Could not parse response: %+v", err)
+		}
+	}
+
+	return nil
+}
+
+// writeAdminResponse writes the status code and, if err is not nil, the error
+// message as the JSON response body.
+func writeAdminResponse(w http.ResponseWriter, code int, err error) {
+	resp := adminResponse{}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	writeAdminJSON(w, code, resp)
+}
+
+// writeAdminJSON writes the status code and v as the JSON response body.
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		jww.WARN.Printf("Failed to write admin API response: %+v", err)
+	}
+}
+
+// parseBase64ID returns the ID from its base64 encoding.
+func parseBase64ID(encoded string) (*id.ID, error) {
+	idBytes, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Errorf("invalid ID %q: %+v", encoded, err)
+	}
+	nid, err := id.Unmarshal(idBytes)
+	if err != nil {
+		return nil, errors.Errorf("invalid ID %q: %+v", encoded, err)
+	}
+	return nid, nil
+}
+
+// parseAdminDuration returns the positive duration in the query parameter, or
+// the default if it is not given.
+func parseAdminDuration(query url.Values, key string,
+	defaultDuration time.Duration) (time.Duration, error) {
+	value := query.Get(key)
+	if value == "" {
+		return defaultDuration, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, errors.Errorf("invalid %s duration %q", key, value)
+	}
+	return duration, nil
+}
@@ -0,0 +1,59 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/region"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newAdminTestImpl creates a RegistrationImpl with a whitelist and an admin
+// token for testing the admin API.
+func newAdminTestImpl(t *testing.T) *RegistrationImpl {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %+v", err)
+	}
+
+	whitelist, err := storage.NewWhitelist("", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create whitelist: %+v", err)
+	}
+
+	return &RegistrationImpl{
+		State:     state,
+		params:    &Params{adminToken: "token"},
+		whitelist: whitelist,
+	}
+}
+
+// sendAdminTestRequest sends the request with the admin token to the admin API
+// and returns the response recorder.
+func sendAdminTestRequest(t *testing.T, mux http.Handler, method, path string,
+	body interface{}) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %+v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
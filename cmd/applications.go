@@ -0,0 +1,600 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles onboarding Node Applications through the admin API and the
+// applications subcommand: submitting, reviewing, and editing Applications,
+// issuing registration codes on approval, and exporting the signed public Node
+// directory.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Number of random bytes in a registration code issued on approval
+	registrationCodeLen = 10
+)
+
+// applicationFields are the editable fields of an Application. Unset fields
+// are left unchanged.
+type applicationFields struct {
+	Name     *string `json:",omitempty"`
+	Url      *string `json:",omitempty"`
+	Blurb    *string `json:",omitempty"`
+	Other    *string `json:",omitempty"`
+	Location *string `json:",omitempty"`
+	GeoBin   *string `json:",omitempty"`
+	Team     *string `json:",omitempty"`
+	Network  *string `json:",omitempty"`
+
+	Forum     *string `json:",omitempty"`
+	Email     *string `json:",omitempty"`
+	Twitter   *string `json:",omitempty"`
+	Discord   *string `json:",omitempty"`
+	Instagram *string `json:",omitempty"`
+	Medium    *string `json:",omitempty"`
+}
+
+// set sets the field with the name, which is matched without case. Errors if
+// there is no such field.
+func (f *applicationFields) set(name, value string) error {
+	field := reflect.ValueOf(f).Elem().FieldByNameFunc(func(n string) bool {
+		return strings.EqualFold(n, name)
+	})
+	if !field.IsValid() {
+		return errors.Errorf("unknown application field %q", name)
+	}
+	field.Set(reflect.ValueOf(&value))
+	return nil
+}
+
+// apply copies every set field to the Application.
+func (f *applicationFields) apply(app *storage.Application) {
+	fields := reflect.ValueOf(f).Elem()
+	target := reflect.ValueOf(app).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		if value := fields.Field(i); !value.IsNil() {
+			target.FieldByName(fields.Type().Field(i).Name).Set(value.Elem())
+		}
+	}
+}
+
+// applicationFieldNames returns the names of the editable fields.
+func applicationFieldNames() []string {
+	t := reflect.TypeOf(applicationFields{})
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i] = t.Field(i).Name
+	}
+	return names
+}
+
+// applicationRequest is the JSON body of a request to the application
+// endpoints. Id is not used when submitting, Sequence is only used when
+// approving, and Note is only used when approving or rejecting.
+type applicationRequest struct {
+	Id uint64
+	applicationFields
+	Sequence string
+	Note     string
+}
+
+// applicationView is an Application as returned by the admin API, with the
+// registration code and ID of its Node once approved.
+type applicationView struct {
+	*storage.Application
+	RegistrationCode string `json:",omitempty"`
+	NodeId           []byte `json:",omitempty"`
+}
+
+// newApplicationView returns the view of the Application.
+func newApplicationView(app *storage.Application) *applicationView {
+	return &applicationView{
+		Application:      app,
+		RegistrationCode: app.Node.Code,
+		NodeId:           app.Node.Id,
+	}
+}
+
+// Flags for the applications subcommand
+var (
+	applicationsAdminAddress string
+	applicationsAdminToken   string
+	applicationsCertPath     string
+	applicationsStatus       string
+	applicationsSequence     string
+	applicationsNote         string
+	applicationsOutputPath   string
+	applicationFieldFlags    = make(map[string]*string)
+)
+
+var applicationsCmd = &cobra.Command{
+	Use:   "applications",
+	Short: "Manages Node Applications",
+	Long: `Submits, reviews, and edits Node Applications through the admin ` +
+		`API of the permissioning server. Approving an Application issues ` +
+		`the registration code its Node registers with. The public fields of ` +
+		`approved Applications can be exported as a signed Node directory.`,
+}
+
+var applicationsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the Applications",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := "/applications"
+		if applicationsStatus != "" {
+			path += "?status=" + applicationsStatus
+		}
+
+		var views []*applicationView
+		runApplicationsRequest(http.MethodGet, path, nil, &views)
+		for _, v := range views {
+			fmt.Printf("%d\t%s\t%s\t%s\t%s\n", v.Id, v.Status, v.Name,
+				v.Team, v.RegistrationCode)
+		}
+	},
+}
+
+var applicationsSubmitCmd = &cobra.Command{
+	Use:   "submit",
+	Short: "Submits an Application for review",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		req := applicationRequest{applicationFields: fieldsFromFlags(cmd)}
+		v := &applicationView{}
+		runApplicationsRequest(http.MethodPost, "/applications/submit", req, v)
+		fmt.Printf("Submitted application %d\n", v.Id)
+	},
+}
+
+var applicationsEditCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Edits the fields of an Application set by flags",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := applicationRequest{
+			Id:                parseApplicationId(args[0]),
+			applicationFields: fieldsFromFlags(cmd),
+		}
+		runApplicationsRequest(http.MethodPost, "/applications/edit", req, nil)
+		fmt.Printf("Edited application %d\n", req.Id)
+	},
+}
+
+var applicationsApproveCmd = &cobra.Command{
+	Use:   "approve <id>",
+	Short: "Approves a pending Application and issues its registration code",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := applicationRequest{
+			Id:       parseApplicationId(args[0]),
+			Sequence: applicationsSequence,
+			Note:     applicationsNote,
+		}
+		v := &applicationView{}
+		runApplicationsRequest(http.MethodPost, "/applications/approve", req, v)
+		fmt.Printf("Approved application %d with registration code %s\n",
+			v.Id, v.RegistrationCode)
+	},
+}
+
+var applicationsRejectCmd = &cobra.Command{
+	Use:   "reject <id>",
+	Short: "Rejects a pending Application",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := applicationRequest{
+			Id:   parseApplicationId(args[0]),
+			Note: applicationsNote,
+		}
+		runApplicationsRequest(http.MethodPost, "/applications/reject", req, nil)
+		fmt.Printf("Rejected application %d\n", req.Id)
+	},
+}
+
+var applicationsImportCmd = &cobra.Command{
+	Use:   "import <csv file>",
+	Short: "Submits an Application for each row of a CSV file",
+	Long: `Submits an Application for each row of a CSV file, such as one ` +
+		`exported from a spreadsheet. The header row names the field of ` +
+		`each column; columns which are not Application fields are ignored.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			fmt.Printf("Could not open %s: %+v\n", args[0], err)
+			os.Exit(1)
+		}
+		reqs, err := parseApplicationsCsv(f)
+		_ = f.Close()
+		if err != nil {
+			fmt.Printf("Could not parse %s: %+v\n", args[0], err)
+			os.Exit(1)
+		}
+
+		for _, req := range reqs {
+			v := &applicationView{}
+			runApplicationsRequest(http.MethodPost, "/applications/submit",
+				req, v)
+			fmt.Printf("Submitted application %d for %s\n", v.Id, v.Name)
+		}
+	},
+}
+
+var applicationsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Exports the signed public directory of approved Nodes",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		directory := &storage.NodeDirectory{}
+		runApplicationsRequest(http.MethodGet, "/applications/directory", nil,
+			directory)
+
+		data, err := json.MarshalIndent(directory, "", "  ")
+		if err == nil {
+			err = os.WriteFile(applicationsOutputPath, data, 0644)
+		}
+		if err != nil {
+			fmt.Printf("Could not write directory: %+v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %d nodes to %s\n", len(directory.Nodes),
+			applicationsOutputPath)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(applicationsCmd)
+	applicationsCmd.AddCommand(applicationsListCmd, applicationsSubmitCmd,
+		applicationsEditCmd, applicationsApproveCmd, applicationsRejectCmd,
+		applicationsImportCmd, applicationsExportCmd)
+
+	applicationsCmd.PersistentFlags().StringVarP(&applicationsAdminAddress,
+		"adminAddress", "a", "", "Address of the permissioning server's admin API")
+	applicationsCmd.PersistentFlags().StringVar(&applicationsAdminToken,
+		"adminToken", "", "Admin token of the permissioning server")
+	applicationsCmd.PersistentFlags().StringVar(&applicationsCertPath,
+		"certPath", "", "Path to the permissioning server's TLS certificate")
+	applicationsCmd.PersistentFlags().BoolVar(&noTLS, "noTLS", false,
+		"Connects to the admin API without TLS")
+
+	applicationsListCmd.Flags().StringVar(&applicationsStatus, "status", "",
+		"Only lists Applications with the status: pending, approved, or rejected")
+	applicationsApproveCmd.Flags().StringVar(&applicationsSequence,
+		"sequence", "", "Country code of the Node, used to bin its gateway "+
+			"and when scheduling")
+	if err := applicationsApproveCmd.MarkFlagRequired("sequence"); err != nil {
+		jww.FATAL.Panicf("Failed to mark sequence as required: %+v", err)
+	}
+	for _, c := range []*cobra.Command{applicationsApproveCmd, applicationsRejectCmd} {
+		c.Flags().StringVar(&applicationsNote, "note", "",
+			"Reason for the review decision")
+	}
+	applicationsExportCmd.Flags().StringVarP(&applicationsOutputPath,
+		"output", "o", "nodeDirectory.json", "Path to write the directory to")
+
+	for _, name := range applicationFieldNames() {
+		flag := strings.ToLower(name[:1]) + name[1:]
+		applicationFieldFlags[flag] = new(string)
+		for _, c := range []*cobra.Command{applicationsSubmitCmd, applicationsEditCmd} {
+			c.Flags().StringVar(applicationFieldFlags[flag], flag, "",
+				fmt.Sprintf("%s of the Application", name))
+		}
+	}
+
+	for _, flag := range []string{"adminAddress", "adminToken"} {
+		if err := applicationsCmd.MarkPersistentFlagRequired(flag); err != nil {
+			jww.FATAL.Panicf("Failed to mark %s as required: %+v", flag, err)
+		}
+	}
+}
+
+// fieldsFromFlags returns the Application fields set on the command line.
+func fieldsFromFlags(cmd *cobra.Command) applicationFields {
+	fields := applicationFields{}
+	for flag, value := range applicationFieldFlags {
+		if cmd.Flags().Changed(flag) {
+			_ = fields.set(flag, *value)
+		}
+	}
+	return fields
+}
+
+// parseApplicationId parses the Application ID argument and exits if it is
+// invalid.
+func parseApplicationId(arg string) uint64 {
+	appId, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil {
+		fmt.Printf("Invalid application ID %q: %+v\n", arg, err)
+		os.Exit(1)
+	}
+	return appId
+}
+
+// parseApplicationsCsv parses a CSV file with a header row of Application
+// field names into a request for each following row. Columns which are not
+// Application fields are ignored and empty cells are left unset.
+func parseApplicationsCsv(r io.Reader) ([]applicationRequest, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	header := records[0]
+	nameColumn := -1
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+		if strings.EqualFold(header[i], "name") {
+			nameColumn = i
+		}
+	}
+	if nameColumn == -1 {
+		return nil, errors.New("header row has no name column")
+	}
+
+	reqs := make([]applicationRequest, 0, len(records)-1)
+	for row, record := range records[1:] {
+		req := applicationRequest{}
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			// Columns of other data kept in the spreadsheet are skipped
+			_ = req.set(header[i], value)
+		}
+		if req.Name == nil {
+			return nil, errors.Errorf("row %d has no name", row+2)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+// runApplicationsRequest sends the request to the application endpoint of the
+// admin API from the command line flags and exits on failure.
+func runApplicationsRequest(method, path string, req, resp interface{}) {
+	client, scheme, err := newAdminClient(applicationsCertPath, noTLS)
+	if err == nil {
+		err = sendAdminRequest(client, method, fmt.Sprintf("%s://%s%s",
+			scheme, applicationsAdminAddress, path), applicationsAdminToken,
+			req, resp)
+	}
+	if err != nil {
+		fmt.Printf("Applications request failed: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// newRegistrationCode returns a random registration code for an approved
+// Application.
+func newRegistrationCode() (string, error) {
+	b := make([]byte, registrationCodeLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Errorf("could not generate registration code: %+v",
+			err)
+	}
+	return strings.ToLower(base32.StdEncoding.EncodeToString(b)), nil
+}
+
+// getApplicationForReview returns the Application in the request. On failure,
+// it writes the error response and returns nil. If status is not nil, the
+// Application must have that status.
+func getApplicationForReview(w http.ResponseWriter, req *applicationRequest,
+	status *storage.ApplicationStatus) *storage.Application {
+	app, err := storage.PermissioningDb.GetApplication(req.Id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeAdminResponse(w, http.StatusNotFound,
+			errors.Errorf("application %d does not exist", req.Id))
+		return nil
+	} else if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return nil
+	}
+
+	if status != nil && app.Status != *status {
+		writeAdminResponse(w, http.StatusConflict, errors.Errorf(
+			"application %d is %s, not %s", req.Id, app.Status, *status))
+		return nil
+	}
+	return app
+}
+
+// applicationsListHandler returns every Application, or only those with the
+// status in the status query parameter.
+func (m *RegistrationImpl) applicationsListHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	var status *storage.ApplicationStatus
+	if query := r.URL.Query().Get("status"); query != "" {
+		status = new(storage.ApplicationStatus)
+		if err := status.UnmarshalText([]byte(query)); err != nil {
+			writeAdminResponse(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	applications, err := storage.PermissioningDb.GetApplications()
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	views := make([]*applicationView, 0, len(applications))
+	for _, app := range applications {
+		if status == nil || app.Status == *status {
+			views = append(views, newApplicationView(app))
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, views)
+}
+
+// applicationsSubmitHandler stores a new Application pending review.
+func (m *RegistrationImpl) applicationsSubmitHandler(w http.ResponseWriter,
+	r *http.Request) {
+	req := &applicationRequest{}
+	if !decodeAdminRequest(w, r, req) {
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		writeAdminResponse(w, http.StatusBadRequest,
+			errors.New("application must have a name"))
+		return
+	}
+
+	app := &storage.Application{Submitted: time.Now()}
+	req.apply(app)
+	if err := storage.PermissioningDb.InsertPendingApplication(app); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	jww.INFO.Printf("Application %d submitted for %s", app.Id, app.Name)
+
+	writeAdminJSON(w, http.StatusOK, newApplicationView(app))
+}
+
+// applicationsEditHandler changes the fields set in the request of an
+// Application of any status.
+func (m *RegistrationImpl) applicationsEditHandler(w http.ResponseWriter,
+	r *http.Request) {
+	req := &applicationRequest{}
+	if !decodeAdminRequest(w, r, req) {
+		return
+	}
+	app := getApplicationForReview(w, req, nil)
+	if app == nil {
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		writeAdminResponse(w, http.StatusBadRequest,
+			errors.New("application must have a name"))
+		return
+	}
+
+	req.apply(app)
+	if err := storage.PermissioningDb.UpdateApplication(app); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	jww.INFO.Printf("Application %d edited", app.Id)
+
+	writeAdminJSON(w, http.StatusOK, newApplicationView(app))
+}
+
+// applicationsApproveHandler approves a pending Application and creates its
+// Node with a new registration code, which is returned. The sequence must be a
+// country of the GeoBin table, which places the Node's gateway in the NDF.
+func (m *RegistrationImpl) applicationsApproveHandler(w http.ResponseWriter,
+	r *http.Request) {
+	req := &applicationRequest{}
+	if !decodeAdminRequest(w, r, req) {
+		return
+	}
+	if req.Sequence == "" {
+		writeAdminResponse(w, http.StatusBadRequest,
+			errors.New("sequence is required to approve an application"))
+		return
+	} else if _, exists := m.State.GetGeoBin(req.Sequence); !exists {
+		writeAdminResponse(w, http.StatusBadRequest, errors.Errorf(
+			"sequence %q is not a country with a GeoBin", req.Sequence))
+		return
+	}
+	pending := storage.ApplicationPending
+	app := getApplicationForReview(w, req, &pending)
+	if app == nil {
+		return
+	}
+
+	code, err := newRegistrationCode()
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	app.ReviewNote = req.Note
+	err = storage.PermissioningDb.ApproveApplication(app,
+		&storage.Node{Code: code, Sequence: req.Sequence})
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	jww.INFO.Printf("Application %d approved for %s", app.Id, app.Name)
+
+	writeAdminJSON(w, http.StatusOK, newApplicationView(app))
+}
+
+// applicationsRejectHandler rejects a pending Application.
+func (m *RegistrationImpl) applicationsRejectHandler(w http.ResponseWriter,
+	r *http.Request) {
+	req := &applicationRequest{}
+	if !decodeAdminRequest(w, r, req) {
+		return
+	}
+	pending := storage.ApplicationPending
+	app := getApplicationForReview(w, req, &pending)
+	if app == nil {
+		return
+	}
+
+	app.Status = storage.ApplicationRejected
+	app.ReviewNote = req.Note
+	if err := storage.PermissioningDb.UpdateApplication(app); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	jww.INFO.Printf("Application %d rejected: %s", app.Id, req.Note)
+
+	writeAdminResponse(w, http.StatusOK, nil)
+}
+
+// applicationsDirectoryHandler returns the public directory of approved Nodes
+// signed with the permissioning key.
+func (m *RegistrationImpl) applicationsDirectoryHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	applications, err := storage.PermissioningDb.GetApplications()
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	directory := storage.NewNodeDirectory(applications, time.Now().UnixNano())
+	if err = directory.Sign(m.State.GetPrivateKey()); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, directory)
+}
@@ -0,0 +1,189 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Tests that an Application can be submitted, edited, and approved through the
+// admin API, and that approval issues a registration code and adds the
+// Application to the signed directory.
+func TestRegistrationImpl_applicationsHandlers(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	mux := impl.newAdminMux()
+
+	name, team := "Example Node", "alpha"
+	w := sendAdminTestRequest(t, mux, http.MethodPost, "/applications/submit",
+		applicationRequest{applicationFields: applicationFields{Name: &name}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Submit failed with status %d: %s", w.Code, w.Body)
+	}
+	submitted := &applicationView{}
+	if err := json.NewDecoder(w.Body).Decode(submitted); err != nil {
+		t.Fatalf("Failed to decode response: %+v", err)
+	}
+	if submitted.Status != storage.ApplicationPending {
+		t.Errorf("Submitted application is %s", submitted.Status)
+	}
+
+	w = sendAdminTestRequest(t, mux, http.MethodPost, "/applications/edit",
+		applicationRequest{Id: submitted.Id,
+			applicationFields: applicationFields{Team: &team}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Edit failed with status %d: %s", w.Code, w.Body)
+	}
+
+	w = sendAdminTestRequest(t, mux, http.MethodGet,
+		"/applications/directory", nil)
+	directory := &storage.NodeDirectory{}
+	if err := json.NewDecoder(w.Body).Decode(directory); err != nil {
+		t.Fatalf("Failed to decode directory: %+v", err)
+	}
+	if len(directory.Nodes) != 0 {
+		t.Errorf("Pending application in directory: %+v", directory.Nodes)
+	}
+
+	w = sendAdminTestRequest(t, mux, http.MethodPost, "/applications/approve",
+		applicationRequest{Id: submitted.Id, Sequence: "US"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Approve failed with status %d: %s", w.Code, w.Body)
+	}
+	approved := &applicationView{}
+	if err := json.NewDecoder(w.Body).Decode(approved); err != nil {
+		t.Fatalf("Failed to decode response: %+v", err)
+	}
+	if approved.RegistrationCode == "" {
+		t.Fatalf("No registration code issued on approval")
+	}
+
+	n, err := storage.PermissioningDb.GetNode(approved.RegistrationCode)
+	if err != nil || n.ApplicationId != submitted.Id || n.Sequence != "US" {
+		t.Errorf("Unexpected node for registration code: %+v %+v", n, err)
+	}
+
+	w = sendAdminTestRequest(t, mux, http.MethodGet,
+		"/applications/directory", nil)
+	directory = &storage.NodeDirectory{}
+	if err = json.NewDecoder(w.Body).Decode(directory); err != nil {
+		t.Fatalf("Failed to decode directory: %+v", err)
+	}
+	if len(directory.Nodes) != 1 || directory.Nodes[0].Name != name ||
+		directory.Nodes[0].Team != team {
+		t.Errorf("Unexpected directory nodes: %+v", directory.Nodes)
+	}
+	if err = directory.Verify(impl.State.GetPrivateKey().GetPublic()); err != nil {
+		t.Errorf("Failed to verify directory: %+v", err)
+	}
+
+	// A reviewed application cannot be reviewed again
+	w = sendAdminTestRequest(t, mux, http.MethodPost, "/applications/reject",
+		applicationRequest{Id: submitted.Id})
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status %d rejecting approved application, "+
+			"received %d", http.StatusConflict, w.Code)
+	}
+}
+
+// Tests that the application endpoints reject invalid requests.
+func TestRegistrationImpl_applicationsHandlers_Invalid(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	mux := impl.newAdminMux()
+
+	empty := " "
+	tests := []struct {
+		path   string
+		req    applicationRequest
+		status int
+	}{
+		{"/applications/submit", applicationRequest{}, http.StatusBadRequest},
+		{"/applications/submit", applicationRequest{
+			applicationFields: applicationFields{Name: &empty}},
+			http.StatusBadRequest},
+		{"/applications/approve", applicationRequest{Id: 42, Sequence: "US"},
+			http.StatusNotFound},
+		{"/applications/approve", applicationRequest{Id: 42},
+			http.StatusBadRequest},
+		{"/applications/approve", applicationRequest{Id: 42, Sequence: "XX"},
+			http.StatusBadRequest},
+		{"/applications/edit", applicationRequest{Id: 42},
+			http.StatusNotFound},
+	}
+
+	for i, tt := range tests {
+		w := sendAdminTestRequest(t, mux, http.MethodPost, tt.path, tt.req)
+		if w.Code != tt.status {
+			t.Errorf("Expected status %d for %s (%d), received %d",
+				tt.status, tt.path, i, w.Code)
+		}
+	}
+
+	w := sendAdminTestRequest(t, mux, http.MethodGet,
+		"/applications?status=accepted", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unknown status, received %d",
+			http.StatusBadRequest, w.Code)
+	}
+}
+
+// Tests that parseApplicationsCsv matches header names without case, skips
+// unknown columns and empty cells, and requires a name.
+func Test_parseApplicationsCsv(t *testing.T) {
+	data := "Name,Team,Wallet,twitter\n" +
+		"First Node,alpha,0x1234,@first\n" +
+		"Second Node,,0x5678,\n"
+
+	reqs, err := parseApplicationsCsv(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %+v", err)
+	}
+	if len(reqs) != 2 {
+		t.Fatalf("Unexpected number of requests: %d", len(reqs))
+	}
+
+	if *reqs[0].Name != "First Node" || *reqs[0].Team != "alpha" ||
+		*reqs[0].Twitter != "@first" {
+		t.Errorf("Unexpected first request: %+v", reqs[0].applicationFields)
+	}
+	if *reqs[1].Name != "Second Node" || reqs[1].Team != nil ||
+		reqs[1].Twitter != nil {
+		t.Errorf("Unexpected second request: %+v", reqs[1].applicationFields)
+	}
+
+	for i, invalid := range []string{"", "Team\nalpha\n",
+		"Name,Team\n,alpha\n"} {
+		if _, err = parseApplicationsCsv(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected error for %q (%d).", invalid, i)
+		}
+	}
+}
+
+// Tests that applicationFields.apply only changes the fields which are set.
+func TestApplicationFields_apply(t *testing.T) {
+	app := &storage.Application{Name: "name", Url: "url", Team: "alpha"}
+
+	fields := applicationFields{}
+	if err := fields.set("url", "https://example.com"); err != nil {
+		t.Fatalf("Failed to set field: %+v", err)
+	}
+	if err := fields.set("TEAM", ""); err != nil {
+		t.Fatalf("Failed to set field: %+v", err)
+	}
+	if err := fields.set("id", "5"); err == nil {
+		t.Errorf("Expected error setting a field that is not editable.")
+	}
+	fields.apply(app)
+
+	if app.Name != "name" || app.Url != "https://example.com" || app.Team != "" {
+		t.Errorf("Unexpected application after apply: %+v", app)
+	}
+}
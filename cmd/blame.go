@@ -0,0 +1,167 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles looking up which Nodes failed rounds are blamed on, through the admin
+// API and the blame subcommand.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Default period the failed rounds of a Node are looked up over
+	defaultBlameSince = 24 * time.Hour
+)
+
+// Flags for the blame subcommand
+var (
+	blameAdminAddress string
+	blameAdminToken   string
+	blameCertPath     string
+	blameSince        time.Duration
+)
+
+var blameCmd = &cobra.Command{
+	Use:   "blame",
+	Short: "Shows which nodes failed rounds are blamed on",
+	Long: `Looks up the nodes failed rounds are blamed on through the admin ` +
+		`API of the permissioning server. A failure is blamed on the nodes ` +
+		`which stopped polling during the round, else on those behind the ` +
+		`rest of their team, else on those which last changed activity ` +
+		`well before the rest, else on the node which reported the error.`,
+}
+
+var blameRoundCmd = &cobra.Command{
+	Use:   "round <round ID>",
+	Short: "Shows the nodes a failed round is blamed on",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runBlameRequest(url.Values{"round": {args[0]}})
+	},
+}
+
+var blameNodeCmd = &cobra.Command{
+	Use:   "node <base64 node ID>",
+	Short: "Shows the failed rounds blamed on a node",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runBlameRequest(url.Values{"node": {args[0]},
+			"since": {blameSince.String()}})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(blameCmd)
+	blameCmd.AddCommand(blameRoundCmd, blameNodeCmd)
+
+	blameCmd.PersistentFlags().StringVarP(&blameAdminAddress,
+		"adminAddress", "a", "", "Address of the permissioning server's admin API")
+	blameCmd.PersistentFlags().StringVar(&blameAdminToken,
+		"adminToken", "", "Admin token of the permissioning server")
+	blameCmd.PersistentFlags().StringVar(&blameCertPath, "certPath",
+		"", "Path to the permissioning server's TLS certificate")
+	blameCmd.PersistentFlags().BoolVar(&noTLS, "noTLS", false,
+		"Connects to the admin API without TLS")
+
+	blameNodeCmd.Flags().DurationVar(&blameSince, "since", defaultBlameSince,
+		"How far back to look for failed rounds, e.g. 168h")
+
+	for _, flag := range []string{"adminAddress", "adminToken"} {
+		if err := blameCmd.MarkPersistentFlagRequired(flag); err != nil {
+			jww.FATAL.Panicf("Failed to mark %s as required: %+v", flag, err)
+		}
+	}
+}
+
+// runBlameRequest sends the query to the blame endpoint of the admin API from
+// the command line flags, prints the blame, and exits on failure.
+func runBlameRequest(query url.Values) {
+	var blames []*storage.RoundBlame
+	client, scheme, err := newAdminClient(blameCertPath, noTLS)
+	if err == nil {
+		err = sendAdminRequest(client, http.MethodGet, fmt.Sprintf(
+			"%s://%s/rounds/blame?%s", scheme, blameAdminAddress,
+			query.Encode()), blameAdminToken, nil, &blames)
+	}
+	if err != nil {
+		fmt.Printf("Blame request failed: %+v\n", err)
+		os.Exit(1)
+	}
+
+	for _, blame := range blames {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", blame.RoundMetricId,
+			base64.StdEncoding.EncodeToString(blame.NodeId), blame.Reason,
+			blame.Activity, blame.Timestamp.Format(time.RFC3339))
+	}
+}
+
+// blameHandler returns the nodes the round in the round query parameter is
+// blamed on, or the rounds blamed on the node in the node query parameter
+// since the duration in the since query parameter, newest first.
+func (m *RegistrationImpl) blameHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	var blames []*storage.RoundBlame
+	var err error
+	switch roundQuery, nodeQuery := query.Get("round"), query.Get("node"); {
+	case roundQuery != "" && nodeQuery == "":
+		roundID, parseErr := strconv.ParseUint(roundQuery, 10, 64)
+		if parseErr != nil {
+			writeAdminResponse(w, http.StatusBadRequest,
+				errors.Errorf("invalid round ID %q", roundQuery))
+			return
+		}
+		blames, err = storage.PermissioningDb.GetRoundBlames(id.Round(roundID))
+
+	case nodeQuery != "" && roundQuery == "":
+		nid, parseErr := parseBase64ID(nodeQuery)
+		if parseErr != nil {
+			writeAdminResponse(w, http.StatusBadRequest, parseErr)
+			return
+		}
+		since, parseErr := parseAdminDuration(query, "since",
+			defaultBlameSince)
+		if parseErr != nil {
+			writeAdminResponse(w, http.StatusBadRequest, parseErr)
+			return
+		}
+		blames, err = storage.PermissioningDb.GetNodeBlames(nid,
+			time.Now().Add(-since))
+
+	default:
+		writeAdminResponse(w, http.StatusBadRequest,
+			errors.New("exactly one of round or node must be given"))
+		return
+	}
+
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if blames == nil {
+		blames = []*storage.RoundBlame{}
+	}
+
+	writeAdminJSON(w, http.StatusOK, blames)
+}
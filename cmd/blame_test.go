@@ -0,0 +1,89 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// Tests that the blame of a round can be looked up by round and by node.
+func TestRegistrationImpl_blameHandler(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	mux := impl.newAdminMux()
+
+	nid := id.NewIdFromString("blamed", id.Node, t)
+	err := storage.PermissioningDb.InsertApplication(&storage.Application{Id: 1},
+		&storage.Node{Code: "BLAMED", Id: nid.Bytes()})
+	if err != nil {
+		t.Fatalf("Failed to insert node: %+v", err)
+	}
+	err = storage.PermissioningDb.InsertRoundMetric(&storage.RoundMetric{Id: 5,
+		RoundEnd: time.Now()}, [][]byte{nid.Bytes()})
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+	err = storage.PermissioningDb.InsertRoundBlames(5, []*storage.RoundBlame{{
+		NodeId: nid.Marshal(), Reason: "behind", Activity: "PRECOMPUTING",
+		Timestamp: time.Now()}})
+	if err != nil {
+		t.Fatalf("Failed to insert round blames: %+v", err)
+	}
+
+	for _, path := range []string{"/rounds/blame?round=5",
+		"/rounds/blame?node=" + url.QueryEscape(
+			base64.StdEncoding.EncodeToString(nid.Marshal())) + "&since=1h"} {
+		w := sendAdminTestRequest(t, mux, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", path, w.Code, w.Body)
+		}
+		var blames []*storage.RoundBlame
+		if err = json.Unmarshal(w.Body.Bytes(), &blames); err != nil {
+			t.Fatalf("%s: failed to decode blames: %+v", path, err)
+		}
+		if len(blames) != 1 || blames[0].RoundMetricId != 5 ||
+			blames[0].Reason != "behind" {
+			t.Errorf("%s: unexpected blames: %+v", path, blames)
+		}
+	}
+
+	w := sendAdminTestRequest(t, mux, http.MethodGet, "/rounds/blame?round=6",
+		nil)
+	if w.Code != http.StatusOK || w.Body.String() != "[]\n" {
+		t.Errorf("Unexpected response for a round without blame: %d %q",
+			w.Code, w.Body)
+	}
+}
+
+// Tests that the blame endpoint rejects malformed queries.
+func TestRegistrationImpl_blameHandler_Invalid(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	mux := impl.newAdminMux()
+	nodeQuery := url.QueryEscape(base64.StdEncoding.EncodeToString(
+		id.NewIdFromString("blamed", id.Node, t).Marshal()))
+
+	for _, path := range []string{
+		"/rounds/blame",
+		"/rounds/blame?round=five",
+		"/rounds/blame?node=notbase64!",
+		"/rounds/blame?node=" + nodeQuery + "&since=-1h",
+		"/rounds/blame?round=5&node=" + nodeQuery,
+	} {
+		w := sendAdminTestRequest(t, mux, http.MethodGet, path, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", path,
+				http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles looking up and summarising the client errors reported by gateways,
+// through the admin API and the clientErrors subcommand.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Default period client errors are looked up and summarised over
+	defaultClientErrorsSince = 24 * time.Hour
+	// Default length of the intervals client errors are counted in
+	defaultClientErrorsInterval = time.Hour
+	// Most intervals a summary may count client errors in
+	maxClientErrorIntervals = 1000
+)
+
+// Flags for the clientErrors subcommand
+var (
+	clientErrorsAdminAddress string
+	clientErrorsAdminToken   string
+	clientErrorsCertPath     string
+	clientErrorsSince        time.Duration
+	clientErrorsInterval     time.Duration
+)
+
+// clientErrorSummary is the number of client errors of each type reported in
+// every interval since a time.
+type clientErrorSummary struct {
+	Since    time.Time
+	Interval time.Duration
+	Types    []*clientErrorTypeSummary
+}
+
+// clientErrorTypeSummary counts the client errors of a single type.
+type clientErrorTypeSummary struct {
+	ErrorType string
+	Count     int
+	Clients   int
+	Gateways  int
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Number of errors reported in each interval, oldest first
+	Counts []int
+}
+
+var clientErrorsCmd = &cobra.Command{
+	Use:   "clientErrors",
+	Short: "Shows the client errors reported by gateways",
+	Long: `Looks up the client errors gateways report with their nodes' ` +
+		`polls through the admin API of the permissioning server. Errors ` +
+		`are kept with the round, gateway, and client they were reported for.`,
+}
+
+var clientErrorsRoundCmd = &cobra.Command{
+	Use:   "round <round ID>",
+	Short: "Shows the client errors reported in a round",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var clientErrors []*storage.RoundClientError
+		runClientErrorsRequest("/clientErrors",
+			url.Values{"round": {args[0]}}, &clientErrors)
+		printClientErrors(clientErrors)
+	},
+}
+
+var clientErrorsClientCmd = &cobra.Command{
+	Use:   "client <base64 client ID>",
+	Short: "Shows the client errors reported for a client",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var clientErrors []*storage.RoundClientError
+		runClientErrorsRequest("/clientErrors", url.Values{
+			"client": {args[0]}, "since": {clientErrorsSince.String()}},
+			&clientErrors)
+		printClientErrors(clientErrors)
+	},
+}
+
+var clientErrorsSummaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Counts the client errors of each type over time",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var summary clientErrorSummary
+		runClientErrorsRequest("/clientErrors/summary", url.Values{
+			"since":    {clientErrorsSince.String()},
+			"interval": {clientErrorsInterval.String()}}, &summary)
+
+		for _, typeSummary := range summary.Types {
+			counts := make([]string, len(typeSummary.Counts))
+			for i, count := range typeSummary.Counts {
+				counts[i] = strconv.Itoa(count)
+			}
+			fmt.Printf("%d\t%d\t%d\t%s\t%s\t%s\n", typeSummary.Count,
+				typeSummary.Clients, typeSummary.Gateways,
+				typeSummary.LastSeen.Format(time.RFC3339),
+				typeSummary.ErrorType, strings.Join(counts, ","))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clientErrorsCmd)
+	clientErrorsCmd.AddCommand(clientErrorsRoundCmd, clientErrorsClientCmd,
+		clientErrorsSummaryCmd)
+
+	clientErrorsCmd.PersistentFlags().StringVarP(&clientErrorsAdminAddress,
+		"adminAddress", "a", "", "Address of the permissioning server's admin API")
+	clientErrorsCmd.PersistentFlags().StringVar(&clientErrorsAdminToken,
+		"adminToken", "", "Admin token of the permissioning server")
+	clientErrorsCmd.PersistentFlags().StringVar(&clientErrorsCertPath,
+		"certPath", "", "Path to the permissioning server's TLS certificate")
+	clientErrorsCmd.PersistentFlags().BoolVar(&noTLS, "noTLS", false,
+		"Connects to the admin API without TLS")
+
+	for _, cmd := range []*cobra.Command{clientErrorsClientCmd,
+		clientErrorsSummaryCmd} {
+		cmd.Flags().DurationVar(&clientErrorsSince, "since",
+			defaultClientErrorsSince, "How far back to look for client errors")
+	}
+	clientErrorsSummaryCmd.Flags().DurationVar(&clientErrorsInterval,
+		"interval", defaultClientErrorsInterval,
+		"Length of the intervals client errors are counted in")
+
+	for _, flag := range []string{"adminAddress", "adminToken"} {
+		if err := clientErrorsCmd.MarkPersistentFlagRequired(flag); err != nil {
+			jww.FATAL.Panicf("Failed to mark %s as required: %+v", flag, err)
+		}
+	}
+}
+
+// runClientErrorsRequest sends the query to the path of the admin API from the
+// command line flags, decodes the response into v, and exits on failure.
+func runClientErrorsRequest(path string, query url.Values, v interface{}) {
+	client, scheme, err := newAdminClient(clientErrorsCertPath, noTLS)
+	if err == nil {
+		err = sendAdminRequest(client, http.MethodGet, fmt.Sprintf(
+			"%s://%s%s?%s", scheme, clientErrorsAdminAddress, path,
+			query.Encode()), clientErrorsAdminToken, nil, v)
+	}
+	if err != nil {
+		fmt.Printf("Client errors request failed: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printClientErrors prints each client error on its own line.
+func printClientErrors(clientErrors []*storage.RoundClientError) {
+	for _, clientError := range clientErrors {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\n", clientError.RoundId,
+			base64.StdEncoding.EncodeToString(clientError.GatewayId),
+			base64.StdEncoding.EncodeToString(clientError.ClientId),
+			clientError.Timestamp.Format(time.RFC3339), clientError.Error)
+	}
+}
+
+// clientErrorsHandler returns the client errors reported in the round in the
+// round query parameter, or those reported for the client in the client query
+// parameter since the duration in the since query parameter, oldest first.
+func (m *RegistrationImpl) clientErrorsHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	var clientErrors []*storage.RoundClientError
+	var err error
+	switch roundQuery, clientQuery := query.Get("round"), query.Get("client"); {
+	case roundQuery != "" && clientQuery == "":
+		roundID, parseErr := strconv.ParseUint(roundQuery, 10, 64)
+		if parseErr != nil {
+			writeAdminResponse(w, http.StatusBadRequest,
+				errors.Errorf("invalid round ID %q", roundQuery))
+			return
+		}
+		clientErrors, err = storage.PermissioningDb.GetRoundClientErrors(
+			m.State.GetNetwork(), id.Round(roundID))
+
+	case clientQuery != "" && roundQuery == "":
+		clientID, parseErr := parseBase64ID(clientQuery)
+		if parseErr != nil {
+			writeAdminResponse(w, http.StatusBadRequest, parseErr)
+			return
+		}
+		since, parseErr := parseAdminDuration(query, "since",
+			defaultClientErrorsSince)
+		if parseErr != nil {
+			writeAdminResponse(w, http.StatusBadRequest, parseErr)
+			return
+		}
+		clientErrors, err = storage.PermissioningDb.GetClientErrors(
+			m.State.GetNetwork(), clientID.Marshal(), time.Now().Add(-since))
+
+	default:
+		writeAdminResponse(w, http.StatusBadRequest,
+			errors.New("exactly one of round or client must be given"))
+		return
+	}
+
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if clientErrors == nil {
+		clientErrors = []*storage.RoundClientError{}
+	}
+
+	writeAdminJSON(w, http.StatusOK, clientErrors)
+}
+
+// clientErrorsSummaryHandler returns the number of client errors of each type
+// reported in every interval of the interval query parameter since the
+// duration in the since query parameter.
+func (m *RegistrationImpl) clientErrorsSummaryHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	query := r.URL.Query()
+	since, err := parseAdminDuration(query, "since", defaultClientErrorsSince)
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	interval, err := parseAdminDuration(query, "interval",
+		defaultClientErrorsInterval)
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, err)
+		return
+	}
+	if since/interval >= maxClientErrorIntervals {
+		writeAdminResponse(w, http.StatusBadRequest, errors.Errorf(
+			"more than %d intervals of %s in %s", maxClientErrorIntervals,
+			interval, since))
+		return
+	}
+
+	start := time.Now().Add(-since)
+	clientErrors, err := storage.PermissioningDb.GetClientErrors(
+		m.State.GetNetwork(), nil, start)
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK,
+		summarizeClientErrors(clientErrors, start, since, interval))
+}
+
+// summarizeClientErrors counts the client errors of each type in every
+// interval of the period starting at start, most frequent types first.
+func summarizeClientErrors(clientErrors []*storage.RoundClientError,
+	start time.Time, period, interval time.Duration) *clientErrorSummary {
+	intervals := int((period + interval - 1) / interval)
+	types := make(map[string]*clientErrorTypeSummary)
+	clients := make(map[string]map[string]struct{})
+	gateways := make(map[string]map[string]struct{})
+	summary := &clientErrorSummary{
+		Since:    start,
+		Interval: interval,
+		Types:    []*clientErrorTypeSummary{},
+	}
+
+	for _, clientError := range clientErrors {
+		if clientError.Timestamp.Before(start) {
+			continue
+		}
+		i := int(clientError.Timestamp.Sub(start) / interval)
+		if i >= intervals {
+			continue
+		}
+
+		typeSummary, exists := types[clientError.ErrorType]
+		if !exists {
+			typeSummary = &clientErrorTypeSummary{
+				ErrorType: clientError.ErrorType,
+				FirstSeen: clientError.Timestamp,
+				Counts:    make([]int, intervals),
+			}
+			types[clientError.ErrorType] = typeSummary
+			clients[clientError.ErrorType] = make(map[string]struct{})
+			gateways[clientError.ErrorType] = make(map[string]struct{})
+			summary.Types = append(summary.Types, typeSummary)
+		}
+
+		typeSummary.Count++
+		typeSummary.Counts[i]++
+		if clientError.Timestamp.Before(typeSummary.FirstSeen) {
+			typeSummary.FirstSeen = clientError.Timestamp
+		}
+		if clientError.Timestamp.After(typeSummary.LastSeen) {
+			typeSummary.LastSeen = clientError.Timestamp
+		}
+		clients[clientError.ErrorType][string(clientError.ClientId)] = struct{}{}
+		gateways[clientError.ErrorType][string(clientError.GatewayId)] = struct{}{}
+	}
+
+	for _, typeSummary := range summary.Types {
+		typeSummary.Clients = len(clients[typeSummary.ErrorType])
+		typeSummary.Gateways = len(gateways[typeSummary.ErrorType])
+	}
+	sort.SliceStable(summary.Types, func(i, j int) bool {
+		return summary.Types[i].Count > summary.Types[j].Count
+	})
+
+	return summary
+}
@@ -0,0 +1,133 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Tests that client errors can be looked up by round and by client, and
+// summarised by type.
+func TestRegistrationImpl_clientErrorsHandlers(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	mux := impl.newAdminMux()
+
+	gateway := id.NewIdFromString("gateway", id.Gateway, t)
+	clients := []*id.ID{id.NewIdFromString("client0", id.User, t),
+		id.NewIdFromString("client1", id.User, t)}
+	for i, client := range clients {
+		err := storage.PermissioningDb.InsertClientErrors(id.Round(i+1),
+			[]*storage.RoundClientError{{GatewayId: gateway.Marshal(),
+				ClientId: client.Marshal(), ErrorType: "failed to send",
+				Error: "failed to send: timed out", Timestamp: time.Now()}})
+		if err != nil {
+			t.Fatalf("Failed to insert client errors: %+v", err)
+		}
+	}
+
+	for path, round := range map[string]uint64{
+		"/clientErrors?round=2": 2,
+		"/clientErrors?since=1h&client=" + url.QueryEscape(
+			base64.StdEncoding.EncodeToString(clients[0].Marshal())): 1,
+	} {
+		w := sendAdminTestRequest(t, mux, http.MethodGet, path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d: %s", path, w.Code, w.Body)
+		}
+		var clientErrors []*storage.RoundClientError
+		if err := json.Unmarshal(w.Body.Bytes(), &clientErrors); err != nil {
+			t.Fatalf("%s: failed to decode client errors: %+v", path, err)
+		}
+		if len(clientErrors) != 1 || clientErrors[0].RoundId != round {
+			t.Errorf("%s: unexpected client errors: %+v", path, clientErrors)
+		}
+	}
+
+	w := sendAdminTestRequest(t, mux, http.MethodGet,
+		"/clientErrors/summary?since=2h&interval=1h", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Unexpected status %d: %s", w.Code, w.Body)
+	}
+	var summary clientErrorSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatalf("Failed to decode summary: %+v", err)
+	}
+	if len(summary.Types) != 1 || summary.Types[0].Count != 2 ||
+		summary.Types[0].Clients != 2 || summary.Types[0].Gateways != 1 ||
+		!reflect.DeepEqual(summary.Types[0].Counts, []int{0, 2}) {
+		t.Errorf("Unexpected summary: %+v", summary.Types)
+	}
+}
+
+// Tests that the client error endpoints reject malformed queries.
+func TestRegistrationImpl_clientErrorsHandlers_Invalid(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	mux := impl.newAdminMux()
+	clientQuery := url.QueryEscape(base64.StdEncoding.EncodeToString(
+		id.NewIdFromString("client", id.User, t).Marshal()))
+
+	for _, path := range []string{
+		"/clientErrors",
+		"/clientErrors?round=two",
+		"/clientErrors?client=notbase64!",
+		"/clientErrors?client=" + clientQuery + "&since=forever",
+		"/clientErrors?round=2&client=" + clientQuery,
+		"/clientErrors/summary?interval=0s",
+		"/clientErrors/summary?since=10000h&interval=1h",
+	} {
+		w := sendAdminTestRequest(t, mux, http.MethodGet, path, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d, got %d", path,
+				http.StatusBadRequest, w.Code)
+		}
+	}
+}
+
+// Tests that client errors are counted in the interval they were reported in,
+// most frequent types first, and that errors outside the period are ignored.
+func Test_summarizeClientErrors(t *testing.T) {
+	start := time.Unix(1000, 0)
+	newError := func(errorType string, client byte, after time.Duration) *storage.RoundClientError {
+		return &storage.RoundClientError{ErrorType: errorType,
+			ClientId: []byte{client}, GatewayId: []byte{0},
+			Timestamp: start.Add(after)}
+	}
+
+	summary := summarizeClientErrors([]*storage.RoundClientError{
+		newError("rare", 0, 30*time.Minute),
+		newError("common", 0, 10*time.Minute),
+		newError("common", 1, 150*time.Minute),
+		newError("common", 1, 170*time.Minute),
+		newError("common", 2, -time.Minute),
+		newError("common", 2, 4*time.Hour),
+	}, start, 3*time.Hour, time.Hour)
+
+	if len(summary.Types) != 2 {
+		t.Fatalf("Expected 2 types, found %d", len(summary.Types))
+	}
+	common, rare := summary.Types[0], summary.Types[1]
+	if common.ErrorType != "common" || common.Count != 3 ||
+		common.Clients != 2 || common.Gateways != 1 ||
+		!reflect.DeepEqual(common.Counts, []int{1, 0, 2}) ||
+		!common.FirstSeen.Equal(start.Add(10*time.Minute)) ||
+		!common.LastSeen.Equal(start.Add(170*time.Minute)) {
+		t.Errorf("Unexpected summary of common errors: %+v", common)
+	}
+	if rare.ErrorType != "rare" || rare.Count != 1 ||
+		!reflect.DeepEqual(rare.Counts, []int{1, 0, 0}) {
+		t.Errorf("Unexpected summary of rare errors: %+v", rare)
+	}
+}
@@ -0,0 +1,274 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles draining Nodes for planned maintenance. A draining Node finishes its
+// current round, is not scheduled into new ones, and is marked as stale in the
+// NDF until it is made ready again. Requests are signed by the Node's key.
+
+package cmd
+
+import (
+	"crypto"
+	cryptoRand "crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// Actions a drain request can be signed for
+	drainAction = "drain"
+	readyAction = "ready"
+
+	// How far a drain request's timestamp may be from the local time
+	drainRequestWindow = 5 * time.Minute
+)
+
+// drainRequest is the JSON body of a request to the drain or ready endpoints.
+type drainRequest struct {
+	NodeId    []byte
+	Timestamp int64
+	Signature []byte
+}
+
+// Flags for the drain subcommand
+var (
+	drainAdminAddress string
+	drainNodeId       string
+	drainKeyPath      string
+	drainCertPath     string
+	drainReady        bool
+)
+
+var drainCmd = &cobra.Command{
+	Use:   "drain",
+	Short: "Drains a Node for maintenance or makes it ready again",
+	Long: `Sends a request signed with the Node's private key to the admin API ` +
+		`of the permissioning server. A drained Node finishes its current ` +
+		`round and is then kept out of scheduling and marked as stale in the ` +
+		`NDF. Pass --ready to return it to scheduling.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		action := drainAction
+		if drainReady {
+			action = readyAction
+		}
+
+		err := sendDrainRequest(action)
+		if err != nil {
+			fmt.Printf("Failed to %s Node: %+v\n", action, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Node %s request accepted\n", action)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(drainCmd)
+
+	drainCmd.Flags().StringVarP(&drainAdminAddress, "adminAddress", "a", "",
+		"Address of the permissioning server's admin API")
+	drainCmd.Flags().StringVarP(&drainNodeId, "nodeId", "n", "",
+		"Base64 encoded ID of the Node")
+	drainCmd.Flags().StringVarP(&drainKeyPath, "keyPath", "k", "",
+		"Path to the Node's private key")
+	drainCmd.Flags().StringVar(&drainCertPath, "certPath", "",
+		"Path to the permissioning server's TLS certificate")
+	drainCmd.Flags().BoolVar(&drainReady, "ready", false,
+		"Returns a drained Node to scheduling")
+	drainCmd.Flags().BoolVar(&noTLS, "noTLS", false,
+		"Connects to the admin API without TLS")
+
+	for _, flag := range []string{"adminAddress", "nodeId", "keyPath"} {
+		if err := drainCmd.MarkFlagRequired(flag); err != nil {
+			jww.FATAL.Panicf("Failed to mark %s as required: %+v", flag, err)
+		}
+	}
+}
+
+// sendDrainRequest signs a request for the action with the Node's key from the
+// command line flags and sends it to the admin API.
+func sendDrainRequest(action string) error {
+	idBytes, err := base64.StdEncoding.DecodeString(drainNodeId)
+	if err != nil {
+		return errors.Errorf("Could not decode Node ID: %+v", err)
+	}
+	nid, err := id.Unmarshal(idBytes)
+	if err != nil {
+		return errors.Errorf("Could not unmarshal Node ID: %+v", err)
+	}
+
+	keyBytes, err := utils.ReadFile(drainKeyPath)
+	if err != nil {
+		return errors.Errorf("Could not read Node key: %+v", err)
+	}
+	key, err := rsa.LoadPrivateKeyFromPem(keyBytes)
+	if err != nil {
+		return errors.Errorf("Could not load Node key: %+v", err)
+	}
+
+	req, err := signDrainRequest(action, nid, key, time.Now())
+	if err != nil {
+		return err
+	}
+
+	client, scheme, err := newAdminClient(drainCertPath, noTLS)
+	if err != nil {
+		return err
+	}
+
+	return sendAdminRequest(client, http.MethodPost, fmt.Sprintf(
+		"%s://%s/node/%s", scheme, drainAdminAddress, action), "", req, nil)
+}
+
+// drainRequestHash returns the hash signed for a drain request.
+func drainRequestHash(action string, nodeId []byte, timestamp int64) []byte {
+	h := crypto.SHA256.New()
+	h.Write([]byte(action))
+	h.Write(nodeId)
+	tsBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(tsBytes, uint64(timestamp))
+	h.Write(tsBytes)
+	return h.Sum(nil)
+}
+
+// signDrainRequest builds a request for the action signed with the Node's key.
+func signDrainRequest(action string, nid *id.ID, key *rsa.PrivateKey,
+	now time.Time) (*drainRequest, error) {
+	req := &drainRequest{
+		NodeId:    nid.Marshal(),
+		Timestamp: now.UnixNano(),
+	}
+
+	var err error
+	req.Signature, err = rsa.Sign(cryptoRand.Reader, key, crypto.SHA256,
+		drainRequestHash(action, req.NodeId, req.Timestamp), nil)
+	if err != nil {
+		return nil, errors.Errorf("Could not sign request: %+v", err)
+	}
+
+	return req, nil
+}
+
+// verifyDrainRequest checks that the request for the action was signed by the
+// Node's key within the allowed window and is newer than the last request
+// accepted for the Node. Returns the ID of the Node.
+func (m *RegistrationImpl) verifyDrainRequest(action string, req *drainRequest,
+	now time.Time) (*id.ID, error) {
+	nid, err := id.Unmarshal(req.NodeId)
+	if err != nil {
+		return nil, errors.Errorf("invalid Node ID: %+v", err)
+	}
+
+	ts := time.Unix(0, req.Timestamp)
+	if ts.Before(now.Add(-drainRequestWindow)) ||
+		ts.After(now.Add(drainRequestWindow)) {
+		return nil, errors.Errorf("timestamp %s is more than %s from %s",
+			ts, drainRequestWindow, now)
+	}
+
+	nodeInfo, err := storage.PermissioningDb.GetNodeById(nid)
+	if err != nil {
+		return nil, errors.Errorf("unknown Node %s", nid)
+	}
+	cert, err := tls.LoadCertificate(nodeInfo.NodeCertificate)
+	if err != nil {
+		return nil, errors.Errorf("could not load certificate of Node %s: %+v",
+			nid, err)
+	}
+	pubKey, err := tls.ExtractPublicKey(cert)
+	if err != nil {
+		return nil, errors.Errorf("could not get public key of Node %s: %+v",
+			nid, err)
+	}
+
+	err = rsa.Verify(pubKey, crypto.SHA256,
+		drainRequestHash(action, req.NodeId, req.Timestamp), req.Signature, nil)
+	if err != nil {
+		return nil, errors.Errorf("invalid signature for Node %s", nid)
+	}
+
+	// Reject replays of earlier requests still within the window
+	m.drainLock.Lock()
+	defer m.drainLock.Unlock()
+	if m.lastDrainRequest == nil {
+		m.lastDrainRequest = make(map[id.ID]int64)
+	}
+	if req.Timestamp <= m.lastDrainRequest[*nid] {
+		return nil, errors.Errorf("request for Node %s is not newer than "+
+			"the last accepted request", nid)
+	}
+	m.lastDrainRequest[*nid] = req.Timestamp
+
+	return nid, nil
+}
+
+// setNodeDraining puts the Node into or takes it out of drain mode, notifies
+// the scheduler, and updates the NDF to reflect the Node's new status.
+func (m *RegistrationImpl) setNodeDraining(nid *id.ID, draining bool) error {
+	ns := m.State.GetNodeMap().GetNode(nid)
+	if ns == nil {
+		return errors.Errorf("Node %s is not in the network", nid)
+	}
+
+	// Take the polling lock so the change is not interleaved with a poll. It
+	// is released by the scheduler once the update is handled.
+	ns.GetPollingLock().Lock()
+	nun, err := ns.SetDraining(draining)
+	if err != nil {
+		ns.GetPollingLock().Unlock()
+		return err
+	}
+
+	m.State.SendUpdateNotification(nun)
+
+	jww.INFO.Printf("Node %s draining set to %t", nid, draining)
+
+	if err = m.reissueNdf(); err != nil {
+		jww.ERROR.Printf("Failed to update NDF after draining Node %s: %+v",
+			nid, err)
+	}
+
+	return nil
+}
+
+// drainHandler returns the admin API handler which performs the action on the
+// Node named in a signed request.
+func (m *RegistrationImpl) drainHandler(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &drainRequest{}
+		if !decodeAdminRequest(w, r, req) {
+			return
+		}
+
+		nid, err := m.verifyDrainRequest(action, req, time.Now())
+		if err != nil {
+			jww.WARN.Printf("Rejected %s request: %+v", action, err)
+			writeAdminResponse(w, http.StatusForbidden, err)
+			return
+		}
+
+		err = m.setNodeDraining(nid, action == drainAction)
+		if err != nil {
+			writeAdminResponse(w, http.StatusConflict, err)
+			return
+		}
+
+		writeAdminResponse(w, http.StatusOK, nil)
+	}
+}
@@ -0,0 +1,179 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/testkeys"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"gitlab.com/xx_network/primitives/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newDrainTestImpl creates a RegistrationImpl with one Node registered using
+// the test Node certificate and returns it with the Node's ID and key.
+func newDrainTestImpl(t *testing.T) (*RegistrationImpl, *id.ID, *rsa.PrivateKey) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %+v", err)
+	}
+
+	certBytes, err := utils.ReadFile(testkeys.GetNodeCertPath())
+	if err != nil {
+		t.Fatalf("Failed to read node cert: %+v", err)
+	}
+	keyBytes, err := utils.ReadFile(testkeys.GetNodeKeyPath())
+	if err != nil {
+		t.Fatalf("Failed to read node key: %+v", err)
+	}
+	key, err := rsa.LoadPrivateKeyFromPem(keyBytes)
+	if err != nil {
+		t.Fatalf("Failed to load node key: %+v", err)
+	}
+
+	nid := id.NewIdFromUInt(5, id.Node, t)
+	err = storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1}, &storage.Node{Code: "AAA", ApplicationId: 1})
+	if err != nil {
+		t.Fatalf("Failed to insert application: %+v", err)
+	}
+	err = storage.PermissioningDb.RegisterNode(nid, []byte("salt"), "AAA",
+		"", string(certBytes), "", string(certBytes))
+	if err != nil {
+		t.Fatalf("Failed to register node: %+v", err)
+	}
+	if err = state.GetNodeMap().AddNode(nid, "0", "", "", 1); err != nil {
+		t.Fatalf("Failed to add node to node map: %+v", err)
+	}
+
+	return &RegistrationImpl{State: state}, nid, key
+}
+
+// Tests that verifyDrainRequest accepts a correctly signed request and rejects
+// requests with the wrong action, an old timestamp, or a replayed timestamp.
+func TestRegistrationImpl_verifyDrainRequest(t *testing.T) {
+	impl, nid, key := newDrainTestImpl(t)
+	now := time.Now()
+
+	req, err := signDrainRequest(drainAction, nid, key, now)
+	if err != nil {
+		t.Fatalf("Failed to sign request: %+v", err)
+	}
+
+	// Signed for a different action
+	if _, err = impl.verifyDrainRequest(readyAction, req, now); err == nil {
+		t.Errorf("Request signed for %s accepted for %s.", drainAction,
+			readyAction)
+	}
+
+	received, err := impl.verifyDrainRequest(drainAction, req, now)
+	if err != nil {
+		t.Fatalf("Failed to verify valid request: %+v", err)
+	}
+	if !received.Cmp(nid) {
+		t.Errorf("Unexpected node ID.\nexpected: %s\nreceived: %s", nid, received)
+	}
+
+	// Replay
+	if _, err = impl.verifyDrainRequest(drainAction, req, now); err == nil {
+		t.Errorf("Replayed request accepted.")
+	}
+
+	// Outside the window
+	old, err := signDrainRequest(drainAction, nid, key,
+		now.Add(-2*drainRequestWindow))
+	if err != nil {
+		t.Fatalf("Failed to sign request: %+v", err)
+	}
+	if _, err = impl.verifyDrainRequest(drainAction, old, now); err == nil {
+		t.Errorf("Request outside of the window accepted.")
+	}
+}
+
+// Tests that the drain and ready endpoints change the Node's drain mode and
+// notify the scheduler.
+func TestRegistrationImpl_drainHandler(t *testing.T) {
+	impl, nid, key := newDrainTestImpl(t)
+	mux := impl.newAdminMux()
+
+	post := func(action string, ts time.Time) int {
+		req, err := signDrainRequest(action, nid, key, ts)
+		if err != nil {
+			t.Fatalf("Failed to sign request: %+v", err)
+		}
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost,
+			"/node/"+action, bytes.NewReader(body)))
+		return w.Code
+	}
+
+	ns := impl.State.GetNodeMap().GetNode(nid)
+	now := time.Now()
+
+	if code := post(drainAction, now); code != http.StatusOK {
+		t.Fatalf("Drain request failed with status %d", code)
+	}
+	if !ns.IsDraining() {
+		t.Errorf("Node not draining after drain request.")
+	}
+	select {
+	case nun := <-impl.State.GetNodeUpdateChannel():
+		if !nun.DrainChange || !nun.Node.Cmp(nid) {
+			t.Errorf("Unexpected update notification: %+v", nun)
+		}
+		ns.GetPollingLock().Unlock()
+	default:
+		t.Fatalf("No update notification sent for drain.")
+	}
+
+	// Draining again is a conflict
+	if code := post(drainAction, now.Add(time.Second)); code != http.StatusConflict {
+		t.Errorf("Expected status %d for repeated drain, received %d",
+			http.StatusConflict, code)
+	}
+
+	if code := post(readyAction, now.Add(2*time.Second)); code != http.StatusOK {
+		t.Fatalf("Ready request failed with status %d", code)
+	}
+	if ns.IsDraining() {
+		t.Errorf("Node still draining after ready request.")
+	}
+	select {
+	case <-impl.State.GetNodeUpdateChannel():
+		ns.GetPollingLock().Unlock()
+	default:
+		t.Errorf("No update notification sent for ready.")
+	}
+}
+
+// Tests that the admin API rejects requests which are not POST requests.
+func TestRegistrationImpl_drainHandler_Method(t *testing.T) {
+	impl, _, _ := newDrainTestImpl(t)
+
+	w := httptest.NewRecorder()
+	impl.newAdminMux().ServeHTTP(w,
+		httptest.NewRequest(http.MethodGet, "/node/drain", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, received %d",
+			http.StatusMethodNotAllowed, w.Code)
+	}
+}
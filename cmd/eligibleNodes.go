@@ -0,0 +1,84 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles selecting the source of the eligible Node set from the config
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/primitives/utils"
+	"time"
+)
+
+// Eligible Node sources which can be set in the config
+const (
+	databaseEligibleNodeSource = "database"
+	fileEligibleNodeSource     = "file"
+	httpEligibleNodeSource     = "http"
+)
+
+const (
+	// Default duration between reloads of the eligible Node set
+	defaultEligibleNodesPollDuration = time.Minute
+
+	// How long to wait for the eligible Node HTTP endpoint to respond
+	eligibleNodesHttpTimeout = 30 * time.Second
+)
+
+// newEligibleNodeSource returns the eligible Node source set in the config or
+// nil if every Node is eligible. The deprecated onlyScheduleActive flag selects
+// the database source.
+func newEligibleNodeSource() (storage.EligibleNodeSource, error) {
+	source := viper.GetString("eligibleNodeSource")
+	if source == "" && viper.GetBool("onlyScheduleActive") {
+		source = databaseEligibleNodeSource
+	}
+
+	switch source {
+	case "":
+		return nil, nil
+	case databaseEligibleNodeSource:
+		return storage.NewDatabaseEligibleNodes(storage.PermissioningDb), nil
+	case fileEligibleNodeSource:
+		path := viper.GetString("eligibleNodesPath")
+		if path == "" {
+			return nil, errors.New("eligibleNodesPath must be set for the " +
+				"file eligible Node source")
+		}
+		certPEM, err := utils.ReadFile(viper.GetString("eligibleNodesCertPath"))
+		if err != nil {
+			return nil, errors.Errorf("could not read eligibleNodesCertPath: "+
+				"%+v", err)
+		}
+		cert, err := tls.LoadCertificate(string(certPEM))
+		if err != nil {
+			return nil, errors.Errorf("could not load eligible Node "+
+				"certificate: %+v", err)
+		}
+		key, err := tls.ExtractPublicKey(cert)
+		if err != nil {
+			return nil, errors.Errorf("could not get eligible Node "+
+				"certificate public key: %+v", err)
+		}
+		return storage.NewFileEligibleNodes(path, key), nil
+	case httpEligibleNodeSource:
+		url := viper.GetString("eligibleNodesUrl")
+		if url == "" {
+			return nil, errors.New("eligibleNodesUrl must be set for the " +
+				"http eligible Node source")
+		}
+		return storage.NewHttpEligibleNodes(url, eligibleNodesHttpTimeout), nil
+	default:
+		return nil, errors.Errorf("unknown eligibleNodeSource %q, expected "+
+			"%q, %q or %q", source, databaseEligibleNodeSource,
+			fileEligibleNodeSource, httpEligibleNodeSource)
+	}
+}
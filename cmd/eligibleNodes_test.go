@@ -0,0 +1,58 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"gitlab.com/elixxir/registration/testkeys"
+	"testing"
+)
+
+// Tests that newEligibleNodeSource returns a source for each valid config and
+// errors for incomplete or unknown ones.
+func Test_newEligibleNodeSource(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		config map[string]interface{}
+		source bool
+		err    bool
+	}{
+		{map[string]interface{}{}, false, false},
+		{map[string]interface{}{"onlyScheduleActive": true}, true, false},
+		{map[string]interface{}{"eligibleNodeSource": "database"}, true, false},
+		{map[string]interface{}{"eligibleNodeSource": "file",
+			"eligibleNodesPath":     "eligible.json",
+			"eligibleNodesCertPath": testkeys.GetCACertPath()}, true, false},
+		{map[string]interface{}{"eligibleNodeSource": "file",
+			"eligibleNodesCertPath": testkeys.GetCACertPath()}, false, true},
+		{map[string]interface{}{"eligibleNodeSource": "file",
+			"eligibleNodesPath": "eligible.json"}, false, true},
+		{map[string]interface{}{"eligibleNodeSource": "http",
+			"eligibleNodesUrl": "http://localhost:8080"}, true, false},
+		{map[string]interface{}{"eligibleNodeSource": "http"}, false, true},
+		{map[string]interface{}{"eligibleNodeSource": "chain"}, false, true},
+	}
+
+	for i, tt := range tests {
+		viper.Reset()
+		for key, value := range tt.config {
+			viper.Set(key, value)
+		}
+
+		source, err := newEligibleNodeSource()
+		if (err != nil) != tt.err {
+			t.Errorf("Unexpected error for config %v (%d): %+v",
+				tt.config, i, err)
+		}
+		if (source != nil) != tt.source {
+			t.Errorf("Unexpected source for config %v (%d): %v",
+				tt.config, i, source)
+		}
+	}
+}
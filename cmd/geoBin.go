@@ -59,9 +59,10 @@ func (m *RegistrationImpl) setNodeSequence(n *node.State, nodeIpAddr string) err
 		if err != nil {
 			return errors.WithMessage(err, "Failed to get gps for address")
 		}
-		geobin, ok = region.GetCountryBin(countryCode)
+		geobin, ok = m.State.GetGeoBin(countryCode)
 		if !ok {
-			return errors.WithMessage(err, "Could not get bin for country code")
+			return errors.Errorf("Could not get bin for country code %q",
+				countryCode)
 		}
 		countryName, err = lookupCountryName(nodeIpAddr, m.geoIPDB)
 		if err != nil {
@@ -12,6 +12,7 @@ import (
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
 	"math/rand"
 	"testing"
 )
@@ -37,6 +38,12 @@ func TestRegistrationImpl_setNodeBin_GeoIP2DB(t *testing.T) {
 		t.Fatalf("Failed to create new database: %+v", err)
 	}
 
+	// Create a state holding the GeoBin table
+	impl.State, err = storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create state: %+v", err)
+	}
+
 	// Add an application to it
 	testID := id.NewIdFromUInt(0, id.Node, t)
 	applicationId := rand.Uint64()
@@ -11,7 +11,6 @@ package cmd
 
 import (
 	"crypto/x509"
-	"encoding/json"
 	"github.com/oschwald/geoip2-golang"
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
@@ -63,6 +62,21 @@ type RegistrationImpl struct {
 	geoIPDBStatus geoipStatus
 
 	earliestRoundTracker atomic.Value
+
+	// Timestamp of the last accepted drain request for each Node, used to
+	// reject replayed requests
+	lastDrainRequest map[id.ID]int64
+	drainLock        sync.Mutex
+
+	// Pauses and resumes team formation in the Scheduler
+	schedulingPauser *scheduling.Pauser
+
+	// Set when the scheduling params are also polled from storage, which then
+	// takes precedence over the scheduling config file for the params it holds
+	schedulingParamsFromStorage bool
+
+	// IDs and IP addresses exempt from rate limiting
+	whitelist *storage.Whitelist
 }
 
 // function used to schedule nodes
@@ -132,6 +146,7 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 		beginScheduling:      make(chan struct{}, 1),
 		registrationTimes:    make(map[id.ID]int64),
 		earliestRoundTracker: atomic.Value{},
+		schedulingPauser:     scheduling.NewPauser(),
 	}
 
 	// If the the GeoIP2 database file is supplied, then use it to open the
@@ -161,44 +176,26 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 		jww.INFO.Printf("Loaded %d GeoBins from Primitives!", len(geoBins))
 	}
 
-	whitelistedIds := make([]string, 0)
-	if regImpl.params.WhitelistedIdsPath != "" {
-
-		// Load whitelisted ID file
-		preApprovedFile, err := utils.ReadFile(regImpl.params.WhitelistedIdsPath)
-		if err != nil {
-			jww.WARN.Printf("Error while parsing WhitelistedIds Path list: %v", err)
-		} else {
-			// Unmarshal file (should be a JSON of list of IDs))
-			err = json.Unmarshal(preApprovedFile, &whitelistedIds)
-			if err != nil {
-				jww.WARN.Printf("Could not unmarshal whitelisted IDs: %v", err)
-			}
-		}
-
+	// Load the whitelist from storage and the whitelist files
+	regImpl.whitelist, err = storage.NewWhitelist(regImpl.params.network,
+		regImpl.params.WhitelistedIdsPath, regImpl.params.WhitelistedIpAddressPath)
+	if err != nil {
+		return nil, errors.Errorf("failed to load whitelist: %+v", err)
 	}
-
-	whitelistedIpAddresses := make([]string, 0)
-	if regImpl.params.WhitelistedIpAddressPath != "" {
-		// Load whitelisted IP addresses file
-		whitelistFile, err := utils.ReadFile(regImpl.params.WhitelistedIpAddressPath)
-		if err != nil {
-			jww.WARN.Printf("Cannot read whitelisted IP addresses file (%s): %v",
-				regImpl.params.WhitelistedIpAddressPath, err)
-		} else {
-			// Unmarshal file (should be a JSON of list of IDs))
-			err = json.Unmarshal(whitelistFile, &whitelistedIpAddresses)
-			if err != nil {
-				jww.WARN.Printf("Could not unmarshal whitelisted IP addresses: %v", err)
-			} else {
-				jww.INFO.Printf("Added whitelisted IPs: %+v", whitelistedIpAddresses)
-			}
-		}
-
+	whitelistedIds, whitelistedIpAddresses, err := regImpl.whitelist.Get(time.Now())
+	if err != nil {
+		return nil, errors.Errorf("failed to load whitelist: %+v", err)
 	}
+	jww.INFO.Printf("Loaded whitelist of %d IDs and %d IP addresses",
+		len(whitelistedIds), len(whitelistedIpAddresses))
 
 	// Initialize the state tracking object
-	regImpl.State, err = storage.NewState(rsaPrivateKey, uint32(newestAddressSpace.Size),
+	firstRoundId := id.Round(params.firstRoundId)
+	if firstRoundId == 0 {
+		firstRoundId = 1
+	}
+	regImpl.State, err = storage.NewNetworkState(params.network, firstRoundId,
+		id.Round(params.lastRoundId), rsaPrivateKey, uint32(newestAddressSpace.Size),
 		params.FullNdfOutputPath, params.SignedPartialNdfOutputPath, geoBins)
 	if err != nil {
 		return nil, err
@@ -230,26 +227,26 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 	// Construct the NDF
 	networkDef := &ndf.NetworkDefinition{
 		Registration: ndf.Registration{
-			Address:                   RegParams.publicAddress,
+			Address:                   params.publicAddress,
 			TlsCertificate:            regImpl.certFromFile,
 			EllipticPubKey:            regImpl.State.GetEllipticPublicKey().MarshalText(),
-			ClientRegistrationAddress: RegParams.clientRegistrationAddress,
+			ClientRegistrationAddress: params.clientRegistrationAddress,
 		},
 		Timestamp: time.Now(),
 		UDB: ndf.UDB{
-			ID:       RegParams.udbId,
+			ID:       params.udbId,
 			Cert:     string(udbCert),
-			Address:  RegParams.udbAddress,
-			DhPubKey: RegParams.udbDhPubKey,
+			Address:  params.udbAddress,
+			DhPubKey: params.udbDhPubKey,
 		},
-		E2E:  RegParams.e2e,
-		CMIX: RegParams.cmix,
+		E2E:  params.e2e,
+		CMIX: params.cmix,
 		// fixme: consider removing. this allows clients to remain agnostic of teaming order
 		//  by forcing team order == ndf order for simple non-random
 		Nodes:                  make([]ndf.Node, 0),
 		Gateways:               make([]ndf.Gateway, 0),
 		AddressSpace:           addressSpaces,
-		ClientVersion:          RegParams.minClientVersion.String(),
+		ClientVersion:          params.minClientVersion.String(),
 		WhitelistedIds:         whitelistedIds,
 		WhitelistedIpAddresses: whitelistedIpAddresses,
 		RateLimits: ndf.RateLimiting{
@@ -260,13 +257,13 @@ func StartRegistration(params Params) (*RegistrationImpl, error) {
 	}
 
 	// Assemble notification server information if configured
-	if RegParams.NsCertPath != "" && RegParams.NsAddress != "" {
-		nsCert, err := utils.ReadFile(RegParams.NsCertPath)
+	if params.NsCertPath != "" && params.NsAddress != "" {
+		nsCert, err := utils.ReadFile(params.NsCertPath)
 		if err != nil {
 			return nil, errors.Errorf("unable to read notification certificate")
 		}
 		networkDef.Notification = ndf.Notification{
-			Address:        RegParams.NsAddress,
+			Address:        params.NsAddress,
 			TlsCertificate: string(nsCert),
 		}
 	} else {
@@ -326,16 +323,48 @@ func BannedNodeTracker(impl *RegistrationImpl) error {
 		return errors.Errorf("Failed to get nodes by %s status: %v", node.Banned, err)
 	}
 
-	impl.State.InternalNdfLock.Lock()
-	defer impl.State.InternalNdfLock.Unlock()
+	toBan, err := pruneBannedNodes(state, bannedNodes)
+	if err != nil {
+		return err
+	}
+
+	// The NDF lock must not be held here; waiting on a polling lock while
+	// holding it would deadlock with the scheduler
+	for _, ns := range toBan {
+		// Take the polling lock so the ban is not interleaved with a poll. It
+		// is released by the scheduler once the update is handled.
+		ns.GetPollingLock().Lock()
+
+		// Ban the node, propagating the ban to the node's state
+		nun, err := ns.Ban()
+		if err != nil {
+			ns.GetPollingLock().Unlock()
+			return errors.WithMessage(err, "Could not ban node")
+		}
+
+		// Send the node's update notification to the scheduler
+		state.SendUpdateNotification(nun)
+	}
+
+	return nil
+}
+
+// pruneBannedNodes removes the banned nodes from the NDF and returns the states
+// of those which have not yet been banned.
+func pruneBannedNodes(state *storage.NetworkState,
+	bannedNodes []*storage.Node) ([]*node.State, error) {
+	state.InternalNdfLock.Lock()
+	defer state.InternalNdfLock.Unlock()
 	def := state.GetUnprunedNdf()
 
+	var toBan []*node.State
+
 	// Parse through the returned node list
 	for _, n := range bannedNodes {
 		// Convert the id into an id.ID
 		nodeId, err := id.Unmarshal(n.Id)
 		if err != nil {
-			return errors.Errorf("Failed to convert node %s to id.ID: %v", n.Id, err)
+			return nil, errors.Errorf("Failed to convert node %s to id.ID: %v", n.Id, err)
 		}
 
 		gatewayID := nodeId.DeepCopy()
@@ -347,7 +376,7 @@ func BannedNodeTracker(impl *RegistrationImpl) error {
 		for i, n := range def.Nodes {
 			ndfNodeID, err := id.Unmarshal(n.ID)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to unmarshal node id from NDF")
+				return nil, errors.WithMessage(err, "Failed to unmarshal node id from NDF")
 			}
 			if ndfNodeID.Cmp(nodeId) {
 				continue
@@ -359,7 +388,7 @@ func BannedNodeTracker(impl *RegistrationImpl) error {
 		for i, g := range def.Gateways {
 			ndfGatewayID, err := id.Unmarshal(g.ID)
 			if err != nil {
-				return errors.WithMessage(err, "Failed to unmarshal gateway id from NDF")
+				return nil, errors.WithMessage(err, "Failed to unmarshal gateway id from NDF")
 			}
 			if ndfGatewayID.Cmp(gatewayID) {
 				continue
@@ -386,29 +415,14 @@ func BannedNodeTracker(impl *RegistrationImpl) error {
 
 		// Get the node from the nodeMap
 		ns := state.GetNodeMap().GetNode(nodeId)
-		var nun node.UpdateNotification
 		// If the node is already banned do not attempt to re-ban
 		if ns == nil || ns.IsBanned() {
 			continue
 		}
-
-		// Ban the node, propagating the ban to the node's state
-		nun, err = ns.Ban()
-		if err != nil {
-			return errors.WithMessage(err, "Could not ban node")
-		}
-
-		// take the polling lock
-		ns.GetPollingLock().Lock()
-
-		/// Send the node's update notification to the scheduler
-		err = state.SendUpdateNotification(nun)
-		if err != nil {
-			return errors.WithMessage(err, "Could not send update notification")
-		}
+		toBan = append(toBan, ns)
 	}
 
-	return nil
+	return toBan, nil
 }
 
 // NewImplementation returns a registration server Handler
@@ -0,0 +1,503 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles running secondary networks alongside the primary network in the same
+// permissioning instance
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/spf13/viper"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/utils"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// networkConfig is the configuration of a secondary network in the networks
+// section of the config file. Unset paths and values are taken from the
+// primary network except for those which must differ.
+type networkConfig struct {
+	// Port the network's comms listen on; must differ from every other network
+	Port int
+
+	// Public address of the network, without the port (Default publicAddress)
+	PublicAddress string
+
+	// NDF output paths; must differ from every other network
+	FullNdfOutputPath          string
+	SignedPartialNdfOutputPath string
+
+	// Scheduling params JSON (Default schedulingConfigPath)
+	SchedulingConfigPath string
+
+	// Number of Nodes that must register before rounds start
+	// (Default minimumNodes)
+	MinimumNodes uint32
+
+	// Round ID the network starts at. Round metrics of every network share a
+	// table, so the network may only use the round IDs up to the next highest
+	// firstRoundId, which are kept in lastRoundId
+	FirstRoundId uint64
+	lastRoundId  uint64
+}
+
+// loadNetworkConfigs loads the secondary networks from the config, keyed on
+// their lowercase names, checks that they do not collide with each other or
+// the primary network, and assigns each its range of round IDs.
+func loadNetworkConfigs() (map[string]*networkConfig, error) {
+	rawConfigs := make(map[string]*networkConfig)
+	if err := viper.UnmarshalKey("networks", &rawConfigs); err != nil {
+		return nil, errors.Errorf("could not parse networks: %+v", err)
+	}
+
+	// Application networks are matched without case
+	configs := make(map[string]*networkConfig, len(rawConfigs))
+	for name, cfg := range rawConfigs {
+		name = strings.ToLower(name)
+		if _, exists := configs[name]; exists {
+			return nil, errors.Errorf("network %q is configured twice", name)
+		}
+		configs[name] = cfg
+	}
+
+	ports := map[int]string{viper.GetInt("port"): "the primary network"}
+	paths := map[string]string{
+		viper.GetString("fullNdfOutputPath"):          "the primary network",
+		viper.GetString("signedPartialNDFOutputPath"): "the primary network",
+	}
+	firstRoundIds := make(map[uint64]string)
+
+	for _, name := range sortedNetworkNames(configs) {
+		cfg := configs[name]
+		if cfg == nil {
+			return nil, errors.Errorf("network %q has no configuration", name)
+		}
+
+		if cfg.Port == 0 {
+			return nil, errors.Errorf("network %q has no port", name)
+		} else if other, exists := ports[cfg.Port]; exists {
+			return nil, errors.Errorf("network %q uses port %d of %s", name,
+				cfg.Port, other)
+		}
+		ports[cfg.Port] = "network " + name
+
+		for _, path := range []string{
+			cfg.FullNdfOutputPath, cfg.SignedPartialNdfOutputPath} {
+			if path == "" {
+				return nil, errors.Errorf("network %q must set "+
+					"fullNdfOutputPath and signedPartialNdfOutputPath", name)
+			} else if other, exists := paths[path]; exists {
+				return nil, errors.Errorf("network %q outputs its NDF to %q "+
+					"which is used by %s", name, path, other)
+			}
+			paths[path] = "network " + name
+		}
+
+		if cfg.FirstRoundId <= 1 {
+			return nil, errors.Errorf("network %q must set firstRoundId so "+
+				"that its round IDs do not overlap with the primary network",
+				name)
+		} else if other, exists := firstRoundIds[cfg.FirstRoundId]; exists {
+			return nil, errors.Errorf("network %q has the same firstRoundId "+
+				"as %s", name, other)
+		}
+		firstRoundIds[cfg.FirstRoundId] = "network " + name
+	}
+
+	// Each network's round IDs end where those of the next network begin
+	for _, cfg := range configs {
+		for _, other := range configs {
+			if other.FirstRoundId > cfg.FirstRoundId && (cfg.lastRoundId == 0 ||
+				other.FirstRoundId-1 < cfg.lastRoundId) {
+				cfg.lastRoundId = other.FirstRoundId - 1
+			}
+		}
+	}
+
+	return configs, nil
+}
+
+// primaryLastRoundId returns the last round ID the primary network may use,
+// which is the one before the first round ID of the secondary networks, or
+// zero if there are none.
+func primaryLastRoundId(configs map[string]*networkConfig) uint64 {
+	var lastRoundId uint64
+	for _, cfg := range configs {
+		if lastRoundId == 0 || cfg.FirstRoundId-1 < lastRoundId {
+			lastRoundId = cfg.FirstRoundId - 1
+		}
+	}
+	return lastRoundId
+}
+
+// sortedNetworkNames returns the names of the networks in sorted order.
+func sortedNetworkNames(configs map[string]*networkConfig) []string {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inNetwork returns the network of the Application and whether it is served
+// by this instance. If no secondary networks are configured, the primary
+// network serves every Application without looking it up.
+func (m *RegistrationImpl) inNetwork(applicationId uint64) (string, bool, error) {
+	if m.params.network == "" && len(m.params.secondaryNetworks) == 0 {
+		return "", true, nil
+	}
+
+	application, err := storage.PermissioningDb.GetApplication(applicationId)
+	if err != nil {
+		return "", false, errors.Errorf("could not get Application %d: %+v",
+			applicationId, err)
+	}
+
+	network := strings.ToLower(application.Network)
+	if m.params.network == "" {
+		return network, !m.params.secondaryNetworks[network], nil
+	}
+	return network, network == m.params.network, nil
+}
+
+// networkRoutines are the routines which keep the state of a network up to date
+// with storage and the configured sources. Every network runs its own.
+type networkRoutines struct {
+	geoBinPollQuit        chan struct{}
+	latencyPollQuit       chan struct{}
+	eligibleNodesPollQuit chan struct{}
+	whitelistPollQuit     chan struct{}
+	paramsUpdateQuit      chan struct{}
+}
+
+// startNetworkRoutines starts the routines of the network which reload its
+// GeoBins, latency table, eligible Nodes, whitelist, and scheduling params.
+// The scheduling params of the network must already be set.
+func startNetworkRoutines(impl *RegistrationImpl,
+	nodeMetricInterval time.Duration) (*networkRoutines, error) {
+	r := &networkRoutines{
+		geoBinPollQuit:        make(chan struct{}, 1),
+		latencyPollQuit:       make(chan struct{}, 1),
+		eligibleNodesPollQuit: make(chan struct{}, 1),
+		whitelistPollQuit:     make(chan struct{}, 1),
+		paramsUpdateQuit:      make(chan struct{}, 1),
+	}
+
+	network := "the primary network"
+	if impl.State.GetNetwork() != "" {
+		network = fmt.Sprintf("network %q", impl.State.GetNetwork())
+	}
+
+	// Set up the eligible Node source before starting any routine so that
+	// none are left running if it is invalid
+	eligibleNodeSource, err := newEligibleNodeSource()
+	if err != nil {
+		return nil, errors.Errorf("failed to set up eligible Node source of "+
+			"%s: %+v", network, err)
+	}
+
+	// Reload GeoBins from storage so that changes reach the NDF and team
+	// ordering without a restart
+	if impl.params.blockchainGeoBinning {
+		geoBinsPollDuration = viper.GetDuration("geoBinsPollDuration")
+		if geoBinsPollDuration == 0 {
+			geoBinsPollDuration = defaultGeoBinsPollDuration
+		}
+		go impl.State.PollGeoBins(storage.PermissioningDb.GetBins,
+			geoBinsPollDuration, r.geoBinPollQuit)
+	}
+
+	// Build the latency table used for team ordering from measured round
+	// timings
+	if !viper.GetBool("disableMeasuredLatency") {
+		latencyTableInterval = viper.GetDuration("latencyTableInterval")
+		if latencyTableInterval == 0 {
+			latencyTableInterval = defaultLatencyTableInterval
+		}
+		latencyWindow := viper.GetUint64("latencyWindow")
+		if latencyWindow == 0 {
+			latencyWindow = defaultLatencyWindow
+		}
+
+		latencyTracker, err := storage.NewLatencyTracker(
+			impl.State.GetNetwork(), latencyWindow)
+		if err != nil {
+			jww.WARN.Printf("Using static latency table on %s: %+v", network,
+				err)
+		} else {
+			err = impl.State.UpdateLatencyTable(latencyTracker)
+			if err != nil {
+				jww.WARN.Printf("Error while updating latency table of %s: "+
+					"%+v", network, err)
+			}
+			go impl.State.PollLatencyTable(latencyTracker,
+				latencyTableInterval, r.latencyPollQuit)
+		}
+	}
+
+	// Reload the set of Nodes eligible for scheduling so that changes reach
+	// the waiting pool and the NDF without waiting for the node metric tracker
+	if eligibleNodeSource != nil {
+		_, err = impl.State.UpdateEligibleNodes(eligibleNodeSource)
+		if err != nil {
+			jww.WARN.Printf("Error while loading eligible Nodes of %s: %+v",
+				network, err)
+		}
+
+		eligibleNodesPollDuration = viper.GetDuration("eligibleNodesPollDuration")
+		if eligibleNodesPollDuration == 0 {
+			eligibleNodesPollDuration = defaultEligibleNodesPollDuration
+		}
+		go impl.State.PollEligibleNodes(eligibleNodeSource,
+			eligibleNodesPollDuration, func() {
+				if err := impl.reissueNdf(); err != nil {
+					jww.ERROR.Printf("Failed to update NDF of %s after "+
+						"eligible Nodes changed: %+v", network, err)
+				}
+			}, r.eligibleNodesPollQuit)
+	}
+
+	// Rebuild the whitelist so that expired entries and changes to the
+	// whitelist files reach the NDF
+	whitelistPollDuration = viper.GetDuration("whitelistPollDuration")
+	if whitelistPollDuration == 0 {
+		whitelistPollDuration = defaultWhitelistPollDuration
+	}
+	go impl.State.PollWhitelist(impl.whitelist, whitelistPollDuration,
+		func() {
+			if err := impl.State.UpdateOutputNdf(); err != nil {
+				jww.ERROR.Printf("Failed to update NDF of %s after whitelist "+
+					"changed: %+v", network, err)
+			}
+		}, r.whitelistPollQuit)
+
+	// Poll the scheduling params of the network from storage if enabled
+	if impl.params.enableBlockchain {
+		go scheduling.UpdateParams(impl.schedulingParams, nodeMetricInterval,
+			impl.State, r.paramsUpdateQuit)
+		impl.schedulingParamsFromStorage = true
+	}
+
+	return r, nil
+}
+
+// stop signals every routine of the network to stop.
+func (r *networkRoutines) stop() {
+	r.geoBinPollQuit <- struct{}{}
+	r.latencyPollQuit <- struct{}{}
+	r.eligibleNodesPollQuit <- struct{}{}
+	r.whitelistPollQuit <- struct{}{}
+	r.paramsUpdateQuit <- struct{}{}
+}
+
+// secondaryNetwork is a network run alongside the primary network, with its
+// own state, comms, and Scheduler.
+type secondaryNetwork struct {
+	name string
+	impl *RegistrationImpl
+
+	// Set to 1 once the Scheduler has started
+	schedulerStarted  uint32
+	roundCreationQuit chan *scheduling.Shutdown
+
+	metricTrackerQuit chan struct{}
+	bannedTrackerQuit chan struct{}
+	routines          *networkRoutines
+}
+
+// startSecondaryNetwork starts the network from its config using the primary
+// network's params for everything it does not set. The Scheduler starts once
+// the network's minimum number of Nodes have registered.
+func startSecondaryNetwork(name string, cfg *networkConfig,
+	nodeMetricInterval, banTrackerInterval time.Duration) (*secondaryNetwork, error) {
+	params := RegParams
+	params.network = name
+	params.secondaryNetworks = nil
+	params.firstRoundId = cfg.FirstRoundId
+	params.lastRoundId = cfg.lastRoundId
+	params.Address = fmt.Sprintf("0.0.0.0:%d", cfg.Port)
+	params.FullNdfOutputPath = cfg.FullNdfOutputPath
+	params.SignedPartialNdfOutputPath = cfg.SignedPartialNdfOutputPath
+
+	host := cfg.PublicAddress
+	if host == "" {
+		host = viper.GetString("publicAddress")
+	}
+	params.publicAddress = net.JoinHostPort(host, fmt.Sprintf("%d", cfg.Port))
+	if cfg.SchedulingConfigPath != "" {
+		params.schedulingConfigPath = cfg.SchedulingConfigPath
+	}
+	if cfg.MinimumNodes != 0 {
+		params.minimumNodes = cfg.MinimumNodes
+	}
+
+	serialParams, err := utils.ReadFile(params.schedulingConfigPath)
+	if err != nil {
+		return nil, errors.Errorf("could not load scheduling config for "+
+			"network %q: %+v", name, err)
+	}
+	schedulingParams, err := scheduling.LoadParams(serialParams, 0, nil)
+	if err != nil {
+		return nil, errors.Errorf("invalid scheduling config for network "+
+			"%q: %+v", name, err)
+	}
+
+	impl, err := StartRegistration(params)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "could not start network %q",
+			name)
+	}
+	impl.schedulingParams = &scheduling.SafeParams{Params: &schedulingParams}
+
+	n := &secondaryNetwork{
+		name:              name,
+		impl:              impl,
+		roundCreationQuit: make(chan *scheduling.Shutdown),
+		metricTrackerQuit: make(chan struct{}, 1),
+		bannedTrackerQuit: make(chan struct{}, 1),
+	}
+
+	n.routines, err = startNetworkRoutines(impl, nodeMetricInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	schedulingViper := viper.New()
+	schedulingViper.SetConfigFile(params.schedulingConfigPath)
+	schedulingViper.OnConfigChange(impl.updateSchedulingParams)
+	schedulingViper.WatchConfig()
+
+	go TrackNodeMetrics(impl, n.metricTrackerQuit, nodeMetricInterval)
+	go trackBannedNodes(impl, banTrackerInterval, n.bannedTrackerQuit)
+
+	go func() {
+		<-impl.beginScheduling
+		jww.INFO.Printf("Minimum number of nodes %d have registered on "+
+			"network %q, beginning scheduling", params.minimumNodes, name)
+		if err := impl.State.UpdateOutputNdf(); err != nil {
+			jww.FATAL.Panicf("Failed to update output NDF of network %q: %+v",
+				name, err)
+		}
+
+		atomic.StoreUint32(&n.schedulerStarted, 1)
+		err := scheduling.Scheduler(impl.schedulingParams, impl.State,
+			impl.schedulingPauser, n.roundCreationQuit)
+		if err != nil {
+			jww.FATAL.Panicf("Scheduling Algorithm of network %q exited on "+
+				"a fatal error: %v", name, err)
+		}
+		jww.INFO.Printf("Scheduling Algorithm of network %q stopped", name)
+	}()
+
+	jww.INFO.Printf("Started network %q on %s, waiting for %d nodes to "+
+		"register", name, params.publicAddress, params.minimumNodes)
+	return n, nil
+}
+
+// stopRounds stops round creation on the network and returns the exit code
+// reflecting how scheduling stopped.
+func (n *secondaryNetwork) stopRounds(closeTimeout, killTimeout time.Duration) int {
+	exitCode := cleanShutdownExitCode
+	if atomic.LoadUint32(&n.schedulerStarted) == 1 {
+		jww.INFO.Printf("Stopping round creation on network %q", n.name)
+		exitCode = stopScheduler(n.roundCreationQuit, closeTimeout, killTimeout)
+	}
+
+	n.bannedTrackerQuit <- struct{}{}
+	atomic.StoreUint32(n.impl.Stopped, 1)
+	return exitCode
+}
+
+// stopAllRounds stops round creation on the primary network with stopPrimary
+// and on every secondary network at the same time. Returns the first exit code
+// that is not clean, starting with the primary network.
+func stopAllRounds(networks []*secondaryNetwork, closeTimeout,
+	killTimeout time.Duration, stopPrimary func() int) int {
+	exitCodes := make([]int, len(networks))
+	var wg sync.WaitGroup
+	for i, n := range networks {
+		wg.Add(1)
+		go func(i int, n *secondaryNetwork) {
+			defer wg.Done()
+			exitCodes[i] = n.stopRounds(closeTimeout, killTimeout)
+		}(i, n)
+	}
+
+	exitCode := stopPrimary()
+	wg.Wait()
+
+	for _, code := range exitCodes {
+		if exitCode == cleanShutdownExitCode {
+			exitCode = code
+		}
+	}
+	return exitCode
+}
+
+// stop publishes a final NDF for the network and stops its threads and comms.
+// Round creation must already be stopped.
+func (n *secondaryNetwork) stop() {
+	if err := n.impl.reissueNdf(); err != nil {
+		jww.ERROR.Printf("Failed to write final NDF of network %q: %+v",
+			n.name, err)
+	}
+
+	n.metricTrackerQuit <- struct{}{}
+	n.routines.stop()
+
+	if n.impl.geoIPDB != nil {
+		n.impl.geoIPDBStatus.ToStopped()
+		if err := n.impl.geoIPDB.Close(); err != nil {
+			jww.ERROR.Printf("Error closing GeoIP2 database reader of "+
+				"network %q: %+v", n.name, err)
+		}
+	}
+
+	n.impl.Comms.Shutdown()
+}
+
+// mountNetworkMuxes serves the API returned by newMux for each secondary
+// network under /networks/<name>/ of the primary network's API.
+func mountNetworkMuxes(mux *http.ServeMux, networks []*secondaryNetwork,
+	newMux func(impl *RegistrationImpl) http.Handler) {
+	for _, n := range networks {
+		prefix := "/networks/" + n.name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, newMux(n.impl)))
+	}
+}
+
+// trackBannedNodes runs the BannedNodeTracker at the interval until the quit
+// channel is signalled.
+func trackBannedNodes(impl *RegistrationImpl, interval time.Duration,
+	quitChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// Keep track of banned nodes
+			err := BannedNodeTracker(impl)
+			if err != nil {
+				jww.FATAL.Panicf("BannedNodeTracker failed: %v", err)
+			}
+		case <-quitChan:
+			return
+		}
+	}
+}
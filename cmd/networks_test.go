@@ -0,0 +1,224 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"testing"
+	"time"
+)
+
+// testNetworkConfig returns a valid config for a secondary network.
+func testNetworkConfig(port int, name string, firstRoundId uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"port":                       port,
+		"fullNdfOutputPath":          name + "-ndf.json",
+		"signedPartialNdfOutputPath": name + "-partial-ndf.json",
+		"firstRoundId":               firstRoundId,
+	}
+}
+
+// Tests that loadNetworkConfigs loads valid networks under lowercase names and
+// rejects networks which collide with each other or the primary network.
+func Test_loadNetworkConfigs(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		networks map[string]interface{}
+		names    []string
+		err      bool
+	}{
+		{nil, []string{}, false},
+		{map[string]interface{}{
+			"Testnet": testNetworkConfig(11430, "testnet", 1<<40),
+			"canary":  testNetworkConfig(11431, "canary", 2<<40),
+		}, []string{"canary", "testnet"}, false},
+		// Port of the primary network
+		{map[string]interface{}{
+			"testnet": testNetworkConfig(11420, "testnet", 1<<40),
+		}, nil, true},
+		// NDF path of the primary network
+		{map[string]interface{}{
+			"testnet": testNetworkConfig(11430, "primary", 1<<40),
+		}, nil, true},
+		// No first round ID
+		{map[string]interface{}{
+			"testnet": testNetworkConfig(11430, "testnet", 0),
+		}, nil, true},
+		// Same first round ID
+		{map[string]interface{}{
+			"testnet": testNetworkConfig(11430, "testnet", 1<<40),
+			"canary":  testNetworkConfig(11431, "canary", 1<<40),
+		}, nil, true},
+	}
+
+	for i, tt := range tests {
+		viper.Reset()
+		viper.Set("port", 11420)
+		viper.Set("fullNdfOutputPath", "primary-ndf.json")
+		viper.Set("signedPartialNDFOutputPath", "primary-partial-ndf.json")
+		if tt.networks != nil {
+			viper.Set("networks", tt.networks)
+		}
+
+		configs, err := loadNetworkConfigs()
+		if (err != nil) != tt.err {
+			t.Errorf("Unexpected error for networks %v (%d): %+v",
+				tt.networks, i, err)
+			continue
+		} else if err != nil {
+			continue
+		}
+
+		names := sortedNetworkNames(configs)
+		if len(names) != len(tt.names) {
+			t.Errorf("Unexpected networks (%d).\nexpected: %v\nreceived: %v",
+				i, tt.names, names)
+			continue
+		}
+		for j := range names {
+			if names[j] != tt.names[j] {
+				t.Errorf("Unexpected networks (%d).\nexpected: %v\nreceived: %v",
+					i, tt.names, names)
+				break
+			}
+		}
+	}
+}
+
+// Tests that loadNetworkConfigs ends the round ID range of each network before
+// the first round ID of the next network.
+func Test_loadNetworkConfigs_RoundIdRanges(t *testing.T) {
+	defer viper.Reset()
+	viper.Reset()
+	viper.Set("port", 11420)
+	viper.Set("networks", map[string]interface{}{
+		"testnet": testNetworkConfig(11430, "testnet", 2<<40),
+		"canary":  testNetworkConfig(11431, "canary", 1<<40),
+		"staging": testNetworkConfig(11432, "staging", 3<<40),
+	})
+
+	configs, err := loadNetworkConfigs()
+	if err != nil {
+		t.Fatalf("Failed to load networks: %+v", err)
+	}
+
+	expected := map[string]uint64{
+		"canary":  2<<40 - 1,
+		"testnet": 3<<40 - 1,
+		"staging": 0,
+	}
+	for name, lastRoundId := range expected {
+		if configs[name].lastRoundId != lastRoundId {
+			t.Errorf("Unexpected last round ID of %s."+
+				"\nexpected: %d\nreceived: %d", name, lastRoundId,
+				configs[name].lastRoundId)
+		}
+	}
+	if lastRoundId := primaryLastRoundId(configs); lastRoundId != 1<<40-1 {
+		t.Errorf("Unexpected last round ID of the primary network."+
+			"\nexpected: %d\nreceived: %d", uint64(1<<40-1), lastRoundId)
+	}
+	if lastRoundId := primaryLastRoundId(nil); lastRoundId != 0 {
+		t.Errorf("Primary network bounded without secondary networks: %d",
+			lastRoundId)
+	}
+}
+
+// Tests that inNetwork assigns Applications to the secondary network matching
+// their Network and every other Application to the primary network.
+func TestRegistrationImpl_inNetwork(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	for i, network := range []string{"", "TestNet", "other"} {
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: uint64(i + 1), Network: network},
+			&storage.Node{Code: network + "code", ApplicationId: uint64(i + 1)})
+		if err != nil {
+			t.Fatalf("Failed to insert application: %+v", err)
+		}
+	}
+
+	primary := &RegistrationImpl{params: &Params{
+		secondaryNetworks: map[string]bool{"testnet": true}}}
+	testnet := &RegistrationImpl{params: &Params{network: "testnet"}}
+	single := &RegistrationImpl{params: &Params{}}
+
+	tests := []struct {
+		impl          *RegistrationImpl
+		applicationId uint64
+		inNetwork     bool
+	}{
+		{primary, 1, true},
+		{primary, 2, false},
+		{primary, 3, true},
+		{testnet, 1, false},
+		{testnet, 2, true},
+		{testnet, 3, false},
+		{single, 2, true},
+		{single, 42, true},
+	}
+
+	for i, tt := range tests {
+		_, inNetwork, err := tt.impl.inNetwork(tt.applicationId)
+		if err != nil {
+			t.Errorf("inNetwork returned an error (%d): %+v", i, err)
+		} else if inNetwork != tt.inNetwork {
+			t.Errorf("Unexpected result for Application %d (%d)."+
+				"\nexpected: %t\nreceived: %t", tt.applicationId, i,
+				tt.inNetwork, inNetwork)
+		}
+	}
+
+	if _, _, err = primary.inNetwork(42); err == nil {
+		t.Errorf("Expected error for unknown Application.")
+	}
+}
+
+// Tests that startNetworkRoutines starts the routines of the network and that
+// stop signals them to quit.
+func Test_startNetworkRoutines(t *testing.T) {
+	defer viper.Reset()
+	impl := newAdminTestImpl(t)
+	impl.schedulingParams = &scheduling.SafeParams{Params: &scheduling.Params{}}
+
+	r, err := startNetworkRoutines(impl, time.Minute)
+	if err != nil {
+		t.Fatalf("startNetworkRoutines returned an error: %+v", err)
+	}
+	r.stop()
+
+	// The latency table and whitelist routines are always started, so their
+	// quit signals are received
+	for i := 0; i < 10 && (len(r.latencyPollQuit) > 0 ||
+		len(r.whitelistPollQuit) > 0); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(r.latencyPollQuit) > 0 || len(r.whitelistPollQuit) > 0 {
+		t.Errorf("Network routines did not receive the quit signal")
+	}
+}
+
+// Error path: an invalid eligible Node source is rejected.
+func Test_startNetworkRoutines_BadEligibleNodeSource(t *testing.T) {
+	defer viper.Reset()
+	impl := newAdminTestImpl(t)
+	impl.schedulingParams = &scheduling.SafeParams{Params: &scheduling.Params{}}
+	viper.Set("eligibleNodeSource", fileEligibleNodeSource)
+
+	if _, err := startNetworkRoutines(impl, time.Minute); err == nil {
+		t.Errorf("startNetworkRoutines did not error on an eligible Node " +
+			"source without a path")
+	}
+}
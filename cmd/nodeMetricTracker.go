@@ -8,27 +8,18 @@
 package cmd
 
 import (
-	"encoding/json"
 	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/xx_network/primitives/id"
-	"gitlab.com/xx_network/primitives/utils"
 	"time"
 )
 
-// Error messages.
-const (
-	getActiveNodesDbErr      = "failed to get active node list from database: %+v"
-	unmarshalActiveNodeDbErr = "failed to unmarshal active node ID #%d: %+v"
-)
-
 func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetricInterval time.Duration) {
 	jww.DEBUG.Printf("Beginning storage of node metrics every %+v...",
 		nodeMetricInterval)
 	nodeTicker := time.NewTicker(nodeMetricInterval)
-	onlyScheduleActive := impl.params.onlyScheduleActive
 
 	for {
 		// Store the metric start time
@@ -40,59 +31,12 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 		case <-nodeTicker.C:
 			var err error
 
-			// Update whitelisted IDs
-			whitelistedIds := make([]string, 0)
-			if impl.params.WhitelistedIdsPath != "" {
-				// Read file
-				whitelistedIdsFile, err := utils.ReadFile(impl.params.WhitelistedIdsPath)
-				if err != nil {
-					jww.ERROR.Printf("Cannot read whitelisted IDs file (%s): %v",
-						impl.params.WhitelistedIdsPath, err)
-				}
-
-				// Unmarshal JSON
-				err = json.Unmarshal(whitelistedIdsFile, &whitelistedIds)
-				if err != nil {
-					jww.ERROR.Printf("Could not unmarshal whitelisted IDs: %v", err)
-				}
-
-			}
-
-			// Update whitelisted IP addresses
-			whitelistedIpAddresses := make([]string, 0)
-			if impl.params.WhitelistedIpAddressPath != "" {
-				// Read file
-				whitelistedIpAddressesFile, err := utils.ReadFile(impl.params.WhitelistedIpAddressPath)
-				if err != nil {
-					jww.ERROR.Printf("Cannot read whitelisted IP addresses file (%s): %v",
-						impl.params.WhitelistedIpAddressPath, err)
-				}
-
-				// Unmarshal JSON
-				err = json.Unmarshal(whitelistedIpAddressesFile, &whitelistedIpAddresses)
-				if err != nil {
-					jww.ERROR.Printf("Could not unmarshal whitelisted IP addresses: %v", err)
-				} else {
-					jww.INFO.Printf("Added whitelisted IPs: %+v", whitelistedIpAddresses)
-				}
-			}
-
 			// Keep track of stale/pruned nodes
 			// Set to true if pruned, false if stale
 			toPrune := make(map[id.ID]bool)
 			// List of nodes to update activity in Storage
 			var toUpdate []*id.ID
 
-			// Obtain active nodes
-			var active map[id.ID]bool
-			if onlyScheduleActive {
-				active, err = GetActiveNodeIDs()
-				if err != nil {
-					jww.ERROR.Print(err)
-				}
-				jww.DEBUG.Printf("Found %d active nodes!", len(active))
-			}
-
 			// Iterate over the Node States
 			nodeStates := impl.State.GetNodeMap().GetNodeStates()
 			for _, nodeState := range nodeStates {
@@ -106,19 +50,25 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 					NumPings:  nodeState.GetAndResetNumPolls(),
 				}
 
-				// set the node to prune if it has not contacted
-				if metric.NumPings == 0 || (onlyScheduleActive && !active[*nodeState.GetID()]) {
+				// set the node to prune if it has not contacted. Ineligible
+				// nodes which keep polling stay active so that they are not
+				// pruned and can finish their rounds; they are marked as stale
+				// when the NDF is output and their activity is not stored
+				eligible := nodeState.IsEligible()
+				if metric.NumPings == 0 {
 					toPrune[*nodeState.GetID()] = false
 				} else {
 					nodeState.SetLastActive()
-					toUpdate = append(toUpdate, nodeState.GetID())
+					if eligible {
+						toUpdate = append(toUpdate, nodeState.GetID())
+					}
 				}
 				if time.Since(nodeState.GetLastActive()) > impl.params.pruneRetentionLimit {
 					toPrune[*nodeState.GetID()] = true
 				}
 
 				// Store the NodeMetric
-				if !onlyScheduleActive || active[*nodeState.GetID()] {
+				if eligible {
 					err = storage.PermissioningDb.InsertNodeMetric(metric)
 					if err != nil {
 						jww.FATAL.Panicf("Unable to store node metric: %+v", err)
@@ -138,8 +88,6 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 				impl.State.InternalNdfLock.Lock()
 				impl.State.SetPrunedNodes(toPrune)
 				currentNdf := impl.State.GetUnprunedNdf()
-				currentNdf.WhitelistedIds = whitelistedIds
-				currentNdf.WhitelistedIpAddresses = whitelistedIpAddresses
 				impl.State.UpdateInternalNdf(currentNdf)
 				impl.State.InternalNdfLock.Unlock()
 			}
@@ -149,6 +97,19 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 				jww.ERROR.Printf("Failed to trigger NDF output: %+v", err)
 			}
 
+			// Delete the network's client errors past their retention
+			if retention := impl.params.clientErrorRetentionLimit; retention > 0 {
+				deleted, err := storage.PermissioningDb.DeleteClientErrors(
+					impl.State.GetNetwork(), time.Now().Add(-retention))
+				if err != nil {
+					jww.ERROR.Printf("TrackNodeMetrics: Could not delete "+
+						"client errors: %+v", err)
+				} else if deleted > 0 {
+					jww.DEBUG.Printf("Deleted %d client errors older than %s",
+						deleted, retention)
+				}
+			}
+
 			paramsCopy := impl.schedulingParams.SafeCopy()
 
 			clientCutoff := impl.params.messageRetentionLimit + paramsCopy.RealtimeTimeout
@@ -156,10 +117,10 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 				2*(paramsCopy.PrecomputationTimeout+paramsCopy.RealtimeDelay+paramsCopy.RealtimeTimeout)
 
 			earliestClientRound, _, clientErr := storage.PermissioningDb.
-				GetEarliestRound(clientCutoff)
+				GetEarliestRound(impl.State.GetNetwork(), clientCutoff)
 
 			earliestGwRound, earliestGwRoundTs, gatewayErr := storage.PermissioningDb.
-				GetEarliestRound(gatewayCutoff)
+				GetEarliestRound(impl.State.GetNetwork(), gatewayCutoff)
 
 			if clientErr != nil || gatewayErr != nil {
 				if clientErr != nil && !errors.Is(clientErr, gorm.ErrRecordNotFound) {
@@ -182,25 +143,3 @@ func TrackNodeMetrics(impl *RegistrationImpl, quitChan chan struct{}, nodeMetric
 		}
 	}
 }
-
-// GetActiveNodeIDs gets the active nodes from the database and returns the list
-// of unmarshalled node IDs.
-func GetActiveNodeIDs() (map[id.ID]bool, error) {
-	nodes, err := storage.PermissioningDb.GetActiveNodes()
-	if err != nil {
-		return nil, errors.Errorf(getActiveNodesDbErr, err)
-	}
-
-	nodeIDs := make(map[id.ID]bool, len(nodes))
-	for i, n := range nodes {
-
-		nid, err := id.Unmarshal(n.Id)
-		if err != nil {
-			return nil, errors.Errorf(unmarshalActiveNodeDbErr, i, err)
-		}
-
-		nodeIDs[*nid] = true
-	}
-
-	return nodeIDs, nil
-}
@@ -174,6 +174,108 @@ func TestTrackNodeMetrics(t *testing.T) {
 
 }
 
+// Tests that an ineligible node which keeps polling stays active and is not
+// pruned from the NDF.
+func TestTrackNodeMetrics_Ineligible(t *testing.T) {
+	kill := make(chan struct{})
+	defer quit(kill)
+	interval := 100 * time.Millisecond
+
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Unable to create state: %+v", err)
+	}
+
+	nid := id.NewIdFromString("ineligible", id.Node, t)
+	if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node to state: %v", err)
+	}
+	n := state.GetNodeMap().GetNode(nid)
+	n.SetEligible(false)
+	n.SetNumPollsTesting(25, t)
+	n.SetLastActiveTesting(time.Now().Add(-48*time.Hour), t)
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes:    []ndf.Node{{ID: nid.Bytes()}},
+		Gateways: []ndf.Gateway{{ID: nid.Bytes()}},
+	})
+
+	params := testParams
+	params.pruneRetentionLimit = 24 * time.Hour
+	params.disableNDFPruning = false
+	impl := &RegistrationImpl{
+		params:           &params,
+		State:            state,
+		schedulingParams: &scheduling.SafeParams{Params: &scheduling.Params{}},
+	}
+
+	go TrackNodeMetrics(impl, kill, interval)
+	time.Sleep(interval * 3)
+
+	if time.Since(n.GetLastActive()) > time.Hour {
+		t.Errorf("Activity of polling ineligible node was not refreshed")
+	}
+	if nodes := impl.State.GetFullNdf().Get().Nodes; len(nodes) != 1 {
+		t.Errorf("Polling ineligible node was pruned from the NDF")
+	}
+}
+
+// Tests that TrackNodeMetrics deletes the network's client errors past their
+// retention and keeps the rest.
+func TestTrackNodeMetrics_ClientErrorRetention(t *testing.T) {
+	kill := make(chan struct{})
+	defer quit(kill)
+	interval := 100 * time.Millisecond
+
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "",
+		"TestTrackNodeMetrics_ClientErrorRetention", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Unable to create state: %+v", err)
+	}
+
+	gateway := id.NewIdFromString("gateway", id.Gateway, t)
+	err = storage.PermissioningDb.InsertClientErrors(1,
+		[]*storage.RoundClientError{
+			{GatewayId: gateway.Marshal(), ErrorType: "old", Error: "old",
+				Timestamp: time.Now().Add(-48 * time.Hour)},
+			{GatewayId: gateway.Marshal(), ErrorType: "new", Error: "new",
+				Timestamp: time.Now()},
+		})
+	if err != nil {
+		t.Fatalf("Failed to insert client errors: %+v", err)
+	}
+
+	params := testParams
+	params.clientErrorRetentionLimit = 24 * time.Hour
+	impl := &RegistrationImpl{
+		params:           &params,
+		State:            state,
+		schedulingParams: &scheduling.SafeParams{Params: &scheduling.Params{}},
+	}
+
+	go TrackNodeMetrics(impl, kill, interval)
+	time.Sleep(interval * 3)
+
+	clientErrors, err := storage.PermissioningDb.GetClientErrors("", nil,
+		time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get client errors: %+v", err)
+	}
+	if len(clientErrors) != 1 || clientErrors[0].ErrorType != "new" {
+		t.Errorf("Unexpected client errors after retention: %+v",
+			clientErrors)
+	}
+}
+
 func quit(kill chan struct{}) {
 	kill <- struct{}{}
 }
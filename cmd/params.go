@@ -48,13 +48,31 @@ type Params struct {
 	disableGeoBinning     bool
 	blockchainGeoBinning  bool
 	disablePing           bool
-	onlyScheduleActive    bool
 	enableBlockchain      bool
 
 	disableNDFPruning bool
 
 	geoIPDBFile string
 
+	// Bearer token required by the operator endpoints of the admin API. If
+	// empty, those endpoints are disabled
+	adminToken string
+
+	// Path to the scheduling params JSON, watched for live updates
+	schedulingConfigPath string
+
+	// Name of the network served; empty for the primary network, which serves
+	// every Node whose Application is not in one of the secondaryNetworks
+	network string
+
+	// Round ID the network starts at when it has none stored (Defaults to 1)
+	firstRoundId uint64
+	// Last round ID the network may use; zero if it is unbounded
+	lastRoundId uint64
+
+	// Names of the networks configured alongside the primary network
+	secondaryNetworks map[string]bool
+
 	clientRegistrationAddress string
 
 	versionLock sync.RWMutex
@@ -71,6 +89,10 @@ type Params struct {
 	messageRetentionLimit    time.Duration
 	messageRetentionLimitMux sync.Mutex
 
+	// How long the client errors reported by gateways are kept in storage.
+	// Zero keeps them indefinitely
+	clientErrorRetentionLimit time.Duration
+
 	// Specs on rate limiting clients
 	leakedCapacity uint32
 	leakedTokens   uint32
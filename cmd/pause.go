@@ -0,0 +1,70 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles pausing and resuming round creation. While paused, polls are still
+// accepted and in-flight rounds complete, but no new teams are formed.
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"net/http"
+)
+
+// schedulingStatus is the JSON body returned by the scheduling endpoints.
+type schedulingStatus struct {
+	Paused bool
+	// Depth of the queue of node updates waiting for the scheduler, only
+	// reported by the status endpoint
+	NodeUpdates *storage.NodeUpdateQueueStats `json:",omitempty"`
+}
+
+// pauseHandler pauses round creation.
+func (m *RegistrationImpl) pauseHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if !m.schedulingPauser.Pause() {
+		writeAdminResponse(w, http.StatusConflict,
+			errors.New("round creation is already paused"))
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, schedulingStatus{Paused: true})
+}
+
+// resumeHandler resumes round creation.
+func (m *RegistrationImpl) resumeHandler(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if !m.schedulingPauser.Resume() {
+		writeAdminResponse(w, http.StatusConflict,
+			errors.New("round creation is not paused"))
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, schedulingStatus{Paused: false})
+}
+
+// schedulingStatusHandler reports whether round creation is paused and the
+// depth of the node update queue.
+func (m *RegistrationImpl) schedulingStatusHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	nodeUpdates := m.State.GetNodeUpdateQueueStats()
+	writeAdminJSON(w, http.StatusOK, schedulingStatus{
+		Paused:      m.schedulingPauser.IsPaused(),
+		NodeUpdates: &nodeUpdates,
+	})
+}
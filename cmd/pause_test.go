@@ -0,0 +1,128 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/json"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// sendSchedulingRequest sends a request with the token to the admin API and
+// returns the response recorder.
+func sendSchedulingRequest(mux http.Handler, method, path,
+	token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+// Tests that the scheduling endpoints pause and resume round creation and
+// report conflicts.
+func TestRegistrationImpl_pauseHandler(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	impl.schedulingPauser = scheduling.NewPauser()
+	mux := impl.newAdminMux()
+
+	tests := []struct {
+		method, path string
+		code         int
+		paused       bool
+	}{
+		{http.MethodPost, "/scheduling/pause", http.StatusOK, true},
+		{http.MethodPost, "/scheduling/pause", http.StatusConflict, true},
+		{http.MethodGet, "/scheduling/status", http.StatusOK, true},
+		{http.MethodPost, "/scheduling/resume", http.StatusOK, false},
+		{http.MethodPost, "/scheduling/resume", http.StatusConflict, false},
+		{http.MethodGet, "/scheduling/status", http.StatusOK, false},
+		{http.MethodGet, "/scheduling/pause", http.StatusMethodNotAllowed, false},
+	}
+
+	for i, tt := range tests {
+		w := sendSchedulingRequest(mux, tt.method, tt.path, "token")
+		if w.Code != tt.code {
+			t.Errorf("Unexpected status code for %s %s (%d)."+
+				"\nexpected: %d\nreceived: %d", tt.method, tt.path, i, tt.code,
+				w.Code)
+		}
+
+		if impl.schedulingPauser.IsPaused() != tt.paused {
+			t.Errorf("Unexpected paused state after %s %s (%d)."+
+				"\nexpected: %t\nreceived: %t", tt.method, tt.path, i,
+				tt.paused, impl.schedulingPauser.IsPaused())
+		}
+
+		if tt.code == http.StatusOK {
+			var status schedulingStatus
+			if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+				t.Errorf("Failed to decode response (%d): %+v", i, err)
+			} else if status.Paused != tt.paused {
+				t.Errorf("Unexpected status in response (%d)."+
+					"\nexpected: %t\nreceived: %t", i, tt.paused, status.Paused)
+			}
+		}
+	}
+}
+
+// Tests that the scheduling endpoints reject requests without the admin token
+// and are disabled when no token is configured.
+func TestRegistrationImpl_requireAdminToken(t *testing.T) {
+	impl := &RegistrationImpl{
+		params:           &Params{adminToken: "token"},
+		schedulingPauser: scheduling.NewPauser(),
+	}
+	mux := impl.newAdminMux()
+
+	for _, token := range []string{"", "wrong"} {
+		w := sendSchedulingRequest(mux, http.MethodPost, "/scheduling/pause",
+			token)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Unexpected status code for token %q."+
+				"\nexpected: %d\nreceived: %d", token,
+				http.StatusUnauthorized, w.Code)
+		}
+	}
+
+	impl.params.adminToken = ""
+	w := sendSchedulingRequest(mux, http.MethodPost, "/scheduling/pause", "")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Unexpected status code with no token configured."+
+			"\nexpected: %d\nreceived: %d", http.StatusForbidden, w.Code)
+	}
+
+	if impl.schedulingPauser.IsPaused() {
+		t.Errorf("Unauthorized request paused round creation.")
+	}
+}
+
+// Tests that the scheduling status endpoint reports the depth of the node
+// update queue.
+func TestRegistrationImpl_schedulingStatusHandler(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	impl.schedulingPauser = scheduling.NewPauser()
+	impl.State.SendUpdateNotification(node.UpdateNotification{
+		Node: id.NewIdFromString("queued", id.Node, t)})
+
+	w := sendSchedulingRequest(impl.newAdminMux(), http.MethodGet,
+		"/scheduling/status", "token")
+	status := &schedulingStatus{}
+	if err := json.NewDecoder(w.Body).Decode(status); err != nil {
+		t.Fatalf("Failed to decode status: %+v", err)
+	}
+	if status.NodeUpdates == nil || status.NodeUpdates.Queued != 1 {
+		t.Errorf("Unexpected node update queue stats: %+v", status.NodeUpdates)
+	}
+}
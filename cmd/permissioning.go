@@ -82,6 +82,16 @@ func (m *RegistrationImpl) RegisterNode(salt []byte, serverAddr, serverTlsCert,
 			"Registration code %+v is invalid or not currently enabled: %+v", registrationCode, err)
 	}
 
+	// Nodes may only register with the network their Application is in
+	network, inNetwork, err := m.inNetwork(nodeInfo.ApplicationId)
+	if err != nil {
+		return errors.Errorf("Could not get network of registration code "+
+			"%s: %+v", registrationCode, err)
+	} else if !inNetwork {
+		return errors.Errorf("Registration code %s belongs to network %q, "+
+			"which is served at a different address", registrationCode, network)
+	}
+
 	// Generate the Node ID
 	tlsCert, err := tls.LoadCertificate(serverTlsCert)
 	if err != nil {
@@ -157,6 +167,12 @@ func (m *RegistrationImpl) LoadAllRegisteredNodes() ([]*connect.Host, error) {
 	}
 
 	for _, n := range nodes {
+		if _, inNetwork, err := m.inNetwork(n.ApplicationId); err != nil {
+			return nil, err
+		} else if !inNetwork {
+			continue
+		}
+
 		nid, err := id.Unmarshal(n.Id)
 
 		h, _ := connect.NewHost(nid, n.ServerAddress, []byte(n.NodeCertificate), connect.GetDefaultHostParams())
@@ -180,6 +196,12 @@ func (m *RegistrationImpl) LoadAllRegisteredNodes() ([]*connect.Host, error) {
 	}
 
 	for _, n := range bannedNodes {
+		if _, inNetwork, err := m.inNetwork(n.ApplicationId); err != nil {
+			return nil, err
+		} else if !inNetwork {
+			continue
+		}
+
 		nid, err := id.Unmarshal(n.Id)
 
 		h, _ := connect.NewHost(nid, n.ServerAddress, []byte(n.NodeCertificate), connect.GetDefaultHostParams())
@@ -212,7 +234,7 @@ func (m *RegistrationImpl) completeNodeRegistration(regCode string) error {
 	// Add the new node to the topology
 	m.State.InternalNdfLock.Lock()
 	networkDef := m.State.GetUnprunedNdf()
-	gateway, n, regTime, err := assembleNdf(regCode)
+	gateway, n, regTime, err := assembleNdf(regCode, m.State.GetGeoBins())
 	if err != nil {
 		m.State.InternalNdfLock.Unlock()
 		err := errors.Errorf("unable to assemble topology: %+v", err)
@@ -298,8 +320,9 @@ func (m *RegistrationImpl) insertNdf(definition *ndf.NetworkDefinition, g ndf.Ga
 	return nil
 }
 
-// Assemble information for the given registration code
-func assembleNdf(code string) (ndf.Gateway, ndf.Node, int64, error) {
+// Assemble information for the given registration code, placing the gateway
+// in its country's bin from the passed in GeoBin table
+func assembleNdf(code string, geoBins map[string]region.GeoBin) (ndf.Gateway, ndf.Node, int64, error) {
 
 	// Get node information for each registration code
 	nodeInfo, err := storage.PermissioningDb.GetNode(code)
@@ -325,7 +348,7 @@ func assembleNdf(code string) (ndf.Gateway, ndf.Node, int64, error) {
 	gwID := nodeID.DeepCopy()
 	gwID.SetType(id.Gateway)
 
-	bin, exists := region.GetCountryBin(nodeInfo.Sequence)
+	bin, exists := geoBins[nodeInfo.Sequence]
 	if !exists {
 		return ndf.Gateway{}, ndf.Node{}, 0,
 			errors.Errorf("Error parsing node sequence %s, countru does not exist", nodeInfo.Sequence)
@@ -340,3 +363,15 @@ func assembleNdf(code string) (ndf.Gateway, ndf.Node, int64, error) {
 
 	return gateway, n, nodeInfo.DateRegistered.UnixNano(), nil
 }
+
+// reissueNdf bumps the timestamp of the internal NDF and outputs it so that
+// changes to the state of the Nodes it lists are published.
+func (m *RegistrationImpl) reissueNdf() error {
+	m.State.InternalNdfLock.Lock()
+	if currentNdf := m.State.GetUnprunedNdf(); currentNdf != nil {
+		m.State.UpdateInternalNdf(currentNdf)
+	}
+	m.State.InternalNdfLock.Unlock()
+
+	return m.State.UpdateOutputNdf()
+}
@@ -165,7 +165,8 @@ func (m *RegistrationImpl) Poll(msg *pb.PermissioningPoll, auth *connect.Auth) (
 	updateNotification.ClientErrors = msg.ClientErrors
 
 	// Update occurred, report it to the control thread
-	return response, m.State.SendUpdateNotification(updateNotification)
+	m.State.SendUpdateNotification(updateNotification)
+	return response, nil
 }
 
 // PollNdf handles the client polling for an updated NDF
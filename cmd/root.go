@@ -10,7 +10,6 @@
 package cmd
 
 import (
-	"errors"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
 	"github.com/mitchellh/go-homedir"
@@ -25,6 +24,7 @@ import (
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/primitives/utils"
 	"net"
+	"net/http"
 	"os"
 	"path"
 	"runtime/pprof"
@@ -47,6 +47,18 @@ var (
 
 	// Duration between polls of the disabled Node list for updates.
 	disabledNodesPollDuration time.Duration
+
+	// Duration between polls of storage for GeoBin updates.
+	geoBinsPollDuration time.Duration
+
+	// Duration between updates of the measured latency table.
+	latencyTableInterval time.Duration
+
+	// Duration between reloads of the eligible Node set.
+	eligibleNodesPollDuration time.Duration
+
+	// Duration between rebuilds of the whitelist.
+	whitelistPollDuration time.Duration
 )
 
 const (
@@ -55,8 +67,12 @@ const (
 
 	// Default duration between polls of the disabled Node list for updates.
 	defaultDisabledNodesPollDuration = time.Minute
+	defaultGeoBinsPollDuration       = time.Minute
+	defaultLatencyTableInterval      = 10 * time.Minute
+	defaultLatencyWindow             = 100
 	defaultPruneRetention            = 24 * 7 * time.Hour
 	defaultMessageRetention          = 24 * 7 * time.Hour
+	defaultClientErrorRetention      = 24 * 7 * time.Hour
 
 	// Default settings for Go profiling
 	profilingOutputFlags   = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
@@ -237,6 +253,7 @@ var rootCmd = &cobra.Command{
 		viper.SetDefault("pruneRetentionLimit", defaultPruneRetention)
 
 		viper.SetDefault("messageRetentionLimit", defaultMessageRetention)
+		viper.SetDefault("clientErrorRetentionLimit", defaultClientErrorRetention)
 
 		// Get rate limiting values
 		capacity := viper.GetUint32("RateLimiting.Capacity")
@@ -253,6 +270,16 @@ var rootCmd = &cobra.Command{
 		}
 		leakedDurations = leakedDurations * uint64(time.Millisecond)
 
+		// Load the networks run alongside the primary network
+		networkConfigs, err := loadNetworkConfigs()
+		if err != nil {
+			jww.FATAL.Panicf("Invalid networks config: %+v", err)
+		}
+		secondaryNetworks := make(map[string]bool, len(networkConfigs))
+		for name := range networkConfigs {
+			secondaryNetworks[name] = true
+		}
+
 		// Populate params
 		RegParams = Params{
 			Address:                    localAddress,
@@ -282,14 +309,18 @@ var rootCmd = &cobra.Command{
 			allowLocalIPs:              viper.GetBool("allowLocalIPs"),
 			disableGeoBinning:          viper.GetBool("disableGeoBinning"),
 			blockchainGeoBinning:       viper.GetBool("blockchainGeoBinning"),
-			onlyScheduleActive:         viper.GetBool("onlyScheduleActive"),
 			enableBlockchain:           viper.GetBool("enableBlockchain"),
 
-			disableNDFPruning:     viper.GetBool("disableNDFPruning"),
-			geoIPDBFile:           viper.GetString("geoIPDBFile"),
-			pruneRetentionLimit:   viper.GetDuration("pruneRetentionLimit"),
-			messageRetentionLimit: viper.GetDuration("messageRetentionLimit"),
-			versionLock:           sync.RWMutex{},
+			disableNDFPruning:         viper.GetBool("disableNDFPruning"),
+			geoIPDBFile:               viper.GetString("geoIPDBFile"),
+			adminToken:                viper.GetString("adminToken"),
+			schedulingConfigPath:      SchedulingConfigPath,
+			secondaryNetworks:         secondaryNetworks,
+			lastRoundId:               primaryLastRoundId(networkConfigs),
+			pruneRetentionLimit:       viper.GetDuration("pruneRetentionLimit"),
+			messageRetentionLimit:     viper.GetDuration("messageRetentionLimit"),
+			clientErrorRetentionLimit: viper.GetDuration("clientErrorRetentionLimit"),
+			versionLock:               sync.RWMutex{},
 
 			// Rate limiting specs
 			leakedCapacity: capacity,
@@ -312,9 +343,6 @@ var rootCmd = &cobra.Command{
 			jww.FATAL.Panicf(err.Error())
 		}
 
-		viper.OnConfigChange(impl.update)
-		viper.WatchConfig()
-
 		// Get disabled Nodes poll duration from config file or default to 1
 		// minute if not set
 		disabledNodesPollDuration = viper.GetDuration("disabledNodesPollDuration")
@@ -339,13 +367,19 @@ var rootCmd = &cobra.Command{
 
 		// Parse params JSON
 		params := scheduling.ParseParams(SchedulingConfig)
+		impl.schedulingParams = params
 
-		// Initialize param update if it is enabled
-		if impl.params.enableBlockchain {
-			go scheduling.UpdateParams(params, nodeMetricInterval)
+		// Start the routines which keep the network's state up to date
+		routines, err := startNetworkRoutines(impl, nodeMetricInterval)
+		if err != nil {
+			jww.FATAL.Panicf("%+v", err)
 		}
 
-		impl.schedulingParams = params
+		// Watch the scheduling params file so changes are applied live
+		schedulingViper := viper.New()
+		schedulingViper.SetConfigFile(SchedulingConfigPath)
+		schedulingViper.OnConfigChange(impl.updateSchedulingParams)
+		schedulingViper.WatchConfig()
 
 		// Run the Node metric tracker forever in another thread
 		metricTrackerQuitChan := make(chan struct{})
@@ -359,27 +393,75 @@ var rootCmd = &cobra.Command{
 			storage.PermissioningDb, addressSpaceTrackerQuitChan)
 
 		// Determine how long between polling for banned nodes
-		interval := viper.GetInt("BanTrackerInterval")
-		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		banTrackerInterval := time.Duration(
+			viper.GetInt("BanTrackerInterval")) * time.Minute
 
 		// Run the independent node tracker in own go thread
 		bannedNodeTrackerQuitChan := make(chan struct{})
-		go func(quitChan chan struct{}) {
-		nodeTrackerLoop:
-			for {
-				select {
-				case <-ticker.C:
-					// Keep track of banned nodes
-					err = BannedNodeTracker(impl)
-					if err != nil {
-						jww.FATAL.Panicf("BannedNodeTracker failed: %v", err)
-					}
-				case <-quitChan:
-					break nodeTrackerLoop
-				}
+		go trackBannedNodes(impl, banTrackerInterval, bannedNodeTrackerQuitChan)
+
+		// Start the secondary networks, each with its own state, comms, and
+		// Scheduler
+		var networks []*secondaryNetwork
+		for _, name := range sortedNetworkNames(networkConfigs) {
+			n, err := startSecondaryNetwork(name, networkConfigs[name],
+				nodeMetricInterval, banTrackerInterval)
+			if err != nil {
+				jww.FATAL.Panicf("%+v", err)
+			}
+			networks = append(networks, n)
+		}
+
+		viper.OnConfigChange(func(in fsnotify.Event) {
+			impl.update(in)
+			for _, n := range networks {
+				n.impl.update(in)
+			}
+		})
+		viper.WatchConfig()
 
+		// Start the admin API if an address is configured
+		var adminServer *http.Server
+		if adminAddress := viper.GetString("adminAddress"); adminAddress != "" {
+			adminMux := impl.newAdminMux()
+			mountNetworkMuxes(adminMux, networks,
+				func(impl *RegistrationImpl) http.Handler {
+					return impl.newAdminMux()
+				})
+			adminServer = startHttpServer("admin API", adminAddress,
+				RegParams.CertPath, RegParams.KeyPath, noTLS, adminWriteTimeout,
+				adminMux)
+		}
+
+		// Start the public status API if an address is configured
+		var statusServer *http.Server
+		statusRateLimitQuitChan := make(chan struct{}, 1)
+		if statusAddress := viper.GetString("statusAddress"); statusAddress != "" {
+			cacheDuration := viper.GetDuration("statusCacheDuration")
+			if cacheDuration == 0 {
+				cacheDuration = defaultStatusCacheDuration
+			}
+			requestsPerMinute := viper.GetUint32("statusRequestsPerMinute")
+			if requestsPerMinute == 0 {
+				requestsPerMinute = defaultStatusRequestsPerMinute
+			}
+			streamLimit := viper.GetInt("statusRoundStreamLimit")
+			if streamLimit == 0 {
+				streamLimit = defaultRoundStreamLimit
 			}
-		}(bannedNodeTrackerQuitChan)
+
+			statusMux := impl.newStatusMux(cacheDuration, streamLimit)
+			mountNetworkMuxes(statusMux, networks,
+				func(impl *RegistrationImpl) http.Handler {
+					return impl.newStatusMux(cacheDuration, streamLimit)
+				})
+			// Writes are allowed to run for the whole round update stream so
+			// that the server does not cut streams off early
+			statusServer = startHttpServer("status API", statusAddress,
+				RegParams.CertPath, RegParams.KeyPath, noTLS,
+				roundStreamDuration+adminWriteTimeout, rateLimitStatus(statusMux, requestsPerMinute,
+					statusRateLimitQuitChan))
+		}
 
 		jww.INFO.Printf("Waiting for for %v nodes to register so "+
 			"rounds can start", RegParams.minimumNodes)
@@ -393,30 +475,30 @@ var rootCmd = &cobra.Command{
 				"registered nodes for scheduling: %+v", err)
 		}
 
-		roundCreationQuitChan := make(chan chan struct{})
+		roundCreationQuitChan := make(chan *scheduling.Shutdown)
 
 		// Begin scheduling algorithm
 		go func() {
 			// Initialize scheduling
-			err = scheduling.Scheduler(params, impl.State, roundCreationQuitChan)
+			err := scheduling.Scheduler(params, impl.State,
+				impl.schedulingPauser, roundCreationQuitChan)
 			if err == nil {
-				err = errors.New("")
+				jww.INFO.Printf("Scheduling Algorithm stopped")
+				return
 			}
-			jww.FATAL.Panicf("Scheduling Algorithm exited: %v", err)
+			jww.FATAL.Panicf("Scheduling Algorithm exited on a fatal error: %v", err)
 		}()
 
 		var stopOnce sync.Once
+		// Exit code reflecting whether round creation stopped cleanly
+		shutdownExitCode := cleanShutdownExitCode
 		// Set up signal handler for stopping round creation
 		stopRounds := func() {
-			k := make(chan struct{})
-			roundCreationQuitChan <- k
-			jww.INFO.Printf("Stopping round creation...")
-			select {
-			case <-k:
-				jww.INFO.Printf("stopped!\n")
-			case <-time.After(closeTimeout):
-				jww.ERROR.Print("couldn't stop round creation!")
-			}
+			shutdownExitCode = stopAllRounds(networks, closeTimeout,
+				schedulingKillTimeout, func() int {
+					return stopScheduler(roundCreationQuitChan, closeTimeout,
+						schedulingKillTimeout)
+				})
 
 			bannedNodeTrackerQuitChan <- struct{}{}
 
@@ -425,6 +507,22 @@ var rootCmd = &cobra.Command{
 		}
 		ReceiveUSR1Signal(func() { stopOnce.Do(stopRounds) })
 
+		// Set up signal handler for pausing and resuming round creation on
+		// every network, following the primary network's state
+		ReceiveHUPSignal(func() {
+			if impl.schedulingPauser.IsPaused() {
+				impl.schedulingPauser.Resume()
+				for _, n := range networks {
+					n.impl.schedulingPauser.Resume()
+				}
+			} else {
+				impl.schedulingPauser.Pause()
+				for _, n := range networks {
+					n.impl.schedulingPauser.Pause()
+				}
+			}
+		})
+
 		var stopForKillOnce sync.Once
 		// Stops the long-running threads which are used for tracking node activity
 		// You should only do this if you're killing permissioning outright,
@@ -438,9 +536,27 @@ var rootCmd = &cobra.Command{
 			// Stop polling for disabled Nodes
 			disabledNodePollQuitChan <- struct{}{}
 
+			// Stop the routines which keep the network's state up to date
+			routines.stop()
+
 			// Stop address space tracker
 			addressSpaceTrackerQuitChan <- struct{}{}
 
+			// Stop the admin API
+			if adminServer != nil {
+				if err := adminServer.Close(); err != nil {
+					jww.ERROR.Printf("Error closing admin API: %+v", err)
+				}
+			}
+
+			// Stop the status API
+			if statusServer != nil {
+				statusRateLimitQuitChan <- struct{}{}
+				if err := statusServer.Close(); err != nil {
+					jww.ERROR.Printf("Error closing status API: %+v", err)
+				}
+			}
+
 			// Close GeoIP2 reader
 			impl.geoIPDBStatus.ToStopped()
 			err := impl.geoIPDB.Close()
@@ -459,6 +575,15 @@ var rootCmd = &cobra.Command{
 				pprof.StopCPUProfile()
 			}
 			stopOnce.Do(stopRounds)
+
+			// Publish a final NDF reflecting the state of the network
+			if err := impl.reissueNdf(); err != nil {
+				jww.ERROR.Printf("Failed to write final NDF: %+v", err)
+			}
+			for _, n := range networks {
+				n.stop()
+			}
+
 			stopForKillOnce.Do(stopForKill)
 			impl.Comms.Shutdown()
 		}
@@ -475,9 +600,7 @@ var rootCmd = &cobra.Command{
 			jww.INFO.Printf(
 				"Received Exit (SIGTERM or SIGINT) signal...\n")
 			stopEverything()
-			if atomic.LoadUint32(impl.Stopped) != 1 {
-				os.Exit(-1)
-			}
+			os.Exit(shutdownExitCode)
 		}
 	},
 }
@@ -700,6 +823,40 @@ func (m *RegistrationImpl) updateVersions() {
 	m.params.versionLock.Unlock()
 }
 
+// updateSchedulingParams reloads the scheduling params file and, if the new
+// params are valid, applies them to the running Scheduler. Invalid params are
+// logged and the current params are kept.
+func (m *RegistrationImpl) updateSchedulingParams(in fsnotify.Event) {
+	serialParams, err := utils.ReadFile(m.params.schedulingConfigPath)
+	if err != nil {
+		jww.ERROR.Printf("Could not reload scheduling config file %q: %+v",
+			m.params.schedulingConfigPath, err)
+		return
+	}
+
+	newParams, err := scheduling.LoadParams(serialParams, 0, nil)
+	if err == nil {
+		activeNodes, geoBins := m.State.CountActiveNodes(), m.State.GetGeoBins()
+		err = m.schedulingParams.UpdateWith(
+			func(current scheduling.Params) (scheduling.Params, error) {
+				if m.schedulingParamsFromStorage {
+					// Keep the params last polled from storage so that the
+					// file does not undo them until the next poll
+					newParams = newParams.WithStorageParams(current)
+				}
+				return newParams, newParams.Validate(activeNodes, geoBins)
+			})
+	}
+	if err != nil {
+		jww.ERROR.Printf("Rejecting update to scheduling config file %q, "+
+			"keeping current params: %+v", m.params.schedulingConfigPath, err)
+		return
+	}
+
+	jww.INFO.Printf("Updated scheduling params from %q: %+v",
+		m.params.schedulingConfigPath, newParams)
+}
+
 // initLog initializes logging thresholds and the log path.
 func initLog() {
 	if viper.Get("logPath") != nil {
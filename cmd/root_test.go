@@ -0,0 +1,111 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/region"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// Tests that a valid scheduling config file is applied and an invalid one is
+// rejected while keeping the current params.
+func TestRegistrationImpl_updateSchedulingParams(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Unable to create state: %+v", err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "scheduling.json")
+	impl := &RegistrationImpl{
+		params: &Params{schedulingConfigPath: configPath},
+		State:  state,
+		schedulingParams: &scheduling.SafeParams{
+			RWMutex: sync.RWMutex{},
+			Params:  &scheduling.Params{TeamSize: 3, BatchSize: 32},
+		},
+	}
+
+	err = os.WriteFile(configPath, []byte(`{"TeamSize": 4, "BatchSize": 64, `+
+		`"Threshold": 0.5}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %+v", err)
+	}
+	impl.updateSchedulingParams(fsnotify.Event{Name: configPath, Op: fsnotify.Write})
+
+	received := impl.schedulingParams.SafeCopy()
+	if received.TeamSize != 4 || received.BatchSize != 64 {
+		t.Errorf("Valid params were not applied: %+v", received)
+	}
+
+	err = os.WriteFile(configPath, []byte(`{"TeamSize": 4, "BatchSize": 64, `+
+		`"Threshold": 7}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %+v", err)
+	}
+	impl.updateSchedulingParams(fsnotify.Event{Name: configPath, Op: fsnotify.Write})
+
+	received = impl.schedulingParams.SafeCopy()
+	if received.Threshold != 0.5 {
+		t.Errorf("Invalid params were applied: %+v", received)
+	}
+}
+
+// Tests that while params are polled from storage, updates to the scheduling
+// config file keep the params last polled from storage.
+func TestRegistrationImpl_updateSchedulingParams_FromStorage(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Unable to create state: %+v", err)
+	}
+
+	configPath := filepath.Join(t.TempDir(), "scheduling.json")
+	impl := &RegistrationImpl{
+		params: &Params{schedulingConfigPath: configPath},
+		State:  state,
+		schedulingParams: &scheduling.SafeParams{
+			Params: &scheduling.Params{TeamSize: 3, BatchSize: 32,
+				Threshold: 0.3, PrecomputationTimeout: 60000,
+				RealtimeTimeout: 15000},
+		},
+		schedulingParamsFromStorage: true,
+	}
+
+	err = os.WriteFile(configPath, []byte(`{"TeamSize": 4, "BatchSize": 64, `+
+		`"Threshold": 0.5, "NodeCleanUpInterval": 3}`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write config: %+v", err)
+	}
+	impl.updateSchedulingParams(fsnotify.Event{Name: configPath, Op: fsnotify.Write})
+
+	received := impl.schedulingParams.SafeCopy()
+	if received.TeamSize != 3 || received.BatchSize != 32 ||
+		received.Threshold != 0.3 {
+		t.Errorf("Params from storage were replaced: %+v", received)
+	}
+	if received.NodeCleanUpInterval != 3 {
+		t.Errorf("Params not held in storage were not applied: %+v", received)
+	}
+}
@@ -0,0 +1,196 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the round update stream of the status API, which pushes every signed
+// round update to subscribers as Server-Sent Events so that explorers and
+// monitoring services do not need to poll as a node.
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"google.golang.org/protobuf/encoding/protojson"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// Default number of round update streams which may be open at once for
+	// each network
+	defaultRoundStreamLimit = 100
+
+	// How often a comment is sent on an idle stream so that proxies do not
+	// close it
+	roundStreamHeartbeat = 15 * time.Second
+
+	// How long a stream is open for before it is closed. This releases streams
+	// held by stalled subscribers; subscribers reconnect and resume from the
+	// last update ID they received.
+	roundStreamDuration = 10 * time.Minute
+
+	// Time subscribers are told to wait before reconnecting
+	roundStreamRetry = 5 * time.Second
+)
+
+// roundStream serves the signed round updates of a network as they are added
+// to the NetworkState.
+type roundStream struct {
+	state    *storage.NetworkState
+	duration time.Duration
+
+	// Holds a value for every open stream
+	streams chan struct{}
+}
+
+// newRoundStream creates a round update stream which allows at most limit
+// streams to be open at once.
+func newRoundStream(state *storage.NetworkState, limit int) *roundStream {
+	return &roundStream{
+		state:    state,
+		duration: roundStreamDuration,
+		streams:  make(chan struct{}, limit),
+	}
+}
+
+// ServeHTTP streams round updates until the subscriber disconnects or the
+// stream duration elapses. Updates after the ID in the "after" query parameter
+// or the Last-Event-ID header are sent first; if neither is set, only new
+// updates are sent. Each update is sent as a "round" event with the update ID
+// as its event ID and the signed pb.RoundInfo, encoded as protobuf JSON, as its
+// data. If updates the subscriber has not received are no longer held, a "gap"
+// event lists the IDs which were skipped.
+func (rs *roundStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminResponse(w, http.StatusMethodNotAllowed,
+			errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAdminResponse(w, http.StatusInternalServerError,
+			errors.New("streaming is not supported"))
+		return
+	}
+
+	lastID, err := parseRoundStreamResume(r)
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	select {
+	case rs.streams <- struct{}{}:
+		defer func() { <-rs.streams }()
+	default:
+		w.Header().Set("Retry-After",
+			strconv.Itoa(int(roundStreamRetry.Seconds())))
+		writeAdminResponse(w, http.StatusServiceUnavailable,
+			errors.New("too many round update streams are open"))
+		return
+	}
+
+	// Subscribe before reading the newest update so that none are missed
+	updates, unsubscribe := rs.state.SubscribeRoundUpdates()
+	defer unsubscribe()
+	if lastID < 0 {
+		lastID = rs.state.GetLastUpdateID()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_, err = fmt.Fprintf(w, "retry: %d\n\n", roundStreamRetry.Milliseconds())
+
+	heartbeat := time.NewTicker(roundStreamHeartbeat)
+	defer heartbeat.Stop()
+	closeTimer := time.NewTimer(rs.duration)
+	defer closeTimer.Stop()
+
+	for err == nil {
+		lastID, err = rs.send(w, lastID)
+		if err != nil {
+			break
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-closeTimer.C:
+			return
+		case <-updates:
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		}
+	}
+
+	jww.DEBUG.Printf("Closing round update stream to %s: %+v",
+		r.RemoteAddr, err)
+}
+
+// send writes every round update after lastID to the stream and returns the
+// ID of the last update written.
+func (rs *roundStream) send(w io.Writer, lastID int) (int, error) {
+	updates, err := rs.state.GetUpdates(lastID)
+	if err != nil {
+		return lastID, err
+	}
+
+	for _, info := range updates {
+		updateID := int(info.UpdateID)
+		if updateID <= lastID {
+			continue
+		}
+
+		if lastID >= 0 && updateID > lastID+1 {
+			_, err = fmt.Fprintf(w, "event: gap\ndata: {\"From\":%d,\"To\":%d}\n\n",
+				lastID+1, updateID-1)
+			if err != nil {
+				return lastID, err
+			}
+		}
+
+		data, err := protojson.Marshal(info)
+		if err != nil {
+			return lastID, errors.Errorf("could not marshal round update "+
+				"%d: %+v", updateID, err)
+		}
+		_, err = fmt.Fprintf(w, "id: %d\nevent: round\ndata: %s\n\n",
+			updateID, data)
+		if err != nil {
+			return lastID, err
+		}
+		lastID = updateID
+	}
+
+	return lastID, nil
+}
+
+// parseRoundStreamResume returns the update ID the subscriber wants to resume
+// after, or -1 if it did not request one.
+func parseRoundStreamResume(r *http.Request) (int, error) {
+	resume := r.URL.Query().Get("after")
+	if resume == "" {
+		resume = r.Header.Get("Last-Event-ID")
+	}
+	if resume == "" {
+		return -1, nil
+	}
+
+	lastID, err := strconv.Atoi(resume)
+	if err != nil || lastID < 0 {
+		return 0, errors.Errorf("invalid update ID %q", resume)
+	}
+	return lastID, nil
+}
@@ -0,0 +1,146 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bufio"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/comms/signature"
+	"google.golang.org/protobuf/encoding/protojson"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundStreamEvent is an event read from a round update stream.
+type roundStreamEvent struct {
+	id, event, data string
+}
+
+// readRoundStreamEvent reads the next event from the stream, skipping
+// comments and fields without an event.
+func readRoundStreamEvent(t *testing.T, r *bufio.Reader) roundStreamEvent {
+	ev := roundStreamEvent{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read stream: %+v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && ev.event != "":
+			return ev
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// Tests that the round update stream resumes after the requested update ID,
+// pushes new updates as they are added, and limits the number of streams.
+func TestRoundStream_ServeHTTP(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	addUpdate := func(roundID uint64) {
+		err := impl.State.AddRoundUpdate(&pb.RoundInfo{ID: roundID,
+			State:      uint32(states.PRECOMPUTING),
+			Timestamps: make([]uint64, states.FAILED)})
+		if err != nil {
+			t.Fatalf("Failed to add round update: %+v", err)
+		}
+		if !impl.State.FlushRoundUpdates(time.Second) {
+			t.Fatalf("Round update was not added.")
+		}
+	}
+
+	addUpdate(1)
+	resumeID := impl.State.GetLastUpdateID()
+	addUpdate(2)
+
+	srv := httptest.NewServer(newRoundStream(impl.State, 1))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?after=" + strconv.Itoa(resumeID))
+	if err != nil {
+		t.Fatalf("Failed to open stream: %+v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK ||
+		resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode,
+			resp.Header.Get("Content-Type"))
+	}
+	r := bufio.NewReader(resp.Body)
+
+	for _, roundID := range []uint64{2, 3} {
+		if roundID == 3 {
+			addUpdate(3)
+		}
+
+		ev := readRoundStreamEvent(t, r)
+		info := &pb.RoundInfo{}
+		if err = protojson.Unmarshal([]byte(ev.data), info); err != nil {
+			t.Fatalf("Failed to decode round update: %+v", err)
+		}
+		if ev.event != "round" || info.ID != roundID ||
+			ev.id != strconv.FormatUint(info.UpdateID, 10) {
+			t.Errorf("Unexpected event for round %d: %+v", roundID, ev)
+		}
+		err = signature.VerifyRsa(info, impl.State.GetPrivateKey().GetPublic())
+		if err != nil {
+			t.Errorf("Failed to verify round update: %+v", err)
+		}
+	}
+
+	// Only one stream may be open at once
+	second, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to request second stream: %+v", err)
+	}
+	_ = second.Body.Close()
+	if second.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d over the stream limit, received %d",
+			http.StatusServiceUnavailable, second.StatusCode)
+	}
+}
+
+// Tests that parseRoundStreamResume prefers the query parameter to the
+// Last-Event-ID header and rejects invalid IDs.
+func Test_parseRoundStreamResume(t *testing.T) {
+	tests := []struct {
+		query, header string
+		expected      int
+		valid         bool
+	}{
+		{"", "", -1, true},
+		{"", "12", 12, true},
+		{"5", "12", 5, true},
+		{"-1", "", 0, false},
+		{"", "abc", 0, false},
+	}
+
+	for i, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/rounds/stream?after="+tt.query, nil)
+		if tt.header != "" {
+			r.Header.Set("Last-Event-ID", tt.header)
+		}
+
+		lastID, err := parseRoundStreamResume(r)
+		if (err == nil) != tt.valid || (tt.valid && lastID != tt.expected) {
+			t.Errorf("Unexpected result for test %d: %d %+v", i, lastID, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the orchestrated shutdown of round scheduling
+
+package cmd
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/scheduling"
+	"time"
+)
+
+// Exit codes used when the server is stopped by a signal.
+const (
+	// Every round finished and all data was written
+	cleanShutdownExitCode = 0
+	// Rounds were failed at the deadline or data was not written in time
+	forcedShutdownExitCode = 1
+	// Round scheduling did not stop
+	failedShutdownExitCode = 2
+)
+
+// stopScheduler asks the Scheduler to stop creating rounds and logs its
+// progress. Active rounds are given closeTimeout to finish before they are
+// failed; the Scheduler then has killTimeout to fail them and killTimeout to
+// write round metrics and updates. Returns the exit code reflecting how
+// scheduling stopped.
+func stopScheduler(quitChan chan *scheduling.Shutdown, closeTimeout,
+	killTimeout time.Duration) int {
+	shutdown := scheduling.NewShutdown(closeTimeout, killTimeout)
+
+	select {
+	case quitChan <- shutdown:
+	case <-time.After(killTimeout):
+		jww.ERROR.Print("couldn't stop round creation: scheduler is not " +
+			"receiving")
+		return failedShutdownExitCode
+	}
+
+	jww.INFO.Printf("Stopping round creation, waiting up to %s for active "+
+		"rounds to finish...", closeTimeout)
+	hardDeadline := time.After(closeTimeout + 2*killTimeout)
+	for {
+		select {
+		case progress := <-shutdown.Progress():
+			jww.INFO.Printf("Waiting on %d active rounds %v, they will be "+
+				"failed in %s", len(progress.ActiveRounds),
+				progress.ActiveRounds, progress.Remaining)
+		case result := <-shutdown.Done():
+			return logShutdownResult(result)
+		case <-hardDeadline:
+			jww.ERROR.Print("couldn't stop round creation!")
+			return failedShutdownExitCode
+		}
+	}
+}
+
+// logShutdownResult logs what happened during the Scheduler's shutdown and
+// returns the matching exit code.
+func logShutdownResult(result scheduling.ShutdownResult) int {
+	if len(result.ForcedRounds) > 0 {
+		jww.WARN.Printf("Failed %d rounds which did not finish in time: %v",
+			len(result.ForcedRounds), result.ForcedRounds)
+	}
+	if !result.MetricsFlushed {
+		jww.ERROR.Print("Not all round metrics were stored before shutdown")
+	}
+	if !result.UpdatesFlushed {
+		jww.ERROR.Print("Not all round updates were issued before shutdown")
+	}
+
+	if result.Clean() {
+		jww.INFO.Printf("Round creation stopped cleanly")
+		return cleanShutdownExitCode
+	}
+
+	jww.WARN.Printf("Round creation was forced to stop")
+	return forcedShutdownExitCode
+}
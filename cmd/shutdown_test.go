@@ -0,0 +1,61 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that stopScheduler returns the failed exit code when nothing receives
+// the shutdown request.
+func Test_stopScheduler_NotReceiving(t *testing.T) {
+	quitChan := make(chan *scheduling.Shutdown)
+
+	code := stopScheduler(quitChan, time.Millisecond, 10*time.Millisecond)
+	if code != failedShutdownExitCode {
+		t.Errorf("Expected exit code %d, received %d",
+			failedShutdownExitCode, code)
+	}
+}
+
+// Tests that stopScheduler returns the failed exit code when the Scheduler
+// receives the request but never finishes.
+func Test_stopScheduler_HardDeadline(t *testing.T) {
+	quitChan := make(chan *scheduling.Shutdown, 1)
+
+	code := stopScheduler(quitChan, time.Millisecond, 10*time.Millisecond)
+	if code != failedShutdownExitCode {
+		t.Errorf("Expected exit code %d, received %d",
+			failedShutdownExitCode, code)
+	}
+}
+
+// Tests that logShutdownResult returns the exit code matching the result.
+func Test_logShutdownResult(t *testing.T) {
+	clean := scheduling.ShutdownResult{MetricsFlushed: true, UpdatesFlushed: true}
+	if code := logShutdownResult(clean); code != cleanShutdownExitCode {
+		t.Errorf("Expected exit code %d for clean shutdown, received %d",
+			cleanShutdownExitCode, code)
+	}
+
+	forced := clean
+	forced.ForcedRounds = []id.Round{3}
+	if code := logShutdownResult(forced); code != forcedShutdownExitCode {
+		t.Errorf("Expected exit code %d for forced shutdown, received %d",
+			forcedShutdownExitCode, code)
+	}
+
+	unflushed := scheduling.ShutdownResult{UpdatesFlushed: true}
+	if code := logShutdownResult(unflushed); code != forcedShutdownExitCode {
+		t.Errorf("Expected exit code %d for unflushed shutdown, received %d",
+			forcedShutdownExitCode, code)
+	}
+}
@@ -101,3 +101,32 @@ func TestReceiveExitSignal(t *testing.T) {
 		t.Errorf("Signal INT was not handled!")
 	}
 }*/
+
+func TestReceiveHUPSignal(t *testing.T) {
+	called := make(chan bool, 1)
+	testfn := func() {
+		called <- true
+	}
+
+	go ReceiveHUPSignal(testfn)
+	// Give a little bit of time for the subthread to start picking up
+	// the signal
+	time.Sleep(100 * time.Millisecond)
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGHUP)
+
+	res := false
+	// Sleep multiple times to give the kernel more tries to
+	// deliver the signal.
+	for i := 0; i < 10; i++ {
+		select {
+		case res = <-called:
+			break
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if res != true {
+		t.Errorf("Signal HUP was not handled!")
+	}
+}
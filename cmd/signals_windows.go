@@ -41,6 +41,12 @@ func ReceiveUSR2Signal(usr1Fn func()) {
 	jww.WARN.Printf("Windows does not support SIGUSR2 signals, ignored!")
 }
 
+// ReceiveHUPSignal is a dummy function because windows doesn't have the
+// support we need.
+func ReceiveHUPSignal(hupFn func()) {
+	jww.WARN.Printf("Windows does not support SIGHUP signals, ignored!")
+}
+
 // ReceiveExitSignal calls the provided exit function and exits
 // with the provided exit status when the program receives
 // SIGTERM or SIGINT
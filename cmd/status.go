@@ -0,0 +1,279 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the public status API, an unauthenticated read-only view of the
+// network for wallets, explorers, and websites. Responses are cached and
+// requests are rate limited per IP address.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/rateLimiting"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Default duration a status response is cached for
+	defaultStatusCacheDuration = 10 * time.Second
+
+	// Default number of requests per minute allowed from each IP address
+	defaultStatusRequestsPerMinute = 60
+
+	// Window the round rate is measured over
+	statusRoundRateWindow = 10 * time.Minute
+
+	// How often rate limit buckets of inactive IP addresses are removed and
+	// how long they must be inactive for
+	statusBucketPollDuration = time.Minute
+	statusBucketMaxAge       = 10 * time.Minute
+)
+
+// networkStatus is the response of the /status endpoint.
+type networkStatus struct {
+	// Network, if this is not the primary network
+	Network   string `json:",omitempty"`
+	Timestamp time.Time
+
+	// Hash and timestamp of the full NDF currently served
+	NdfHash      []byte
+	NdfTimestamp time.Time
+
+	AddressSpaceSize uint32
+	// ID the next round created will be given
+	NextRoundId uint64
+	// Rounds which have been created and have not yet completed or failed
+	ActiveRounds int
+	// Rounds completed per minute, averaged over the last ten minutes
+	RoundsPerMinute  float64
+	SchedulingPaused bool
+
+	// Number of Nodes with each status
+	Nodes map[string]int
+}
+
+// nodeStatus is the public information of a Node in the /nodes endpoint.
+type nodeStatus struct {
+	Id []byte
+	// Name from the Node's approved Application
+	Name     string `json:",omitempty"`
+	Status   string
+	Activity string
+	Draining bool
+	Country  string
+	GeoBin   string `json:",omitempty"`
+	// Time the Node last polled
+	LastActive time.Time
+}
+
+// statusCache holds the encoded response of a status endpoint until it is
+// older than the cache duration.
+type statusCache struct {
+	build    func(now time.Time) (interface{}, error)
+	duration time.Duration
+
+	mux     sync.Mutex
+	body    []byte
+	etag    string
+	expires time.Time
+}
+
+// newStatusCache creates a cache of the response returned by build.
+func newStatusCache(duration time.Duration,
+	build func(now time.Time) (interface{}, error)) *statusCache {
+	return &statusCache{build: build, duration: duration}
+}
+
+// get returns the encoded response, its ETag, and when it expires, rebuilding
+// it if it has expired.
+func (c *statusCache) get(now time.Time) ([]byte, string, time.Time, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.body != nil && now.Before(c.expires) {
+		return c.body, c.etag, c.expires, nil
+	}
+
+	resp, err := c.build(now)
+	if err != nil {
+		return nil, "", time.Time{}, err
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return nil, "", time.Time{}, errors.Errorf("could not marshal "+
+			"status: %+v", err)
+	}
+
+	hash := sha256.Sum256(body)
+	c.body = body
+	c.etag = `"` + base64.RawURLEncoding.EncodeToString(hash[:16]) + `"`
+	c.expires = now.Add(c.duration)
+	return c.body, c.etag, c.expires, nil
+}
+
+// ServeHTTP serves the cached response with headers allowing clients and
+// proxies to cache it until it expires.
+func (c *statusCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeAdminResponse(w, http.StatusMethodNotAllowed,
+			errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	now := time.Now()
+	body, etag, expires, err := c.get(now)
+	if err != nil {
+		jww.WARN.Printf("Failed to build status response: %+v", err)
+		writeAdminResponse(w, http.StatusInternalServerError,
+			errors.New("status unavailable"))
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d",
+		int(expires.Sub(now).Seconds())))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodGet {
+		if _, err = w.Write(body); err != nil {
+			jww.WARN.Printf("Failed to write status response: %+v", err)
+		}
+	}
+}
+
+// newStatusMux returns the handler for every status API endpoint of the
+// network, with responses cached for the duration and at most streamLimit round
+// update streams open at once.
+func (m *RegistrationImpl) newStatusMux(cacheDuration time.Duration,
+	streamLimit int) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/status", newStatusCache(cacheDuration,
+		func(now time.Time) (interface{}, error) {
+			return m.getNetworkStatus(now)
+		}))
+	mux.Handle("/nodes", newStatusCache(cacheDuration,
+		func(now time.Time) (interface{}, error) {
+			return m.getNodeStatuses()
+		}))
+	mux.Handle("/rounds/stream", newRoundStream(m.State, streamLimit))
+	return mux
+}
+
+// getNetworkStatus returns the current status of the network.
+func (m *RegistrationImpl) getNetworkStatus(now time.Time) (*networkStatus, error) {
+	status := &networkStatus{
+		Network:          m.State.GetNetwork(),
+		Timestamp:        now,
+		AddressSpaceSize: m.State.GetAddressSpaceSize(),
+		ActiveRounds:     m.State.GetRoundMap().Len(),
+		SchedulingPaused: m.schedulingPauser.IsPaused(),
+		Nodes:            make(map[string]int),
+	}
+
+	if fullNdf := m.State.GetFullNdf(); fullNdf != nil && fullNdf.Get() != nil {
+		status.NdfHash = fullNdf.GetHash()
+		status.NdfTimestamp = fullNdf.Get().Timestamp
+	}
+
+	roundId, err := m.State.GetRoundID()
+	if err != nil {
+		return nil, err
+	}
+	status.NextRoundId = uint64(roundId)
+
+	rounds, err := storage.PermissioningDb.CountRoundMetricsSince(
+		m.State.GetNetwork(), now.Add(-statusRoundRateWindow))
+	if err != nil {
+		return nil, errors.Errorf("could not count rounds: %+v", err)
+	}
+	status.RoundsPerMinute = float64(rounds) / statusRoundRateWindow.Minutes()
+
+	for _, n := range m.State.GetNodeMap().GetNodeStates() {
+		status.Nodes[n.GetStatus().String()]++
+	}
+
+	return status, nil
+}
+
+// getNodeStatuses returns the public information of every Node, ordered by
+// ID.
+func (m *RegistrationImpl) getNodeStatuses() ([]*nodeStatus, error) {
+	applications, err := storage.PermissioningDb.GetApplications()
+	if err != nil {
+		return nil, errors.Errorf("could not get applications: %+v", err)
+	}
+	names := make(map[uint64]string, len(applications))
+	for _, app := range applications {
+		if app.Status == storage.ApplicationApproved {
+			names[app.Id] = app.Name
+		}
+	}
+
+	nodes := m.State.GetNodeMap().GetNodeStates()
+	statuses := make([]*nodeStatus, 0, len(nodes))
+	for _, n := range nodes {
+		ns := &nodeStatus{
+			Id:         n.GetID().Marshal(),
+			Name:       names[n.GetAppID()],
+			Status:     n.GetStatus().String(),
+			Activity:   n.GetActivity().String(),
+			Draining:   n.IsDraining(),
+			Country:    n.GetOrdering(),
+			LastActive: n.GetLastPoll(),
+		}
+		if bin, exists := m.State.GetGeoBin(n.GetOrdering()); exists {
+			ns.GeoBin = bin.String()
+		}
+		statuses = append(statuses, ns)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return string(statuses[i].Id) < string(statuses[j].Id)
+	})
+	return statuses, nil
+}
+
+// rateLimitStatus wraps the status API so that each IP address may only make
+// a limited number of requests. Requests over the limit are rejected with
+// http.StatusTooManyRequests.
+func rateLimitStatus(next http.Handler, requestsPerMinute uint32,
+	quit chan struct{}) http.Handler {
+	buckets := rateLimiting.CreateBucketMap(requestsPerMinute,
+		requestsPerMinute, time.Minute, statusBucketPollDuration,
+		statusBucketMaxAge, nil, quit)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+
+		if ok, _ := buckets.LookupBucket(host).Add(1); !ok {
+			w.Header().Set("Retry-After", "60")
+			writeAdminResponse(w, http.StatusTooManyRequests,
+				errors.New("too many requests"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,157 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"encoding/json"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that the status endpoints serve cacheable JSON and that nothing
+// private about the Nodes is included.
+func TestRegistrationImpl_newStatusMux(t *testing.T) {
+	impl := newAdminTestImpl(t)
+	impl.schedulingPauser = scheduling.NewPauser()
+
+	nid := id.NewIdFromString("status", id.Node, t)
+	err := impl.State.GetNodeMap().AddNode(nid, "US", "10.0.0.1:11420",
+		"10.0.0.1:22840", 1)
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	err = storage.PermissioningDb.InsertApplication(
+		&storage.Application{Id: 1, Name: "Example Node"},
+		&storage.Node{Code: "CODE", Id: nid.Marshal()})
+	if err != nil {
+		t.Fatalf("Failed to insert application: %+v", err)
+	}
+
+	mux := impl.newStatusMux(time.Minute, 1)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Status failed with status %d: %s", w.Code, w.Body)
+	}
+	if cc := w.Header().Get("Cache-Control"); !strings.HasPrefix(cc, "public") {
+		t.Errorf("Unexpected Cache-Control header: %q", cc)
+	}
+	status := &networkStatus{}
+	if err = json.NewDecoder(w.Body).Decode(status); err != nil {
+		t.Fatalf("Failed to decode status: %+v", err)
+	}
+	if status.Nodes[nodeStatusName(impl, nid)] != 1 {
+		t.Errorf("Unexpected node counts: %+v", status.Nodes)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nodes", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Nodes failed with status %d: %s", w.Code, w.Body)
+	}
+	if body := w.Body.String(); strings.Contains(body, "10.0.0.1") ||
+		strings.Contains(body, "CODE") {
+		t.Errorf("Node list contains private information: %s", body)
+	}
+	var nodes []*nodeStatus
+	if err = json.NewDecoder(w.Body).Decode(&nodes); err != nil {
+		t.Fatalf("Failed to decode nodes: %+v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "Example Node" ||
+		nodes[0].Country != "US" {
+		t.Errorf("Unexpected nodes: %+v", nodes)
+	}
+
+	// A request with the current ETag is not sent the body again
+	etag := w.Header().Get("ETag")
+	req := httptest.NewRequest(http.MethodGet, "/nodes", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected status %d with no body, received %d: %s",
+			http.StatusNotModified, w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/status", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d for POST, received %d",
+			http.StatusMethodNotAllowed, w.Code)
+	}
+}
+
+// nodeStatusName returns the status name of the Node in the state.
+func nodeStatusName(impl *RegistrationImpl, nid *id.ID) string {
+	return impl.State.GetNodeMap().GetNode(nid).GetStatus().String()
+}
+
+// Tests that statusCache only rebuilds the response once it has expired.
+func TestStatusCache_get(t *testing.T) {
+	builds := 0
+	c := newStatusCache(time.Minute, func(time.Time) (interface{}, error) {
+		builds++
+		return builds, nil
+	})
+
+	now := time.Now()
+	first, etag, _, err := c.get(now)
+	if err != nil {
+		t.Fatalf("Failed to get response: %+v", err)
+	}
+	cached, cachedEtag, _, _ := c.get(now.Add(30 * time.Second))
+	if string(cached) != string(first) || cachedEtag != etag || builds != 1 {
+		t.Errorf("Response rebuilt before expiring: %s", cached)
+	}
+
+	rebuilt, rebuiltEtag, _, _ := c.get(now.Add(time.Minute))
+	if string(rebuilt) != "2" || rebuiltEtag == etag {
+		t.Errorf("Response not rebuilt after expiring: %s", rebuilt)
+	}
+}
+
+// Tests that rateLimitStatus rejects requests from an IP address over the
+// limit without affecting other addresses.
+func Test_rateLimitStatus(t *testing.T) {
+	quit := make(chan struct{}, 1)
+	defer func() { quit <- struct{}{} }()
+
+	handler := rateLimitStatus(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}), 2, quit)
+
+	send := func(addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/status", nil)
+		req.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := send("10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Errorf("Request %d rejected with status %d", i, w.Code)
+		}
+	}
+	w := send("10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected status %d over the limit, received %d",
+			http.StatusTooManyRequests, w.Code)
+	}
+	if w = send("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("Other address rejected with status %d", w.Code)
+	}
+}
@@ -0,0 +1,306 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles the validate subcommand, which checks the configuration and every
+// file it references without starting the server
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/oschwald/geoip2-golang"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gitlab.com/elixxir/crypto/contact"
+	"gitlab.com/elixxir/primitives/version"
+	"gitlab.com/elixxir/registration/scheduling"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/crypto/tls"
+	"gitlab.com/xx_network/primitives/utils"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// Number of Miller-Rabin rounds used when checking group primes
+const primalityRounds = 8
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the configuration without starting the server",
+	Long: `Loads the config file and every file it references (scheduling ` +
+		`params, registration codes, whitelists, disabled Nodes, certificates ` +
+		`and the GeoIP2 database), reports every problem found, and exits ` +
+		`with a non-zero status if there are any. The database is not ` +
+		`contacted and no ports are opened.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		problems := validateConfig()
+		if len(problems) == 0 {
+			fmt.Printf("Configuration %s is valid\n", cfgFile)
+			return
+		}
+
+		fmt.Printf("Found %d problem(s) in configuration %s:\n",
+			len(problems), cfgFile)
+		for _, problem := range problems {
+			fmt.Printf("\t%v\n", problem)
+		}
+		os.Exit(1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().StringVarP(&cfgFile, "config", "c",
+		"", "Sets a custom config file path")
+
+	validateCmd.Flags().BoolVar(&noTLS, "noTLS", false,
+		"Skips checking the TLS certificate")
+}
+
+// validateConfig runs every check on the loaded configuration and returns all
+// problems found.
+func validateConfig() []error {
+	var problems []error
+	check := func(err error) {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if viper.ConfigFileUsed() == "" {
+		check(errors.Errorf("Unable to open config file %q", cfgFile))
+	} else if err := viper.ReadInConfig(); err != nil {
+		check(errors.Errorf("Unable to parse config file %q: %+v", cfgFile, err))
+	}
+
+	// Groups
+	check(validateGroup("groups.cmix"))
+	check(validateGroup("groups.e2e"))
+
+	// Versions
+	for _, key := range []string{
+		"minGatewayVersion", "minServerVersion", "minClientVersion"} {
+		if _, err := version.ParseVersion(viper.GetString(key)); err != nil {
+			check(errors.Errorf("Could not parse %s %#v: %+v", key,
+				viper.GetString(key), err))
+		}
+	}
+
+	// Durations
+	for _, key := range []string{"schedulingKillTimeout", "closeTimeout"} {
+		if _, err := time.ParseDuration(viper.GetString(key)); err != nil {
+			check(errors.Errorf("Could not parse %s %q as a duration: %+v",
+				key, viper.GetString(key), err))
+		}
+	}
+
+	// Database address is only checked for format
+	if rawAddr := viper.GetString("dbAddress"); rawAddr != "" {
+		if _, _, err := net.SplitHostPort(rawAddr); err != nil {
+			check(errors.Errorf("Unable to get database port from %q: %+v",
+				rawAddr, err))
+		}
+	}
+
+	// Keys and certificates
+	check(validateFile("keyPath", func(data []byte) error {
+		_, err := rsa.LoadPrivateKeyFromPem(data)
+		return err
+	}))
+	if !noTLS {
+		check(validateFile("certPath", validateCert))
+	}
+	check(validateFile("udbCertPath", validateCert))
+	if viper.GetString("nsCertPath") != "" && viper.GetString("nsAddress") != "" {
+		check(validateFile("nsCertPath", validateCert))
+	}
+	check(validateFile("udContactPath", func(data []byte) error {
+		_, _, err := contact.ReadContactFromFile(data)
+		return err
+	}))
+
+	// GeoIP2 database
+	if !viper.GetBool("disableGeoBinning") {
+		check(validateGeoIPDB(viper.GetString("geoIPDBFile")))
+	}
+
+	// Scheduling params
+	check(validateFile("schedulingConfigPath", func(data []byte) error {
+		_, err := scheduling.LoadParams(data, 0, nil)
+		return err
+	}))
+
+	// Optional files
+	if viper.GetString("regCodesFilePath") != "" {
+		check(validateRegCodes(viper.GetString("regCodesFilePath")))
+	}
+	if viper.GetString("whitelistedIdsPath") != "" {
+		check(validateFile("whitelistedIdsPath", validateWhitelistedIds))
+	}
+	if viper.GetString("whitelistedIpAddressesPath") != "" {
+		check(validateFile("whitelistedIpAddressesPath",
+			validateWhitelistedIpAddresses))
+	}
+	if viper.GetString("disabledNodesPath") != "" {
+		_, err := storage.LoadDisabledNodes(viper.GetString("disabledNodesPath"))
+		if err != nil {
+			check(errors.WithMessage(err, "disabledNodesPath"))
+		}
+	}
+	if _, err := newEligibleNodeSource(); err != nil {
+		check(errors.WithMessage(err, "eligibleNodeSource"))
+	}
+
+	// Secondary networks
+	networkConfigs, err := loadNetworkConfigs()
+	check(err)
+	for _, name := range sortedNetworkNames(networkConfigs) {
+		if path := networkConfigs[name].SchedulingConfigPath; path != "" {
+			check(validateNetworkSchedulingConfig(name, path))
+		}
+	}
+
+	return problems
+}
+
+// validateFile reads the file at the path in the config key and checks its
+// contents with the passed in function.
+func validateFile(key string, validate func(data []byte) error) error {
+	path := viper.GetString(key)
+	if path == "" {
+		return errors.Errorf("%s is not set", key)
+	}
+
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return errors.Errorf("Could not read %s %q: %+v", key, path, err)
+	}
+
+	if err = validate(data); err != nil {
+		return errors.Errorf("Invalid %s %q: %+v", key, path, err)
+	}
+	return nil
+}
+
+// validateNetworkSchedulingConfig checks that the scheduling params file of
+// the secondary network can be loaded.
+func validateNetworkSchedulingConfig(network, path string) error {
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return errors.Errorf("Could not read schedulingConfigPath %q of "+
+			"network %q: %+v", path, network, err)
+	}
+
+	if _, err = scheduling.LoadParams(data, 0, nil); err != nil {
+		return errors.Errorf("Invalid schedulingConfigPath %q of network "+
+			"%q: %+v", path, network, err)
+	}
+	return nil
+}
+
+// validateCert checks that the data is a PEM encoded certificate.
+func validateCert(data []byte) error {
+	_, err := tls.LoadCertificate(string(data))
+	return err
+}
+
+// validateGroup checks that the group in the config key has a safe prime and a
+// generator in range.
+func validateGroup(key string) error {
+	grp, err := toGroup(viper.GetStringMapString(key))
+	if err != nil {
+		return errors.WithMessagef(err, "Invalid %s", key)
+	}
+
+	p, ok := new(big.Int).SetString(grp.Prime, 16)
+	if !ok {
+		return errors.Errorf("Invalid %s: prime is not a hex number", key)
+	}
+	g, ok := new(big.Int).SetString(grp.Generator, 16)
+	if !ok {
+		return errors.Errorf("Invalid %s: generator is not a hex number", key)
+	}
+
+	if !p.ProbablyPrime(primalityRounds) {
+		return errors.Errorf("Invalid %s: prime is not prime", key)
+	}
+
+	// A safe prime p has (p-1)/2 prime as well
+	q := new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+	if !q.ProbablyPrime(primalityRounds) {
+		return errors.Errorf("Invalid %s: prime is not a safe prime", key)
+	}
+
+	pMinusOne := new(big.Int).Sub(p, big.NewInt(1))
+	if g.Cmp(big.NewInt(1)) <= 0 || g.Cmp(pMinusOne) >= 0 {
+		return errors.Errorf("Invalid %s: generator must be in the range "+
+			"(1, p-1)", key)
+	}
+
+	return nil
+}
+
+// validateGeoIPDB checks that the GeoIP2 database file can be opened.
+func validateGeoIPDB(path string) error {
+	if path == "" {
+		return errors.New("Must provide either a MaxMind GeoLite2 " +
+			"compatible database file in geoIPDBFile or set disableGeoBinning")
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return errors.Errorf("Failed to load GeoIP2 database file %q: %+v",
+			path, err)
+	}
+	return reader.Close()
+}
+
+// validateRegCodes checks that the registration code file can be loaded and
+// contains no empty or duplicate codes.
+func validateRegCodes(path string) error {
+	infos, err := node.LoadInfo(path)
+	if err != nil {
+		return errors.WithMessagef(err, "Invalid regCodesFilePath %q", path)
+	}
+
+	codes := make(map[string]int, len(infos))
+	for i, info := range infos {
+		if info.RegCode == "" {
+			return errors.Errorf("Invalid regCodesFilePath %q: empty "+
+				"registration code at index %d", path, i)
+		}
+		if j, exists := codes[info.RegCode]; exists {
+			return errors.Errorf("Invalid regCodesFilePath %q: duplicate "+
+				"registration code %q at index %d and %d", path, info.RegCode,
+				j, i)
+		}
+		codes[info.RegCode] = i
+	}
+
+	return nil
+}
+
+// validateWhitelistedIds checks that the data is a JSON list of base64 encoded
+// IDs.
+func validateWhitelistedIds(data []byte) error {
+	_, err := storage.ParseWhitelist(data, storage.WhitelistId)
+	return err
+}
+
+// validateWhitelistedIpAddresses checks that the data is a JSON list of IP
+// addresses and CIDR blocks.
+func validateWhitelistedIpAddresses(data []byte) error {
+	_, err := storage.ParseWhitelist(data, storage.WhitelistIp)
+	return err
+}
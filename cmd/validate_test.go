@@ -0,0 +1,108 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"github.com/spf13/viper"
+	"strings"
+	"testing"
+)
+
+// Tests that validateGroup accepts a safe prime with a valid generator and
+// rejects bad groups.
+func TestValidateGroup(t *testing.T) {
+	defer viper.Reset()
+
+	tests := []struct {
+		prime, generator string
+		valid            bool
+	}{
+		{"17", "2", true},   // 23 is a safe prime
+		{"15", "2", false},  // 21 is not prime
+		{"D", "2", false},   // 13 is prime but 6 is not
+		{"17", "1", false},  // generator too small
+		{"17", "16", false}, // generator is p-1
+		{"zz", "2", false},  // not hex
+	}
+
+	for i, tt := range tests {
+		viper.Set("groups.cmix", map[string]string{
+			"prime": tt.prime, "generator": tt.generator})
+		err := validateGroup("groups.cmix")
+		if tt.valid && err != nil {
+			t.Errorf("Unexpected error for group %d: %+v", i, err)
+		} else if !tt.valid && err == nil {
+			t.Errorf("Expected error for group %d (prime: %s, generator: %s)",
+				i, tt.prime, tt.generator)
+		}
+	}
+}
+
+// Tests that validateGroup errors when the group is missing.
+func TestValidateGroup_Missing(t *testing.T) {
+	defer viper.Reset()
+	if err := validateGroup("groups.e2e"); err == nil {
+		t.Error("Expected error for missing group")
+	}
+}
+
+// Tests that validateConfig reports every problem instead of stopping at the
+// first one.
+func TestValidateConfig_AllProblems(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("groups.cmix", map[string]string{"prime": "15", "generator": "2"})
+	viper.Set("minGatewayVersion", "not a version")
+	viper.Set("closeTimeout", "forever")
+	viper.Set("dbAddress", "no-port")
+	viper.Set("keyPath", "does/not/exist.key")
+	viper.Set("whitelistedIdsPath", "does/not/exist.json")
+
+	problems := validateConfig()
+
+	var all []string
+	for _, p := range problems {
+		all = append(all, p.Error())
+	}
+	joined := strings.Join(all, "\n")
+
+	for _, expected := range []string{"groups.cmix", "groups.e2e",
+		"minGatewayVersion", "closeTimeout", "database port", "keyPath",
+		"udbCertPath", "schedulingConfigPath", "whitelistedIdsPath",
+		"geoIPDBFile"} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Expected a problem mentioning %q.\nproblems:\n%s",
+				expected, joined)
+		}
+	}
+}
+
+// Tests that validateWhitelistedIds rejects entries that are not IDs.
+func TestValidateWhitelistedIds(t *testing.T) {
+	if err := validateWhitelistedIds([]byte(`[]`)); err != nil {
+		t.Errorf("Unexpected error for empty list: %+v", err)
+	}
+	if err := validateWhitelistedIds([]byte(`["not base64!"]`)); err == nil {
+		t.Error("Expected error for invalid ID")
+	}
+	if err := validateWhitelistedIds([]byte(`{`)); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+// Tests that validateWhitelistedIpAddresses rejects invalid addresses.
+func TestValidateWhitelistedIpAddresses(t *testing.T) {
+	if err := validateWhitelistedIpAddresses(
+		[]byte(`["127.0.0.1", "::1"]`)); err != nil {
+		t.Errorf("Unexpected error for valid addresses: %+v", err)
+	}
+	if err := validateWhitelistedIpAddresses(
+		[]byte(`["256.0.0.1"]`)); err == nil {
+		t.Error("Expected error for invalid address")
+	}
+}
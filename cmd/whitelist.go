@@ -0,0 +1,248 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Handles managing the whitelist of IDs and IP addresses that gateways exempt
+// from rate limiting, through the admin API and the whitelist subcommand.
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// Default duration between rebuilds of the whitelist, which removes
+	// expired entries from the NDF
+	defaultWhitelistPollDuration = time.Minute
+)
+
+// whitelistRequest is the JSON body of a request to the whitelist endpoints.
+// Expiry and Note are only used when adding an entry.
+type whitelistRequest struct {
+	Value  string
+	Expiry time.Time
+	Note   string
+}
+
+// Flags for the whitelist subcommand
+var (
+	whitelistAdminAddress string
+	whitelistAdminToken   string
+	whitelistCertPath     string
+	whitelistExpiresIn    time.Duration
+	whitelistNote         string
+)
+
+var whitelistCmd = &cobra.Command{
+	Use:   "whitelist",
+	Short: "Manages the IDs and IP addresses exempt from rate limiting",
+	Long: `Adds, removes, and lists whitelist entries through the admin API ` +
+		`of the permissioning server. Entries are base64 encoded IDs, IP ` +
+		`addresses, or CIDR blocks, and changes are published in the NDF ` +
+		`immediately.`,
+}
+
+var whitelistAddCmd = &cobra.Command{
+	Use:   "add <value>",
+	Short: "Adds or replaces a whitelist entry",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		req := whitelistRequest{Value: args[0], Note: whitelistNote}
+		if whitelistExpiresIn > 0 {
+			req.Expiry = time.Now().Add(whitelistExpiresIn)
+		}
+
+		entry := &storage.WhitelistEntry{}
+		runWhitelistRequest(http.MethodPost, "/whitelist/add", req, entry)
+		fmt.Printf("Added %s %s\n", entry.Type, entry.Value)
+	},
+}
+
+var whitelistRemoveCmd = &cobra.Command{
+	Use:   "remove <value>",
+	Short: "Removes a whitelist entry",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runWhitelistRequest(http.MethodPost, "/whitelist/remove",
+			whitelistRequest{Value: args[0]}, nil)
+		fmt.Printf("Removed %s\n", args[0])
+	},
+}
+
+var whitelistListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the whitelist entries in storage",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var entries []*storage.WhitelistEntry
+		runWhitelistRequest(http.MethodGet, "/whitelist", nil, &entries)
+
+		now := time.Now()
+		for _, entry := range entries {
+			expiry := "never expires"
+			if entry.IsExpired(now) {
+				expiry = "expired " + entry.Expiry.Format(time.RFC3339)
+			} else if !entry.Expiry.IsZero() {
+				expiry = "expires " + entry.Expiry.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\n", entry.Type, entry.Value, expiry,
+				entry.Note)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whitelistCmd)
+	whitelistCmd.AddCommand(whitelistAddCmd, whitelistRemoveCmd,
+		whitelistListCmd)
+
+	whitelistCmd.PersistentFlags().StringVarP(&whitelistAdminAddress,
+		"adminAddress", "a", "", "Address of the permissioning server's admin API")
+	whitelistCmd.PersistentFlags().StringVar(&whitelistAdminToken,
+		"adminToken", "", "Admin token of the permissioning server")
+	whitelistCmd.PersistentFlags().StringVar(&whitelistCertPath, "certPath",
+		"", "Path to the permissioning server's TLS certificate")
+	whitelistCmd.PersistentFlags().BoolVar(&noTLS, "noTLS", false,
+		"Connects to the admin API without TLS")
+
+	whitelistAddCmd.Flags().DurationVar(&whitelistExpiresIn, "expiresIn", 0,
+		"How long until the entry expires, e.g. 720h. Never expires if unset")
+	whitelistAddCmd.Flags().StringVar(&whitelistNote, "note", "",
+		"Description of who the entry is granted to")
+
+	for _, flag := range []string{"adminAddress", "adminToken"} {
+		if err := whitelistCmd.MarkPersistentFlagRequired(flag); err != nil {
+			jww.FATAL.Panicf("Failed to mark %s as required: %+v", flag, err)
+		}
+	}
+}
+
+// runWhitelistRequest sends the request to the whitelist endpoint of the admin
+// API from the command line flags and exits on failure.
+func runWhitelistRequest(method, path string, req, resp interface{}) {
+	client, scheme, err := newAdminClient(whitelistCertPath, noTLS)
+	if err == nil {
+		err = sendAdminRequest(client, method, fmt.Sprintf("%s://%s%s",
+			scheme, whitelistAdminAddress, path), whitelistAdminToken, req,
+			resp)
+	}
+	if err != nil {
+		fmt.Printf("Whitelist request failed: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// updateWhitelist rebuilds the whitelist and outputs a new NDF if it changed.
+func (m *RegistrationImpl) updateWhitelist() error {
+	ids, ips, err := m.whitelist.Get(time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !m.State.SetWhitelist(ids, ips) {
+		return nil
+	}
+	return m.State.UpdateOutputNdf()
+}
+
+// whitelistListHandler returns every whitelist entry in storage, including
+// expired ones.
+func (m *RegistrationImpl) whitelistListHandler(w http.ResponseWriter,
+	r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	entries, err := storage.PermissioningDb.GetWhitelistEntries(
+		m.State.GetNetwork())
+	if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	if entries == nil {
+		entries = []*storage.WhitelistEntry{}
+	}
+
+	writeAdminJSON(w, http.StatusOK, entries)
+}
+
+// whitelistAddHandler adds or replaces a whitelist entry and publishes the
+// change in the NDF.
+func (m *RegistrationImpl) whitelistAddHandler(w http.ResponseWriter,
+	r *http.Request) {
+	req := &whitelistRequest{}
+	if !decodeAdminRequest(w, r, req) {
+		return
+	}
+
+	entry, err := storage.NewWhitelistEntry(m.State.GetNetwork(), req.Value,
+		req.Expiry, req.Note, time.Now())
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err = storage.PermissioningDb.UpsertWhitelistEntry(entry); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	jww.INFO.Printf("Added whitelist %s %s (%s)", entry.Type, entry.Value,
+		entry.Note)
+
+	if err = m.updateWhitelist(); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError,
+			errors.WithMessage(err, "entry added but NDF not updated"))
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, entry)
+}
+
+// whitelistRemoveHandler removes a whitelist entry from storage and publishes
+// the change in the NDF. Entries from the whitelist files cannot be removed.
+func (m *RegistrationImpl) whitelistRemoveHandler(w http.ResponseWriter,
+	r *http.Request) {
+	req := &whitelistRequest{}
+	if !decodeAdminRequest(w, r, req) {
+		return
+	}
+
+	_, value, err := storage.ParseWhitelistValue(req.Value)
+	if err != nil {
+		writeAdminResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = storage.PermissioningDb.DeleteWhitelistEntry(m.State.GetNetwork(),
+		value)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeAdminResponse(w, http.StatusNotFound, errors.Errorf("%s is not "+
+			"in storage; entries in whitelist files must be removed from the "+
+			"file", value))
+		return
+	} else if err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError, err)
+		return
+	}
+	jww.INFO.Printf("Removed whitelist entry %s", value)
+
+	if err = m.updateWhitelist(); err != nil {
+		writeAdminResponse(w, http.StatusInternalServerError,
+			errors.WithMessage(err, "entry removed but NDF not updated"))
+		return
+	}
+
+	writeAdminResponse(w, http.StatusOK, nil)
+}
@@ -0,0 +1,135 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/region"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newWhitelistTestImpl creates a RegistrationImpl with a whitelist and an
+// admin token.
+func newWhitelistTestImpl(t *testing.T) *RegistrationImpl {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	state, err := storage.NewState(getTestKey(), 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %+v", err)
+	}
+
+	whitelist, err := storage.NewWhitelist("", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create whitelist: %+v", err)
+	}
+
+	return &RegistrationImpl{
+		State:     state,
+		params:    &Params{adminToken: "token"},
+		whitelist: whitelist,
+	}
+}
+
+// sendWhitelistRequest sends the request with the admin token to the admin API
+// and returns the response recorder.
+func sendWhitelistRequest(t *testing.T, mux http.Handler, method, path string,
+	body interface{}) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %+v", err)
+	}
+	req := httptest.NewRequest(method, path, bytes.NewReader(data))
+	req.Header.Set("Authorization", "Bearer token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	return w
+}
+
+// Tests that entries added and removed through the admin API are stored and
+// published in the NDF.
+func TestRegistrationImpl_whitelistHandlers(t *testing.T) {
+	impl := newWhitelistTestImpl(t)
+	mux := impl.newAdminMux()
+
+	w := sendWhitelistRequest(t, mux, http.MethodPost, "/whitelist/add",
+		whitelistRequest{Value: "10.0.0.7/24", Note: "partner",
+			Expiry: time.Now().Add(time.Hour)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Add failed with status %d: %s", w.Code, w.Body)
+	}
+	expected := []string{"10.0.0.0/24"}
+	if ips := impl.State.GetUnprunedNdf().WhitelistedIpAddresses; !reflect.DeepEqual(expected, ips) {
+		t.Errorf("Unexpected NDF whitelist after add."+
+			"\nexpected: %v\nreceived: %v", expected, ips)
+	}
+	if ips := impl.State.GetFullNdf().Get().WhitelistedIpAddresses; !reflect.DeepEqual(expected, ips) {
+		t.Errorf("Unexpected output NDF whitelist after add."+
+			"\nexpected: %v\nreceived: %v", expected, ips)
+	}
+
+	w = sendWhitelistRequest(t, mux, http.MethodGet, "/whitelist", nil)
+	var entries []*storage.WhitelistEntry
+	if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+		t.Fatalf("Failed to decode list: %+v", err)
+	}
+	if len(entries) != 1 || entries[0].Value != "10.0.0.0/24" ||
+		entries[0].Note != "partner" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	w = sendWhitelistRequest(t, mux, http.MethodPost, "/whitelist/remove",
+		whitelistRequest{Value: "10.0.0.0/24"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Remove failed with status %d: %s", w.Code, w.Body)
+	}
+	if ips := impl.State.GetUnprunedNdf().WhitelistedIpAddresses; len(ips) != 0 {
+		t.Errorf("NDF whitelist not empty after remove: %v", ips)
+	}
+
+	w = sendWhitelistRequest(t, mux, http.MethodPost, "/whitelist/remove",
+		whitelistRequest{Value: "10.0.0.0/24"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for missing entry, received %d",
+			http.StatusNotFound, w.Code)
+	}
+}
+
+// Tests that the whitelist endpoints reject invalid values and expiries.
+func TestRegistrationImpl_whitelistAddHandler_Invalid(t *testing.T) {
+	impl := newWhitelistTestImpl(t)
+	mux := impl.newAdminMux()
+
+	for i, req := range []whitelistRequest{
+		{Value: "not an id"},
+		{Value: "10.0.0.1", Expiry: time.Now().Add(-time.Hour)},
+	} {
+		w := sendWhitelistRequest(t, mux, http.MethodPost, "/whitelist/add", req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %+v (%d), received %d",
+				http.StatusBadRequest, req, i, w.Code)
+		}
+	}
+
+	entries, err := storage.PermissioningDb.GetWhitelistEntries("")
+	if err != nil {
+		t.Fatalf("Failed to get entries: %+v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Invalid entries stored: %+v", entries)
+	}
+}
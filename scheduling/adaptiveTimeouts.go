@@ -0,0 +1,361 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// adaptiveTimeouts.go contains the logic which derives the precomputation and
+// realtime timeouts of a round from the durations of recently completed rounds
+// with the same batch size and, optionally, team geography.
+
+const (
+	// Defaults for the AdaptiveTimeouts which are not set
+	defaultAdaptiveHeadroom   = 1.5
+	defaultAdaptiveWindow     = 100
+	defaultAdaptiveMinSamples = 20
+
+	// Number of windows of completed rounds loaded from storage at startup,
+	// so that the windows of each batch size and geography can be filled
+	adaptiveLoadWindows = 10
+)
+
+// AdaptiveTimeouts derives the precomputation and realtime timeouts from a
+// percentile of the durations of recently completed rounds, bounded by the
+// configured minimums and maximums. The fixed timeouts are used until enough
+// rounds have completed. A zero Percentile disables adaptive timeouts.
+type AdaptiveTimeouts struct {
+	// Percentile of recent durations the timeouts are based on, above 0 and
+	// at most 100
+	Percentile float64
+	// Factor the percentile is multiplied by to give the timeout. Defaults to
+	// 1.5
+	Headroom float64
+	// Number of recent rounds kept per batch size and geography. Defaults to
+	// 100
+	Window uint32
+	// Number of rounds required before the fixed timeout is replaced.
+	// Defaults to 20
+	MinSamples uint32
+	// Key rounds by the GeoBins of their team as well as their batch size.
+	// Rounds of a geography without enough samples use those of the batch
+	// size
+	PerGeography bool
+
+	// NOTE: All times in MS
+	// Bounds on the adaptive precomputation timeout
+	MinPrecomputationTimeout time.Duration
+	MaxPrecomputationTimeout time.Duration
+	// Bounds on the adaptive realtime timeout
+	MinRealtimeTimeout time.Duration
+	MaxRealtimeTimeout time.Duration
+}
+
+// enabled returns true if adaptive timeouts are on.
+func (at AdaptiveTimeouts) enabled() bool {
+	return at.Percentile != 0
+}
+
+// withDefaults returns the config with defaults for the unset fields.
+func (at AdaptiveTimeouts) withDefaults() AdaptiveTimeouts {
+	if at.Headroom == 0 {
+		at.Headroom = defaultAdaptiveHeadroom
+	}
+	if at.Window == 0 {
+		at.Window = defaultAdaptiveWindow
+	}
+	if at.MinSamples == 0 {
+		at.MinSamples = defaultAdaptiveMinSamples
+	}
+	return at
+}
+
+// validate returns every problem with the config.
+func (at AdaptiveTimeouts) validate() []string {
+	if !at.enabled() {
+		return nil
+	}
+	at = at.withDefaults()
+
+	var problems []string
+	if at.Percentile < 0 || at.Percentile > 100 {
+		problems = append(problems, fmt.Sprintf("AdaptiveTimeouts."+
+			"Percentile %f must be between 0 and 100", at.Percentile))
+	}
+	if at.Headroom < 1 {
+		problems = append(problems, fmt.Sprintf("AdaptiveTimeouts."+
+			"Headroom %f cannot be less than 1", at.Headroom))
+	}
+	if at.MinSamples > at.Window {
+		problems = append(problems, fmt.Sprintf("AdaptiveTimeouts."+
+			"MinSamples %d is larger than Window %d", at.MinSamples,
+			at.Window))
+	}
+
+	bounds := []struct {
+		name     string
+		min, max time.Duration
+	}{
+		{"PrecomputationTimeout", at.MinPrecomputationTimeout,
+			at.MaxPrecomputationTimeout},
+		{"RealtimeTimeout", at.MinRealtimeTimeout, at.MaxRealtimeTimeout},
+	}
+	for _, b := range bounds {
+		if b.min <= 0 || b.max <= 0 {
+			problems = append(problems, fmt.Sprintf("AdaptiveTimeouts."+
+				"Min%s and Max%s must be greater than 0", b.name, b.name))
+		} else if b.min > b.max {
+			problems = append(problems, fmt.Sprintf("AdaptiveTimeouts."+
+				"Min%s is larger than Max%s", b.name, b.name))
+		} else if b.max*time.Millisecond > maxParamDuration {
+			problems = append(problems, fmt.Sprintf("AdaptiveTimeouts."+
+				"Max%s of %s is longer than the maximum of %s", b.name,
+				b.max*time.Millisecond, maxParamDuration))
+		}
+	}
+
+	return problems
+}
+
+// bounds returns the bounds on the timeout of the phase.
+func (at AdaptiveTimeouts) bounds(phase timeoutPhase) (min, max time.Duration) {
+	if phase == precompPhase {
+		return at.MinPrecomputationTimeout * time.Millisecond,
+			at.MaxPrecomputationTimeout * time.Millisecond
+	}
+	return at.MinRealtimeTimeout * time.Millisecond,
+		at.MaxRealtimeTimeout * time.Millisecond
+}
+
+// timeoutKey identifies the rounds whose durations are compared. Rounds keyed
+// by batch size alone have no geography.
+type timeoutKey struct {
+	batchSize uint32
+	geography string
+}
+
+// newTimeoutKey returns the key of a round with the batch size and team. The
+// geography is the sorted, distinct GeoBins of the team's nodes.
+func newTimeoutKey(state *storage.NetworkState, batchSize uint32,
+	topology *connect.Circuit) timeoutKey {
+	geoBins := state.GetGeoBins()
+	seen := make(map[string]bool)
+	var bins []string
+	for i := 0; i < topology.Len(); i++ {
+		n := state.GetNodeMap().GetNode(topology.GetNodeAtIndex(i))
+		if n == nil {
+			continue
+		}
+		bin, exists := geoBins[strings.ToUpper(n.GetOrdering())]
+		if exists && !seen[bin.String()] {
+			seen[bin.String()] = true
+			bins = append(bins, bin.String())
+		}
+	}
+	sort.Strings(bins)
+
+	return timeoutKey{batchSize: batchSize, geography: strings.Join(bins, ",")}
+}
+
+// roundSamples holds the most recent durations of each phase, oldest first.
+type roundSamples struct {
+	precomp  []time.Duration
+	realtime []time.Duration
+}
+
+// adaptiveTimeouts holds the durations of recently completed rounds and
+// derives timeouts from them. It is safe for concurrent use.
+type adaptiveTimeouts struct {
+	mux     sync.Mutex
+	samples map[timeoutKey]*roundSamples
+}
+
+// newAdaptiveTimeouts creates an adaptiveTimeouts with no samples.
+func newAdaptiveTimeouts() *adaptiveTimeouts {
+	return &adaptiveTimeouts{samples: make(map[timeoutKey]*roundSamples)}
+}
+
+// loadAdaptiveTimeouts creates an adaptiveTimeouts with the durations of the
+// network's most recently completed rounds in storage, so that the adaptive
+// timeouts carry over a restart. Nothing is loaded while adaptive timeouts are
+// disabled.
+func loadAdaptiveTimeouts(config AdaptiveTimeouts,
+	state *storage.NetworkState) (*adaptiveTimeouts, error) {
+	a := newAdaptiveTimeouts()
+	if !config.enabled() {
+		return a, nil
+	}
+	config = config.withDefaults()
+
+	metrics, err := storage.PermissioningDb.GetCompletedRoundMetrics(
+		state.GetNetwork(), int(config.Window)*adaptiveLoadWindows)
+	if err != nil {
+		return nil, errors.Errorf("Failed to load round metrics: %+v", err)
+	}
+
+	for _, metric := range metrics {
+		topology := make([]*id.ID, 0, len(metric.Topologies))
+		for _, t := range metric.Topologies {
+			nid, err := id.Unmarshal(t.NodeId)
+			if err != nil {
+				jww.WARN.Printf("Skipping node with invalid ID in round %d: "+
+					"%+v", metric.Id, err)
+				continue
+			}
+			topology = append(topology, nid)
+		}
+		key := newTimeoutKey(state, metric.BatchSize,
+			connect.NewCircuit(topology))
+		a.add(config, key, metric.PrecompEnd.Sub(metric.PrecompStart),
+			metric.RealtimeEnd.Sub(metric.RealtimeStart))
+	}
+	jww.INFO.Printf("Loaded the durations of %d rounds for adaptive timeouts",
+		len(metrics))
+
+	return a, nil
+}
+
+// record keeps the durations of the completed round under its batch size and
+// its geography. Nothing is kept while adaptive timeouts are disabled.
+func (a *adaptiveTimeouts) record(config AdaptiveTimeouts, key timeoutKey,
+	roundInfo *pb.RoundInfo, realtimeTs int64) {
+	if !config.enabled() {
+		return
+	}
+	precomp, realtime := roundDurations(roundInfo, realtimeTs)
+	a.add(config.withDefaults(), key, precomp, realtime)
+}
+
+// add keeps the durations under the batch size of the key and, if the config
+// compares rounds by geography, under the key. Durations which are not
+// positive are not kept.
+func (a *adaptiveTimeouts) add(config AdaptiveTimeouts, key timeoutKey,
+	precomp, realtime time.Duration) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+
+	keys := []timeoutKey{{batchSize: key.batchSize}}
+	if config.PerGeography {
+		keys = append(keys, key)
+	}
+	for _, k := range keys {
+		s, exists := a.samples[k]
+		if !exists {
+			s = &roundSamples{}
+			a.samples[k] = s
+		}
+		if precomp > 0 {
+			s.precomp = appendSample(s.precomp, precomp, config.Window)
+		}
+		if realtime > 0 {
+			s.realtime = appendSample(s.realtime, realtime, config.Window)
+		}
+	}
+}
+
+// timeout returns the timeout of the phase for a round with the key. The fixed
+// timeout is returned while adaptive timeouts are disabled or until enough
+// rounds like it have completed.
+func (a *adaptiveTimeouts) timeout(config AdaptiveTimeouts, key timeoutKey,
+	phase timeoutPhase, fixed time.Duration) time.Duration {
+	if !config.enabled() {
+		return fixed
+	}
+	config = config.withDefaults()
+
+	keys := []timeoutKey{{batchSize: key.batchSize}}
+	if config.PerGeography {
+		keys = []timeoutKey{key, keys[0]}
+	}
+
+	a.mux.Lock()
+	var samples []time.Duration
+	for _, k := range keys {
+		s, exists := a.samples[k]
+		if !exists {
+			continue
+		}
+		samples = s.precomp
+		if phase != precompPhase {
+			samples = s.realtime
+		}
+		if uint32(len(samples)) >= config.MinSamples {
+			break
+		}
+	}
+	if uint32(len(samples)) < config.MinSamples {
+		a.mux.Unlock()
+		return fixed
+	}
+	p := percentile(samples, config.Percentile)
+	a.mux.Unlock()
+
+	timeout := time.Duration(float64(p) * config.Headroom)
+	min, max := config.bounds(phase)
+	if timeout < min {
+		timeout = min
+	} else if timeout > max {
+		timeout = max
+	}
+
+	jww.TRACE.Printf("Adaptive %s timeout for batch size %d (%s): %s",
+		phase, key.batchSize, key.geography, timeout)
+	return timeout
+}
+
+// roundDurations returns how long the round took to precompute and to run
+// realtime, or zero for a phase without timestamps.
+func roundDurations(roundInfo *pb.RoundInfo, realtimeTs int64) (
+	precomp, realtime time.Duration) {
+	ts := roundInfo.GetTimestamps()
+	if len(ts) > int(states.REALTIME) {
+		if start, end := ts[states.PRECOMPUTING], ts[states.STANDBY]; start != 0 && end > start {
+			precomp = time.Duration(end - start)
+		}
+		if start := ts[states.REALTIME]; start != 0 && uint64(realtimeTs) > start {
+			realtime = time.Duration(uint64(realtimeTs) - start)
+		}
+	}
+	return precomp, realtime
+}
+
+// appendSample adds the sample, dropping the oldest beyond the window.
+func appendSample(samples []time.Duration, sample time.Duration,
+	window uint32) []time.Duration {
+	samples = append(samples, sample)
+	if excess := len(samples) - int(window); excess > 0 {
+		samples = append(samples[:0], samples[excess:]...)
+	}
+	return samples
+}
+
+// percentile returns the nearest-rank percentile of the samples.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,287 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// newTestRoundInfo returns the info of a completed round which precomputed and
+// ran realtime for the given durations, and the time realtime completed.
+func newTestRoundInfo(batchSize uint32, precomp, realtime time.Duration) (
+	*pb.RoundInfo, int64) {
+	start := time.Unix(1000, 0)
+	ts := make([]uint64, states.NUM_STATES)
+	ts[states.PRECOMPUTING] = uint64(start.UnixNano())
+	ts[states.STANDBY] = uint64(start.Add(precomp).UnixNano())
+	realtimeStart := start.Add(precomp + time.Second)
+	ts[states.REALTIME] = uint64(realtimeStart.UnixNano())
+	return &pb.RoundInfo{BatchSize: batchSize, Timestamps: ts},
+		realtimeStart.Add(realtime).UnixNano()
+}
+
+// Tests that the fixed timeout is used until enough rounds complete, after
+// which the timeout is the percentile with headroom, within the bounds.
+func TestAdaptiveTimeouts_timeout(t *testing.T) {
+	config := AdaptiveTimeouts{
+		Percentile:               90,
+		Headroom:                 2,
+		MinSamples:               10,
+		MinPrecomputationTimeout: 1000,
+		MaxPrecomputationTimeout: 60000,
+		MinRealtimeTimeout:       5000,
+		MaxRealtimeTimeout:       15000,
+	}
+	a := newAdaptiveTimeouts()
+	key := timeoutKey{batchSize: 32}
+	fixed := time.Minute
+
+	for i := 1; i <= 10; i++ {
+		if timeout := a.timeout(config, key, precompPhase, fixed); timeout != fixed {
+			t.Fatalf("Adaptive timeout %s used with %d samples", timeout, i-1)
+		}
+		roundInfo, realtimeTs := newTestRoundInfo(32,
+			time.Duration(i)*time.Second, time.Duration(i)*time.Second)
+		a.record(config, key, roundInfo, realtimeTs)
+	}
+
+	// The 90th percentile of 1s to 10s is 9s
+	if timeout := a.timeout(config, key, precompPhase, fixed); timeout != 18*time.Second {
+		t.Errorf("Unexpected precomputation timeout: %s", timeout)
+	}
+	// Bounded by the maximum realtime timeout
+	if timeout := a.timeout(config, key, realtimePhase, fixed); timeout != 15*time.Second {
+		t.Errorf("Unexpected realtime timeout: %s", timeout)
+	}
+	// Other batch sizes keep the fixed timeout
+	other := timeoutKey{batchSize: 64}
+	if timeout := a.timeout(config, other, precompPhase, fixed); timeout != fixed {
+		t.Errorf("Unexpected timeout for another batch size: %s", timeout)
+	}
+	// Disabling adaptive timeouts restores the fixed timeout
+	if timeout := a.timeout(AdaptiveTimeouts{}, key, precompPhase, fixed); timeout != fixed {
+		t.Errorf("Unexpected timeout while disabled: %s", timeout)
+	}
+}
+
+// Tests that rounds are keyed by geography when enabled, falling back to the
+// batch size while a geography has too few samples.
+func TestAdaptiveTimeouts_PerGeography(t *testing.T) {
+	config := AdaptiveTimeouts{
+		Percentile:               100,
+		Headroom:                 1,
+		MinSamples:               2,
+		PerGeography:             true,
+		MinPrecomputationTimeout: 1,
+		MaxPrecomputationTimeout: 600000,
+		MinRealtimeTimeout:       1,
+		MaxRealtimeTimeout:       600000,
+	}
+	a := newAdaptiveTimeouts()
+	europe := timeoutKey{batchSize: 32, geography: "WesternEurope"}
+	asia := timeoutKey{batchSize: 32, geography: "EasternAsia"}
+
+	for i := 0; i < 2; i++ {
+		roundInfo, realtimeTs := newTestRoundInfo(32, 10*time.Second, time.Second)
+		a.record(config, europe, roundInfo, realtimeTs)
+	}
+	roundInfo, realtimeTs := newTestRoundInfo(32, 30*time.Second, time.Second)
+	a.record(config, asia, roundInfo, realtimeTs)
+
+	if timeout := a.timeout(config, europe, precompPhase, time.Minute); timeout != 10*time.Second {
+		t.Errorf("Unexpected timeout for geography: %s", timeout)
+	}
+	// Asia has one sample, so the batch size's samples are used
+	if timeout := a.timeout(config, asia, precompPhase, time.Minute); timeout != 30*time.Second {
+		t.Errorf("Unexpected timeout for geography without samples: %s",
+			timeout)
+	}
+}
+
+// Tests that only the most recent rounds in the window are kept.
+func Test_appendSample(t *testing.T) {
+	var samples []time.Duration
+	for i := 1; i <= 5; i++ {
+		samples = appendSample(samples, time.Duration(i), 3)
+	}
+	if len(samples) != 3 || samples[0] != 3 || samples[2] != 5 {
+		t.Errorf("Unexpected samples: %v", samples)
+	}
+}
+
+// Tests the nearest-rank percentile.
+func Test_percentile(t *testing.T) {
+	samples := []time.Duration{5, 1, 4, 2, 3}
+	tests := map[float64]time.Duration{1: 1, 20: 1, 50: 3, 90: 5, 100: 5}
+	for p, expected := range tests {
+		if received := percentile(samples, p); received != expected {
+			t.Errorf("Percentile %f is %d, expected %d", p, received, expected)
+		}
+	}
+}
+
+// Tests that roundDurations reads the durations from the round timestamps.
+func Test_roundDurations(t *testing.T) {
+	roundInfo, realtimeTs := newTestRoundInfo(32, 3*time.Second, time.Second)
+	precomp, realtime := roundDurations(roundInfo, realtimeTs)
+	if precomp != 3*time.Second || realtime != time.Second {
+		t.Errorf("Unexpected durations: %s %s", precomp, realtime)
+	}
+
+	precomp, realtime = roundDurations(&pb.RoundInfo{}, 0)
+	if precomp != 0 || realtime != 0 {
+		t.Errorf("Unexpected durations without timestamps: %s %s", precomp,
+			realtime)
+	}
+}
+
+// Tests that the geography of a team is its distinct, sorted GeoBins.
+func Test_newTimeoutKey(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testState := setupNodeMap(t)
+	countries := []string{"US", "DE", "US", "CA"}
+	nodeList := make([]*id.ID, len(countries))
+	for i, country := range countries {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], country, "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	key := newTimeoutKey(testState, 32, connect.NewCircuit(nodeList))
+	if key.batchSize != 32 || key.geography != "CentralEurope,NorthAmerica" {
+		t.Errorf("Unexpected key: %+v", key)
+	}
+}
+
+// Tests that loadAdaptiveTimeouts keeps the durations of the completed rounds
+// in storage under their batch size and geography.
+func Test_loadAdaptiveTimeouts(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "",
+		"Test_loadAdaptiveTimeouts", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testState := setupNodeMap(t)
+	countries := []string{"US", "DE"}
+	topology := make([][]byte, len(countries))
+	nodeList := make([]*id.ID, len(countries))
+	for i, country := range countries {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		topology[i] = nodeList[i].Bytes()
+		err = storage.PermissioningDb.InsertApplication(
+			&storage.Application{Id: uint64(i + 1)},
+			&storage.Node{Code: nodeList[i].String(), Id: topology[i]})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+		err = testState.GetNodeMap().AddNode(nodeList[i], country, "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	start := time.Now().Add(-time.Hour)
+	for i := uint64(1); i <= 3; i++ {
+		precompStart := start.Add(time.Duration(i) * time.Minute)
+		realtimeStart := precompStart.Add(10 * time.Second)
+		metric := &storage.RoundMetric{
+			Id:            i,
+			PrecompStart:  precompStart,
+			PrecompEnd:    precompStart.Add(time.Duration(i) * time.Second),
+			RealtimeStart: realtimeStart,
+			RealtimeEnd:   realtimeStart.Add(time.Second),
+			RoundEnd:      realtimeStart.Add(time.Second),
+			BatchSize:     32,
+		}
+		// The last round failed before realtime completed
+		if i == 3 {
+			metric.RealtimeEnd = time.Unix(0, 0)
+		}
+		err = storage.PermissioningDb.InsertRoundMetric(metric, topology)
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	config := AdaptiveTimeouts{
+		Percentile:               100,
+		Headroom:                 1,
+		MinSamples:               2,
+		PerGeography:             true,
+		MinPrecomputationTimeout: 1,
+		MaxPrecomputationTimeout: 600000,
+		MinRealtimeTimeout:       1,
+		MaxRealtimeTimeout:       600000,
+	}
+	a, err := loadAdaptiveTimeouts(config, testState)
+	if err != nil {
+		t.Fatalf("Failed to load adaptive timeouts: %+v", err)
+	}
+
+	key := newTimeoutKey(testState, 32, connect.NewCircuit(nodeList))
+	for _, k := range []timeoutKey{key, {batchSize: 32}} {
+		if samples := a.samples[k]; samples == nil ||
+			len(samples.precomp) != 2 || len(samples.realtime) != 2 {
+			t.Errorf("Unexpected samples for %+v: %+v", k, samples)
+		}
+	}
+	if timeout := a.timeout(config, key, precompPhase, time.Minute); timeout != 2*time.Second {
+		t.Errorf("Unexpected timeout from loaded rounds: %s", timeout)
+	}
+
+	// Nothing is loaded while adaptive timeouts are disabled
+	a, err = loadAdaptiveTimeouts(AdaptiveTimeouts{}, testState)
+	if err != nil || len(a.samples) != 0 {
+		t.Errorf("Loaded rounds while disabled: %+v %+v", a.samples, err)
+	}
+}
+
+// Tests that an enabled config requires bounds and a valid percentile.
+func TestAdaptiveTimeouts_validate(t *testing.T) {
+	if problems := (AdaptiveTimeouts{}).validate(); len(problems) != 0 {
+		t.Errorf("Disabled config has problems: %v", problems)
+	}
+
+	valid := AdaptiveTimeouts{
+		Percentile:               95,
+		MinPrecomputationTimeout: 1000,
+		MaxPrecomputationTimeout: 60000,
+		MinRealtimeTimeout:       1000,
+		MaxRealtimeTimeout:       15000,
+	}
+	if problems := valid.validate(); len(problems) != 0 {
+		t.Errorf("Valid config has problems: %v", problems)
+	}
+
+	invalid := AdaptiveTimeouts{
+		Percentile:               101,
+		Headroom:                 0.5,
+		Window:                   5,
+		MinSamples:               10,
+		MinPrecomputationTimeout: 60000,
+		MaxPrecomputationTimeout: 1000,
+	}
+	if problems := invalid.validate(); len(problems) != 5 {
+		t.Errorf("Expected 5 problems, found %d: %v", len(problems), problems)
+	}
+}
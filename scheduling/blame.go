@@ -0,0 +1,173 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// blame.go contains the logic which attributes the failure of a round to the
+// members of its team which caused it, from the activity each member last
+// reported, when it reported it, and when it last polled.
+
+const (
+	// BlameUnresponsive blames a member which stopped polling during the round
+	BlameUnresponsive = "unresponsive"
+	// BlameBehind blames a member whose activity is behind the rest of its
+	// team
+	BlameBehind = "behind"
+	// BlameStale blames a member which, with its whole team at the same
+	// activity, has gone the longest without reporting a new activity
+	BlameStale = "stale"
+	// BlameReportedError blames the member which reported the round's error
+	// when no other member is at fault
+	BlameReportedError = "reported error"
+	// BlameBanned blames a member which was banned during the round
+	BlameBanned = "banned"
+
+	// How long a member may go without polling before it is unresponsive
+	blameUnresponsiveAfter = 30 * time.Second
+	// How much longer than the rest of its team a member may go without
+	// reporting a new activity before it is stale
+	blameStaleAfter = 5 * time.Second
+)
+
+// blameRound attributes the failure of the round and keeps the attribution on
+// the round. If the failure was already attributed, that attribution is
+// returned instead.
+func blameRound(state *storage.NetworkState, r *round.State,
+	roundError *pb.RoundError, now time.Time) []round.Blame {
+	if blame, blamed := r.GetBlame(); blamed {
+		return blame
+	}
+	blame := attributeBlame(state, r, roundError, now)
+	r.SetBlame(blame)
+	return blame
+}
+
+// attributeBlame returns the members of the round which caused it to fail. A
+// member which finished the round is past every phase, and one which left it
+// with an error is not compared. The first of these which applies is blamed:
+//
+//	the members still in the round which have not polled recently,
+//	the members still in the round behind the furthest member,
+//	the members still in the round whose last activity update is older than
+//	the newest by more than blameStaleAfter, i.e. those which did not keep up
+//	with the rest when the round timed out with its team at one activity,
+//	the member which reported the error.
+//
+// Nobody is blamed if none apply, such as when permissioning kills a round
+// whose team all moved to its activity together.
+func attributeBlame(state *storage.NetworkState, r *round.State,
+	roundError *pb.RoundError, now time.Time) []round.Blame {
+	topology := r.GetTopology()
+	members := make([]*node.State, 0, topology.Len())
+	inRound := make(map[*node.State]bool, topology.Len())
+	furthest := current.NOT_STARTED
+	for i := 0; i < topology.Len(); i++ {
+		n := state.GetNodeMap().GetNode(topology.GetNodeAtIndex(i))
+		if n == nil {
+			continue
+		}
+		members = append(members, n)
+
+		activity := n.GetActivity()
+		if activity == current.ERROR || activity == current.CRASH {
+			continue
+		}
+		hasRound, nodeRound := n.GetCurrentRound()
+		if hasRound && nodeRound.GetRoundID() == r.GetRoundID() {
+			inRound[n] = true
+		} else {
+			activity = current.COMPLETED
+		}
+		if activity > furthest {
+			furthest = activity
+		}
+	}
+
+	var unresponsive, behind []round.Blame
+	var newestUpdate time.Time
+	for _, n := range members {
+		if !inRound[n] {
+			continue
+		}
+		if n.GetLastPoll().Before(now.Add(-blameUnresponsiveAfter)) {
+			unresponsive = append(unresponsive, newBlame(n, BlameUnresponsive))
+		} else if n.GetActivity() < furthest {
+			behind = append(behind, newBlame(n, BlameBehind))
+		}
+		if lastUpdate := n.GetLastUpdate(); lastUpdate.After(newestUpdate) {
+			newestUpdate = lastUpdate
+		}
+	}
+
+	switch {
+	case len(unresponsive) > 0:
+		return unresponsive
+	case len(behind) > 0:
+		return behind
+	}
+
+	// The members still in the round are all at the furthest activity
+	var stale []round.Blame
+	for _, n := range members {
+		if inRound[n] &&
+			n.GetLastUpdate().Before(newestUpdate.Add(-blameStaleAfter)) {
+			stale = append(stale, newBlame(n, BlameStale))
+		}
+	}
+	if len(stale) > 0 {
+		return stale
+	}
+
+	if roundError != nil {
+		reporter, err := id.Unmarshal(roundError.NodeId)
+		if err == nil && topology.GetNodeLocation(reporter) != -1 {
+			if n := state.GetNodeMap().GetNode(reporter); n != nil {
+				return []round.Blame{newBlame(n, BlameReportedError)}
+			}
+		}
+	}
+	return nil
+}
+
+// newBlame blames the member for the reason, with the activity and times it
+// last reported.
+func newBlame(n *node.State, reason string) round.Blame {
+	return round.Blame{
+		NodeID:     n.GetID(),
+		Reason:     reason,
+		Activity:   n.GetActivity(),
+		LastUpdate: n.GetLastUpdate(),
+		LastPoll:   n.GetLastPoll(),
+	}
+}
+
+// blameRecords returns the blame as it is kept in storage, for a round which
+// failed at the timestamp.
+func blameRecords(blame []round.Blame, failed time.Time) []*storage.RoundBlame {
+	records := make([]*storage.RoundBlame, len(blame))
+	for i, b := range blame {
+		records[i] = &storage.RoundBlame{
+			NodeId:     b.NodeID.Marshal(),
+			Reason:     b.Reason,
+			Activity:   b.Activity.String(),
+			LastUpdate: b.LastUpdate,
+			LastPoll:   b.LastPoll,
+			Timestamp:  failed,
+		}
+	}
+	return records
+}
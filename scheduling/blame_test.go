@@ -0,0 +1,192 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// newBlameTestRound returns a precomputing round and its team of the given
+// size, every member of which has polled at now.
+func newBlameTestRound(t *testing.T, size int, now time.Time) (
+	*storage.NetworkState, *round.State, []*node.State) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testState := setupNodeMap(t)
+	nodes := make([]*node.State, size)
+	ids := make([]*id.ID, size)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		ids[i] = nodes[i].GetID()
+	}
+	r := round.NewState_Testing(42, states.PRECOMPUTING,
+		connect.NewCircuit(ids), t)
+
+	for _, n := range nodes {
+		if _, _, err = n.Update(current.WAITING); err != nil {
+			t.Fatalf("Failed to update node: %+v", err)
+		}
+		if err = n.SetRound(r); err != nil {
+			t.Fatalf("Failed to set round: %+v", err)
+		}
+		if _, _, err = n.Update(current.PRECOMPUTING); err != nil {
+			t.Fatalf("Failed to update node: %+v", err)
+		}
+		n.SetLastPoll(now, t)
+	}
+	return testState, r, nodes
+}
+
+// Tests that the members behind their team are blamed, and that nobody is
+// blamed for a permissioning error while the team is at the same activity.
+func Test_attributeBlame_Behind(t *testing.T) {
+	now := time.Now()
+	testState, r, nodes := newBlameTestRound(t, 3, now)
+	timeoutError := &pb.RoundError{NodeId: id.Permissioning.Marshal()}
+
+	if blame := attributeBlame(testState, r, timeoutError, now); len(blame) != 0 {
+		t.Errorf("Blamed a team at the same activity: %+v", blame)
+	}
+
+	for _, n := range nodes[1:] {
+		if _, _, err := n.Update(current.STANDBY); err != nil {
+			t.Fatalf("Failed to update node: %+v", err)
+		}
+	}
+	blame := attributeBlame(testState, r, timeoutError, now)
+	if len(blame) != 1 || !blame[0].NodeID.Cmp(nodes[0].GetID()) ||
+		blame[0].Reason != BlameBehind ||
+		blame[0].Activity != current.PRECOMPUTING {
+		t.Errorf("Unexpected blame: %+v", blame)
+	}
+}
+
+// Tests that, with the whole team at the same activity, the member which last
+// updated its activity long before the rest is blamed.
+func Test_attributeBlame_Stale(t *testing.T) {
+	now := time.Now()
+	testState, r, nodes := newBlameTestRound(t, 3, now)
+	timeoutError := &pb.RoundError{NodeId: id.Permissioning.Marshal()}
+	for _, n := range nodes {
+		n.SetLastUpdate(now.Add(-time.Second), t)
+	}
+	nodes[1].SetLastUpdate(now.Add(-time.Minute), t)
+
+	blame := attributeBlame(testState, r, timeoutError, now)
+	if len(blame) != 1 || !blame[0].NodeID.Cmp(nodes[1].GetID()) ||
+		blame[0].Reason != BlameStale ||
+		blame[0].Activity != current.PRECOMPUTING ||
+		!blame[0].LastUpdate.Equal(now.Add(-time.Minute)) {
+		t.Errorf("Unexpected blame: %+v", blame)
+	}
+
+	// Members behind are blamed before stale ones
+	if _, _, err := nodes[1].Update(current.STANDBY); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	nodes[1].SetLastUpdate(now.Add(-time.Minute), t)
+	blame = attributeBlame(testState, r, timeoutError, now)
+	if len(blame) != 2 || blame[0].Reason != BlameBehind {
+		t.Errorf("Unexpected blame with members behind: %+v", blame)
+	}
+}
+
+// Tests that members which stopped polling are blamed before those behind.
+func Test_attributeBlame_Unresponsive(t *testing.T) {
+	now := time.Now()
+	testState, r, nodes := newBlameTestRound(t, 3, now)
+	if _, _, err := nodes[2].Update(current.STANDBY); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	nodes[1].SetLastPoll(now.Add(-2*blameUnresponsiveAfter), t)
+
+	blame := attributeBlame(testState, r, nil, now)
+	if len(blame) != 1 || !blame[0].NodeID.Cmp(nodes[1].GetID()) ||
+		blame[0].Reason != BlameUnresponsive {
+		t.Errorf("Unexpected blame: %+v", blame)
+	}
+}
+
+// Tests that the member which reported the error is blamed when no other
+// member is at fault, and that the members which finished are not behind.
+func Test_attributeBlame_ReportedError(t *testing.T) {
+	now := time.Now()
+	testState, r, nodes := newBlameTestRound(t, 3, now)
+
+	if _, _, err := nodes[0].Update(current.ERROR); err != nil {
+		t.Fatalf("Failed to update node: %+v", err)
+	}
+	nodes[0].ClearRound()
+	reportedError := &pb.RoundError{NodeId: nodes[0].GetID().Marshal()}
+
+	blame := attributeBlame(testState, r, reportedError, now)
+	if len(blame) != 1 || !blame[0].NodeID.Cmp(nodes[0].GetID()) ||
+		blame[0].Reason != BlameReportedError ||
+		blame[0].Activity != current.ERROR {
+		t.Errorf("Unexpected blame: %+v", blame)
+	}
+
+	// A member which left the round without an error has finished it, so
+	// those still in it are behind
+	nodes[2].ClearRound()
+	blame = attributeBlame(testState, r, reportedError, now)
+	if len(blame) != 1 || !blame[0].NodeID.Cmp(nodes[1].GetID()) ||
+		blame[0].Reason != BlameBehind {
+		t.Errorf("Unexpected blame after a member finished: %+v", blame)
+	}
+}
+
+// Tests that blameRound keeps the first attribution of a round's failure.
+func Test_blameRound(t *testing.T) {
+	now := time.Now()
+	testState, r, nodes := newBlameTestRound(t, 2, now)
+	nodes[0].SetLastPoll(now.Add(-2*blameUnresponsiveAfter), t)
+
+	first := blameRound(testState, r, nil, now)
+	if len(first) != 1 || first[0].Reason != BlameUnresponsive {
+		t.Fatalf("Unexpected blame: %+v", first)
+	}
+
+	nodes[0].SetLastPoll(now, t)
+	if again := blameRound(testState, r, nil, now); len(again) != 1 ||
+		!again[0].NodeID.Cmp(first[0].NodeID) {
+		t.Errorf("Attribution changed: %+v", again)
+	}
+
+	// A round attributed to nobody stays attributed to nobody
+	r = round.NewState_Testing(43, states.PRECOMPUTING, r.GetTopology(), t)
+	r.SetBlame(nil)
+	if blame := blameRound(testState, r, nil, now); len(blame) != 0 {
+		t.Errorf("Round blamed after being attributed to nobody: %+v", blame)
+	}
+}
+
+// Tests that blame is converted to the records kept in storage.
+func Test_blameRecords(t *testing.T) {
+	failed := time.Unix(1000, 0)
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	records := blameRecords([]round.Blame{{NodeID: nid,
+		Reason: BlameBehind, Activity: current.STANDBY}}, failed)
+	if len(records) != 1 || string(records[0].NodeId) != string(nid.Marshal()) ||
+		records[0].Activity != "STANDBY" || !records[0].Timestamp.Equal(failed) {
+		t.Errorf("Unexpected records: %+v", records)
+	}
+}
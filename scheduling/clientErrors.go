@@ -0,0 +1,79 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/primitives/id"
+	"strings"
+	"time"
+)
+
+// clientErrors.go contains the conversion of the client errors gateways report
+// through their nodes' polls into the records kept in storage.
+
+const (
+	// Type of client errors which have no message
+	unknownClientErrorType = "unknown"
+	// Longest type of a client error kept, in bytes
+	maxClientErrorTypeLen = 128
+	// Longest client error kept, in bytes
+	maxClientErrorLen = 1024
+)
+
+// clientErrorType returns the type of the client error, which is its message
+// up to the first colon or line break. Details such as IDs and the wrapped
+// cause follow these, so errors of the same type share it.
+func clientErrorType(message string) string {
+	if i := strings.IndexAny(message, ":\n"); i != -1 {
+		message = message[:i]
+	}
+	message = strings.TrimSpace(message)
+	if len(message) > maxClientErrorTypeLen {
+		message = message[:maxClientErrorTypeLen]
+	}
+	if message == "" {
+		return unknownClientErrorType
+	}
+	return message
+}
+
+// clientErrorRecords returns the client errors reported by the node on the
+// network as they are kept in storage. Errors which do not name the gateway
+// they came from are attributed to the reporting node's gateway, and errors
+// longer than maxClientErrorLen are truncated.
+func clientErrorRecords(network string, reporter *id.ID,
+	clientErrors []*pb.ClientError, received time.Time) []*storage.RoundClientError {
+	gateway := reporter.DeepCopy()
+	gateway.SetType(id.Gateway)
+
+	records := make([]*storage.RoundClientError, 0, len(clientErrors))
+	for _, clientError := range clientErrors {
+		if clientError == nil {
+			continue
+		}
+		source := clientError.Source
+		if len(source) == 0 {
+			source = gateway.Marshal()
+		}
+		message := clientError.Error
+		if len(message) > maxClientErrorLen {
+			message = message[:maxClientErrorLen]
+		}
+		records = append(records, &storage.RoundClientError{
+			Network:   network,
+			GatewayId: source,
+			ClientId:  clientError.ClientId,
+			ErrorType: clientErrorType(clientError.Error),
+			Error:     message,
+			Timestamp: received,
+		})
+	}
+	return records
+}
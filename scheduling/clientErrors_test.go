@@ -0,0 +1,77 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"bytes"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/xx_network/primitives/id"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests that client errors of the same type share it regardless of details.
+func Test_clientErrorType(t *testing.T) {
+	tests := map[string]string{
+		"failed to decrypt: cipher: message authentication failed": "failed to decrypt",
+		"  round 12 not found\nstack trace":                        "round 12 not found",
+		"no details":                                               "no details",
+		"":                                                         unknownClientErrorType,
+		": missing prefix":                                         unknownClientErrorType,
+		strings.Repeat("a", 200):                                   strings.Repeat("a", maxClientErrorTypeLen),
+	}
+	for message, expected := range tests {
+		if errorType := clientErrorType(message); errorType != expected {
+			t.Errorf("Type of %q is %q, expected %q", message, errorType,
+				expected)
+		}
+	}
+}
+
+// Tests that client errors are attributed to the gateway which sent them, or
+// else to the reporting node's gateway.
+func Test_clientErrorRecords(t *testing.T) {
+	reporter := id.NewIdFromUInt(0, id.Node, t)
+	source := id.NewIdFromUInt(1, id.Gateway, t)
+	client := id.NewIdFromUInt(2, id.User, t)
+	received := time.Unix(1000, 0)
+
+	long := "failed: " + strings.Repeat("a", 2*maxClientErrorLen)
+	records := clientErrorRecords("testnet", reporter, []*pb.ClientError{
+		{ClientId: client.Marshal(), Error: "failed: reason",
+			Source: source.Marshal()},
+		nil,
+		{ClientId: client.Marshal(), Error: "failed: other reason"},
+		{ClientId: client.Marshal(), Error: long},
+	}, received)
+
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, found %d", len(records))
+	}
+	if records[2].Error != long[:maxClientErrorLen] {
+		t.Errorf("Error not truncated to %d bytes: %d bytes",
+			maxClientErrorLen, len(records[2].Error))
+	}
+	if !bytes.Equal(records[0].GatewayId, source.Marshal()) {
+		t.Errorf("Error not attributed to its source: %+v", records[0])
+	}
+	gateway := reporter.DeepCopy()
+	gateway.SetType(id.Gateway)
+	if !bytes.Equal(records[1].GatewayId, gateway.Marshal()) {
+		t.Errorf("Error not attributed to the reporter's gateway: %+v",
+			records[1])
+	}
+	for _, record := range records {
+		if record.ErrorType != "failed" || record.Network != "testnet" ||
+			!bytes.Equal(record.ClientId, client.Marshal()) ||
+			!record.Timestamp.Equal(received) {
+			t.Errorf("Unexpected record: %+v", record)
+		}
+	}
+}
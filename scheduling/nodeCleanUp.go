@@ -0,0 +1,86 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"time"
+)
+
+// nodeCleanUp.go contains the logic which finds nodes that stopped polling
+// while waiting to be scheduled and moves them out of the waiting pool, so that
+// they are not picked into teams that would then time out.
+
+// cleanUpTicker ticks every NodeCleanUpInterval. Its channel is nil, and so
+// never fires, while clean up is disabled by an interval of zero.
+type cleanUpTicker struct {
+	ticker   *time.Ticker
+	interval time.Duration
+}
+
+// newCleanUpTicker starts a ticker for the interval, in milliseconds.
+func newCleanUpTicker(interval time.Duration) *cleanUpTicker {
+	c := &cleanUpTicker{interval: interval}
+	c.start()
+	return c
+}
+
+// C returns the channel the ticks are sent on.
+func (c *cleanUpTicker) C() <-chan time.Time {
+	if c.ticker == nil {
+		return nil
+	}
+	return c.ticker.C
+}
+
+// reset restarts the ticker if the interval, in milliseconds, has changed.
+func (c *cleanUpTicker) reset(interval time.Duration) {
+	if interval == c.interval {
+		return
+	}
+	c.stop()
+	c.interval = interval
+	c.start()
+}
+
+// start starts the ticker unless the interval is zero.
+func (c *cleanUpTicker) start() {
+	if c.interval == 0 {
+		jww.WARN.Printf("NodeCleanUpInterval is not set, nodes which " +
+			"stop polling will remain in the waiting pool")
+		return
+	}
+	c.ticker = time.NewTicker(c.interval * time.Millisecond)
+}
+
+// stop stops the ticker.
+func (c *cleanUpTicker) stop() {
+	if c.ticker != nil {
+		c.ticker.Stop()
+		c.ticker = nil
+	}
+}
+
+// cleanUpOfflineNodes moves the nodes in the pool which have not polled in
+// timeToInactive to the offline pool, sets them inactive, and records the
+// disconnect in their reputation. A node returns to the pool once it polls as
+// waiting again.
+func cleanUpOfflineNodes(pool *waitingPool, rep *reputation,
+	config Reputation, now time.Time) {
+	offline := pool.SetNodesToOffline(now.Add(-timeToInactive))
+	for _, n := range offline {
+		rep.disconnected(config, n.GetID(), now)
+		jww.WARN.Printf("Node %s has not polled since %s, it will not be "+
+			"scheduled until it polls again", n.GetID(),
+			n.GetLastPoll().Format(time.RFC3339))
+	}
+	if len(offline) > 0 {
+		jww.INFO.Printf("Moved %d nodes to the offline pool, %d nodes are "+
+			"offline", len(offline), pool.OfflineLen())
+	}
+}
@@ -0,0 +1,113 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"testing"
+	"time"
+)
+
+// Tests that a node which stops polling while waiting is moved to the offline
+// pool and returns to the online pool once it polls as waiting again.
+func Test_cleanUpOfflineNodes(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testState := setupNodeMap(t)
+	testPool := NewWaitingPool()
+	stale := setupNode(t, testState, 0)
+	fresh := setupNode(t, testState, 1)
+	testPool.Add(stale)
+	testPool.Add(fresh)
+
+	now := time.Now()
+	stale.SetLastPoll(now.Add(-2*timeToInactive), t)
+	fresh.SetLastPoll(now, t)
+
+	rep := newReputation("")
+	cleanUpOfflineNodes(testPool, rep, Reputation{}, now)
+
+	if testPool.Len() != 1 || testPool.OfflineLen() != 1 {
+		t.Fatalf("Expected 1 online and 1 offline node, found %d and %d",
+			testPool.Len(), testPool.OfflineLen())
+	}
+	if stale.GetStatus() != node.Inactive || fresh.GetStatus() != node.Active {
+		t.Errorf("Unexpected node statuses %s and %s", stale.GetStatus(),
+			fresh.GetStatus())
+	}
+
+	// Polling as waiting reactivates the node
+	updated, update, err := stale.Update(current.WAITING)
+	if err != nil || !updated {
+		t.Fatalf("Inactive node failed to update: %t %+v", updated, err)
+	}
+
+	sc := &stateChanger{
+		pool:         testPool,
+		state:        testState,
+		roundTracker: NewRoundTracker(),
+		timeouts:     newTimeoutManager(time.Now, newStdTimer),
+		pacer:        newPacer(),
+		reputation:   rep,
+	}
+	stale.GetPollingLock().Lock()
+	if err = sc.HandleNodeUpdates(update); err != nil {
+		t.Fatalf("Failed to handle update: %+v", err)
+	}
+
+	if testPool.Len() != 2 || testPool.OfflineLen() != 0 {
+		t.Errorf("Expected 2 online and 0 offline nodes, found %d and %d",
+			testPool.Len(), testPool.OfflineLen())
+	}
+	if stale.GetStatus() != node.Active {
+		t.Errorf("Node not reactivated: %s", stale.GetStatus())
+	}
+
+	// The disconnect is kept in the node's reputation and it is back online
+	nr := rep.nodes[*stale.GetID()]
+	if nr == nil || nr.Disconnects != 1 || !nr.OfflineSince.IsZero() {
+		t.Errorf("Disconnect not recorded in reputation: %+v", nr)
+	}
+	if _, exists := rep.nodes[*fresh.GetID()]; exists {
+		t.Errorf("Reputation recorded for node which did not disconnect")
+	}
+}
+
+// Tests that cleanUpTicker only ticks while the interval is set and restarts
+// when the interval changes.
+func TestCleanUpTicker_reset(t *testing.T) {
+	c := newCleanUpTicker(0)
+	defer c.stop()
+	if c.C() != nil {
+		t.Fatalf("Ticker started with no interval.")
+	}
+
+	c.reset(5)
+	select {
+	case <-c.C():
+	case <-time.After(time.Second):
+		t.Fatalf("Ticker did not tick after the interval was set.")
+	}
+
+	ticker := c.ticker
+	c.reset(5)
+	if c.ticker != ticker {
+		t.Errorf("Ticker restarted for an unchanged interval.")
+	}
+
+	c.reset(0)
+	if c.C() != nil {
+		t.Errorf("Ticker not stopped when the interval was unset.")
+	}
+}
@@ -29,15 +29,100 @@ type stateChanger struct {
 	realtimeDelay time.Duration
 	realtimeDelta time.Duration
 
+	precompTimeout  time.Duration
 	realtimeTimeout time.Duration
 
+	// Durations of completed rounds the timeouts may be adapted to
+	adaptive       *adaptiveTimeouts
+	adaptiveConfig AdaptiveTimeouts
+
+	// Timeouts of the round classes which set their own, by name
+	classPrecompTimeouts  map[string]time.Duration
+	classRealtimeTimeouts map[string]time.Duration
+
+	// Spaces realtime starts in place of realtimeDelta if enabled
+	pacer  *pacer
+	pacing Pacing
+
+	// Reputation of the nodes, updated from the outcomes of their rounds
+	reputation       *reputation
+	reputationConfig Reputation
+
 	pool *waitingPool
 
 	state *storage.NetworkState
 
 	roundTracker *RoundTracker
 
-	roundTimeoutChan chan id.Round
+	timeouts *timeoutManager
+}
+
+// setParams updates the realtime spacing and timeouts used by the
+// stateChanger to the values in the passed in params.
+func (sc *stateChanger) setParams(params Params) {
+	sc.realtimeDelay = params.RealtimeDelay * time.Millisecond
+	sc.realtimeDelta = params.MinimumDelay * time.Millisecond
+	sc.precompTimeout = params.PrecomputationTimeout * time.Millisecond
+	sc.realtimeTimeout = params.RealtimeTimeout * time.Millisecond
+	sc.adaptiveConfig = params.AdaptiveTimeouts
+	sc.pacing = params.Pacing
+	sc.reputationConfig = params.Reputation
+	sc.classPrecompTimeouts = make(map[string]time.Duration)
+	sc.classRealtimeTimeouts = make(map[string]time.Duration)
+	for _, rc := range params.RoundClasses {
+		if rc.PrecomputationTimeout != 0 {
+			sc.classPrecompTimeouts[rc.Name] = rc.PrecomputationTimeout * time.Millisecond
+		}
+		if rc.RealtimeTimeout != 0 {
+			sc.classRealtimeTimeouts[rc.Name] = rc.RealtimeTimeout * time.Millisecond
+		}
+	}
+}
+
+// roundPrecompTimeout returns the precomputation timeout of the round's class,
+// adapted to the durations of rounds like it if adaptive timeouts are enabled.
+func (sc *stateChanger) roundPrecompTimeout(r *round.State) time.Duration {
+	fixed, exists := sc.classPrecompTimeouts[r.GetClass()]
+	if !exists {
+		fixed = sc.precompTimeout
+	}
+	return sc.adaptTimeout(r, precompPhase, fixed)
+}
+
+// roundRealtimeTimeout returns the realtime timeout of the round's class,
+// adapted to the durations of rounds like it if adaptive timeouts are enabled.
+func (sc *stateChanger) roundRealtimeTimeout(r *round.State) time.Duration {
+	fixed, exists := sc.classRealtimeTimeouts[r.GetClass()]
+	if !exists {
+		fixed = sc.realtimeTimeout
+	}
+	return sc.adaptTimeout(r, realtimePhase, fixed)
+}
+
+// adaptTimeout returns the fixed timeout of the phase, or one adapted to the
+// durations of rounds like the round if adaptive timeouts are enabled.
+func (sc *stateChanger) adaptTimeout(r *round.State, phase timeoutPhase,
+	fixed time.Duration) time.Duration {
+	if !sc.adaptiveConfig.enabled() {
+		return fixed
+	}
+	key := newTimeoutKey(sc.state, r.BuildRoundInfo().BatchSize,
+		r.GetTopology())
+	return sc.adaptive.timeout(sc.adaptiveConfig, key, phase, fixed)
+}
+
+// endFailedRound cancels the timeout of a failed round and records the failure
+// against its team. A round which failed once queued for realtime is reported
+// to the pacer.
+func (sc *stateChanger) endFailedRound(r *round.State) {
+	phase, exists := endRound(sc.state, sc.timeouts, r)
+	if !exists {
+		return
+	}
+	sc.reputation.failed(sc.reputationConfig, r.GetTopology(), time.Now())
+	if phase.queued() {
+		sc.pacer.failed(sc.pacing, sc.realtimeDelta, time.Now())
+	}
 }
 
 // HandleNodeUpdates handles the node state changes.
@@ -49,6 +134,10 @@ type stateChanger struct {
 func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error {
 	// Check the round's error state
 	n := sc.state.GetNodeMap().GetNode(update.Node)
+	if n == nil {
+		return failNode(update.Node, errors.Errorf("Node %s is not in the "+
+			"node map", update.Node))
+	}
 	// when a node poll is received, the nodes polling lock is taken.  If there
 	// is no update, it is released in the endpoint, otherwise it is released
 	// here which blocks all future polls until processing completes
@@ -63,8 +152,24 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 		update.ToActivity = current.ERROR
 	}
 
-	if update.ClientErrors != nil && len(update.ClientErrors) > 0 {
-		r.AppendClientErrors(update.ClientErrors)
+	if len(update.ClientErrors) > 0 {
+		if hasRound {
+			r.AppendClientErrors(update.ClientErrors)
+			roundID := r.GetRoundID()
+			records := clientErrorRecords(sc.state.GetNetwork(), update.Node,
+				update.ClientErrors, time.Now())
+			sc.roundTracker.StoreAsync(func() {
+				err := storage.PermissioningDb.InsertClientErrors(roundID,
+					records)
+				if err != nil {
+					jww.WARN.Printf("Could not insert client errors for "+
+						"round %d: %+v", roundID, err)
+				}
+			})
+		} else {
+			jww.WARN.Printf("Dropping %d client errors reported by %s "+
+				"outside of a round", len(update.ClientErrors), update.Node)
+		}
 	}
 	//ban the node if it is supposed to be banned
 	if update.ToStatus == node.Banned {
@@ -74,11 +179,14 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 				NodeId: id.Permissioning.Marshal(),
 				Error:  fmt.Sprintf("Round killed due to particiption of banned node %s", update.Node),
 			}
+			n.ClearRound()
+			r.SetBlame([]round.Blame{newBlame(n, BlameBanned)})
 			err := signature.SignRsa(banError, sc.state.GetPrivateKey())
 			if err != nil {
-				return errors.Errorf("Failed to sign error message for banned node %s: %+v", update.Node, err)
+				return failRound(r, errors.Errorf("Failed to sign error "+
+					"message for banned node %s: %+v", update.Node, err))
 			}
-			n.ClearRound()
+			sc.endFailedRound(r)
 			return killRound(sc.state, r, banError, sc.roundTracker)
 		} else {
 			sc.pool.Ban(n)
@@ -86,14 +194,46 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 		}
 	}
 
+	// Drain mode and eligibility changes do not change the node's activity,
+	// they only move it out of or back into the pool
+	if update.DrainChange || update.EligibilityChange {
+		eligible := sc.state.RefreshEligibility(n)
+		if n.IsDraining() {
+			jww.INFO.Printf("Node %s is draining, it will not be "+
+				"scheduled into new rounds", update.Node)
+			sc.pool.Remove(n)
+		} else if !eligible {
+			jww.INFO.Printf("Node %s is not eligible, it will not be "+
+				"scheduled into new rounds", update.Node)
+			sc.pool.Remove(n)
+		} else {
+			jww.INFO.Printf("Node %s is ready to be scheduled", update.Node)
+			if update.ToActivity == current.WAITING &&
+				update.ToStatus == node.Active && !hasRound {
+				sc.pool.Add(n)
+			}
+		}
+		return nil
+	}
+
 	//get node and round information
 	switch update.ToActivity {
 	case current.NOT_STARTED:
 		// Do nothing
 	case current.WAITING:
+		// A draining or ineligible node is kept out of the pool until it can
+		// be scheduled again
+		if !sc.state.RefreshEligibility(n) || !n.IsSchedulable() {
+			jww.INFO.Printf("Node %s is waiting but cannot be scheduled",
+				update.Node)
+			sc.pool.Remove(n)
+			break
+		}
+
 		// If the node was in the offline pool, set it to online
 		//  (which also adds it to the online pool)
 		if update.FromStatus == node.Inactive && update.ToStatus == node.Active {
+			sc.reputation.connected(n.GetID(), time.Now())
 			sc.pool.SetNodeToOnline(n)
 		} else {
 			// Otherwise, add it to the online pool normally
@@ -103,15 +243,22 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 	case current.PRECOMPUTING:
 		// Check that node in precomputing does have a round
 		if !hasRound {
-			return errors.Errorf("Node %s without round should "+
-				"not be moving to the %s state", update.Node, states.PRECOMPUTING)
+			return failNode(update.Node, errors.Errorf("Node %s without round should "+
+				"not be moving to the %s state", update.Node, states.PRECOMPUTING))
+		}
+		// This ends the resource queue timeout once every node has left its
+		// resource queue. The round must then finish precomputation within
+		// the precomputation timeout.
+		if r.NodeBeganPrecomputing() && r.GetRoundState() == states.PRECOMPUTING {
+			sc.timeouts.set(r.GetRoundID(), precompPhase,
+				sc.roundPrecompTimeout(r))
 		}
 
 	case current.STANDBY:
 		// Check that node in standby actually does have a round
 		if !hasRound {
-			return errors.Errorf("Node %s without round should "+
-				"not be in %s state", update.Node, states.PRECOMPUTING)
+			return failNode(update.Node, errors.Errorf("Node %s without round should "+
+				"not be in %s state", update.Node, states.PRECOMPUTING))
 		}
 		// Check if the round is ready for all the nodes
 		// in order to transition
@@ -121,48 +268,50 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			err := r.Update(states.STANDBY, time.Now())
 
 			if err != nil {
-				return errors.WithMessagef(err,
+				return failRound(r, errors.WithMessagef(err,
 					"Could not move round %v from %s to %s",
-					r.GetRoundID(), states.PRECOMPUTING, states.STANDBY)
+					r.GetRoundID(), states.PRECOMPUTING, states.STANDBY))
 			}
 
-			// This signals the end of the precomp timeout,
-			// followed by initiating the realtime timeout.
-			r.DenoteRoundCompleted()
-			go waitForRoundTimeout(sc.roundTimeoutChan, sc.state, r,
-				sc.realtimeTimeout, true)
-
-			startTime := time.Now().Add(sc.realtimeDelay)
-			nextRoundMinimum := sc.lastRealtime.Add(sc.realtimeDelta)
+			now := time.Now()
+			startTime := now.Add(sc.realtimeDelay)
+			nextRoundMinimum := sc.lastRealtime.Add(
+				sc.pacer.realtimeSpacing(sc.pacing, sc.realtimeDelta))
 			if nextRoundMinimum.After(startTime) {
 				startTime = nextRoundMinimum
 			}
 
 			sc.lastRealtime = startTime
+			sc.pacer.schedule(startTime, now)
+
+			// This ends the precomp timeout. The round must start and
+			// finish realtime within the realtime timeout of its start.
+			sc.timeouts.set(r.GetRoundID(), standbyPhase,
+				time.Until(startTime)+sc.roundRealtimeTimeout(r))
 
 			// Update the round for realtime transition
 			err = r.Update(states.QUEUED, startTime)
 
 			if err != nil {
-				return errors.WithMessagef(err,
+				return failRound(r, errors.WithMessagef(err,
 					"Could not move round %v from %s to %s",
-					r.GetRoundID(), states.STANDBY, states.QUEUED)
+					r.GetRoundID(), states.STANDBY, states.QUEUED))
 			}
 
 			// Build the round info and add to the networkState
 			err = sc.state.AddRoundUpdate(r.BuildRoundInfo())
 			if err != nil {
-				return errors.WithMessagef(err, "Could not issue "+
+				return failRound(r, errors.WithMessagef(err, "Could not issue "+
 					"update for round %v transitioning from %s to %s",
-					r.GetRoundID(), states.STANDBY, states.QUEUED)
+					r.GetRoundID(), states.STANDBY, states.QUEUED))
 			}
 
 		}
 	case current.REALTIME:
 		// Check that node in standby actually does have a round
 		if !hasRound {
-			return errors.Errorf("Node %s without round should "+
-				"not be moving to the %s state", update.Node, states.REALTIME)
+			return failNode(update.Node, errors.Errorf("Node %s without round should "+
+				"not be moving to the %s state", update.Node, states.REALTIME))
 		}
 		// REALTIME does not use the state complete handler because it
 		// increments on the first report, not when every node reports in
@@ -172,16 +321,19 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			err := r.Update(states.REALTIME, time.Now())
 
 			if err != nil {
-				return errors.WithMessagef(err,
+				return failRound(r, errors.WithMessagef(err,
 					"Could not move round %v from %s to %s",
-					r.GetRoundID(), states.QUEUED, states.REALTIME)
+					r.GetRoundID(), states.QUEUED, states.REALTIME))
 			}
+
+			sc.timeouts.set(r.GetRoundID(), realtimePhase,
+				sc.roundRealtimeTimeout(r))
 		}
 	case current.COMPLETED:
 		// Check that node in standby actually does have a round
 		if !hasRound {
-			return errors.Errorf("Node %s without round should "+
-				"not be in %s state", update.Node, states.COMPLETED)
+			return failNode(update.Node, errors.Errorf("Node %s without round should "+
+				"not be in %s state", update.Node, states.COMPLETED))
 		}
 
 		// Clear the round
@@ -200,26 +352,43 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			// Update the round for realtime transition
 			err := r.Update(states.COMPLETED, time.Now())
 			if err != nil {
-				return errors.WithMessagef(err,
+				return failRound(r, errors.WithMessagef(err,
 					"Could not move round %v from %s to %s",
-					r.GetRoundID(), states.REALTIME, states.COMPLETED)
+					r.GetRoundID(), states.REALTIME, states.COMPLETED))
 			}
 
 			// Build the round info and add to the networkState
 			roundInfo := r.BuildRoundInfo()
 			err = sc.state.AddRoundUpdate(roundInfo)
 			if err != nil {
-				return errors.WithMessagef(err, "Could not issue "+
+				return failRound(r, errors.WithMessagef(err, "Could not issue "+
 					"update for round %v transitioning from %s to %s",
-					r.GetRoundID(), states.REALTIME, states.COMPLETED)
+					r.GetRoundID(), states.REALTIME, states.COMPLETED))
 			}
 
-			// Signal the round as completed to disable the timeout
-			r.DenoteRoundCompleted()
+			// Cancel the timeout of the completed round
+			endRound(sc.state, sc.timeouts, r)
 			sc.roundTracker.RemoveActiveRound(r.GetRoundID())
 
 			// Store round metric in another thread for completed round
-			go StoreRoundMetric(roundInfo, r.GetRoundState(), r.GetRealtimeCompletedTs())
+			realtimeCompletedTs := r.GetRealtimeCompletedTs()
+			_, realtimeDuration := roundDurations(roundInfo,
+				realtimeCompletedTs)
+			sc.pacer.completed(sc.pacing, sc.realtimeDelta, realtimeDuration,
+				time.Now())
+			sc.reputation.completed(sc.reputationConfig, r.GetTopology(),
+				time.Now())
+			if sc.adaptiveConfig.enabled() {
+				key := newTimeoutKey(sc.state, roundInfo.BatchSize,
+					r.GetTopology())
+				sc.adaptive.record(sc.adaptiveConfig, key, roundInfo,
+					realtimeCompletedTs)
+			}
+			roundEnd := r.GetRoundState()
+			sc.roundTracker.StoreAsync(func() {
+				StoreRoundMetric(sc.state.GetNetwork(), roundInfo, roundEnd,
+					realtimeCompletedTs)
+			})
 
 			// Commit metrics about the round to storage
 			return nil
@@ -232,8 +401,8 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 			// Clear the round from the node state
 			n.ClearRound()
 
-			// Signal the round as completed to disable the timeout
-			r.DenoteRoundCompleted()
+			// Cancel the timeout of the failed round
+			sc.endFailedRound(r)
 
 			// Fail the round and make accompanying round state updates
 			err = killRound(sc.state, r, update.Error, sc.roundTracker)
@@ -244,8 +413,9 @@ func (sc *stateChanger) HandleNodeUpdates(update node.UpdateNotification) error
 	return nil
 }
 
-// Insert metrics about the newly-completed round into storage
-func StoreRoundMetric(roundInfo *pb.RoundInfo, roundEnd states.Round, realtimeTs int64) {
+// Insert metrics about the newly-completed round on the network into storage
+func StoreRoundMetric(network string, roundInfo *pb.RoundInfo,
+	roundEnd states.Round, realtimeTs int64) {
 	metric := &storage.RoundMetric{
 		Id:            roundInfo.ID,
 		PrecompStart:  time.Unix(0, int64(roundInfo.Timestamps[states.PRECOMPUTING])),
@@ -254,10 +424,10 @@ func StoreRoundMetric(roundInfo *pb.RoundInfo, roundEnd states.Round, realtimeTs
 		RealtimeEnd:   time.Unix(0, realtimeTs),
 		RoundEnd:      time.Unix(0, int64(roundInfo.Timestamps[roundEnd])),
 		BatchSize:     roundInfo.BatchSize,
+		Network:       network,
 	}
 
-	precompDuration := metric.PrecompEnd.Sub(metric.PrecompStart)
-	realTimeDuration := metric.RealtimeEnd.Sub(metric.RealtimeStart)
+	precompDuration, realTimeDuration := roundDurations(roundInfo, realtimeTs)
 
 	jww.TRACE.Printf("Precomp for round %v took: %v", roundInfo.GetRoundId(), precompDuration)
 	jww.TRACE.Printf("Realtime for round %v took: %v", roundInfo.GetRoundId(), realTimeDuration)
@@ -282,6 +452,10 @@ func killRound(state *storage.NetworkState, r *round.State,
 		roundTracker.RemoveActiveRound(roundId)
 	}
 
+	// Attribute the failure while the members' activities are as they were
+	// when it failed
+	blame := blameRound(state, r, roundError, time.Now())
+
 	// Build the new round info and update the network state
 	roundInfo := r.BuildRoundInfo()
 	err = state.AddRoundUpdate(roundInfo)
@@ -311,9 +485,24 @@ func killRound(state *storage.NetworkState, r *round.State,
 	} else if isFirstToClear := numClearedNodes == 1; isFirstToClear {
 		// Ensure we only store round metrics for the first node to kill
 		// the round in order to prevent pointless duplicate inserts.
-		go func() {
+		roundTracker.StoreAsync(func() {
 			// Attempt to insert the RoundMetric for the failed round
-			StoreRoundMetric(roundInfo, r.GetRoundState(), 0)
+			StoreRoundMetric(state.GetNetwork(), roundInfo, r.GetRoundState(), 0)
+
+			// Insert the members the failure is blamed on
+			if len(blame) > 0 {
+				for _, b := range blame {
+					jww.INFO.Printf("Round %d failure blamed on %s: %s "+
+						"while %s", roundId, b.NodeID, b.Reason, b.Activity)
+				}
+				failed := time.Unix(0,
+					int64(roundInfo.Timestamps[states.FAILED]))
+				err := storage.PermissioningDb.InsertRoundBlames(roundId,
+					blameRecords(blame, failed))
+				if err != nil {
+					jww.WARN.Printf("Could not insert round blame: %+v", err)
+				}
+			}
 
 			// Return early if there is no roundError
 			if roundError == nil {
@@ -336,7 +525,7 @@ func killRound(state *storage.NetworkState, r *round.State,
 			if err != nil {
 				jww.WARN.Printf("Could not insert round error: %+v", err)
 			}
-		}()
+		})
 	}
 
 	return nil
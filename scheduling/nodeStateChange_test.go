@@ -11,6 +11,7 @@ import (
 	"crypto/rand"
 	"gitlab.com/elixxir/comms/mixmessages"
 	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/elixxir/registration/storage/round"
@@ -73,17 +74,18 @@ func TestHandleNodeStateChance_Waiting(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -142,17 +144,18 @@ func TestHandleNodeStateChance_Waiting_SetNodeToOnline(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -216,17 +219,17 @@ func TestHandleNodeStateChance_Standby(t *testing.T) {
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
 		testTracker := NewRoundTracker()
 
-		timeoutCh := make(chan id.Round, 1)
-
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
-			realtimeDelay:    0,
-			realtimeDelta:    0,
-			realtimeTimeout:  15 * time.Second,
-			pool:             testPool,
-			state:            testState,
-			roundTracker:     testTracker,
-			roundTimeoutChan: timeoutCh,
+			lastRealtime:    time.Unix(0, 0),
+			realtimeDelay:   0,
+			realtimeDelta:   0,
+			realtimeTimeout: 15 * time.Second,
+			pool:            testPool,
+			state:           testState,
+			roundTracker:    testTracker,
+			timeouts:        newTimeoutManager(time.Now, newStdTimer),
+			pacer:           newPacer(),
+			reputation:      newReputation(""),
 		}
 
 		err = sc.HandleNodeUpdates(testUpdate)
@@ -246,16 +249,17 @@ func TestHandleNodeStateChance_Standby(t *testing.T) {
 			ToActivity:   current.STANDBY}
 
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
-		timeoutCh := make(chan id.Round, 1)
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
-			realtimeDelay:    0,
-			realtimeDelta:    0,
-			realtimeTimeout:  15 * time.Second,
-			pool:             testPool,
-			state:            testState,
-			roundTracker:     nil,
-			roundTimeoutChan: timeoutCh,
+			lastRealtime:    time.Unix(0, 0),
+			realtimeDelay:   0,
+			realtimeDelta:   0,
+			realtimeTimeout: 15 * time.Second,
+			pool:            testPool,
+			state:           testState,
+			roundTracker:    nil,
+			timeouts:        newTimeoutManager(time.Now, newStdTimer),
+			pacer:           newPacer(),
+			reputation:      newReputation(""),
 		}
 
 		err = sc.HandleNodeUpdates(testUpdate)
@@ -311,17 +315,18 @@ func TestHandleNodeStateChance_Standby_NoRound(t *testing.T) {
 
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
 		testTracker := NewRoundTracker()
-		timeoutCh := make(chan id.Round, 1)
 
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
-			realtimeDelay:    0,
-			realtimeDelta:    0,
-			realtimeTimeout:  15 * time.Second,
-			pool:             testPool,
-			state:            testState,
-			roundTracker:     testTracker,
-			roundTimeoutChan: timeoutCh,
+			lastRealtime:    time.Unix(0, 0),
+			realtimeDelay:   0,
+			realtimeDelta:   0,
+			realtimeTimeout: 15 * time.Second,
+			pool:            testPool,
+			state:           testState,
+			roundTracker:    testTracker,
+			timeouts:        newTimeoutManager(time.Now, newStdTimer),
+			pacer:           newPacer(),
+			reputation:      newReputation(""),
 		}
 
 		err = sc.HandleNodeUpdates(testUpdate)
@@ -388,17 +393,17 @@ func TestHandleNodeUpdates_Completed(t *testing.T) {
 
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
 
-		timeoutCh := make(chan id.Round, 1)
-
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
-			realtimeDelay:    0,
-			realtimeDelta:    0,
-			realtimeTimeout:  15 * time.Second,
-			pool:             testPool,
-			state:            testState,
-			roundTracker:     testTracker,
-			roundTimeoutChan: timeoutCh,
+			lastRealtime:    time.Unix(0, 0),
+			realtimeDelay:   0,
+			realtimeDelta:   0,
+			realtimeTimeout: 15 * time.Second,
+			pool:            testPool,
+			state:           testState,
+			roundTracker:    testTracker,
+			timeouts:        newTimeoutManager(time.Now, newStdTimer),
+			pacer:           newPacer(),
+			reputation:      newReputation(""),
 		}
 
 		err = sc.HandleNodeUpdates(testUpdate)
@@ -418,17 +423,17 @@ func TestHandleNodeUpdates_Completed(t *testing.T) {
 
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
 
-		timeoutCh := make(chan id.Round, 1)
-
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
-			realtimeDelay:    0,
-			realtimeDelta:    0,
-			realtimeTimeout:  15 * time.Second,
-			pool:             testPool,
-			state:            testState,
-			roundTracker:     testTracker,
-			roundTimeoutChan: timeoutCh,
+			lastRealtime:    time.Unix(0, 0),
+			realtimeDelay:   0,
+			realtimeDelta:   0,
+			realtimeTimeout: 15 * time.Second,
+			pool:            testPool,
+			state:           testState,
+			roundTracker:    testTracker,
+			timeouts:        newTimeoutManager(time.Now, newStdTimer),
+			pacer:           newPacer(),
+			reputation:      newReputation(""),
 		}
 
 		err = sc.HandleNodeUpdates(testUpdate)
@@ -478,17 +483,17 @@ func TestHandleNodeUpdates_Completed_NoRound(t *testing.T) {
 		testState.GetNodeMap().GetNode(nodeList[i]).GetPollingLock().Lock()
 		testTracker := NewRoundTracker()
 
-		timeoutCh := make(chan id.Round, 1)
-
 		sc := &stateChanger{
-			lastRealtime:     time.Unix(0, 0),
-			realtimeDelay:    0,
-			realtimeDelta:    0,
-			realtimeTimeout:  15 * time.Second,
-			pool:             testPool,
-			state:            testState,
-			roundTracker:     testTracker,
-			roundTimeoutChan: timeoutCh,
+			lastRealtime:    time.Unix(0, 0),
+			realtimeDelay:   0,
+			realtimeDelta:   0,
+			realtimeTimeout: 15 * time.Second,
+			pool:            testPool,
+			state:           testState,
+			roundTracker:    testTracker,
+			timeouts:        newTimeoutManager(time.Now, newStdTimer),
+			pacer:           newPacer(),
+			reputation:      newReputation(""),
 		}
 
 		err = sc.HandleNodeUpdates(testUpdate)
@@ -556,17 +561,17 @@ func TestHandleNodeUpdates_Error(t *testing.T) {
 
 	testTracker := NewRoundTracker()
 
-	timeoutCh := make(chan id.Round, 1)
-
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     testTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    testTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -618,17 +623,18 @@ func TestHandleNodeUpdates_BannedNode(t *testing.T) {
 	// Ban the first node in the state map
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -659,6 +665,8 @@ func TestHandleNodeUpdates_BannedNode(t *testing.T) {
 		ToStatus: node.Banned,
 	}
 
+	sc.timeouts.set(r.GetRoundID(), precompPhase, time.Minute)
+
 	// Ban the the second node in the state map
 	testState.GetNodeMap().GetNode(nodeList[1]).GetPollingLock().Lock()
 
@@ -675,6 +683,10 @@ func TestHandleNodeUpdates_BannedNode(t *testing.T) {
 			"\n\tReceived: %v", receivedRound)
 	}
 
+	// Test that the failed round was ended, so that its timeout will not fire
+	if _, exists := sc.timeouts.cancel(r.GetRoundID()); exists {
+		t.Errorf("Round killed by a banned node still has a timeout.")
+	}
 }
 
 // Happy path
@@ -769,17 +781,18 @@ func TestHandleNodeUpdates_Precomputing_RoundError(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -835,17 +848,18 @@ func TestHandleNodeUpdates_Realtime(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -854,6 +868,75 @@ func TestHandleNodeUpdates_Realtime(t *testing.T) {
 	}
 }
 
+// Tests that the client errors reported with an update are added to the round
+// and stored.
+func TestHandleNodeUpdates_ClientErrors(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if err = testState.GetNodeMap().AddNode(nid, "0", "", "", 0); err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+	roundID, err := testState.GetRoundID()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	roundState := round.NewState_Testing(roundID, 0, nil, t)
+	_ = testState.GetNodeMap().GetNode(nid).SetRound(roundState)
+
+	clientErrors := []*mixmessages.ClientError{{
+		ClientId: id.NewIdFromUInt(1, id.User, t).Marshal(),
+		Error:    "failed to send: timed out",
+	}}
+	testUpdate := node.UpdateNotification{
+		Node:         nid,
+		FromActivity: current.STANDBY,
+		ToActivity:   current.REALTIME,
+		ClientErrors: clientErrors,
+	}
+
+	testState.GetNodeMap().GetNode(nid).GetPollingLock().Lock()
+	roundTracker := NewRoundTracker()
+	sc := &stateChanger{
+		lastRealtime:    time.Unix(0, 0),
+		realtimeTimeout: 15 * time.Second,
+		pool:            NewWaitingPool(),
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
+	}
+
+	if err = sc.HandleNodeUpdates(testUpdate); err != nil {
+		t.Errorf("Happy path received error: %v", err)
+	}
+	if !roundTracker.Flush(time.Second) {
+		t.Fatalf("Client errors were not stored in time")
+	}
+
+	if len(roundState.BuildRoundInfo().ClientErrors) != 1 {
+		t.Errorf("Client errors not added to the round")
+	}
+	stored, err := storage.PermissioningDb.GetRoundClientErrors("", roundID)
+	if err != nil {
+		t.Fatalf("Failed to get client errors: %+v", err)
+	}
+	if len(stored) != 1 || stored[0].ErrorType != "failed to send" {
+		t.Errorf("Unexpected stored client errors: %+v", stored)
+	}
+}
+
 // Tests that the Realtime case of HandleNodeUpdates produces the correct
 // error when there is no round.
 func TestHandleNodeUpdates_Realtime_RoundError(t *testing.T) {
@@ -894,17 +977,18 @@ func TestHandleNodeUpdates_Realtime_RoundError(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -964,17 +1048,18 @@ func TestHandleNodeUpdates_Realtime_UpdateError(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -1031,17 +1116,18 @@ func TestHandleNodeUpdates_RoundErrored(t *testing.T) {
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
 	roundTracker := NewRoundTracker()
-	timeoutCh := make(chan id.Round, 1)
 
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             testPool,
-		state:            testState,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            testPool,
+		state:           testState,
+		roundTracker:    roundTracker,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -1081,16 +1167,17 @@ func TestHandleNodeUpdates_NOT_STARTED(t *testing.T) {
 		ToActivity:   current.NOT_STARTED}
 
 	testState.GetNodeMap().GetNode(nodeList[0]).GetPollingLock().Lock()
-	timeoutCh := make(chan id.Round, 1)
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    0,
-		realtimeDelta:    0,
-		realtimeTimeout:  15 * time.Second,
-		pool:             nil,
-		state:            testState,
-		roundTracker:     nil,
-		roundTimeoutChan: timeoutCh,
+		lastRealtime:    time.Unix(0, 0),
+		realtimeDelay:   0,
+		realtimeDelta:   0,
+		realtimeTimeout: 15 * time.Second,
+		pool:            nil,
+		state:           testState,
+		roundTracker:    nil,
+		timeouts:        newTimeoutManager(time.Now, newStdTimer),
+		pacer:           newPacer(),
+		reputation:      newReputation(""),
 	}
 
 	err = sc.HandleNodeUpdates(testUpdate)
@@ -1098,3 +1185,268 @@ func TestHandleNodeUpdates_NOT_STARTED(t *testing.T) {
 		t.Errorf("Happy path received error: %v", err)
 	}
 }
+
+// Tests that draining a node removes it from the pool, that it is kept out of
+// the pool when it reports WAITING, and that it is returned once made ready.
+func TestHandleNodeUpdates_Drain(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if err = testState.GetNodeMap().AddNode(nid, "0", "", "", 0); err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+	n := testState.GetNodeMap().GetNode(nid)
+	if _, _, err = n.Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to move node to waiting: %+v", err)
+	}
+
+	testPool := NewWaitingPool()
+	testPool.Add(n)
+	sc := &stateChanger{
+		lastRealtime: time.Unix(0, 0),
+		pool:         testPool,
+		state:        testState,
+		roundTracker: NewRoundTracker(),
+		timeouts:     newTimeoutManager(time.Now, newStdTimer),
+		pacer:        newPacer(),
+		reputation:   newReputation(""),
+	}
+
+	// Drain the node
+	nun, err := n.SetDraining(true)
+	if err != nil {
+		t.Fatalf("Failed to drain node: %+v", err)
+	}
+	n.GetPollingLock().Lock()
+	if err = sc.HandleNodeUpdates(nun); err != nil {
+		t.Errorf("Failed to handle drain: %+v", err)
+	}
+	if testPool.Len() != 0 {
+		t.Errorf("Draining node not removed from pool.")
+	}
+
+	// A WAITING report from a draining node must not add it to the pool
+	n.GetPollingLock().Lock()
+	err = sc.HandleNodeUpdates(node.UpdateNotification{
+		Node:         nid,
+		FromActivity: current.COMPLETED,
+		ToActivity:   current.WAITING,
+	})
+	if err != nil {
+		t.Errorf("Failed to handle waiting update: %+v", err)
+	}
+	if testPool.Len() != 0 {
+		t.Errorf("Draining node added to pool on WAITING.")
+	}
+
+	// Make the node ready again
+	nun, err = n.SetDraining(false)
+	if err != nil {
+		t.Fatalf("Failed to ready node: %+v", err)
+	}
+	n.GetPollingLock().Lock()
+	if err = sc.HandleNodeUpdates(nun); err != nil {
+		t.Errorf("Failed to handle ready: %+v", err)
+	}
+	if testPool.Len() != 1 {
+		t.Errorf("Ready node not returned to pool.")
+	}
+}
+
+// Tests that an ineligible node is removed from the pool, is not added back on
+// WAITING, and returns to the pool once eligible again.
+func TestHandleNodeUpdates_Eligibility(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if err = testState.GetNodeMap().AddNode(nid, "0", "", "", 0); err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+	n := testState.GetNodeMap().GetNode(nid)
+	if _, _, err = n.Update(current.WAITING); err != nil {
+		t.Fatalf("Failed to move node to waiting: %+v", err)
+	}
+
+	testPool := NewWaitingPool()
+	testPool.Add(n)
+	sc := &stateChanger{
+		lastRealtime: time.Unix(0, 0),
+		pool:         testPool,
+		state:        testState,
+		roundTracker: NewRoundTracker(),
+		timeouts:     newTimeoutManager(time.Now, newStdTimer),
+		pacer:        newPacer(),
+		reputation:   newReputation(""),
+	}
+
+	nun, _ := n.SetEligible(false)
+	n.GetPollingLock().Lock()
+	if err = sc.HandleNodeUpdates(nun); err != nil {
+		t.Errorf("Failed to handle eligibility change: %+v", err)
+	}
+	if testPool.Len() != 0 {
+		t.Errorf("Ineligible node not removed from pool.")
+	}
+
+	n.GetPollingLock().Lock()
+	err = sc.HandleNodeUpdates(node.UpdateNotification{
+		Node:         nid,
+		FromActivity: current.COMPLETED,
+		ToActivity:   current.WAITING,
+	})
+	if err != nil {
+		t.Errorf("Failed to handle waiting update: %+v", err)
+	}
+	if testPool.Len() != 0 {
+		t.Errorf("Ineligible node added to pool on WAITING.")
+	}
+
+	nun, _ = n.SetEligible(true)
+	n.GetPollingLock().Lock()
+	if err = sc.HandleNodeUpdates(nun); err != nil {
+		t.Errorf("Failed to handle eligibility change: %+v", err)
+	}
+	if testPool.Len() != 1 {
+		t.Errorf("Eligible node not returned to pool.")
+	}
+}
+
+// Tests that a waiting node is kept out of the pool if the loaded eligible
+// node set excludes it, even before the set has been applied to it.
+func TestHandleNodeUpdates_Waiting_NotInEligibleSet(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %v", err)
+	}
+
+	// Load a set which does not include the node registered afterwards
+	source := eligibleNodesSource{*id.NewIdFromUInt(1, id.Node, t): true}
+	if _, err = testState.UpdateEligibleNodes(source); err != nil {
+		t.Fatalf("Failed to load eligible nodes: %+v", err)
+	}
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if err = testState.GetNodeMap().AddNode(nid, "0", "", "", 0); err != nil {
+		t.Fatalf("Couldn't add node: %v", err)
+	}
+	n := testState.GetNodeMap().GetNode(nid)
+
+	testPool := NewWaitingPool()
+	sc := &stateChanger{
+		lastRealtime: time.Unix(0, 0),
+		pool:         testPool,
+		state:        testState,
+		roundTracker: NewRoundTracker(),
+		timeouts:     newTimeoutManager(time.Now, newStdTimer),
+		pacer:        newPacer(),
+		reputation:   newReputation(""),
+	}
+
+	n.GetPollingLock().Lock()
+	err = sc.HandleNodeUpdates(node.UpdateNotification{
+		Node:         nid,
+		FromActivity: current.NOT_STARTED,
+		ToActivity:   current.WAITING,
+	})
+	if err != nil {
+		t.Errorf("Failed to handle waiting update: %+v", err)
+	}
+	if testPool.Len() != 0 || n.IsEligible() {
+		t.Errorf("Node excluded by the eligible set was added to the pool.")
+	}
+}
+
+// eligibleNodesSource is a storage.EligibleNodeSource returning a fixed set.
+type eligibleNodesSource map[id.ID]bool
+
+func (s eligibleNodesSource) GetEligibleNodes() (map[id.ID]bool, error) {
+	return s, nil
+}
+
+func (s eligibleNodesSource) String() string {
+	return "test set"
+}
+
+// Tests that a round moves from its resource queue timeout to its
+// precomputation timeout once every node has begun precomputing.
+func TestHandleNodeUpdates_ResourceQueue(t *testing.T) {
+	sc, nodes := newSupervisorTestStateChanger(t, 2)
+	clock := &testClock{now: time.Unix(1000, 0)}
+	sc.timeouts = newTimeoutManager(clock.Now, clock.newTimer)
+	sc.setParams(Params{PrecomputationTimeout: 30000, RealtimeTimeout: 15000})
+
+	topology := connect.NewCircuit([]*id.ID{nodes[0].GetID(), nodes[1].GetID()})
+	r := round.NewState_Testing(42, states.PRECOMPUTING, topology, t)
+	sc.timeouts.set(r.GetRoundID(), resourceQueuePhase, 3*time.Minute)
+	for _, n := range nodes {
+		sc.pool.Remove(n)
+		if err := n.SetRound(r); err != nil {
+			t.Fatalf("Failed to set round: %+v", err)
+		}
+	}
+
+	for i, n := range nodes {
+		n.GetPollingLock().Lock()
+		err := sc.HandleNodeUpdates(node.UpdateNotification{
+			Node:         n.GetID(),
+			FromActivity: current.WAITING,
+			ToActivity:   current.PRECOMPUTING,
+		})
+		if err != nil {
+			t.Fatalf("Failed to handle precomputing update: %+v", err)
+		}
+
+		expected := roundDeadline{round: 42, phase: resourceQueuePhase,
+			deadline: clock.Now().Add(3 * time.Minute)}
+		if i == len(nodes)-1 {
+			expected = roundDeadline{round: 42, phase: precompPhase,
+				deadline: clock.Now().Add(30 * time.Second)}
+		}
+		upcoming := sc.timeouts.upcoming()
+		if len(upcoming) != 1 || upcoming[0].phase != expected.phase ||
+			!upcoming[0].deadline.Equal(expected.deadline) {
+			t.Errorf("Unexpected deadline after %d nodes began "+
+				"precomputing.\nexpected: %+v\nreceived: %+v", i+1,
+				expected, upcoming)
+		}
+	}
+
+	// The round times out in precomputation, not in its resource queue
+	clock.advance(30 * time.Second)
+	select {
+	case <-sc.timeouts.C():
+	default:
+		t.Fatalf("Precomputation timeout did not fire.")
+	}
+	expired := sc.timeouts.expire()
+	if len(expired) != 1 || expired[0].phase != precompPhase {
+		t.Errorf("Unexpected expired deadlines: %+v", expired)
+	}
+}
@@ -0,0 +1,99 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"time"
+)
+
+// operatorTeams.go contains the operator teams of nodes, loaded from the Team
+// of each node's Application, which restrict which nodes may share a round.
+
+const (
+	// OperatorTeamsFixed only forms rounds from nodes of a single operator
+	// team. Nodes without a team are not scheduled.
+	OperatorTeamsFixed = "fixed"
+	// OperatorTeamsSeparate never places two nodes of the same operator team
+	// in a round. Nodes without a team are not restricted.
+	OperatorTeamsSeparate = "separate"
+
+	// How long the operator teams are used before being reloaded from storage
+	operatorTeamsRefreshInterval = time.Minute
+)
+
+// operatorTeams maps the Application ID of each node to its operator team. It
+// is only used by the Scheduler thread and so is not thread safe.
+type operatorTeams struct {
+	teams    map[uint64]string
+	loaded   time.Time
+	interval time.Duration
+
+	// Loads the operator teams of every Application with a team
+	load func() (map[uint64]string, error)
+}
+
+// newOperatorTeams creates operatorTeams loaded from the Application table.
+func newOperatorTeams() *operatorTeams {
+	return &operatorTeams{
+		interval: operatorTeamsRefreshInterval,
+		load: func() (map[uint64]string, error) {
+			return storage.PermissioningDb.GetApplicationTeams()
+		},
+	}
+}
+
+// refresh reloads the operator teams if they are older than the refresh
+// interval. Applications are not given a team after they are created, so the
+// teams are also reloaded if a node has an Application that has not been seen;
+// otherwise a new node could join a round with another node of its operator.
+// If loading fails, the last loaded teams are kept. Errors if no teams have
+// ever been loaded.
+func (ot *operatorTeams) refresh(nodes []*node.State, now time.Time) error {
+	stale := ot.teams == nil || now.Sub(ot.loaded) >= ot.interval
+	for i := 0; !stale && i < len(nodes); i++ {
+		_, known := ot.teams[nodes[i].GetAppID()]
+		stale = !known
+	}
+	if !stale {
+		return nil
+	}
+
+	teams, err := ot.load()
+	if err != nil {
+		if ot.teams == nil {
+			return errors.Errorf("could not load operator teams: %+v", err)
+		}
+		jww.WARN.Printf("Keeping operator teams loaded at %s: %+v",
+			ot.loaded, err)
+		return nil
+	}
+
+	// Applications without a team are recorded so that they are not reloaded
+	for _, n := range nodes {
+		if _, exists := teams[n.GetAppID()]; !exists {
+			teams[n.GetAppID()] = ""
+		}
+	}
+
+	ot.teams = teams
+	ot.loaded = now
+	return nil
+}
+
+// teamOf returns the operator team of the node or an empty string if it has
+// none.
+func (ot *operatorTeams) teamOf(n *node.State) string {
+	if ot == nil {
+		return ""
+	}
+	return ot.teams[n.GetAppID()]
+}
@@ -0,0 +1,256 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// newTestOperatorTeams creates operatorTeams which load the passed in teams
+// and count the number of loads.
+func newTestOperatorTeams(teams map[uint64]string, loads *int) *operatorTeams {
+	return &operatorTeams{
+		interval: time.Minute,
+		load: func() (map[uint64]string, error) {
+			*loads++
+			loaded := make(map[uint64]string, len(teams))
+			for appId, team := range teams {
+				loaded[appId] = team
+			}
+			return loaded, nil
+		},
+	}
+}
+
+// Tests that refresh only reloads the operator teams once they are stale or a
+// node with an unseen Application is in the pool.
+func TestOperatorTeams_refresh(t *testing.T) {
+	loads := 0
+	ot := newTestOperatorTeams(map[uint64]string{1: "alpha"}, &loads)
+	testPool := setupOperatorPool(t, map[uint64]string{1: "alpha", 2: ""})
+	nodes := testPool.Nodes()
+
+	now := time.Now()
+	if err := ot.refresh(nodes, now); err != nil {
+		t.Fatalf("Failed to refresh: %+v", err)
+	}
+	if err := ot.refresh(nodes, now.Add(time.Second)); err != nil {
+		t.Fatalf("Failed to refresh: %+v", err)
+	}
+	if loads != 1 {
+		t.Errorf("Teams loaded %d times, expected 1", loads)
+	}
+
+	// A node of a new Application causes a reload
+	newNode := setupOperatorPool(t, map[uint64]string{3: ""}).Nodes()
+	if err := ot.refresh(append(nodes, newNode...), now.Add(time.Second)); err != nil {
+		t.Fatalf("Failed to refresh: %+v", err)
+	}
+	if loads != 2 {
+		t.Errorf("Teams loaded %d times after new node, expected 2", loads)
+	}
+
+	if err := ot.refresh(nodes, now.Add(2*time.Minute)); err != nil {
+		t.Fatalf("Failed to refresh: %+v", err)
+	}
+	if loads != 3 {
+		t.Errorf("Teams loaded %d times after interval, expected 3", loads)
+	}
+
+	for _, n := range nodes {
+		expected := map[uint64]string{1: "alpha", 2: ""}[n.GetAppID()]
+		if team := ot.teamOf(n); team != expected {
+			t.Errorf("Unexpected team for application %d."+
+				"\nexpected: %q\nreceived: %q", n.GetAppID(), expected, team)
+		}
+	}
+}
+
+// Tests that refresh keeps the last loaded teams when loading fails and only
+// errors if no teams have been loaded.
+func TestOperatorTeams_refresh_Error(t *testing.T) {
+	ot := &operatorTeams{
+		interval: time.Minute,
+		load: func() (map[uint64]string, error) {
+			return nil, errors.New("database unavailable")
+		},
+	}
+	if err := ot.refresh(nil, time.Now()); err == nil {
+		t.Errorf("Expected error when no teams have been loaded.")
+	}
+
+	ot.teams = map[uint64]string{1: "alpha"}
+	ot.loaded = time.Now().Add(-time.Hour)
+	if err := ot.refresh(nil, time.Now()); err != nil {
+		t.Errorf("Unexpected error with loaded teams: %+v", err)
+	}
+	if ot.teams[1] != "alpha" {
+		t.Errorf("Loaded teams were not kept: %v", ot.teams)
+	}
+}
+
+// Tests that with OperatorTeamsSeparate no team picked contains two nodes of
+// the same operator team.
+func TestWaitingPool_PickNRandWithConstraints_OperatorTeamsSeparate(t *testing.T) {
+	constraints := TeamConstraints{OperatorTeams: OperatorTeamsSeparate}
+	teams := map[uint64]string{
+		1: "alpha", 2: "alpha", 3: "alpha", 4: "beta", 5: "beta", 6: "", 7: ""}
+
+	for i := 0; i < 10; i++ {
+		loads := 0
+		ot := newTestOperatorTeams(teams, &loads)
+		testPool := setupOperatorPool(t, teams)
+		if err := ot.refresh(testPool.Nodes(), time.Now()); err != nil {
+			t.Fatalf("Failed to refresh: %+v", err)
+		}
+
+		team, err := testPool.PickNRandWithConstraints(4, 4, constraints,
+			region.GetCountryBins(), ot)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+
+		seen := make(map[string]bool)
+		for _, n := range team {
+			operator := ot.teamOf(n)
+			if operator != "" && seen[operator] {
+				t.Fatalf("Team has two nodes of operator team %q", operator)
+			}
+			seen[operator] = true
+		}
+	}
+}
+
+// Tests that with OperatorTeamsFixed every team picked is from a single
+// operator team and nodes without a team are never picked.
+func TestWaitingPool_PickNRandWithConstraints_OperatorTeamsFixed(t *testing.T) {
+	constraints := TeamConstraints{OperatorTeams: OperatorTeamsFixed}
+	teams := map[uint64]string{
+		1: "alpha", 2: "alpha", 3: "alpha", 4: "beta", 5: "beta", 6: "beta",
+		7: "gamma", 8: "", 9: "", 10: ""}
+
+	for i := 0; i < 10; i++ {
+		loads := 0
+		ot := newTestOperatorTeams(teams, &loads)
+		testPool := setupOperatorPool(t, teams)
+		if err := ot.refresh(testPool.Nodes(), time.Now()); err != nil {
+			t.Fatalf("Failed to refresh: %+v", err)
+		}
+
+		team, err := testPool.PickNRandWithConstraints(3, 3, constraints,
+			region.GetCountryBins(), ot)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+
+		operator := ot.teamOf(team[0])
+		if operator != "alpha" && operator != "beta" {
+			t.Fatalf("Team picked from operator team %q", operator)
+		}
+		for _, n := range team[1:] {
+			if ot.teamOf(n) != operator {
+				t.Fatalf("Team mixes operator teams %q and %q", operator,
+					ot.teamOf(n))
+			}
+		}
+	}
+}
+
+// Tests that pickTeam waits rather than mixing operator teams when the pool
+// cannot satisfy them, even with the FallbackRelax fallback.
+func TestPickTeam_OperatorTeamsNotRelaxed(t *testing.T) {
+	params := Params{
+		TeamSize: 3,
+		TeamConstraints: TeamConstraints{
+			MaxNodesPerBin: 1,
+			OperatorTeams:  OperatorTeamsFixed,
+		},
+	}
+	teams := map[uint64]string{1: "alpha", 2: "alpha", 3: "beta", 4: "beta"}
+
+	loads := 0
+	ot := newTestOperatorTeams(teams, &loads)
+	testPool := setupOperatorPool(t, teams)
+	_, err := pickTeam(params, testPool, 3, region.GetCountryBins(), ot)
+	if err != errTeamConstraints {
+		t.Errorf("Expected errTeamConstraints, received: %v", err)
+	}
+	if testPool.Len() != 4 {
+		t.Errorf("Pool modified while waiting. Pool size: %d", testPool.Len())
+	}
+
+	// The geographic constraints are relaxed but the operator teams are not
+	testPool.Add(setupOperatorPool(t, map[uint64]string{5: "alpha"}).Nodes()[0])
+	ot.teams[5] = "alpha"
+	team, err := pickTeam(params, testPool, 3, region.GetCountryBins(), ot)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	for _, n := range team {
+		if ot.teamOf(n) != "alpha" {
+			t.Errorf("Team contains node of operator team %q", ot.teamOf(n))
+		}
+	}
+}
+
+// setupOperatorPool builds a waiting pool with one node in the US for each
+// passed in Application ID. The teams are not used.
+func setupOperatorPool(t *testing.T, teams map[uint64]string) *waitingPool {
+	testState := setupNodeMap(t)
+	testPool := NewWaitingPool()
+	for appId := range teams {
+		nid := id.NewIdFromUInt(appId, id.Node, t)
+		err := testState.GetNodeMap().AddNode(nid, "US", "", "", appId)
+		if err != nil {
+			t.Fatalf("Failed to add node to state: %v", err)
+		}
+		testPool.Add(testState.GetNodeMap().GetNode(nid))
+	}
+	return testPool
+}
+
+// Tests that Validate reports an unknown operator teams mode.
+func TestParams_Validate_OperatorTeams(t *testing.T) {
+	for _, mode := range []string{"", OperatorTeamsFixed, OperatorTeamsSeparate} {
+		tc := TeamConstraints{OperatorTeams: mode}
+		if problems := tc.validate(3, nil); len(problems) != 0 {
+			t.Errorf("Unexpected problems for %q: %v", mode, problems)
+		}
+	}
+
+	tc := TeamConstraints{OperatorTeams: "together"}
+	if problems := tc.validate(3, nil); len(problems) != 1 {
+		t.Errorf("Expected one problem for unknown mode, received: %v",
+			problems)
+	}
+}
+
+// Tests that a node without an operator team never fits a fixed team.
+func TestTeamBuilder_fits_OperatorTeamsFixed(t *testing.T) {
+	teams := map[uint64]string{1: "alpha", 2: ""}
+	loads := 0
+	ot := newTestOperatorTeams(teams, &loads)
+	testPool := setupOperatorPool(t, teams)
+	if err := ot.refresh(testPool.Nodes(), time.Now()); err != nil {
+		t.Fatalf("Failed to refresh: %+v", err)
+	}
+
+	tb := newTeamBuilder(TeamConstraints{OperatorTeams: OperatorTeamsFixed},
+		region.GetCountryBins(), ot)
+	testPool.pool.Do(func(face interface{}) {
+		n := face.(*node.State)
+		if tb.fits(n) != (n.GetAppID() == 1) {
+			t.Errorf("Unexpected fit for application %d", n.GetAppID())
+		}
+	})
+}
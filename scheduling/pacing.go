@@ -0,0 +1,304 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"fmt"
+	jww "github.com/spf13/jwalterweatherman"
+	"sync"
+	"time"
+)
+
+// pacing.go contains the pacer, which adjusts the spacing between realtime
+// starts and the rate rounds are created at from the rounds queued for
+// realtime, how long realtime takes, and how often it fails.
+
+const (
+	// Defaults for the Pacing which are not set
+	defaultTargetQueuedRounds = 2
+	defaultMaxFailureRate     = 0.1
+
+	// Number of recent realtime outcomes the failure rate is taken over, and
+	// the number needed before it is acted on
+	pacingFailureWindow     = 20
+	pacingMinFailureSamples = 10
+
+	// Weight of a new realtime duration in the moving average
+	pacingRealtimeWeight = 0.2
+)
+
+// Pacing adjusts the spacing between realtime starts in place of the fixed
+// MinimumDelay. The spacing is backed off when too many realtime rounds fail,
+// returned to the fixed spacing once they stop, and tightened while more
+// rounds are queued for realtime than targeted. Round
+// creation slows to the spacing while the queue is over target.
+type Pacing struct {
+	// Turns on pacing
+	Enabled bool
+	// Number of rounds queued for realtime the spacing is tightened down to.
+	// Defaults to 2
+	TargetQueuedRounds uint32
+	// Number of realtime rounds which may run at once. The spacing is kept
+	// above the average realtime duration divided by this. Zero disables this
+	// floor
+	TargetConcurrentRealtime uint32
+	// Fraction of realtime rounds which may fail before the spacing is backed
+	// off. Defaults to 0.1
+	MaxFailureRate float64
+
+	// NOTE: All times in MS
+	// Bounds on the spacing
+	MinSpacing time.Duration
+	MaxSpacing time.Duration
+	// Spacing to use instead of the pacer's, while set
+	OverrideSpacing time.Duration
+}
+
+// withDefaults returns the config with defaults for the unset fields.
+func (p Pacing) withDefaults() Pacing {
+	if p.TargetQueuedRounds == 0 {
+		p.TargetQueuedRounds = defaultTargetQueuedRounds
+	}
+	if p.MaxFailureRate == 0 {
+		p.MaxFailureRate = defaultMaxFailureRate
+	}
+	return p
+}
+
+// validate returns every problem with the config.
+func (p Pacing) validate() []string {
+	var problems []string
+	if p.OverrideSpacing < 0 {
+		problems = append(problems, "Pacing.OverrideSpacing cannot be negative")
+	} else if p.OverrideSpacing*time.Millisecond > maxParamDuration {
+		problems = append(problems, fmt.Sprintf("Pacing.OverrideSpacing "+
+			"of %s is longer than the maximum of %s",
+			p.OverrideSpacing*time.Millisecond, maxParamDuration))
+	}
+	if !p.Enabled {
+		return problems
+	}
+
+	if p.MaxFailureRate < 0 || p.MaxFailureRate > 1 {
+		problems = append(problems, fmt.Sprintf("Pacing.MaxFailureRate %f "+
+			"must be between 0 and 1", p.MaxFailureRate))
+	}
+	if p.MinSpacing <= 0 || p.MaxSpacing <= 0 {
+		problems = append(problems, "Pacing.MinSpacing and "+
+			"Pacing.MaxSpacing must be greater than 0")
+	} else if p.MinSpacing > p.MaxSpacing {
+		problems = append(problems, "Pacing.MinSpacing is larger than "+
+			"Pacing.MaxSpacing")
+	} else if p.MaxSpacing*time.Millisecond > maxParamDuration {
+		problems = append(problems, fmt.Sprintf("Pacing.MaxSpacing of %s "+
+			"is longer than the maximum of %s",
+			p.MaxSpacing*time.Millisecond, maxParamDuration))
+	}
+	return problems
+}
+
+// pacer tracks the rounds queued for realtime and the outcomes of realtime
+// rounds, and sets the spacing from them. It is safe for concurrent use.
+type pacer struct {
+	mux sync.Mutex
+	// Current spacing, zero until pacing is first enabled
+	spacing time.Duration
+	// Moving average of realtime durations
+	realtime time.Duration
+	// Recent realtime outcomes, true for a failure
+	outcomes []bool
+	// Realtime start times which have been scheduled
+	starts []time.Time
+}
+
+// newPacer creates a pacer with no history.
+func newPacer() *pacer {
+	return &pacer{}
+}
+
+// realtimeSpacing returns the time to leave between realtime starts. The fixed
+// spacing is returned while pacing is disabled.
+func (p *pacer) realtimeSpacing(config Pacing, fixed time.Duration) time.Duration {
+	if config.OverrideSpacing > 0 {
+		return config.OverrideSpacing * time.Millisecond
+	}
+	if !config.Enabled {
+		return fixed
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.current(config, fixed)
+}
+
+// creationInterval returns the minimum time between creating rounds. It is a
+// third of the spacing, as with the fixed MinimumDelay, or the full spacing
+// while more rounds are queued for realtime than targeted.
+func (p *pacer) creationInterval(config Pacing, fixed time.Duration,
+	now time.Time) time.Duration {
+	spacing := p.realtimeSpacing(config, fixed)
+	if !config.Enabled || config.OverrideSpacing > 0 {
+		return spacing / 3
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if uint32(p.queued(now)) > config.withDefaults().TargetQueuedRounds {
+		return spacing
+	}
+	return spacing / 3
+}
+
+// schedule records a round queued to start realtime at the start time.
+func (p *pacer) schedule(start, now time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.queued(now)
+	p.starts = append(p.starts, start)
+}
+
+// status returns the current spacing, the number of rounds queued for
+// realtime, and the fraction of recent realtime rounds which failed.
+func (p *pacer) status(now time.Time) (time.Duration, int, float64) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.spacing, p.queued(now), p.failureRate()
+}
+
+// completed records a realtime round which completed in the duration and
+// adjusts the spacing.
+func (p *pacer) completed(config Pacing, fixed, realtime time.Duration,
+	now time.Time) {
+	p.observe(config, fixed, now, func() {
+		if p.realtime == 0 {
+			p.realtime = realtime
+		} else if realtime > 0 {
+			p.realtime += time.Duration(pacingRealtimeWeight *
+				float64(realtime-p.realtime))
+		}
+		p.outcomes = appendOutcome(p.outcomes, false)
+	})
+}
+
+// failed records a round which failed once queued for realtime and adjusts
+// the spacing.
+func (p *pacer) failed(config Pacing, fixed time.Duration, now time.Time) {
+	p.observe(config, fixed, now, func() {
+		p.outcomes = appendOutcome(p.outcomes, true)
+	})
+}
+
+// observe records the outcome and adjusts the spacing. Nothing is recorded
+// while pacing is disabled.
+func (p *pacer) observe(config Pacing, fixed time.Duration, now time.Time,
+	record func()) {
+	if !config.Enabled {
+		return
+	}
+	config = config.withDefaults()
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	record()
+
+	spacing := p.current(config, fixed)
+	queued := p.queued(now)
+	failureRate := p.failureRate()
+
+	switch {
+	case len(p.outcomes) >= pacingMinFailureSamples &&
+		failureRate > config.MaxFailureRate:
+		p.spacing = p.bound(config, 2*spacing)
+		// Start over so that the next back off needs new failures
+		p.outcomes = p.outcomes[:0]
+		jww.WARN.Printf("%.0f%% of recent realtime rounds failed, "+
+			"spacing realtime starts %s apart", 100*failureRate, p.spacing)
+	case spacing > p.bound(config, fixed) &&
+		len(p.outcomes) >= pacingMinFailureSamples:
+		// Enough rounds have run since the last back off without failing too
+		// often, so return to the configured spacing
+		p.spacing = p.bound(config, fixed)
+		jww.INFO.Printf("Realtime failures recovered to %.0f%%, spacing "+
+			"realtime starts %s apart", 100*failureRate, p.spacing)
+	case uint32(queued) > config.TargetQueuedRounds:
+		p.spacing = p.bound(config, spacing*9/10)
+		if p.spacing != spacing {
+			jww.INFO.Printf("%d rounds are queued for realtime, spacing "+
+				"realtime starts %s apart", queued, p.spacing)
+		}
+	default:
+		p.spacing = spacing
+	}
+}
+
+// current returns the pacer's spacing within the bounds, starting from the
+// fixed spacing. The lock must be held.
+func (p *pacer) current(config Pacing, fixed time.Duration) time.Duration {
+	if p.spacing == 0 {
+		p.spacing = fixed
+	}
+	return p.bound(config, p.spacing)
+}
+
+// bound returns the spacing within the configured bounds and above the floor
+// set by the realtime durations. The lock must be held.
+func (p *pacer) bound(config Pacing, spacing time.Duration) time.Duration {
+	min := config.MinSpacing * time.Millisecond
+	if config.TargetConcurrentRealtime > 0 {
+		floor := p.realtime / time.Duration(config.TargetConcurrentRealtime)
+		if floor > min {
+			min = floor
+		}
+	}
+	max := config.MaxSpacing * time.Millisecond
+	if min > max {
+		min = max
+	}
+
+	if spacing < min {
+		return min
+	} else if spacing > max {
+		return max
+	}
+	return spacing
+}
+
+// queued returns the number of rounds scheduled to start realtime after now,
+// forgetting those which have started. The lock must be held.
+func (p *pacer) queued(now time.Time) int {
+	started := 0
+	for started < len(p.starts) && !p.starts[started].After(now) {
+		started++
+	}
+	p.starts = append(p.starts[:0], p.starts[started:]...)
+	return len(p.starts)
+}
+
+// failureRate returns the fraction of recent realtime rounds which failed. The
+// lock must be held.
+func (p *pacer) failureRate() float64 {
+	if len(p.outcomes) == 0 {
+		return 0
+	}
+	failures := 0
+	for _, failed := range p.outcomes {
+		if failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(p.outcomes))
+}
+
+// appendOutcome adds the outcome, dropping the oldest beyond the window.
+func appendOutcome(outcomes []bool, failed bool) []bool {
+	outcomes = append(outcomes, failed)
+	if len(outcomes) > pacingFailureWindow {
+		outcomes = append(outcomes[:0], outcomes[1:]...)
+	}
+	return outcomes
+}
@@ -0,0 +1,164 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"testing"
+	"time"
+)
+
+// newTestPacing returns a pacing config with spacing bounds of 1s and 60s.
+func newTestPacing() Pacing {
+	return Pacing{
+		Enabled:            true,
+		TargetQueuedRounds: 1,
+		MaxFailureRate:     0.2,
+		MinSpacing:         1000,
+		MaxSpacing:         60000,
+	}
+}
+
+// Tests that the fixed spacing is used while pacing is disabled and that the
+// override replaces the pacer's spacing.
+func TestPacer_realtimeSpacing(t *testing.T) {
+	p := newPacer()
+	fixed := 3 * time.Second
+
+	if spacing := p.realtimeSpacing(Pacing{}, fixed); spacing != fixed {
+		t.Errorf("Unexpected spacing while disabled: %s", spacing)
+	}
+	if interval := p.creationInterval(Pacing{}, fixed, time.Now()); interval != time.Second {
+		t.Errorf("Unexpected creation interval while disabled: %s", interval)
+	}
+
+	config := newTestPacing()
+	if spacing := p.realtimeSpacing(config, fixed); spacing != fixed {
+		t.Errorf("Pacer did not start from the fixed spacing: %s", spacing)
+	}
+
+	config.OverrideSpacing = 9000
+	if spacing := p.realtimeSpacing(config, fixed); spacing != 9*time.Second {
+		t.Errorf("Override not used: %s", spacing)
+	}
+	if interval := p.creationInterval(config, fixed, time.Now()); interval != 3*time.Second {
+		t.Errorf("Unexpected creation interval with override: %s", interval)
+	}
+}
+
+// Tests that the spacing tightens while more rounds are queued for realtime
+// than targeted, down to the minimum, and that round creation slows.
+func TestPacer_Backlog(t *testing.T) {
+	p := newPacer()
+	config := newTestPacing()
+	fixed := 2 * time.Second
+	now := time.Unix(1000, 0)
+
+	for i := 1; i <= 3; i++ {
+		p.schedule(now.Add(time.Duration(i)*time.Minute), now)
+	}
+	if interval := p.creationInterval(config, fixed, now); interval != fixed {
+		t.Errorf("Creation not slowed with a backlog: %s", interval)
+	}
+
+	p.completed(config, fixed, 10*time.Second, now)
+	if spacing := p.realtimeSpacing(config, fixed); spacing != 1800*time.Millisecond {
+		t.Errorf("Spacing not tightened with a backlog: %s", spacing)
+	}
+
+	for i := 0; i < 20; i++ {
+		p.completed(config, fixed, 10*time.Second, now)
+	}
+	if spacing := p.realtimeSpacing(config, fixed); spacing != time.Second {
+		t.Errorf("Spacing not bounded by the minimum: %s", spacing)
+	}
+
+	// Once the queued rounds start the backlog is gone
+	later := now.Add(time.Hour)
+	if interval := p.creationInterval(config, fixed, later); interval != time.Second/3 {
+		t.Errorf("Creation still slowed without a backlog: %s", interval)
+	}
+	if _, queued, _ := p.status(later); queued != 0 {
+		t.Errorf("Started rounds still queued: %d", queued)
+	}
+}
+
+// Tests that the spacing backs off once too many realtime rounds fail and
+// returns once they stop.
+func TestPacer_Failures(t *testing.T) {
+	p := newPacer()
+	config := newTestPacing()
+	fixed := 2 * time.Second
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < pacingMinFailureSamples-3; i++ {
+		p.completed(config, fixed, time.Second, now)
+	}
+	for i := 0; i < 2; i++ {
+		p.failed(config, fixed, now)
+	}
+	if spacing := p.realtimeSpacing(config, fixed); spacing != fixed {
+		t.Fatalf("Spacing backed off before enough outcomes: %s", spacing)
+	}
+
+	p.failed(config, fixed, now)
+	if spacing := p.realtimeSpacing(config, fixed); spacing != 2*fixed {
+		t.Errorf("Spacing not backed off after failures: %s", spacing)
+	}
+	if _, _, failureRate := p.status(now); failureRate != 0 {
+		t.Errorf("Outcomes not reset after backing off: %f", failureRate)
+	}
+
+	// The configured spacing returns once enough rounds run without failing
+	// too often
+	p.failed(config, fixed, now)
+	for i := 0; i < pacingMinFailureSamples-2; i++ {
+		p.completed(config, fixed, time.Second, now)
+	}
+	if spacing := p.realtimeSpacing(config, fixed); spacing != 2*fixed {
+		t.Fatalf("Spacing recovered before enough outcomes: %s", spacing)
+	}
+	p.completed(config, fixed, time.Second, now)
+	if spacing := p.realtimeSpacing(config, fixed); spacing != fixed {
+		t.Errorf("Spacing did not return to the configured spacing: %s",
+			spacing)
+	}
+}
+
+// Tests that the spacing is kept above the average realtime duration divided
+// by the target number of concurrent realtime rounds.
+func TestPacer_ConcurrentRealtime(t *testing.T) {
+	p := newPacer()
+	config := newTestPacing()
+	config.TargetConcurrentRealtime = 2
+
+	p.completed(config, time.Second, 10*time.Second, time.Now())
+	if spacing := p.realtimeSpacing(config, time.Second); spacing != 5*time.Second {
+		t.Errorf("Spacing not kept above the realtime floor: %s", spacing)
+	}
+}
+
+// Tests that an enabled config requires valid bounds.
+func TestPacing_validate(t *testing.T) {
+	if problems := (Pacing{OverrideSpacing: 500}).validate(); len(problems) != 0 {
+		t.Errorf("Disabled config has problems: %v", problems)
+	}
+	if problems := newTestPacing().validate(); len(problems) != 0 {
+		t.Errorf("Valid config has problems: %v", problems)
+	}
+
+	invalid := Pacing{
+		Enabled:         true,
+		MaxFailureRate:  2,
+		MinSpacing:      5000,
+		MaxSpacing:      1000,
+		OverrideSpacing: -1,
+	}
+	if problems := invalid.validate(); len(problems) != 3 {
+		t.Errorf("Expected 3 problems, found %d: %v", len(problems), problems)
+	}
+}
@@ -10,13 +10,30 @@ package scheduling
 // Contains the scheduling params object and the internal protoRound object
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	// Default values for params which are not set in the scheduling config.
+	// All values are in MS
+	defaultResourceQueueTimeout  = 180000
+	defaultPrecomputationTimeout = 60000
+	defaultRealtimeTimeout       = 15000
+
+	// Upper bound on any timeout or delay in the params. Anything longer is
+	// almost certainly a units mistake (seconds vs milliseconds)
+	maxParamDuration = time.Hour
+)
+
 // This exists to provide thread-safe functionality to the Params object
 // and to allow making safe copies of the internal Params object
 type SafeParams struct {
@@ -34,6 +51,28 @@ func (s *SafeParams) SafeCopy() Params {
 	return *s.Params
 }
 
+// Update replaces the internal Params object with the passed in params. The
+// new params are picked up by the Scheduler on its next iteration.
+func (s *SafeParams) Update(newParams Params) {
+	s.Lock()
+	defer s.Unlock()
+	*s.Params = newParams
+}
+
+// UpdateWith replaces the internal Params object with the params update returns
+// for a copy of it, holding the lock throughout so that concurrent updates are
+// not lost. If update returns an error, the params are kept.
+func (s *SafeParams) UpdateWith(update func(current Params) (Params, error)) error {
+	s.Lock()
+	defer s.Unlock()
+	newParams, err := update(*s.Params)
+	if err != nil {
+		return err
+	}
+	*s.Params = newParams
+	return nil
+}
+
 // JSONable structure which defines the parameters of the Scheduler
 type Params struct {
 	// number of nodes in a team
@@ -60,13 +99,144 @@ type Params struct {
 	//SECURE ONLY
 	// Minimum percentage of nodes in the waiting pool before secure teaming wil create a team
 	Threshold float64
+	// Geographic restrictions on which nodes may be teamed together
+	TeamConstraints TeamConstraints
+	// Timeouts derived from the durations of recently completed rounds
+	AdaptiveTimeouts AdaptiveTimeouts
+	// Spacing of realtime starts adjusted to the state of the network
+	Pacing Pacing
+	// Kinds of rounds to create, each with its own sizes and timeouts. Rounds
+	// of the above sizes and timeouts are created if none are set
+	RoundClasses []RoundClass
+	// Use of node reputations when picking teams
+	Reputation Reputation
+}
+
+// WithStorageParams returns the params with the values UpdateParams polls
+// storage for taken from stored instead.
+func (p Params) WithStorageParams(stored Params) Params {
+	p.TeamSize = stored.TeamSize
+	p.BatchSize = stored.BatchSize
+	p.PrecomputationTimeout = stored.PrecomputationTimeout
+	p.RealtimeTimeout = stored.RealtimeTimeout
+	p.MinimumDelay = stored.MinimumDelay
+	p.RealtimeDelay = stored.RealtimeDelay
+	p.Threshold = stored.Threshold
+	return p
+}
+
+// LoadParams parses the scheduling params JSON, sets defaults for unset
+// timeouts, and validates the result. The passed in number of active nodes is
+// used to bound the team size; pass zero to skip that check. The country codes
+// of the team constraints are checked against the passed in GeoBin table; pass
+// nil to skip that check.
+func LoadParams(serialParam []byte, activeNodes int,
+	geoBins map[string]region.GeoBin) (Params, error) {
+	params := Params{}
+	err := json.Unmarshal(serialParam, &params)
+	if err != nil {
+		return Params{}, errors.Errorf("Could not extract parameters: %+v", err)
+	}
+
+	// If resource queue timeout isn't set, set it to a default of 3 minutes
+	if params.ResourceQueueTimeout == 0 {
+		params.ResourceQueueTimeout = defaultResourceQueueTimeout
+	}
+	// If round times haven't been set, set to a default of one minute
+	if params.PrecomputationTimeout == 0 {
+		params.PrecomputationTimeout = defaultPrecomputationTimeout
+	}
+	if params.RealtimeTimeout == 0 {
+		params.RealtimeTimeout = defaultRealtimeTimeout
+	}
+
+	return params, params.Validate(activeNodes, geoBins)
+}
+
+// Validate checks that the params are usable by the Scheduler. All problems
+// found are reported in the returned error. If activeNodes is greater than
+// zero, the team size must not exceed it. If geoBins is not nil, the country
+// codes of the team constraints must be in it.
+func (p Params) Validate(activeNodes int,
+	geoBins map[string]region.GeoBin) error {
+	var problems []string
+
+	if p.TeamSize == 0 {
+		problems = append(problems, "TeamSize must be greater than 0")
+	} else if activeNodes > 0 && int(p.TeamSize) > activeNodes {
+		problems = append(problems, fmt.Sprintf("TeamSize %d is larger "+
+			"than the number of active nodes %d", p.TeamSize, activeNodes))
+	}
+
+	if p.BatchSize == 0 {
+		problems = append(problems, "BatchSize must be greater than 0")
+	}
+
+	if p.Threshold < 0 || p.Threshold > 1 {
+		problems = append(problems, fmt.Sprintf("Threshold %f must be "+
+			"between 0 and 1", p.Threshold))
+	}
+
+	timeouts := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ResourceQueueTimeout", p.ResourceQueueTimeout},
+		{"PrecomputationTimeout", p.PrecomputationTimeout},
+		{"RealtimeTimeout", p.RealtimeTimeout},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value <= 0 {
+			problems = append(problems, fmt.Sprintf("%s must be greater "+
+				"than 0", timeout.name))
+		} else if timeout.value*time.Millisecond > maxParamDuration {
+			problems = append(problems, fmt.Sprintf("%s of %s is longer "+
+				"than the maximum of %s", timeout.name,
+				timeout.value*time.Millisecond, maxParamDuration))
+		}
+	}
+
+	delays := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"MinimumDelay", p.MinimumDelay},
+		{"RealtimeDelay", p.RealtimeDelay},
+		{"NodeCleanUpInterval", p.NodeCleanUpInterval},
+	}
+	for _, delay := range delays {
+		if delay.value < 0 {
+			problems = append(problems, fmt.Sprintf("%s cannot be "+
+				"negative", delay.name))
+		} else if delay.value*time.Millisecond > maxParamDuration {
+			problems = append(problems, fmt.Sprintf("%s of %s is longer "+
+				"than the maximum of %s", delay.name,
+				delay.value*time.Millisecond, maxParamDuration))
+		}
+	}
+
+	problems = append(problems, p.TeamConstraints.validate(p.TeamSize,
+		geoBins)...)
+	problems = append(problems, p.AdaptiveTimeouts.validate()...)
+	problems = append(problems, p.Pacing.validate()...)
+	problems = append(problems, p.validateRoundClasses(activeNodes,
+		geoBins)...)
+	problems = append(problems, p.Reputation.validate()...)
+
+	if len(problems) > 0 {
+		return errors.Errorf("Invalid scheduling params: %s",
+			strings.Join(problems, "; "))
+	}
+	return nil
 }
 
 //internal structure which describes a round to be created
 type protoRound struct {
-	Topology             *connect.Circuit
-	ID                   id.Round
-	NodeStateList        []*node.State
-	BatchSize            uint32
-	ResourceQueueTimeout time.Duration
+	Topology              *connect.Circuit
+	ID                    id.Round
+	Class                 string
+	NodeStateList         []*node.State
+	BatchSize             uint32
+	ResourceQueueTimeout  time.Duration
+	PrecomputationTimeout time.Duration
 }
@@ -0,0 +1,202 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/region"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Happy path: defaults are set for unset timeouts.
+func TestLoadParams(t *testing.T) {
+	serialParams := []byte(`{"TeamSize": 3, "BatchSize": 32, ` +
+		`"MinimumDelay": 60, "RealtimeDelay": 120, "Threshold": 0.5}`)
+
+	params, err := LoadParams(serialParams, 5, nil)
+	if err != nil {
+		t.Fatalf("LoadParams returned an error: %+v", err)
+	}
+
+	if params.TeamSize != 3 || params.BatchSize != 32 {
+		t.Errorf("Unexpected params: %+v", params)
+	}
+	if params.ResourceQueueTimeout != defaultResourceQueueTimeout ||
+		params.PrecomputationTimeout != defaultPrecomputationTimeout ||
+		params.RealtimeTimeout != defaultRealtimeTimeout {
+		t.Errorf("Defaults not set on timeouts: %+v", params)
+	}
+}
+
+// Error path: malformed JSON.
+func TestLoadParams_BadJson(t *testing.T) {
+	_, err := LoadParams([]byte(`{"TeamSize": "three"`), 0, nil)
+	if err == nil {
+		t.Errorf("LoadParams did not error on malformed JSON")
+	}
+}
+
+// Error path: the team size is larger than the active nodes.
+func TestLoadParams_TeamTooLarge(t *testing.T) {
+	serialParams := []byte(`{"TeamSize": 5, "BatchSize": 32, "Threshold": 0.5}`)
+
+	_, err := LoadParams(serialParams, 4, nil)
+	if err == nil || !strings.Contains(err.Error(), "TeamSize") {
+		t.Errorf("Expected TeamSize error, received: %+v", err)
+	}
+
+	// Passing zero active nodes skips the check
+	_, err = LoadParams(serialParams, 0, nil)
+	if err != nil {
+		t.Errorf("Unexpected error with active node check off: %+v", err)
+	}
+}
+
+// Tests that Validate reports every problem at once.
+func TestParams_Validate_AllProblems(t *testing.T) {
+	params := Params{
+		TeamSize:              0,
+		BatchSize:             0,
+		Threshold:             1.5,
+		ResourceQueueTimeout:  0,
+		PrecomputationTimeout: 60000,
+		RealtimeTimeout:       -1,
+		MinimumDelay:          -5,
+		RealtimeDelay:         10 * 60 * 60 * 1000,
+	}
+
+	err := params.Validate(3, nil)
+	if err == nil {
+		t.Fatalf("Validate did not error on invalid params")
+	}
+
+	for _, field := range []string{"TeamSize", "BatchSize", "Threshold",
+		"ResourceQueueTimeout", "RealtimeTimeout", "MinimumDelay",
+		"RealtimeDelay"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("Error does not report %s: %+v", field, err)
+		}
+	}
+	if strings.Contains(err.Error(), "PrecomputationTimeout") {
+		t.Errorf("Error reports valid PrecomputationTimeout: %+v", err)
+	}
+}
+
+// Tests that SafeParams.Update replaces the params seen by SafeCopy.
+func TestSafeParams_Update(t *testing.T) {
+	sp := &SafeParams{
+		RWMutex: sync.RWMutex{},
+		Params:  &Params{TeamSize: 3},
+	}
+
+	sp.Update(Params{TeamSize: 5, BatchSize: 64})
+
+	received := sp.SafeCopy()
+	if received.TeamSize != 5 || received.BatchSize != 64 {
+		t.Errorf("SafeCopy did not return the updated params: %+v", received)
+	}
+}
+
+// Tests that SafeParams.UpdateWith applies the params returned for the current
+// params and keeps them if an error is returned.
+func TestSafeParams_UpdateWith(t *testing.T) {
+	sp := &SafeParams{Params: &Params{TeamSize: 3, BatchSize: 32}}
+
+	err := sp.UpdateWith(func(current Params) (Params, error) {
+		current.TeamSize++
+		return current, nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update: %+v", err)
+	}
+	if received := sp.SafeCopy(); received.TeamSize != 4 ||
+		received.BatchSize != 32 {
+		t.Errorf("Update was not applied to the current params: %+v", received)
+	}
+
+	err = sp.UpdateWith(func(current Params) (Params, error) {
+		return Params{}, errors.New("rejected")
+	})
+	if err == nil || sp.SafeCopy().TeamSize != 4 {
+		t.Errorf("Rejected update changed the params: %+v", sp.SafeCopy())
+	}
+}
+
+// Tests that WithStorageParams only takes the params polled from storage.
+func TestParams_WithStorageParams(t *testing.T) {
+	fromFile := Params{TeamSize: 3, BatchSize: 32, RealtimeTimeout: 1000,
+		ResourceQueueTimeout: 5000, Pacing: Pacing{MaxFailureRate: 0.2}}
+	stored := Params{TeamSize: 5, BatchSize: 64, RealtimeTimeout: 2000,
+		ResourceQueueTimeout: 1, Pacing: Pacing{MaxFailureRate: 0.5}}
+
+	merged := fromFile.WithStorageParams(stored)
+	if merged.TeamSize != 5 || merged.BatchSize != 64 ||
+		merged.RealtimeTimeout != 2000 {
+		t.Errorf("Stored params not taken: %+v", merged)
+	}
+	if merged.ResourceQueueTimeout != 5000 || merged.Pacing.MaxFailureRate != 0.2 {
+		t.Errorf("Params not polled from storage were replaced: %+v", merged)
+	}
+}
+
+// Tests that updateParams applies the params stored under the keys of the
+// network rather than those of the primary network.
+func Test_updateParams_Network(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	state, err := storage.NewNetworkState("testnet", 100, 0, privKey, 8, "",
+		"", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %+v", err)
+	}
+
+	values := map[string]string{
+		storage.TeamSize:             "3",
+		storage.BatchSize:            "64",
+		storage.PrecompTimeout:       "60000",
+		storage.RealtimeTimeout:      "60000",
+		storage.MinDelay:             "1",
+		storage.AdvertisementTimeout: "1000",
+		storage.PoolThreshold:        "0.5",
+	}
+	for key, value := range values {
+		for _, s := range []*storage.State{{Key: key, Value: "1"},
+			{Key: state.GetStateKey(key), Value: value}} {
+			if err = storage.PermissioningDb.UpsertState(s); err != nil {
+				t.Fatalf("Failed to store %s: %+v", s.Key, err)
+			}
+		}
+	}
+
+	loaded, err := LoadParams([]byte(`{"TeamSize": 5, "BatchSize": 32, `+
+		`"MinimumDelay": 60, "RealtimeDelay": 120, "Threshold": 0.3}`), 0, nil)
+	if err != nil {
+		t.Fatalf("LoadParams returned an error: %+v", err)
+	}
+	params := &SafeParams{Params: &loaded}
+
+	if err = updateParams(params, state); err != nil {
+		t.Fatalf("updateParams returned an error: %+v", err)
+	}
+
+	updated := params.SafeCopy()
+	if updated.TeamSize != 3 || updated.BatchSize != 64 ||
+		updated.Threshold != 0.5 {
+		t.Errorf("Params of the network not applied: %+v", updated)
+	}
+}
@@ -0,0 +1,65 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+// Contains the control used to pause and resume team formation
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"sync/atomic"
+)
+
+// Pauser pauses and resumes team formation in the Scheduler. While paused, the
+// Scheduler keeps handling node updates so that polls are accepted, in-flight
+// rounds complete, and waiting nodes stay in the pool for when it resumes.
+type Pauser struct {
+	paused uint32
+
+	// Wakes the Scheduler on resume so that teams are formed from the nodes
+	// which entered the pool while paused
+	wake chan struct{}
+}
+
+// NewPauser creates a Pauser with team formation running.
+func NewPauser() *Pauser {
+	return &Pauser{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+// Pause stops the Scheduler from forming new teams. Returns false if it was
+// already paused.
+func (p *Pauser) Pause() bool {
+	if !atomic.CompareAndSwapUint32(&p.paused, 0, 1) {
+		return false
+	}
+
+	jww.WARN.Printf("Round creation paused")
+	return true
+}
+
+// Resume allows the Scheduler to form new teams again. Returns false if it was
+// not paused.
+func (p *Pauser) Resume() bool {
+	if !atomic.CompareAndSwapUint32(&p.paused, 1, 0) {
+		return false
+	}
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+
+	jww.WARN.Printf("Round creation resumed")
+	return true
+}
+
+// IsPaused returns true if team formation is paused.
+func (p *Pauser) IsPaused() bool {
+	return atomic.LoadUint32(&p.paused) == 1
+}
@@ -0,0 +1,61 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"testing"
+)
+
+// Tests that Pause and Resume only succeed when changing the paused state and
+// that resuming wakes the Scheduler.
+func TestPauser_PauseResume(t *testing.T) {
+	p := NewPauser()
+	if p.IsPaused() {
+		t.Fatalf("New Pauser is paused.")
+	}
+
+	if p.Resume() {
+		t.Errorf("Resume succeeded when not paused.")
+	}
+
+	if !p.Pause() {
+		t.Errorf("Pause failed when not paused.")
+	}
+	if !p.IsPaused() {
+		t.Errorf("Pauser not paused after Pause.")
+	}
+	if p.Pause() {
+		t.Errorf("Pause succeeded when already paused.")
+	}
+
+	if !p.Resume() {
+		t.Errorf("Resume failed when paused.")
+	}
+	if p.IsPaused() {
+		t.Errorf("Pauser paused after Resume.")
+	}
+
+	select {
+	case <-p.wake:
+	default:
+		t.Errorf("Resume did not wake the Scheduler.")
+	}
+}
+
+// Tests that repeated resumes do not block when the Scheduler has not woken.
+func TestPauser_Resume_NoBlock(t *testing.T) {
+	p := NewPauser()
+	for i := 0; i < 3; i++ {
+		p.Pause()
+		p.Resume()
+	}
+
+	if len(p.wake) != 1 {
+		t.Errorf("Expected one wake signal, found %d.", len(p.wake))
+	}
+}
@@ -13,7 +13,9 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/crypto/shuffle"
 	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/region"
 	"sync"
+	"time"
 )
 
 // pool.go contains logic for the secure teaming algorithm's
@@ -29,9 +31,17 @@ type waitingPool struct {
 	pool    *set.Set
 	offline *set.Set
 
+	// Orders the pool before a team is picked from it; the pool is shuffled
+	// if nil
+	order teamOrder
+
 	mux sync.RWMutex
 }
 
+// teamOrder returns the nodes in the random order they are considered for a
+// team in.
+type teamOrder func(nodes []*node.State) []*node.State
+
 // NewWaitingPool is a constructor for the waiting pool object
 func NewWaitingPool() *waitingPool {
 	return &waitingPool{
@@ -61,6 +71,14 @@ func (wp *waitingPool) Add(n *node.State) {
 	wp.mux.Unlock()
 }
 
+// SetOrder sets the order the pool is considered in when picking a team. A nil
+// order shuffles the pool uniformly.
+func (wp *waitingPool) SetOrder(order teamOrder) {
+	wp.mux.Lock()
+	wp.order = order
+	wp.mux.Unlock()
+}
+
 // Removes the node from the pool banning it
 func (wp *waitingPool) Ban(n *node.State) {
 	wp.mux.Lock()
@@ -69,6 +87,15 @@ func (wp *waitingPool) Ban(n *node.State) {
 	wp.mux.Unlock()
 }
 
+// Remove takes the node out of both the online and offline pools so that it is
+// not picked for a team until it is added back
+func (wp *waitingPool) Remove(n *node.State) {
+	wp.mux.Lock()
+	wp.pool.Remove(n)
+	wp.offline.Remove(n)
+	wp.mux.Unlock()
+}
+
 // SetNodeToOnline removes a node from the offline pool and
 //  inserts it into the online pool
 func (wp *waitingPool) SetNodeToOnline(ns *node.State) {
@@ -80,6 +107,41 @@ func (wp *waitingPool) SetNodeToOnline(ns *node.State) {
 	wp.pool.Insert(ns)
 }
 
+// SetNodeToOffline removes a node from the online pool and
+//  inserts it into the offline pool
+func (wp *waitingPool) SetNodeToOffline(ns *node.State) {
+	jww.TRACE.Printf("Node %v is offline. Removing from waiting pool", ns.GetID())
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	wp.pool.Remove(ns)
+	wp.offline.Insert(ns)
+}
+
+// SetNodesToOffline moves the nodes in the online pool which have not polled
+//  since the cutoff into the offline pool, marks them inactive, and returns
+//  them. They return to the online pool through SetNodeToOnline once they
+//  poll again.
+func (wp *waitingPool) SetNodesToOffline(cutoff time.Time) []*node.State {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	var offline []*node.State
+	wp.pool.Do(func(face interface{}) {
+		n := face.(*node.State)
+		if n.GetLastPoll().Before(cutoff) {
+			offline = append(offline, n)
+		}
+	})
+
+	for _, n := range offline {
+		n.SetInactive()
+		wp.pool.Remove(n)
+		wp.offline.Insert(n)
+	}
+	return offline
+}
+
 // PickNRandAtThreshold collects n nodes at random from the pool and returns
 //   those nodes.
 // If there are not enough nodes, either from the threshold or
@@ -101,25 +163,8 @@ func (wp *waitingPool) PickNRandAtThreshold(thresh, n int) ([]*node.State, error
 			" to pick %v nodes", newPool.Len(), n)
 	}
 
-	// Create an incrementing list of numbers up to pool's length
-	numList := make([]uint32, newPool.Len())
-	for i := 0; i < newPool.Len(); i++ {
-		numList[i] = uint32(i)
-	}
-
-	// Shuffle these numbers
-	shuffle.Shuffle32(&numList)
-
-	var nodeList []*node.State
-	iterator := 0
-
 	// Collect nodes from pool at random
-	newPool.Do(func(face interface{}) {
-		if numList[iterator] < uint32(n) {
-			nodeList = append(nodeList, face.(*node.State))
-		}
-		iterator++
-	})
+	nodeList := wp.candidates()[:n]
 
 	// Remove collected nodes from pool
 	for _, ns := range nodeList {
@@ -129,3 +174,126 @@ func (wp *waitingPool) PickNRandAtThreshold(thresh, n int) ([]*node.State, error
 	// Return collected ndoes
 	return nodeList, nil
 }
+
+// Nodes returns the nodes in the online pool.
+func (wp *waitingPool) Nodes() []*node.State {
+	wp.mux.RLock()
+	defer wp.mux.RUnlock()
+
+	nodes := make([]*node.State, 0, wp.pool.Len())
+	wp.pool.Do(func(face interface{}) {
+		nodes = append(nodes, face.(*node.State))
+	})
+	return nodes
+}
+
+// PickNRandWithConstraints collects n nodes at random from the pool which
+// together satisfy the team constraints and returns those nodes. Nodes are
+// considered in a random order; while the team lacks the minimum number of
+// countries only nodes from new countries are taken, then the rest of the team
+// is filled from any country the constraints allow. With OperatorTeamsFixed,
+// each operator team is tried in a random order until one can fill the team.
+// As a single pass can miss a satisfying team, up to teamPickAttempts random
+// orders of the pool are tried. Operator teams are looked up in operators,
+// which may be nil if the constraints do not use them.
+// If there are not enough nodes, either from the threshold or the requested
+// nodes, this function errors. If no satisfying team is found, it returns
+// errTeamConstraints and the pool is left untouched.
+func (wp *waitingPool) PickNRandWithConstraints(thresh, n int,
+	constraints TeamConstraints, geoBins map[string]region.GeoBin,
+	operators *operatorTeams) ([]*node.State, error) {
+	wp.mux.Lock()
+	defer wp.mux.Unlock()
+
+	// Check that the pool meets the threshold requirement
+	if wp.pool.Len() < thresh {
+		return nil, errors.Errorf("Number of stored nodes (%v) does not reach threshold", wp.pool.Len())
+	}
+
+	// Check that the pool has enough nodes to satisfy n
+	if wp.pool.Len() < n {
+		return nil, errors.Errorf("Number of stored nodes (%v) not enough"+
+			" to pick %v nodes", wp.pool.Len(), n)
+	}
+
+	for attempt := 0; attempt < teamPickAttempts; attempt++ {
+		// Place the pool in a new random order
+		team := pickConstrainedTeam(wp.candidates(), n, constraints, geoBins,
+			operators)
+		if team == nil {
+			continue
+		}
+
+		// Remove collected nodes from pool
+		for _, ns := range team {
+			wp.pool.Remove(ns)
+		}
+
+		return team, nil
+	}
+
+	return nil, errTeamConstraints
+}
+
+// pickConstrainedTeam makes a single pass over the candidates, in order, for a
+// team of n nodes satisfying the constraints. Returns nil if none is found.
+func pickConstrainedTeam(candidates []*node.State, n int,
+	constraints TeamConstraints, geoBins map[string]region.GeoBin,
+	operators *operatorTeams) []*node.State {
+	// Split the candidates by operator team, in the random order in which
+	// each team is first seen
+	groups := [][]*node.State{candidates}
+	if constraints.OperatorTeams == OperatorTeamsFixed {
+		groups = nil
+		groupIndex := make(map[string]int)
+		for _, ns := range candidates {
+			team := operators.teamOf(ns)
+			if team == "" {
+				continue
+			}
+			i, exists := groupIndex[team]
+			if !exists {
+				i = len(groups)
+				groupIndex[team] = i
+				groups = append(groups, nil)
+			}
+			groups[i] = append(groups[i], ns)
+		}
+	}
+
+	for _, group := range groups {
+		if len(group) < n {
+			continue
+		}
+
+		tb := newTeamBuilder(constraints, geoBins, operators)
+		if tb.fill(group, n) {
+			return tb.team
+		}
+	}
+
+	return nil
+}
+
+// candidates returns the nodes in the pool in the order they are considered
+// for a team. The lock must be held.
+func (wp *waitingPool) candidates() []*node.State {
+	pooled := make([]*node.State, 0, wp.pool.Len())
+	wp.pool.Do(func(face interface{}) {
+		pooled = append(pooled, face.(*node.State))
+	})
+	if wp.order != nil {
+		return wp.order(pooled)
+	}
+
+	numList := make([]uint32, len(pooled))
+	for i := range numList {
+		numList[i] = uint32(i)
+	}
+	shuffle.Shuffle32(&numList)
+	candidates := make([]*node.State, len(pooled))
+	for i, num := range numList {
+		candidates[i] = pooled[num]
+	}
+	return candidates
+}
@@ -92,6 +92,27 @@ func TestWaitingPool_SetNodeToOnline(t *testing.T) {
 
 }
 
+// Tests that Remove takes a node out of both the online and offline pools.
+func TestWaitingPool_Remove(t *testing.T) {
+	testPool := NewWaitingPool()
+	nodeMap := setupNodeMap(t)
+	online := setupNode(t, nodeMap, 0)
+	offline := setupNode(t, nodeMap, 1)
+
+	testPool.Add(online)
+	testPool.offline.Insert(offline)
+
+	testPool.Remove(online)
+	testPool.Remove(offline)
+
+	if testPool.Len() != 0 {
+		t.Errorf("Online pool expected to be empty. Actual size: %d", testPool.Len())
+	}
+	if testPool.OfflineLen() != 0 {
+		t.Errorf("Offline pool expected to be empty. Actual size: %d", testPool.OfflineLen())
+	}
+}
+
 func TestWaitingPool_PickNRandAtThreshold(t *testing.T) {
 	testPool := NewWaitingPool()
 	testState := setupNodeMap(t)
@@ -125,6 +146,42 @@ func TestWaitingPool_PickNRandAtThreshold(t *testing.T) {
 
 }
 
+// Tests that a team is picked in the order set on the pool.
+func TestWaitingPool_PickNRandAtThreshold_Order(t *testing.T) {
+	testPool := NewWaitingPool()
+	testState := setupNodeMap(t)
+
+	nodes := make([]*node.State, 4)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		testPool.Add(nodes[i])
+	}
+
+	// Order the nodes by ID, so that the first two are always picked
+	testPool.SetOrder(func(pooled []*node.State) []*node.State {
+		ordered := make([]*node.State, len(pooled))
+		for _, n := range pooled {
+			for i := range nodes {
+				if nodes[i] == n {
+					ordered[i] = n
+				}
+			}
+		}
+		return ordered
+	})
+
+	nodeList, err := testPool.PickNRandAtThreshold(2, 2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(nodeList) != 2 || nodeList[0] != nodes[0] || nodeList[1] != nodes[1] {
+		t.Errorf("Team not picked in order: %v", nodeList)
+	}
+	if testPool.Len() != 2 {
+		t.Errorf("Picked nodes not removed from the pool")
+	}
+}
+
 // Error path: does not meet threshold
 func TestWaitingPool_PickNRandAtThreshold_ThresholdErr(t *testing.T) {
 	testPool := NewWaitingPool()
@@ -210,3 +267,30 @@ func setupNodeMap(t *testing.T) *storage.NetworkState {
 
 	return testState
 }
+
+// Tests that SetNodesToOffline moves only the nodes which have not polled
+// since the cutoff to the offline pool.
+func TestWaitingPool_SetNodesToOffline(t *testing.T) {
+	testPool := NewWaitingPool()
+	nodeMap := setupNodeMap(t)
+	stale := setupNode(t, nodeMap, 0)
+	fresh := setupNode(t, nodeMap, 1)
+
+	cutoff := time.Now()
+	stale.SetLastPoll(cutoff.Add(-time.Minute), t)
+	fresh.SetLastPoll(cutoff.Add(time.Minute), t)
+	testPool.Add(stale)
+	testPool.Add(fresh)
+
+	offline := testPool.SetNodesToOffline(cutoff)
+	if len(offline) != 1 || offline[0] != stale {
+		t.Fatalf("Unexpected nodes set to offline: %v", offline)
+	}
+	if !testPool.offline.Has(stale) || testPool.pool.Has(stale) ||
+		!testPool.pool.Has(fresh) {
+		t.Errorf("Nodes not moved to the correct pools.")
+	}
+	if stale.GetStatus() != node.Inactive {
+		t.Errorf("Offline node has status %s", stale.GetStatus())
+	}
+}
@@ -0,0 +1,396 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// reputation.go contains the reputation of each node, scored from the outcomes
+// of its rounds, the timeouts it caused, how often it stopped polling, and its
+// uptime, and the team orders which favour nodes with a better score.
+
+const (
+	// ReputationWeighted picks nodes with a probability proportional to their
+	// score
+	ReputationWeighted = "weighted"
+	// ReputationTiered picks nodes scoring at least the TierThreshold before
+	// the others, uniformly at random within each tier
+	ReputationTiered = "tiered"
+
+	// Defaults for the Reputation which are not set
+	defaultReputationDecay         = 0.98
+	defaultReputationMinWeight     = 0.05
+	defaultReputationTierThreshold = 0.5
+
+	// History assumed of a node which has not been in a round, as completed
+	// rounds out of all rounds
+	reputationPriorCompleted = 4
+	reputationPriorRounds    = 5
+	// Number of failed rounds a timeout caused by the node counts as
+	reputationTimeoutPenalty = 2
+
+	// How often changed reputations are written to storage
+	reputationPersistInterval = time.Minute
+)
+
+// Reputation configures how the reputation of nodes is used to pick teams.
+// Reputations are tracked whether or not they are used. An empty Mode picks
+// teams uniformly at random.
+type Reputation struct {
+	// Either ReputationWeighted or ReputationTiered
+	Mode string
+	// Fraction of a node's history kept each time a new outcome is recorded.
+	// Defaults to 0.98
+	Decay float64
+	// Weight of the lowest scoring nodes, so that every node keeps a chance of
+	// being picked. Defaults to 0.05
+	MinWeight float64
+	// Score from which a node is in the upper tier. Defaults to 0.5
+	TierThreshold float64
+}
+
+// enabled returns true if reputations are used to pick teams.
+func (r Reputation) enabled() bool {
+	return r.Mode != ""
+}
+
+// withDefaults returns the config with defaults for the unset fields.
+func (r Reputation) withDefaults() Reputation {
+	if r.Decay == 0 {
+		r.Decay = defaultReputationDecay
+	}
+	if r.MinWeight == 0 {
+		r.MinWeight = defaultReputationMinWeight
+	}
+	if r.TierThreshold == 0 {
+		r.TierThreshold = defaultReputationTierThreshold
+	}
+	return r
+}
+
+// validate returns every problem with the config.
+func (r Reputation) validate() []string {
+	var problems []string
+	if r.Mode != "" && r.Mode != ReputationWeighted &&
+		r.Mode != ReputationTiered {
+		problems = append(problems, fmt.Sprintf("Reputation.Mode %q must "+
+			"be %q, %q, or empty", r.Mode, ReputationWeighted,
+			ReputationTiered))
+	}
+	fractions := []struct {
+		name  string
+		value float64
+	}{
+		{"Decay", r.Decay},
+		{"MinWeight", r.MinWeight},
+		{"TierThreshold", r.TierThreshold},
+	}
+	for _, f := range fractions {
+		if f.value < 0 || f.value > 1 {
+			problems = append(problems, fmt.Sprintf("Reputation.%s %f must "+
+				"be between 0 and 1", f.name, f.value))
+		}
+	}
+	return problems
+}
+
+// reputation holds the reputation of every node of a network which has been
+// tracked. It is safe for concurrent use.
+type reputation struct {
+	mux     sync.Mutex
+	network string
+	nodes   map[id.ID]*storage.NodeReputation
+	// Nodes whose reputation changed since it was last stored
+	dirty map[id.ID]bool
+}
+
+// newReputation creates a reputation of the network with no history.
+func newReputation(network string) *reputation {
+	return &reputation{
+		network: network,
+		nodes:   make(map[id.ID]*storage.NodeReputation),
+		dirty:   make(map[id.ID]bool),
+	}
+}
+
+// loadReputation creates a reputation from the reputations of the network in
+// storage.
+func loadReputation(network string) (*reputation, error) {
+	stored, err := storage.PermissioningDb.GetNodeReputations(network)
+	if err != nil {
+		return nil, errors.Errorf("Failed to load node reputations: %+v", err)
+	}
+
+	rep := newReputation(network)
+	for _, nr := range stored {
+		nid, err := id.Unmarshal(nr.NodeId)
+		if err != nil {
+			jww.WARN.Printf("Skipping reputation with invalid node ID: %+v",
+				err)
+			continue
+		}
+		rep.nodes[*nid] = nr
+	}
+	return rep, nil
+}
+
+// completed records a round which the team completed.
+func (rep *reputation) completed(config Reputation, topology *connect.Circuit,
+	now time.Time) {
+	rep.record(config, topologyIDs(topology), now,
+		func(nr *storage.NodeReputation) { nr.Completed++ })
+}
+
+// failed records a round which failed with the team.
+func (rep *reputation) failed(config Reputation, topology *connect.Circuit,
+	now time.Time) {
+	rep.record(config, topologyIDs(topology), now,
+		func(nr *storage.NodeReputation) { nr.Failed++ })
+}
+
+// timedOut records a round which timed out waiting on the nodes.
+func (rep *reputation) timedOut(config Reputation, nodes []*id.ID,
+	now time.Time) {
+	rep.record(config, nodes, now,
+		func(nr *storage.NodeReputation) { nr.Timeouts++ })
+}
+
+// disconnected records that the node stopped polling while waiting.
+func (rep *reputation) disconnected(config Reputation, nid *id.ID,
+	now time.Time) {
+	rep.record(config, []*id.ID{nid}, now, func(nr *storage.NodeReputation) {
+		nr.Disconnects++
+		if nr.OfflineSince.IsZero() {
+			nr.OfflineSince = now
+		}
+	})
+}
+
+// connected records that the node polled again after it stopped, adding the
+// time it was offline to its downtime.
+func (rep *reputation) connected(nid *id.ID, now time.Time) {
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+
+	nr, exists := rep.nodes[*nid]
+	if !exists || nr.OfflineSince.IsZero() {
+		return
+	}
+	if now.After(nr.OfflineSince) {
+		nr.Downtime += now.Sub(nr.OfflineSince)
+	}
+	nr.OfflineSince = time.Time{}
+	nr.LastUpdated = now
+	rep.dirty[*nid] = true
+}
+
+// record decays the history of each node and applies the outcome to it.
+func (rep *reputation) record(config Reputation, nodes []*id.ID,
+	now time.Time, outcome func(nr *storage.NodeReputation)) {
+	decay := config.withDefaults().Decay
+
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+	for _, nid := range nodes {
+		nr := rep.get(nid, now)
+		nr.Completed *= decay
+		nr.Failed *= decay
+		nr.Timeouts *= decay
+		nr.Disconnects *= decay
+		outcome(nr)
+		nr.LastUpdated = now
+		rep.dirty[*nid] = true
+	}
+}
+
+// get returns the reputation of the node, tracking it from now if it is new.
+// The lock must be held.
+func (rep *reputation) get(nid *id.ID, now time.Time) *storage.NodeReputation {
+	nr, exists := rep.nodes[*nid]
+	if !exists {
+		nr = &storage.NodeReputation{
+			NodeId:    nid.Marshal(),
+			Network:   rep.network,
+			FirstSeen: now,
+		}
+		rep.nodes[*nid] = nr
+	}
+	return nr
+}
+
+// score returns the score of the node between 0 and 1. Nodes which have not
+// been tracked have the score of the prior.
+func (rep *reputation) score(nid *id.ID, now time.Time) float64 {
+	rep.mux.Lock()
+	defer rep.mux.Unlock()
+	return rep.scoreLocked(nid, now)
+}
+
+// scoreLocked returns the score of the node, which is the fraction of its
+// rounds that completed, starting from the prior and counting its timeouts
+// and disconnects as failures, multiplied by its uptime. The lock must be
+// held.
+func (rep *reputation) scoreLocked(nid *id.ID, now time.Time) float64 {
+	nr, exists := rep.nodes[*nid]
+	if !exists {
+		return float64(reputationPriorCompleted) / reputationPriorRounds
+	}
+
+	rounds := nr.Completed + nr.Failed + nr.Disconnects +
+		reputationTimeoutPenalty*nr.Timeouts
+	reliability := (nr.Completed + reputationPriorCompleted) /
+		(rounds + reputationPriorRounds)
+
+	uptime := 1.0
+	if observed := now.Sub(nr.FirstSeen); observed > 0 {
+		downtime := nr.Downtime
+		if !nr.OfflineSince.IsZero() && now.After(nr.OfflineSince) {
+			downtime += now.Sub(nr.OfflineSince)
+		}
+		uptime = math.Max(0, 1-float64(downtime)/float64(observed))
+	}
+
+	return reliability * uptime
+}
+
+// order returns the team order for the config, or nil to shuffle the pool
+// uniformly if reputations are not used. Each node is given a random key
+// which favours higher weights, so that any node may still be picked.
+func (rep *reputation) order(config Reputation) teamOrder {
+	if !config.enabled() {
+		return nil
+	}
+	config = config.withDefaults()
+
+	return func(nodes []*node.State) []*node.State {
+		type keyedNode struct {
+			n    *node.State
+			tier int
+			key  float64
+		}
+
+		now := time.Now()
+		keyed := make([]keyedNode, len(nodes))
+		rep.mux.Lock()
+		for i, n := range nodes {
+			score := rep.scoreLocked(n.GetID(), now)
+			weight := math.Max(score, config.MinWeight)
+			tier := 0
+			if config.Mode == ReputationTiered {
+				weight = 1
+				if score < config.TierThreshold {
+					tier = 1
+				}
+			}
+			// Weighted sampling without replacement: sorting by u^(1/w)
+			// picks each node with a probability proportional to its weight
+			keyed[i] = keyedNode{n: n, tier: tier,
+				key: math.Log(randomUnit()) / weight}
+		}
+		rep.mux.Unlock()
+
+		sort.Slice(keyed, func(i, j int) bool {
+			if keyed[i].tier != keyed[j].tier {
+				return keyed[i].tier < keyed[j].tier
+			}
+			return keyed[i].key > keyed[j].key
+		})
+
+		ordered := make([]*node.State, len(keyed))
+		for i, k := range keyed {
+			ordered[i] = k.n
+		}
+		return ordered
+	}
+}
+
+// persist writes the reputations which changed since they were last written
+// to storage. They are written again on the next call if this fails.
+func (rep *reputation) persist() error {
+	rep.mux.Lock()
+	changed := make([]*storage.NodeReputation, 0, len(rep.dirty))
+	for nid := range rep.dirty {
+		nr := *rep.nodes[nid]
+		changed = append(changed, &nr)
+	}
+	rep.dirty = make(map[id.ID]bool)
+	rep.mux.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+	err := storage.PermissioningDb.UpsertNodeReputations(changed)
+	if err != nil {
+		rep.mux.Lock()
+		for _, nr := range changed {
+			nid, _ := id.Unmarshal(nr.NodeId)
+			rep.dirty[*nid] = true
+		}
+		rep.mux.Unlock()
+		return errors.Errorf("Failed to store %d node reputations: %+v",
+			len(changed), err)
+	}
+	return nil
+}
+
+// roundTimedOut records a failed round for the team of the timed out round
+// and a timeout for the members it is blamed on. Rounds which already ended
+// are not recorded.
+func (rep *reputation) roundTimedOut(config Reputation,
+	state *storage.NetworkState, rid id.Round, now time.Time) {
+	r, exists := state.GetRoundMap().GetRound(rid)
+	if !exists {
+		return
+	}
+	if roundState := r.GetRoundState(); roundState == states.COMPLETED ||
+		roundState == states.FAILED {
+		return
+	}
+
+	rep.failed(config, r.GetTopology(), now)
+	blame := blameRound(state, r, nil, now)
+	if len(blame) > 0 {
+		blamed := make([]*id.ID, len(blame))
+		for i, b := range blame {
+			blamed[i] = b.NodeID
+		}
+		rep.timedOut(config, blamed, now)
+	}
+}
+
+// topologyIDs returns the IDs of the nodes in the topology.
+func topologyIDs(topology *connect.Circuit) []*id.ID {
+	ids := make([]*id.ID, topology.Len())
+	for i := range ids {
+		ids[i] = topology.GetNodeAtIndex(i)
+	}
+	return ids
+}
+
+// randomUnit returns a random number greater than 0 and at most 1.
+func randomUnit() float64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		jww.FATAL.Panicf("Failed to read random bytes: %+v", err)
+	}
+	return float64(binary.BigEndian.Uint64(b[:])>>11+1) / (1 << 53)
+}
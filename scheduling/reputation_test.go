@@ -0,0 +1,175 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that completed rounds raise a node's score above the prior and that
+// failures, and timeouts more so, lower it.
+func TestReputation_score(t *testing.T) {
+	rep := newReputation("")
+	now := time.Now()
+	good := id.NewIdFromUInt(0, id.Node, t)
+	failing := id.NewIdFromUInt(1, id.Node, t)
+	lagging := id.NewIdFromUInt(2, id.Node, t)
+	prior := rep.score(id.NewIdFromUInt(3, id.Node, t), now)
+
+	team := connect.NewCircuit([]*id.ID{good, failing, lagging})
+	for i := 0; i < 5; i++ {
+		rep.completed(Reputation{}, connect.NewCircuit([]*id.ID{good}), now)
+		rep.failed(Reputation{}, connect.NewCircuit([]*id.ID{failing}), now)
+	}
+	for i := 0; i < 5; i++ {
+		rep.failed(Reputation{}, connect.NewCircuit([]*id.ID{lagging}), now)
+		rep.timedOut(Reputation{}, []*id.ID{lagging}, now)
+	}
+	rep.completed(Reputation{}, team, now)
+
+	goodScore, failingScore, laggingScore := rep.score(good, now),
+		rep.score(failing, now), rep.score(lagging, now)
+	if !(goodScore > prior && prior > failingScore &&
+		failingScore > laggingScore) {
+		t.Errorf("Unexpected scores: good %f, prior %f, failing %f, "+
+			"lagging %f", goodScore, prior, failingScore, laggingScore)
+	}
+}
+
+// Tests that the time a node spends offline lowers its score by its uptime.
+func TestReputation_score_Uptime(t *testing.T) {
+	rep := newReputation("")
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	start := time.Unix(1000, 0)
+
+	rep.completed(Reputation{}, connect.NewCircuit([]*id.ID{nid}), start)
+	before := rep.score(nid, start.Add(time.Hour))
+
+	// Offline for a quarter of the next hour
+	rep.disconnected(Reputation{}, nid, start.Add(time.Hour))
+	rep.connected(nid, start.Add(75*time.Minute))
+	after := rep.score(nid, start.Add(2*time.Hour))
+
+	nr := rep.nodes[*nid]
+	if nr.Downtime != 15*time.Minute || !nr.OfflineSince.IsZero() {
+		t.Errorf("Unexpected downtime %s since %s", nr.Downtime,
+			nr.OfflineSince)
+	}
+	if after >= before {
+		t.Errorf("Score did not drop with downtime: %f, %f", before, after)
+	}
+}
+
+// Tests that old outcomes decay, so that a node recovers its score.
+func TestReputation_record_Decay(t *testing.T) {
+	rep := newReputation("")
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	topology := connect.NewCircuit([]*id.ID{nid})
+	config := Reputation{Decay: 0.5}
+	now := time.Now()
+
+	rep.failed(config, topology, now)
+	rep.completed(config, topology, now)
+	rep.completed(config, topology, now)
+
+	nr := rep.nodes[*nid]
+	if nr.Failed != 0.25 || nr.Completed != 1.5 {
+		t.Errorf("Unexpected decayed history: %+v", nr)
+	}
+}
+
+// Tests that the weighted order favours higher scores without always putting
+// them first, and that the tiered order always puts the upper tier first.
+func TestReputation_order(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	testState := setupNodeMap(t)
+	rep := newReputation("")
+	now := time.Now()
+	good := setupNode(t, testState, 0)
+	bad := setupNode(t, testState, 1)
+	for i := 0; i < 20; i++ {
+		rep.completed(Reputation{}, connect.NewCircuit([]*id.ID{good.GetID()}), now)
+		rep.timedOut(Reputation{}, []*id.ID{bad.GetID()}, now)
+	}
+
+	if rep.order(Reputation{}) != nil {
+		t.Errorf("Order returned while reputations are disabled")
+	}
+
+	weighted := rep.order(Reputation{Mode: ReputationWeighted})
+	goodFirst := 0
+	const trials = 1000
+	for i := 0; i < trials; i++ {
+		if weighted([]*node.State{bad, good})[0] == good {
+			goodFirst++
+		}
+	}
+	if goodFirst < trials/2 || goodFirst == trials {
+		t.Errorf("Good node was first in %d of %d weighted orders",
+			goodFirst, trials)
+	}
+
+	tiered := rep.order(Reputation{Mode: ReputationTiered})
+	for i := 0; i < 100; i++ {
+		if tiered([]*node.State{bad, good})[0] != good {
+			t.Fatalf("Lower tier node ordered before upper tier node")
+		}
+	}
+}
+
+// Tests that changed reputations are stored and loaded again.
+func TestReputation_persist(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	rep := newReputation("")
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	rep.completed(Reputation{}, connect.NewCircuit([]*id.ID{nid}), time.Now())
+	if err = rep.persist(); err != nil {
+		t.Fatalf("Failed to persist: %+v", err)
+	}
+	if len(rep.dirty) != 0 {
+		t.Errorf("Reputations still changed after persisting: %d",
+			len(rep.dirty))
+	}
+
+	loaded, err := loadReputation("")
+	if err != nil {
+		t.Fatalf("Failed to load: %+v", err)
+	}
+	if nr, exists := loaded.nodes[*nid]; !exists || nr.Completed != 1 {
+		t.Errorf("Reputation not loaded: %+v", nr)
+	}
+}
+
+// Tests that only known modes and fractions between 0 and 1 are valid.
+func TestReputation_validate(t *testing.T) {
+	if problems := (Reputation{}).validate(); len(problems) != 0 {
+		t.Errorf("Empty config has problems: %v", problems)
+	}
+	valid := Reputation{Mode: ReputationTiered, TierThreshold: 0.7}
+	if problems := valid.validate(); len(problems) != 0 {
+		t.Errorf("Valid config has problems: %v", problems)
+	}
+	invalid := Reputation{Mode: "best", Decay: 2, MinWeight: -1}
+	if problems := invalid.validate(); len(problems) != 3 {
+		t.Errorf("Expected 3 problems, found %d: %v", len(problems), problems)
+	}
+}
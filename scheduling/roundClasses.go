@@ -0,0 +1,179 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"fmt"
+	"gitlab.com/xx_network/primitives/region"
+	"time"
+)
+
+// roundClasses.go contains the classes of rounds the Scheduler creates, each
+// with its own team size, batch size and timeouts, and the logic which picks
+// the class of the next round by weight.
+
+// defaultRoundClass names the class of rounds when no classes are configured.
+const defaultRoundClass = "default"
+
+// RoundClass describes a kind of round. A zero value for the team size, batch
+// size or any timeout uses that of the params.
+type RoundClass struct {
+	// Name of the class, used in logs
+	Name string
+	// Number of nodes in a team
+	TeamSize uint32
+	// Number of slots in a batch
+	BatchSize uint32
+
+	// NOTE: All times in MS
+	ResourceQueueTimeout  time.Duration
+	PrecomputationTimeout time.Duration
+	RealtimeTimeout       time.Duration
+
+	// Share of rounds created of this class relative to the other classes.
+	// Defaults to 1
+	Weight uint32
+}
+
+// weight returns the weight of the class.
+func (rc RoundClass) weight() int64 {
+	if rc.Weight == 0 {
+		return 1
+	}
+	return int64(rc.Weight)
+}
+
+// roundClasses returns the configured round classes, or a single class of the
+// params' sizes and timeouts if there are none.
+func (p Params) roundClasses() []RoundClass {
+	if len(p.RoundClasses) == 0 {
+		return []RoundClass{{Name: defaultRoundClass}}
+	}
+	return p.RoundClasses
+}
+
+// forClass returns the params with the sizes and timeouts of the class.
+func (p Params) forClass(rc RoundClass) Params {
+	if rc.TeamSize != 0 {
+		p.TeamSize = rc.TeamSize
+	}
+	if rc.BatchSize != 0 {
+		p.BatchSize = rc.BatchSize
+	}
+	if rc.ResourceQueueTimeout != 0 {
+		p.ResourceQueueTimeout = rc.ResourceQueueTimeout
+	}
+	if rc.PrecomputationTimeout != 0 {
+		p.PrecomputationTimeout = rc.PrecomputationTimeout
+	}
+	if rc.RealtimeTimeout != 0 {
+		p.RealtimeTimeout = rc.RealtimeTimeout
+	}
+	return p
+}
+
+// validateRoundClasses returns every problem with the round classes. Each
+// class is checked as the params it produces.
+func (p Params) validateRoundClasses(activeNodes int,
+	geoBins map[string]region.GeoBin) []string {
+	var problems []string
+	names := make(map[string]bool, len(p.RoundClasses))
+	for i, rc := range p.RoundClasses {
+		if rc.Name == "" {
+			problems = append(problems, fmt.Sprintf("RoundClasses[%d] has "+
+				"no Name", i))
+		} else if names[rc.Name] {
+			problems = append(problems, fmt.Sprintf("RoundClasses has more "+
+				"than one class named %q", rc.Name))
+		}
+		names[rc.Name] = true
+
+		classParams := p.forClass(rc)
+		if activeNodes > 0 && int(classParams.TeamSize) > activeNodes {
+			problems = append(problems, fmt.Sprintf("RoundClasses %q "+
+				"TeamSize %d is larger than the number of active nodes %d",
+				rc.Name, classParams.TeamSize, activeNodes))
+		}
+		for _, problem := range classParams.TeamConstraints.validate(
+			classParams.TeamSize, geoBins) {
+			problems = append(problems, fmt.Sprintf("RoundClasses %q: %s",
+				rc.Name, problem))
+		}
+
+		timeouts := []struct {
+			name  string
+			value time.Duration
+		}{
+			{"ResourceQueueTimeout", rc.ResourceQueueTimeout},
+			{"PrecomputationTimeout", rc.PrecomputationTimeout},
+			{"RealtimeTimeout", rc.RealtimeTimeout},
+		}
+		for _, timeout := range timeouts {
+			if timeout.value < 0 {
+				problems = append(problems, fmt.Sprintf("RoundClasses %q "+
+					"%s cannot be negative", rc.Name, timeout.name))
+			} else if timeout.value*time.Millisecond > maxParamDuration {
+				problems = append(problems, fmt.Sprintf("RoundClasses %q "+
+					"%s of %s is longer than the maximum of %s", rc.Name,
+					timeout.name, timeout.value*time.Millisecond,
+					maxParamDuration))
+			}
+		}
+	}
+	return problems
+}
+
+// classPicker picks the class of each round so that, over time, each class
+// gets its weighted share of rounds, interleaved as evenly as possible. A
+// picked class is kept until a round of it is created, so a class whose team
+// cannot yet be formed is not skipped.
+type classPicker struct {
+	// Smooth weighted round robin credit of each class by name
+	credit map[string]int64
+	// Class picked for the next round, if any
+	pending *RoundClass
+}
+
+// newClassPicker creates a classPicker with no history.
+func newClassPicker() *classPicker {
+	return &classPicker{credit: make(map[string]int64)}
+}
+
+// next returns the class of the next round. It returns the same class until
+// created is called, unless that class is no longer configured.
+func (cp *classPicker) next(classes []RoundClass) RoundClass {
+	if cp.pending != nil {
+		for _, rc := range classes {
+			if rc.Name == cp.pending.Name {
+				cp.pending = &rc
+				return rc
+			}
+		}
+	}
+
+	var total int64
+	best := 0
+	for i, rc := range classes {
+		cp.credit[rc.Name] += rc.weight()
+		total += rc.weight()
+		if cp.credit[rc.Name] > cp.credit[classes[best].Name] {
+			best = i
+		}
+	}
+	cp.credit[classes[best].Name] -= total
+
+	picked := classes[best]
+	cp.pending = &picked
+	return picked
+}
+
+// created marks that a round of the picked class was created, so the next
+// call to next picks again.
+func (cp *classPicker) created() {
+	cp.pending = nil
+}
@@ -0,0 +1,169 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that each class gets its weighted share of rounds, interleaved rather
+// than in runs.
+func TestClassPicker_next(t *testing.T) {
+	classes := []RoundClass{
+		{Name: "small", Weight: 2},
+		{Name: "large", Weight: 1},
+	}
+	cp := newClassPicker()
+
+	var picked []string
+	counts := make(map[string]int)
+	for i := 0; i < 30; i++ {
+		rc := cp.next(classes)
+		cp.created()
+		picked = append(picked, rc.Name)
+		counts[rc.Name]++
+	}
+
+	if counts["small"] != 20 || counts["large"] != 10 {
+		t.Errorf("Unexpected share of rounds: %v", counts)
+	}
+	for i := 1; i < len(picked); i++ {
+		if picked[i] == "large" && picked[i-1] == "large" {
+			t.Fatalf("Large rounds were not interleaved: %v", picked)
+		}
+	}
+}
+
+// Tests that the picked class is kept until a round of it is created, unless
+// it is no longer configured.
+func TestClassPicker_next_Pending(t *testing.T) {
+	classes := []RoundClass{
+		{Name: "small", Weight: 1},
+		{Name: "large", Weight: 1},
+	}
+	cp := newClassPicker()
+
+	first := cp.next(classes)
+	for i := 0; i < 3; i++ {
+		if rc := cp.next(classes); rc.Name != first.Name {
+			t.Fatalf("Class changed from %q to %q before a round was "+
+				"created", first.Name, rc.Name)
+		}
+	}
+
+	remaining := []RoundClass{{Name: "other"}}
+	if rc := cp.next(remaining); rc.Name != "other" {
+		t.Errorf("Removed class %q was kept", rc.Name)
+	}
+}
+
+// Tests that the default class is used when none are configured.
+func TestParams_roundClasses(t *testing.T) {
+	classes := Params{}.roundClasses()
+	if len(classes) != 1 || classes[0].Name != defaultRoundClass {
+		t.Errorf("Unexpected default classes: %+v", classes)
+	}
+
+	configured := []RoundClass{{Name: "small"}, {Name: "large"}}
+	classes = Params{RoundClasses: configured}.roundClasses()
+	if len(classes) != 2 {
+		t.Errorf("Unexpected classes: %+v", classes)
+	}
+}
+
+// Tests that a class overrides only the fields it sets.
+func TestParams_forClass(t *testing.T) {
+	p := Params{
+		TeamSize:              5,
+		BatchSize:             32,
+		ResourceQueueTimeout:  1000,
+		PrecomputationTimeout: 2000,
+		RealtimeTimeout:       3000,
+	}
+
+	classParams := p.forClass(RoundClass{Name: "large", TeamSize: 7,
+		BatchSize: 1000, RealtimeTimeout: 9000})
+	if classParams.TeamSize != 7 || classParams.BatchSize != 1000 ||
+		classParams.RealtimeTimeout != 9000 {
+		t.Errorf("Class fields not applied: %+v", classParams)
+	}
+	if classParams.ResourceQueueTimeout != 1000 ||
+		classParams.PrecomputationTimeout != 2000 {
+		t.Errorf("Unset class fields changed the params: %+v", classParams)
+	}
+	if p.TeamSize != 5 || p.BatchSize != 32 {
+		t.Errorf("Original params modified: %+v", p)
+	}
+}
+
+// Tests that classes without names, with duplicate names, with teams larger
+// than the network, and with negative timeouts are all reported.
+func TestParams_validateRoundClasses(t *testing.T) {
+	p := Params{TeamSize: 3, BatchSize: 32}
+	if problems := p.validateRoundClasses(5, nil); len(problems) != 0 {
+		t.Errorf("Params without classes have problems: %v", problems)
+	}
+
+	p.RoundClasses = []RoundClass{
+		{Name: "small"},
+		{Name: "large", TeamSize: 5, BatchSize: 1000},
+	}
+	if problems := p.validateRoundClasses(5, nil); len(problems) != 0 {
+		t.Errorf("Valid classes have problems: %v", problems)
+	}
+
+	p.RoundClasses = []RoundClass{
+		{},
+		{Name: "small"},
+		{Name: "small", TeamSize: 9},
+		{Name: "slow", RealtimeTimeout: -1},
+	}
+	if problems := p.validateRoundClasses(5, nil); len(problems) != 4 {
+		t.Errorf("Expected 4 problems, found %d: %v", len(problems), problems)
+	}
+}
+
+// Tests that the realtime timeout of a round is that of its class, falling
+// back to the params' timeout.
+func TestStateChanger_roundRealtimeTimeout_Class(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	testState := setupNodeMap(t)
+
+	sc := &stateChanger{state: testState}
+	sc.setParams(Params{
+		RealtimeTimeout: 1000,
+		RoundClasses: []RoundClass{
+			{Name: "small"},
+			{Name: "large", RealtimeTimeout: 5000},
+		},
+	})
+
+	topology := connect.NewCircuit([]*id.ID{id.NewIdFromUInt(0, id.Node, t)})
+	tests := map[string]time.Duration{
+		"small": time.Second,
+		"large": 5 * time.Second,
+		"":      time.Second,
+	}
+	for class, expected := range tests {
+		r := round.NewState_Testing(1, 0, topology, t)
+		r.SetClass(class)
+		if timeout := sc.roundRealtimeTimeout(r); timeout != expected {
+			t.Errorf("Class %q has realtime timeout %s, expected %s",
+				class, timeout, expected)
+		}
+	}
+}
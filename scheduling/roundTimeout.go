@@ -8,31 +8,239 @@
 package scheduling
 
 import (
-	jww "github.com/spf13/jwalterweatherman"
+	"container/heap"
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/round"
 	"gitlab.com/xx_network/primitives/id"
+	"sort"
+	"sync"
 	"time"
 )
 
-func waitForRoundTimeout(tracker chan id.Round, state *storage.NetworkState,
-	localRound *round.State, timeout time.Duration, isRealtime bool) {
-	roundId := localRound.GetRoundID()
-	// Allow for round the to be added to the map
-	roundTimer := time.NewTimer(timeout)
-	select {
-	// Wait for the timer to go off
-	case <-roundTimer.C:
-		// Send the timed out round id to the timeout handler
-		jww.INFO.Printf("Round %v[Realtime: %t] has timed out after %s, "+
-			"signaling exit", roundId, isRealtime, timeout)
-		tracker <- roundId
-	// Signals the round has been completed.
-	// In this case, we can exit the go-routine
-	case <-localRound.GetRoundCompletedChan():
-		if isRealtime {
-			state.GetRoundMap().DeleteRound(roundId)
+// roundTimeout.go contains the timeoutManager, which tracks the deadline of
+// the current phase of every running round on a single timer owned by the
+// Scheduler.
+
+// timeoutPhase is the phase of a round a deadline applies to.
+type timeoutPhase uint8
+
+const (
+	// From the start of the round until every node leaves its resource queue
+	// and begins precomputation
+	resourceQueuePhase timeoutPhase = iota
+	// From then until every node finishes precomputation
+	precompPhase
+	// From the end of precomputation until realtime starts
+	standbyPhase
+	// From the start of realtime until every node completes it
+	realtimePhase
+)
+
+// String returns the name of the phase, as used in timeout round errors.
+func (p timeoutPhase) String() string {
+	switch p {
+	case resourceQueuePhase:
+		return "resource queue"
+	case precompPhase:
+		return "precomputation"
+	case standbyPhase:
+		return "standby"
+	case realtimePhase:
+		return "realtime"
+	default:
+		return "unknown"
+	}
+}
+
+// queued returns true if the round has been queued for realtime by the phase.
+func (p timeoutPhase) queued() bool {
+	return p >= standbyPhase
+}
+
+// timer is the part of a time.Timer used by the timeoutManager, so that tests
+// may control when deadlines are signalled.
+type timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// stdTimer is a timer backed by a time.Timer.
+type stdTimer struct {
+	*time.Timer
+}
+
+// newStdTimer returns a stopped stdTimer.
+func newStdTimer() timer {
+	t := time.NewTimer(time.Hour)
+	t.Stop()
+	return stdTimer{t}
+}
+
+func (t stdTimer) C() <-chan time.Time { return t.Timer.C }
+
+// roundDeadline is the time by which a round must finish its current phase.
+type roundDeadline struct {
+	round    id.Round
+	phase    timeoutPhase
+	deadline time.Time
+
+	// Position in the deadlineHeap
+	index int
+}
+
+// deadlineHeap orders deadlines from earliest to latest. It implements
+// heap.Interface.
+type deadlineHeap []*roundDeadline
+
+func (h deadlineHeap) Len() int           { return len(h) }
+func (h deadlineHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+func (h deadlineHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *deadlineHeap) Push(x interface{}) {
+	d := x.(*roundDeadline)
+	d.index = len(*h)
+	*h = append(*h, d)
+}
+
+func (h *deadlineHeap) Pop() interface{} {
+	old := *h
+	d := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	d.index = -1
+	return d
+}
+
+// timeoutManager holds at most one deadline per round, for its current phase,
+// and signals on C when the earliest passes. Deadlines are set as rounds move
+// between phases and cancelled when they end. It is safe for concurrent use.
+type timeoutManager struct {
+	// Returns the current time, replaced in tests
+	now func() time.Time
+
+	mux       sync.Mutex
+	deadlines deadlineHeap
+	rounds    map[id.Round]*roundDeadline
+	timer     timer
+}
+
+// newTimeoutManager creates a timeoutManager which reads the time from now and
+// signals deadlines on the stopped timer returned by newTimer.
+func newTimeoutManager(now func() time.Time,
+	newTimer func() timer) *timeoutManager {
+	return &timeoutManager{
+		now:    now,
+		rounds: make(map[id.Round]*roundDeadline),
+		timer:  newTimer(),
+	}
+}
+
+// C returns the channel signalled when the earliest deadline passes. It may
+// also be signalled early, after which expire returns nothing.
+func (tm *timeoutManager) C() <-chan time.Time {
+	return tm.timer.C()
+}
+
+// set replaces the round's deadline with one for the phase, timeout from now.
+func (tm *timeoutManager) set(rid id.Round, phase timeoutPhase,
+	timeout time.Duration) {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+
+	deadline := tm.now().Add(timeout)
+	if d, exists := tm.rounds[rid]; exists {
+		d.phase = phase
+		d.deadline = deadline
+		heap.Fix(&tm.deadlines, d.index)
+	} else {
+		d = &roundDeadline{round: rid, phase: phase, deadline: deadline}
+		heap.Push(&tm.deadlines, d)
+		tm.rounds[rid] = d
+	}
+	tm.arm()
+}
+
+// cancel removes the round's deadline. It returns the phase the deadline was
+// for and false if the round had none.
+func (tm *timeoutManager) cancel(rid id.Round) (timeoutPhase, bool) {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+
+	d, exists := tm.rounds[rid]
+	if !exists {
+		return 0, false
+	}
+	heap.Remove(&tm.deadlines, d.index)
+	delete(tm.rounds, rid)
+	tm.arm()
+	return d.phase, true
+}
+
+// expire removes and returns the deadlines which have passed, earliest first.
+func (tm *timeoutManager) expire() []roundDeadline {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+
+	now := tm.now()
+	var expired []roundDeadline
+	for len(tm.deadlines) > 0 && !tm.deadlines[0].deadline.After(now) {
+		d := heap.Pop(&tm.deadlines).(*roundDeadline)
+		delete(tm.rounds, d.round)
+		expired = append(expired, *d)
+	}
+	tm.arm()
+	return expired
+}
+
+// upcoming returns every deadline, earliest first.
+func (tm *timeoutManager) upcoming() []roundDeadline {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+
+	deadlines := make([]roundDeadline, len(tm.deadlines))
+	for i, d := range tm.deadlines {
+		deadlines[i] = *d
+	}
+	sort.Slice(deadlines, func(i, j int) bool {
+		return deadlines[i].deadline.Before(deadlines[j].deadline)
+	})
+	return deadlines
+}
+
+// stop stops the timer. No further signals are sent on C.
+func (tm *timeoutManager) stop() {
+	tm.mux.Lock()
+	defer tm.mux.Unlock()
+	tm.timer.Stop()
+}
+
+// arm resets the timer to fire at the earliest deadline. The lock must be
+// held.
+func (tm *timeoutManager) arm() {
+	if !tm.timer.Stop() {
+		select {
+		case <-tm.timer.C():
+		default:
 		}
-		return
 	}
+	if len(tm.deadlines) > 0 {
+		tm.timer.Reset(tm.deadlines[0].deadline.Sub(tm.now()))
+	}
+}
+
+// endRound cancels the deadline of a round which has ended. A round which ends
+// after precomputation is also removed from the round map. It returns the
+// phase the round ended in and false if it had no deadline.
+func endRound(state *storage.NetworkState, timeouts *timeoutManager,
+	r *round.State) (timeoutPhase, bool) {
+	phase, exists := timeouts.cancel(r.GetRoundID())
+	if exists && phase.queued() {
+		state.GetRoundMap().DeleteRound(r.GetRoundID())
+	}
+	return phase, exists
 }
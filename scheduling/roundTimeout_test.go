@@ -0,0 +1,250 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/primitives/id"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testClock is a clock for the timeoutManager which only moves when advanced.
+// Its timers fire once the clock is advanced past their deadline.
+type testClock struct {
+	now    time.Time
+	timers []*testTimer
+}
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func (c *testClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+	for _, t := range c.timers {
+		t.fire()
+	}
+}
+
+func (c *testClock) newTimer() timer {
+	t := &testTimer{clock: c, c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// testTimer is a timer of a testClock.
+type testTimer struct {
+	clock    *testClock
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func (t *testTimer) C() <-chan time.Time { return t.c }
+
+func (t *testTimer) Stop() bool {
+	active := t.active
+	t.active = false
+	return active
+}
+
+func (t *testTimer) Reset(d time.Duration) bool {
+	active := t.active
+	t.deadline = t.clock.now.Add(d)
+	t.active = true
+	t.fire()
+	return active
+}
+
+// fire signals the timer if it is active and its deadline has passed.
+func (t *testTimer) fire() {
+	if t.active && !t.deadline.After(t.clock.now) {
+		t.active = false
+		t.c <- t.clock.now
+	}
+}
+
+// Tests that deadlines expire in order once the clock passes them, and that
+// replaced and cancelled deadlines do not expire.
+func TestTimeoutManager_expire(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	tm := newTimeoutManager(clock.Now, clock.newTimer)
+	defer tm.stop()
+
+	tm.set(1, precompPhase, 3*time.Second)
+	tm.set(2, precompPhase, time.Second)
+	tm.set(3, precompPhase, 2*time.Second)
+	tm.set(4, precompPhase, time.Second)
+
+	// Moving round 3 to standby replaces its deadline
+	tm.set(3, standbyPhase, 5*time.Second)
+	if phase, ok := tm.cancel(4); !ok || phase != precompPhase {
+		t.Errorf("Unexpected cancel of round 4: %s %t", phase, ok)
+	}
+	if _, ok := tm.cancel(4); ok {
+		t.Errorf("Round 4 cancelled twice.")
+	}
+
+	if expired := tm.expire(); len(expired) != 0 {
+		t.Fatalf("Deadlines expired early: %+v", expired)
+	}
+
+	clock.advance(3 * time.Second)
+	expired := tm.expire()
+	if len(expired) != 2 || expired[0].round != 2 || expired[1].round != 1 {
+		t.Fatalf("Unexpected expired deadlines: %+v", expired)
+	}
+
+	upcoming := tm.upcoming()
+	if len(upcoming) != 1 || upcoming[0].round != 3 ||
+		upcoming[0].phase != standbyPhase {
+		t.Fatalf("Unexpected upcoming deadlines: %+v", upcoming)
+	}
+
+	clock.advance(2 * time.Second)
+	expired = tm.expire()
+	if len(expired) != 1 || expired[0].round != 3 {
+		t.Errorf("Unexpected expired deadlines: %+v", expired)
+	}
+	if len(tm.upcoming()) != 0 {
+		t.Errorf("Deadlines remain after all expired.")
+	}
+}
+
+// Tests that upcoming lists deadlines earliest first.
+func TestTimeoutManager_upcoming(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	tm := newTimeoutManager(clock.Now, clock.newTimer)
+	defer tm.stop()
+
+	timeouts := []time.Duration{5, 1, 4, 2, 3}
+	for i, timeout := range timeouts {
+		tm.set(id.Round(i), realtimePhase, timeout*time.Second)
+	}
+
+	upcoming := tm.upcoming()
+	if len(upcoming) != len(timeouts) {
+		t.Fatalf("Expected %d deadlines, found %d", len(timeouts),
+			len(upcoming))
+	}
+	for i := 1; i < len(upcoming); i++ {
+		if upcoming[i].deadline.Before(upcoming[i-1].deadline) {
+			t.Errorf("Deadlines out of order: %+v", upcoming)
+		}
+	}
+}
+
+// Tests that C is signalled once the earliest deadline passes, and not before.
+func TestTimeoutManager_C(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	tm := newTimeoutManager(clock.Now, clock.newTimer)
+	defer tm.stop()
+
+	tm.set(1, precompPhase, time.Hour)
+	tm.set(2, precompPhase, time.Minute)
+
+	clock.advance(59 * time.Second)
+	select {
+	case <-tm.C():
+		t.Fatalf("Timer fired before the earliest deadline.")
+	default:
+	}
+
+	clock.advance(time.Second)
+	select {
+	case <-tm.C():
+	default:
+		t.Fatalf("Timer did not fire for the earliest deadline.")
+	}
+
+	expired := tm.expire()
+	if len(expired) != 1 || expired[0].round != 2 {
+		t.Errorf("Unexpected expired deadlines: %+v", expired)
+	}
+
+	// The timer is rearmed for the next deadline
+	clock.advance(time.Hour)
+	select {
+	case <-tm.C():
+	default:
+		t.Fatalf("Timer did not fire for the next deadline.")
+	}
+}
+
+// Tests that the real timer signals C once the earliest deadline passes.
+func TestTimeoutManager_C_StdTimer(t *testing.T) {
+	tm := newTimeoutManager(time.Now, newStdTimer)
+	defer tm.stop()
+
+	tm.set(1, precompPhase, time.Hour)
+	tm.set(2, precompPhase, time.Millisecond)
+
+	select {
+	case <-tm.C():
+	case <-time.After(time.Second):
+		t.Fatalf("Timer did not fire for the earliest deadline.")
+	}
+
+	expired := tm.expire()
+	if len(expired) != 1 || expired[0].round != 2 {
+		t.Errorf("Unexpected expired deadlines: %+v", expired)
+	}
+}
+
+// Tests that a timed out round is killed with an error naming the phase it
+// timed out in, and that endRound only removes rounds past precomputation
+// from the round map.
+func Test_timeoutRound(t *testing.T) {
+	sc, nodes := newSupervisorTestStateChanger(t, 2)
+	topology := connect.NewCircuit([]*id.ID{nodes[0].GetID(), nodes[1].GetID()})
+
+	for i, phase := range []timeoutPhase{precompPhase, realtimePhase} {
+		rid := id.Round(i + 1)
+		r, err := sc.state.GetRoundMap().AddRound(rid, 32, 8, time.Minute,
+			topology)
+		if err != nil {
+			t.Fatalf("Failed to add round: %+v", err)
+		}
+		if err = r.Update(states.PRECOMPUTING, time.Now()); err != nil {
+			t.Fatalf("Failed to update round: %+v", err)
+		}
+
+		err = timeoutRound(sc.state, roundDeadline{round: rid, phase: phase},
+			sc.roundTracker)
+		if err != nil {
+			t.Fatalf("Failed to time out round: %+v", err)
+		}
+		roundErrors := r.BuildRoundInfo().Errors
+		if r.GetRoundState() != states.FAILED || len(roundErrors) != 1 ||
+			!strings.Contains(roundErrors[0].Error, phase.String()) {
+			t.Errorf("Round %d not killed by a %s timeout: %s %+v", rid,
+				phase, r.GetRoundState(), roundErrors)
+		}
+	}
+
+	for i, phase := range []timeoutPhase{resourceQueuePhase, precompPhase,
+		realtimePhase} {
+		rid := id.Round(i + 10)
+		r, err := sc.state.GetRoundMap().AddRound(rid, 32, 8, time.Minute,
+			topology)
+		if err != nil {
+			t.Fatalf("Failed to add round: %+v", err)
+		}
+		sc.timeouts.set(rid, phase, time.Minute)
+
+		endRound(sc.state, sc.timeouts, r)
+		_, exists := sc.state.GetRoundMap().GetRound(rid)
+		if exists == phase.queued() {
+			t.Errorf("Round which ended in %s in round map: %t", phase, exists)
+		}
+	}
+	if len(sc.timeouts.upcoming()) != 0 {
+		t.Errorf("Deadlines of ended rounds not cancelled.")
+	}
+}
@@ -12,6 +12,7 @@ package scheduling
 import (
 	"gitlab.com/xx_network/primitives/id"
 	"sync"
+	"time"
 )
 
 // RoundTracker tracks rounds that are active, meaning between precomputing and
@@ -19,6 +20,9 @@ import (
 type RoundTracker struct {
 	mux          sync.Mutex
 	activeRounds map[id.Round]struct{}
+
+	// Tracks writes of round data to storage which have not finished
+	pendingWrites sync.WaitGroup
 }
 
 // NewRoundTracker creates tracker object.
@@ -71,3 +75,30 @@ func (rt *RoundTracker) GetActiveRounds() []id.Round {
 
 	return rounds
 }
+
+// StoreAsync runs the write to storage in a new goroutine and tracks it so
+// that it can be waited on with Flush.
+func (rt *RoundTracker) StoreAsync(write func()) {
+	rt.pendingWrites.Add(1)
+	go func() {
+		defer rt.pendingWrites.Done()
+		write()
+	}()
+}
+
+// Flush waits for the writes started with StoreAsync to finish or for the
+// timeout to elapse. Returns true if all writes finished.
+func (rt *RoundTracker) Flush(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		rt.pendingWrites.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
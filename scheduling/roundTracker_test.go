@@ -226,3 +226,34 @@ func compare(X, Y []id.Round) []id.Round {
 
 	return ret
 }
+
+// Tests that Flush waits for writes started with StoreAsync.
+func TestRoundTracker_Flush(t *testing.T) {
+	rt := NewRoundTracker()
+	written := make(chan struct{}, 1)
+	rt.StoreAsync(func() {
+		time.Sleep(10 * time.Millisecond)
+		written <- struct{}{}
+	})
+
+	if !rt.Flush(time.Second) {
+		t.Fatalf("Flush timed out.")
+	}
+	select {
+	case <-written:
+	default:
+		t.Errorf("Flush returned before the write finished.")
+	}
+}
+
+// Tests that Flush returns false when a write does not finish in time.
+func TestRoundTracker_Flush_Timeout(t *testing.T) {
+	rt := NewRoundTracker()
+	release := make(chan struct{})
+	rt.StoreAsync(func() { <-release })
+
+	if rt.Flush(10 * time.Millisecond) {
+		t.Errorf("Flush did not time out.")
+	}
+	close(release)
+}
@@ -8,7 +8,6 @@
 package scheduling
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
@@ -21,6 +20,7 @@ import (
 	"gitlab.com/xx_network/crypto/csprng"
 	"gitlab.com/xx_network/primitives/id"
 	"io"
+	"reflect"
 	"runtime"
 	"strconv"
 	"sync/atomic"
@@ -33,108 +33,106 @@ const (
 	//size of round creation channel, just sufficiently large enough to not be jammed
 	newRoundChanLen = 1000
 
-	// how long a node needs to not act to be considered offline or in-active for the
-	// print. arbitrarily chosen.
+	// how long a node needs to not poll to be considered offline and moved out
+	// of the waiting pool. arbitrarily chosen.
 	timeToInactive = 3 * time.Minute
 )
 
-type roundCreator func(params Params, pool *waitingPool, threshold int, roundID id.Round,
+type roundCreator func(params Params, team []*node.State, roundID id.Round,
 	state *storage.NetworkState, rng io.Reader) (protoRound, error)
 
 func ParseParams(serialParam []byte) *SafeParams {
 	// Parse params JSON
-	params := &SafeParams{}
-	err := json.Unmarshal(serialParam, params)
+	params, err := LoadParams(serialParam, 0, nil)
 	if err != nil {
-		jww.FATAL.Panicf("Scheduling Algorithm exited: Could not extract parameters")
+		jww.FATAL.Panicf("Scheduling Algorithm exited: %+v", err)
 	}
 
-	// If resource queue timeout isn't set, set it to a default of 3 minutes
-	if params.ResourceQueueTimeout == 0 {
-		params.ResourceQueueTimeout = 180000
-	}
-	// If round times haven't been set, set to a default of one minute
-	if params.PrecomputationTimeout == 0 {
-		params.PrecomputationTimeout = 60000
-	}
-	if params.RealtimeTimeout == 0 {
-		params.RealtimeTimeout = 15000
-	}
-
-	return params
+	return &SafeParams{Params: &params}
 }
 
-// Runs an infinite loop that checks for updates to scheduling parameters
-func UpdateParams(params *SafeParams, updateFreq time.Duration) {
+// Runs a loop that checks for updates to the scheduling parameters of the
+// network in storage until the quit channel is signalled. The updated params
+// are validated against the number of active nodes and the GeoBin table of the
+// network, as params loaded from file are
+func UpdateParams(params *SafeParams, updateFreq time.Duration,
+	state *storage.NetworkState, quitChan chan struct{}) {
+	ticker := time.NewTicker(updateFreq)
+	defer ticker.Stop()
+
 	for {
-		newParams := make(map[string]uint64, 0)
-		teamSize, err := storage.PermissioningDb.GetStateInt(storage.TeamSize)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.TeamSize] = teamSize
-		batchSize, err := storage.PermissioningDb.GetStateInt(storage.BatchSize)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.BatchSize] = batchSize
-		precompTimeout, err := storage.PermissioningDb.GetStateInt(storage.PrecompTimeout)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.PrecompTimeout] = precompTimeout
-		realtimeTimeout, err := storage.PermissioningDb.GetStateInt(storage.RealtimeTimeout)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.RealtimeTimeout] = realtimeTimeout
-		minDelay, err := storage.PermissioningDb.GetStateInt(storage.MinDelay)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
+		if err := updateParams(params, state); err != nil {
+			jww.ERROR.Printf("%+v", err)
 		}
-		newParams[storage.MinDelay] = minDelay
-		realtimeDelay, err := storage.PermissioningDb.GetStateInt(storage.AdvertisementTimeout)
-		if err != nil {
-			jww.ERROR.Printf(err.Error())
-			continue
-		}
-		newParams[storage.AdvertisementTimeout] = realtimeDelay
-		valueStr, err := storage.PermissioningDb.GetStateValue(storage.PoolThreshold)
-		if err != nil {
-			jww.ERROR.Printf("Unable to find %s: %+v", storage.PoolThreshold, err)
-			continue
+
+		select {
+		case <-quitChan:
+			jww.DEBUG.Printf("Killing scheduling param update routine.")
+			return
+		case <-ticker.C:
 		}
-		threshold, err := strconv.ParseFloat(valueStr, 64)
+	}
+}
+
+// updateParams updates the scheduling params with those of the network in
+// storage if they are valid.
+func updateParams(params *SafeParams, state *storage.NetworkState) error {
+	newParams := make(map[string]uint64, 0)
+	for _, key := range []string{storage.TeamSize, storage.BatchSize,
+		storage.PrecompTimeout, storage.RealtimeTimeout, storage.MinDelay,
+		storage.AdvertisementTimeout} {
+		value, err := storage.PermissioningDb.GetStateInt(state.GetStateKey(key))
 		if err != nil {
-			jww.ERROR.Printf("Unable to decode %s: %+v", valueStr, err)
-			continue
+			return err
 		}
+		newParams[key] = value
+	}
+	valueStr, err := storage.PermissioningDb.GetStateValue(
+		state.GetStateKey(storage.PoolThreshold))
+	if err != nil {
+		return errors.Errorf("Unable to find %s: %+v", storage.PoolThreshold, err)
+	}
+	threshold, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return errors.Errorf("Unable to decode %s: %+v", valueStr, err)
+	}
 
-		jww.INFO.Printf("Preparing to update scheduling params...")
-		params.Lock()
-		jww.INFO.Printf("Updating scheduling params: %+v, %s: %f", newParams, storage.PoolThreshold, threshold)
-		params.TeamSize = uint32(teamSize)
-		params.BatchSize = uint32(batchSize)
-		params.PrecomputationTimeout = time.Duration(precompTimeout)
-		params.RealtimeTimeout = time.Duration(realtimeTimeout)
-		params.MinimumDelay = time.Duration(minDelay)
-		params.RealtimeDelay = time.Duration(realtimeDelay)
-		params.Threshold = threshold
-		params.Unlock()
-
-		time.Sleep(updateFreq)
+	jww.INFO.Printf("Preparing to update scheduling params...")
+	stored := Params{
+		TeamSize:              uint32(newParams[storage.TeamSize]),
+		BatchSize:             uint32(newParams[storage.BatchSize]),
+		PrecomputationTimeout: time.Duration(newParams[storage.PrecompTimeout]),
+		RealtimeTimeout:       time.Duration(newParams[storage.RealtimeTimeout]),
+		MinimumDelay:          time.Duration(newParams[storage.MinDelay]),
+		RealtimeDelay:         time.Duration(newParams[storage.AdvertisementTimeout]),
+		Threshold:             threshold,
+	}
+	active, bins := state.CountActiveNodes(), state.GetGeoBins()
+	err = params.UpdateWith(func(current Params) (Params, error) {
+		updated := current.WithStorageParams(stored)
+		return updated, updated.Validate(active, bins)
+	})
+	if err != nil {
+		return errors.Errorf("Rejecting scheduling params from storage: %+v", err)
 	}
 
+	jww.INFO.Printf("Updating scheduling params: %+v, %s: %f", newParams,
+		storage.PoolThreshold, threshold)
+	return nil
 }
 
 // Scheduler is a utility function which builds a round by handling a node's
-// state changes then creating a team from the nodes in the pool
-func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan chan struct{}) error {
+// state changes then creating a team from the nodes in the pool. Teams are not
+// formed while the pauser is paused. It returns nil once a Shutdown received
+// on killchan has completed.
+func Scheduler(params *SafeParams, state *storage.NetworkState, pauser *Pauser,
+	killchan chan *Shutdown) error {
+
+	// The country codes of the team constraints can only be checked once the
+	// GeoBin table of the network is known
+	if err := params.SafeCopy().Validate(0, state.GetGeoBins()); err != nil {
+		return err
+	}
 
 	rng := fastRNG.NewStreamGenerator(10000,
 		uint(runtime.NumCPU()), csprng.NewSystemRNG)
@@ -150,22 +148,59 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 
 	// Set teaming algorithm
 	jww.INFO.Printf("Using Secure Teaming Algorithm")
-	createRound = createSecureRound
+	createRound = buildSecureRound
+
+	// Tracks the deadline of every running round
+	timeouts := newTimeoutManager(time.Now, newStdTimer)
+	defer timeouts.stop()
+
+	// Durations of completed rounds used to adapt their timeouts
+	adaptive, err := loadAdaptiveTimeouts(params.SafeCopy().AdaptiveTimeouts,
+		state)
+	if err != nil {
+		jww.WARN.Printf("Starting adaptive timeouts over: %+v", err)
+		adaptive = newAdaptiveTimeouts()
+	}
 
-	// Channel to communicate that a round has timed out
-	roundTimeoutTracker := make(chan id.Round, 1000)
+	// Paces realtime starts and round creation
+	pace := newPacer()
 
 	roundTracker := NewRoundTracker()
 
+	// Operator teams of the nodes, used if the team constraints require them
+	operators := newOperatorTeams()
+
+	// Picks the class of each round
+	classes := newClassPicker()
+
+	// Reputation of the nodes, used to pick teams if enabled
+	rep, err := loadReputation(state.GetNetwork())
+	if err != nil {
+		jww.WARN.Printf("Starting node reputations over: %+v", err)
+		rep = newReputation(state.GetNetwork())
+	}
+	persistTicker := time.NewTicker(reputationPersistInterval)
+	defer persistTicker.Stop()
+
+	// Set once a shutdown is received so that queued rounds are not started
+	var stopping uint32
+
 	//begin the thread that starts rounds
 	go func() {
 
 		lastRound := time.Now()
 
-		paramsCopy := params.SafeCopy()
-		minRoundDelay := (paramsCopy.MinimumDelay * time.Millisecond) / 3
-		var err error
 		for newRound := range newRoundChan {
+			if atomic.LoadUint32(&stopping) == 1 {
+				jww.WARN.Printf("Discarding round %d queued before "+
+					"shutdown", newRound.ID)
+				continue
+			}
+
+			// Read the params for every round so that live updates apply
+			paramsCopy := params.SafeCopy()
+			minRoundDelay := pace.creationInterval(paramsCopy.Pacing,
+				paramsCopy.MinimumDelay*time.Millisecond, time.Now())
 
 			// To avoid back-to-back teaming, we make sure to sleep until the minimum delay
 			if timeDiff := time.Now().Sub(lastRound); timeDiff < minRoundDelay {
@@ -173,40 +208,55 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 			}
 			lastRound = time.Now()
 
-			ourRound, err := startRound(newRound, state, roundTracker)
+			// The timeout is set before the round is started so that it
+			// cannot replace the deadline of a later phase
+			timeouts.set(newRound.ID, resourceQueuePhase,
+				newRound.ResourceQueueTimeout)
+			_, err := startRound(newRound, state, roundTracker)
 			if err != nil {
-				jww.FATAL.Panicf("Failed to start round %v: %+v", newRound.ID, err)
+				jww.ERROR.Printf("Failed to start round %v, returning its "+
+					"team to the pool: %+v", newRound.ID, err)
+				timeouts.cancel(newRound.ID)
+				returnToPool(pool, newRound.NodeStateList)
 			}
-
-			go waitForRoundTimeout(roundTimeoutTracker, state, ourRound,
-				paramsCopy.PrecomputationTimeout*time.Millisecond, false)
 		}
 
-		jww.FATAL.Panicf("Round creation thread should never exit: %v", err)
-
+		jww.INFO.Printf("Round creation thread stopped")
 	}()
 
-	var killed chan struct{}
+	// Set once a shutdown is requested. The progress ticker and deadline stay
+	// nil, and so never fire, until then.
+	var shutdown *Shutdown
+	var progressTicker, shutdownDeadline <-chan time.Time
+	var forcedRounds []id.Round
+	forceStop := false
 	iterationsCount := uint32(0)
 
 	// optional debug print which regularly prints the status of rounds and nodes
 	// turned on by setting DebugTrackRounds to true in the scheduling config
 	if params.DebugTrackRounds {
-		go trackRounds(state, pool, roundTracker, &iterationsCount)
+		go trackRounds(state, pool, roundTracker, timeouts, pace,
+			&iterationsCount)
 	}
 
 	paramsCopy := params.SafeCopy()
 
+	// Regularly moves nodes which stopped polling out of the pool
+	cleanUp := newCleanUpTicker(paramsCopy.NodeCleanUpInterval)
+	defer cleanUp.stop()
+
 	sc := &stateChanger{
-		lastRealtime:     time.Unix(0, 0),
-		realtimeDelay:    paramsCopy.RealtimeDelay * time.Millisecond,
-		realtimeDelta:    paramsCopy.MinimumDelay * time.Millisecond,
-		realtimeTimeout:  paramsCopy.RealtimeTimeout * time.Millisecond,
-		pool:             pool,
-		state:            state,
-		roundTracker:     roundTracker,
-		roundTimeoutChan: roundTimeoutTracker,
+		lastRealtime: time.Unix(0, 0),
+		pool:         pool,
+		state:        state,
+		roundTracker: roundTracker,
+		timeouts:     timeouts,
+		adaptive:     adaptive,
+		pacer:        pace,
+		reputation:   rep,
 	}
+	sc.setParams(paramsCopy)
+	pool.SetOrder(rep.order(paramsCopy.Reputation))
 
 	jww.INFO.Printf("Initialized state changer with: "+
 		"\n\t realtimeDelay: %s, "+
@@ -219,34 +269,74 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 
 		isRoundTimeout := false
 		var update node.UpdateNotification
-		var timedOutRoundID id.Round
 		hasUpdate := false
 
 		select {
-		// Receive a signal to kill the Scheduler
-		case killed = <-killchan:
-			// Also kill the unsticker
+		// Receive a signal to shut down the Scheduler
+		case shutdown = <-killchan:
 			jww.WARN.Printf("Scheduler has received a kill signal, exit process has begun")
+			atomic.StoreUint32(&stopping, 1)
+			killchan = nil
+			ticker := time.NewTicker(shutdownProgressInterval)
+			defer ticker.Stop()
+			progressTicker = ticker.C
+			shutdownDeadline = time.After(time.Until(shutdown.deadline))
+			shutdown.reportProgress(roundTracker)
+		// Report the rounds still running while shutting down
+		case <-progressTicker:
+			shutdown.reportProgress(roundTracker)
+		// Fail the rounds which did not finish before the shutdown deadline
+		case <-shutdownDeadline:
+			forceStop = true
+		// Form teams from the nodes which entered the pool while paused
+		case <-pauser.wake:
+		// Move nodes which stopped polling out of the pool
+		case <-cleanUp.C():
+			cleanUpOfflineNodes(pool, rep, sc.reputationConfig, time.Now())
+		// Store the reputations which changed
+		case <-persistTicker.C:
+			roundTracker.StoreAsync(func() {
+				if err := rep.persist(); err != nil {
+					jww.WARN.Printf("%+v", err)
+				}
+			})
 		// When we get a node update, move past the select statement
 		case update = <-state.GetNodeUpdateChannel():
 			hasUpdate = true
-		// Receive a signal indicating that a round has timed out
-		case timedOutRoundID = <-roundTimeoutTracker:
+		// Receive a signal indicating that a round may have timed out
+		case <-timeouts.C():
 			isRoundTimeout = true
 		}
 
 		atomic.AddUint32(&iterationsCount, 1)
+
+		// Pick up any changes made to the params since the last iteration
+		if newParams := params.SafeCopy(); !reflect.DeepEqual(newParams, paramsCopy) {
+			jww.INFO.Printf("Scheduler applying updated params: %+v", newParams)
+			paramsCopy = newParams
+			sc.setParams(paramsCopy)
+			pool.SetOrder(rep.order(paramsCopy.Reputation))
+			cleanUp.reset(paramsCopy.NodeCleanUpInterval)
+		}
+
 		if isRoundTimeout {
-			// Handle the timed out round
-			err := timeoutRound(state, timedOutRoundID, roundTracker)
-			if err != nil {
-				return err
+			// Handle the timed out rounds
+			for _, timedOut := range timeouts.expire() {
+				if timedOut.phase.queued() {
+					pace.failed(sc.pacing, sc.realtimeDelta, time.Now())
+				}
+				rep.roundTimedOut(sc.reputationConfig, state, timedOut.round,
+					time.Now())
+				err := sc.recoverError(timeoutRound(state, timedOut,
+					roundTracker))
+				if err != nil {
+					return err
+				}
 			}
 		} else if hasUpdate {
-			var err error
-
-			// Handle the node's state change
-			err = sc.HandleNodeUpdates(update)
+			// Handle the node's state change. Errors confined to a round or
+			// node are recovered from; only fatal errors are returned.
+			err := sc.handleNodeUpdate(update)
 			if err != nil {
 				return err
 			}
@@ -257,25 +347,53 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 			//nodes can be scheduled
 			numNodesInPool := pool.Len()
 
+			// Pick the class of the next round and use its sizes and timeouts
+			class := classes.next(paramsCopy.roundClasses())
+			classParams := paramsCopy.forClass(class)
+
 			// Create a new round if the pool is full
 			var teamFormationThreshold int
-			teamSize := int(paramsCopy.TeamSize)
+			teamSize := int(classParams.TeamSize)
 			teamFormationThreshold = int(paramsCopy.Threshold * float64(state.CountActiveNodes()))
-			if numNodesInPool >= teamFormationThreshold && numNodesInPool >= teamSize && killed == nil {
+			if numNodesInPool >= teamFormationThreshold && numNodesInPool >= teamSize && shutdown == nil &&
+				!pauser.IsPaused() {
+
+				// Pick the team before taking a round ID so that waiting on
+				// the team constraints does not skip round IDs
+				team, err := pickTeam(classParams, pool, teamFormationThreshold,
+					state.GetGeoBins(), operators)
+				if err == errTeamConstraints {
+					jww.DEBUG.Printf("Waiting for the pool to satisfy the " +
+						"team constraints")
+					break
+				} else if err != nil {
+					jww.ERROR.Printf("Failed to pick random node group, "+
+						"retrying on the next update: %+v", err)
+					break
+				}
 
-				// Increment round ID
+				// Increment round ID. If it fails, the team is returned to the
+				// pool and round creation is retried on the next iteration.
 				currentID, err := state.IncrementRoundID()
-
 				if err != nil {
-					return err
+					jww.ERROR.Printf("Failed to get the next round ID, "+
+						"returning the team to the pool: %+v", err)
+					returnToPool(pool, team)
+					break
 				}
 
 				stream := rng.GetStream()
-				newRound, err := createRound(paramsCopy, pool, teamFormationThreshold, currentID, state, stream)
+				newRound, err := createRound(classParams, team, currentID, state, stream)
 				stream.Close()
 				if err != nil {
-					return err
+					jww.ERROR.Printf("Failed to create round %d, returning "+
+						"the team to the pool: %+v", currentID, err)
+					returnToPool(pool, team)
+					break
 				}
+				newRound.Class = class.Name
+				classes.created()
+
 				// Send the round to the new round channel to be created
 				newRoundChan <- newRound
 			} else {
@@ -283,13 +401,23 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 			}
 		}
 
+		if shutdown != nil && forceStop && roundTracker.Len() > 0 {
+			forced := failActiveRounds(state, roundTracker, timeouts)
+			jww.WARN.Printf("Failed %d rounds which did not finish before "+
+				"shutdown: %v", len(forced), forced)
+			forcedRounds = append(forcedRounds, forced...)
+		}
+
 		// If the Scheduler is to be killed and no rounds are in progress,
 		// kill the Scheduler
-		if killed != nil && roundTracker.Len() == 0 {
+		if shutdown != nil && roundTracker.Len() == 0 {
 			// Stop round creation
 			close(newRoundChan)
 			jww.WARN.Printf("Scheduler is exiting due to kill signal")
-			killed <- struct{}{}
+			if err := rep.persist(); err != nil {
+				jww.WARN.Printf("%+v", err)
+			}
+			shutdown.done <- shutdown.flush(state, roundTracker, forcedRounds)
 			return nil
 		}
 	}
@@ -298,8 +426,9 @@ func Scheduler(params *SafeParams, state *storage.NetworkState, killchan chan ch
 }
 
 // Helper function which handles when we receive a timed out round
-func timeoutRound(state *storage.NetworkState, timeoutRoundID id.Round,
+func timeoutRound(state *storage.NetworkState, timedOut roundDeadline,
 	roundTracker *RoundTracker) error {
+	timeoutRoundID := timedOut.round
 	// On a timeout, check if the round is completed. If not, kill it
 	ourRound, exists := state.GetRoundMap().GetRound(timeoutRoundID)
 	if !exists {
@@ -313,11 +442,7 @@ func timeoutRound(state *storage.NetworkState, timeoutRoundID id.Round,
 	// If the round is neither in completed or failed
 	if roundState != states.COMPLETED && roundState != states.FAILED {
 
-		timeoutType := "precomputation"
-
-		if roundState > states.PRECOMPUTING {
-			timeoutType = "realtime"
-		}
+		timeoutType := timedOut.phase.String()
 
 		// Build the round error message
 		timeoutError := &pb.RoundError{
@@ -327,12 +452,13 @@ func timeoutRound(state *storage.NetworkState, timeoutRoundID id.Round,
 				"round time out", ourRound.GetRoundID(), timeoutType),
 		}
 
-		// Sign the error message with our private key
+		// Sign the error message with our private key. If it cannot be
+		// signed, the round is failed by the supervisor instead.
 		err := signature.SignRsa(timeoutError, state.GetPrivateKey())
 		if err != nil {
-			jww.FATAL.Panicf("Failed to sign error message for "+
-				"%s timed out round %d: %+v", timeoutType,
-				ourRound.GetRoundID(), err)
+			return failRound(ourRound, errors.Errorf("Failed to sign error "+
+				"message for %s timed out round %d: %+v", timeoutType,
+				ourRound.GetRoundID(), err))
 		}
 
 		err = killRound(state, ourRound, timeoutError, roundTracker)
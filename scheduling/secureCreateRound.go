@@ -19,32 +19,56 @@ import (
 	"time"
 )
 
-// createSecureRound.go contains the logic to construct a team for a secure
+// secureCreateRound.go contains the logic to construct a team for a secure
 // teaming algorithm. Focuses largely on constructing an optimal team
 
-// createSimpleRound builds the team for a round of a pool and round id
-// This for this we use the node state's order as its
-// geographic region, where:
-//    Americas       - Entirety of North and South America
-//    Western Europe - todo define countries in region
-//    Central Europe - todo define countries in region
-//    Eastern Europe - todo define countries in region
-//    Middle East    - todo define countries in region
-//    Africa         - Consists of entire continent of Africa
-//    Russia         - Consists of the country of Russia
-//    Asia           - todo define countries in region
-// We shall assume geographical distance causes latency in a naive
-//  manner, as delineated here:
-//  https://docs.google.com/document/d/1oyjIDlqC54u_eoFzQP9SVNU2IqjnQOjpUYd9aqbg5X0/edit#
-func createSecureRound(params Params, pool *waitingPool, threshold int, roundID id.Round,
-	state *storage.NetworkState, rng io.Reader) (protoRound, error) {
+// pickTeam picks the nodes for a team from the pool, enforcing the params'
+// team constraints if any are set. When the pool cannot satisfy them, the
+// constraints' fallback either picks a team with only the operator teams
+// enforced or returns errTeamConstraints, leaving the pool untouched. Operator
+// teams are refreshed from storage before picking if they are used.
+func pickTeam(params Params, pool *waitingPool, threshold int,
+	geoBins map[string]region.GeoBin, operators *operatorTeams) ([]*node.State, error) {
+	constraints := params.TeamConstraints
+	if !constraints.enabled() {
+		return pool.PickNRandAtThreshold(threshold, int(params.TeamSize))
+	}
 
-	// Pick nodes from the pool
-	nodes, err := pool.PickNRandAtThreshold(threshold, int(params.TeamSize))
-	if err != nil {
-		return protoRound{}, errors.Errorf("Failed to pick random node group: %v", err)
+	if constraints.OperatorTeams != "" {
+		if err := operators.refresh(pool.Nodes(), time.Now()); err != nil {
+			jww.WARN.Printf("Waiting for operator teams: %+v", err)
+			return nil, errTeamConstraints
+		}
+	}
+
+	nodes, err := pool.PickNRandWithConstraints(threshold,
+		int(params.TeamSize), constraints, geoBins, operators)
+	if err != errTeamConstraints {
+		return nodes, err
+	}
+
+	if constraints.Fallback == FallbackWait {
+		return nil, err
 	}
 
+	relaxed := constraints.relaxed()
+	if !relaxed.enabled() {
+		jww.WARN.Printf("Waiting pool cannot satisfy the team constraints "+
+			"%+v, picking an unconstrained team", constraints)
+		return pool.PickNRandAtThreshold(threshold, int(params.TeamSize))
+	}
+
+	jww.WARN.Printf("Waiting pool cannot satisfy the team constraints "+
+		"%+v, picking a team with only operator teams %q", constraints,
+		relaxed.OperatorTeams)
+	return pool.PickNRandWithConstraints(threshold, int(params.TeamSize),
+		relaxed, geoBins, operators)
+}
+
+// buildSecureRound orders an already picked team and builds its round.
+func buildSecureRound(params Params, nodes []*node.State, roundID id.Round,
+	state *storage.NetworkState, rng io.Reader) (protoRound, error) {
+
 	jww.TRACE.Printf("Beginning permutations")
 	start := time.Now()
 
@@ -55,8 +79,8 @@ func createSecureRound(params Params, pool *waitingPool, threshold int, roundID
 		nodeIds = append(nodeIds, n.GetID())
 	}
 
-	optimalTeam, _, err := region.OrderNodeTeam(nodeIds, countries, region.GetCountryBins(),
-		region.CreateSetLatencyTableWeights(region.CreateLinkTable()), rng)
+	optimalTeam, _, err := region.OrderNodeTeam(nodeIds, countries, state.GetGeoBins(),
+		state.GetLatencyTable(), rng)
 	if err != nil {
 		return protoRound{}, errors.WithMessage(err,
 			"Failed to generate optimal ordering")
@@ -87,6 +111,7 @@ func createProtoRound(params Params, state *storage.NetworkState,
 	newRound.BatchSize = params.BatchSize
 	newRound.NodeStateList = nodeStateList
 	newRound.ResourceQueueTimeout = params.ResourceQueueTimeout * time.Millisecond
+	newRound.PrecomputationTimeout = params.PrecomputationTimeout * time.Millisecond
 
 	return
 }
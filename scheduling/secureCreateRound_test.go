@@ -64,7 +64,12 @@ func TestCreateRound(t *testing.T) {
 
 	prng := mathRand.New(mathRand.NewSource(42))
 
-	_, err = createSecureRound(testParams, testpool, int(testParams.Threshold*float64(testParams.TeamSize)), roundID, testState, prng)
+	team, err := pickTeam(testParams, testpool, int(testParams.Threshold*float64(testParams.TeamSize)),
+		testState.GetGeoBins(), newOperatorTeams())
+	if err != nil {
+		t.Fatalf("Error in happy path: %v", err)
+	}
+	_, err = buildSecureRound(testParams, team, roundID, testState, prng)
 	if err != nil {
 		t.Errorf("Error in happy path: %v", err)
 	}
@@ -108,13 +113,8 @@ func TestCreateRound_Error_NotEnoughForTeam(t *testing.T) {
 		testpool.Add(nodeState)
 	}
 
-	roundID, err := testState.IncrementRoundID()
-	if err != nil {
-		t.Errorf("IncrementRoundID() failed: %+v", err)
-	}
-	prng := mathRand.New(mathRand.NewSource(42))
-
-	_, err = createSecureRound(testParams, testpool, int(testParams.Threshold*float64(testParams.TeamSize)), roundID, testState, prng)
+	_, err = pickTeam(testParams, testpool, int(testParams.Threshold*float64(testParams.TeamSize)),
+		testState.GetGeoBins(), newOperatorTeams())
 	if err != nil {
 		return
 	}
@@ -162,13 +162,8 @@ func TestCreateRound_Error_NotEnoughForThreshold(t *testing.T) {
 		testpool.Add(nodeState)
 	}
 
-	roundID, err := testState.IncrementRoundID()
-	if err != nil {
-		t.Errorf("IncrementRoundID() failed: %+v", err)
-	}
-	prng := mathRand.New(mathRand.NewSource(42))
-
-	_, err = createSecureRound(testParams, testpool, int(testParams.Threshold*float64(testParams.TeamSize)), roundID, testState, prng)
+	_, err = pickTeam(testParams, testpool, int(testParams.Threshold*float64(testParams.TeamSize)),
+		testState.GetGeoBins(), newOperatorTeams())
 	if err != nil {
 		return
 	}
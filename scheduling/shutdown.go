@@ -0,0 +1,152 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+// Contains the request used to gracefully stop the Scheduler
+
+import (
+	"fmt"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// How often the Scheduler reports the rounds it is waiting on while shutting
+// down
+const shutdownProgressInterval = 5 * time.Second
+
+// Shutdown asks the Scheduler to stop creating rounds and to wait for active
+// rounds to finish. Rounds still active at the deadline are failed with a
+// signed error. Progress is reported on the Progress channel and the outcome
+// on the Done channel.
+type Shutdown struct {
+	deadline time.Time
+	// How long to wait for round metrics and round updates to be written once
+	// all rounds have stopped
+	flushTimeout time.Duration
+	progress     chan ShutdownProgress
+	done         chan ShutdownResult
+}
+
+// ShutdownProgress reports the rounds the Scheduler is still waiting on.
+type ShutdownProgress struct {
+	ActiveRounds []id.Round
+	// Time left until the active rounds are failed
+	Remaining time.Duration
+}
+
+// ShutdownResult reports how the Scheduler stopped.
+type ShutdownResult struct {
+	// Rounds that were failed because they did not finish by the deadline
+	ForcedRounds []id.Round
+	// False if round metrics were still being stored after the flush timeout
+	MetricsFlushed bool
+	// False if round updates were still being signed after the flush timeout
+	UpdatesFlushed bool
+}
+
+// NewShutdown creates a Shutdown which gives active rounds the timeout to
+// finish before they are failed and then waits up to the flush timeout for
+// pending writes.
+func NewShutdown(timeout, flushTimeout time.Duration) *Shutdown {
+	return &Shutdown{
+		deadline:     time.Now().Add(timeout),
+		flushTimeout: flushTimeout,
+		progress:     make(chan ShutdownProgress, 1),
+		done:         make(chan ShutdownResult, 1),
+	}
+}
+
+// Progress returns the channel progress reports are sent on. Reports are
+// dropped if the previous one has not been read.
+func (s *Shutdown) Progress() <-chan ShutdownProgress {
+	return s.progress
+}
+
+// Done returns the channel the result is sent on once the Scheduler stops.
+func (s *Shutdown) Done() <-chan ShutdownResult {
+	return s.done
+}
+
+// Clean returns true if every round finished on its own and everything was
+// flushed.
+func (r ShutdownResult) Clean() bool {
+	return len(r.ForcedRounds) == 0 && r.MetricsFlushed && r.UpdatesFlushed
+}
+
+// flush waits for pending round metrics and round updates to be written and
+// returns the result of the shutdown.
+func (s *Shutdown) flush(state *storage.NetworkState, roundTracker *RoundTracker,
+	forcedRounds []id.Round) ShutdownResult {
+	flushDeadline := time.Now().Add(s.flushTimeout)
+	return ShutdownResult{
+		ForcedRounds:   forcedRounds,
+		MetricsFlushed: roundTracker.Flush(s.flushTimeout),
+		UpdatesFlushed: state.FlushRoundUpdates(time.Until(flushDeadline)),
+	}
+}
+
+// reportProgress sends the rounds still active without blocking.
+func (s *Shutdown) reportProgress(roundTracker *RoundTracker) {
+	progress := ShutdownProgress{
+		ActiveRounds: roundTracker.GetActiveRounds(),
+		Remaining:    time.Until(s.deadline),
+	}
+	if progress.Remaining < 0 {
+		progress.Remaining = 0
+	}
+
+	select {
+	case s.progress <- progress:
+	default:
+	}
+}
+
+// failActiveRounds kills every round still active with a signed error and
+// returns their IDs.
+func failActiveRounds(state *storage.NetworkState, roundTracker *RoundTracker,
+	timeouts *timeoutManager) []id.Round {
+	var forced []id.Round
+	for _, rid := range roundTracker.GetActiveRounds() {
+		r, exists := state.GetRoundMap().GetRound(rid)
+		if !exists {
+			roundTracker.RemoveActiveRound(rid)
+			continue
+		}
+
+		shutdownError := &pb.RoundError{
+			Id:     uint64(rid),
+			NodeId: id.Permissioning.Marshal(),
+			Error: fmt.Sprintf("Round %d killed due to permissioning "+
+				"shutdown", rid),
+		}
+		err := signature.SignRsa(shutdownError, state.GetPrivateKey())
+		if err != nil {
+			jww.ERROR.Printf("Failed to sign shutdown error for round "+
+				"%d: %+v", rid, err)
+		}
+
+		// A round failed by the shutdown is not blamed on its team
+		r.SetBlame(nil)
+		endRound(state, timeouts, r)
+		err = killRound(state, r, shutdownError, roundTracker)
+		if err != nil {
+			jww.ERROR.Printf("Failed to kill round %d on shutdown: %+v",
+				rid, err)
+		}
+
+		// Stop tracking the round even if it could not be moved to failed
+		roundTracker.RemoveActiveRound(rid)
+		forced = append(forced, rid)
+	}
+
+	return forced
+}
@@ -0,0 +1,171 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"crypto/rand"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newShutdownTestState creates a NetworkState with a round in precomputation
+// that is tracked as active and set for every node in its team.
+func newShutdownTestState(t *testing.T) (*storage.NetworkState, *RoundTracker, id.Round) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	privKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	testState, err := storage.NewState(privKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create test state: %+v", err)
+	}
+
+	nodeList := make([]*id.ID, 3)
+	for i := range nodeList {
+		nodeList[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = testState.GetNodeMap().AddNode(nodeList[i], strconv.Itoa(i), "", "", 0)
+		if err != nil {
+			t.Fatalf("Couldn't add node: %+v", err)
+		}
+	}
+
+	rid := id.Round(7)
+	r, err := testState.GetRoundMap().AddRound(rid, 32, 8, 5*time.Minute,
+		connect.NewCircuit(nodeList))
+	if err != nil {
+		t.Fatalf("Failed to add round: %+v", err)
+	}
+	if err = r.Update(states.PRECOMPUTING, time.Now()); err != nil {
+		t.Fatalf("Failed to update round: %+v", err)
+	}
+	for _, nid := range nodeList {
+		if err = testState.GetNodeMap().GetNode(nid).SetRound(r); err != nil {
+			t.Fatalf("Failed to set round for node: %+v", err)
+		}
+	}
+
+	roundTracker := NewRoundTracker()
+	roundTracker.AddActiveRound(rid)
+
+	return testState, roundTracker, rid
+}
+
+// Tests that failActiveRounds fails every active round and stops tracking it.
+func Test_failActiveRounds(t *testing.T) {
+	testState, roundTracker, rid := newShutdownTestState(t)
+
+	forced := failActiveRounds(testState, roundTracker,
+		newTimeoutManager(time.Now, newStdTimer))
+	if !reflect.DeepEqual([]id.Round{rid}, forced) {
+		t.Errorf("Unexpected forced rounds.\nexpected: %v\nreceived: %v",
+			[]id.Round{rid}, forced)
+	}
+
+	if roundTracker.Len() != 0 {
+		t.Errorf("Round tracker still has %d active rounds.", roundTracker.Len())
+	}
+
+	r, exists := testState.GetRoundMap().GetRound(rid)
+	if !exists {
+		t.Fatalf("Round %d removed from the round map.", rid)
+	}
+	if r.GetRoundState() != states.FAILED {
+		t.Errorf("Round in state %s, expected %s.", r.GetRoundState(),
+			states.FAILED)
+	}
+
+	if !roundTracker.Flush(5 * time.Second) {
+		t.Errorf("Round metric for failed round not stored.")
+	}
+}
+
+// Tests that reportProgress reports the active rounds and does not block when
+// the previous report was not read.
+func TestShutdown_reportProgress(t *testing.T) {
+	roundTracker := NewRoundTracker()
+	roundTracker.AddActiveRound(5)
+	shutdown := NewShutdown(time.Minute, time.Second)
+
+	shutdown.reportProgress(roundTracker)
+	shutdown.reportProgress(roundTracker)
+
+	progress := <-shutdown.Progress()
+	if !reflect.DeepEqual([]id.Round{5}, progress.ActiveRounds) {
+		t.Errorf("Unexpected active rounds: %v", progress.ActiveRounds)
+	}
+	if progress.Remaining <= 0 || progress.Remaining > time.Minute {
+		t.Errorf("Unexpected remaining time: %s", progress.Remaining)
+	}
+}
+
+// Tests ShutdownResult.Clean.
+func TestShutdownResult_Clean(t *testing.T) {
+	tests := []struct {
+		result ShutdownResult
+		clean  bool
+	}{
+		{ShutdownResult{MetricsFlushed: true, UpdatesFlushed: true}, true},
+		{ShutdownResult{ForcedRounds: []id.Round{1},
+			MetricsFlushed: true, UpdatesFlushed: true}, false},
+		{ShutdownResult{UpdatesFlushed: true}, false},
+		{ShutdownResult{MetricsFlushed: true}, false},
+	}
+
+	for i, tt := range tests {
+		if tt.result.Clean() != tt.clean {
+			t.Errorf("Clean() returned %t for %+v (%d).", !tt.clean,
+				tt.result, i)
+		}
+	}
+}
+
+// Tests that the Scheduler stops cleanly when shut down with no rounds running.
+func TestScheduler_Shutdown(t *testing.T) {
+	testState, _, _ := newShutdownTestState(t)
+	params := &SafeParams{Params: &Params{
+		TeamSize:              3,
+		BatchSize:             32,
+		Threshold:             1,
+		ResourceQueueTimeout:  defaultResourceQueueTimeout,
+		PrecomputationTimeout: 60000,
+		RealtimeTimeout:       15000,
+	}}
+
+	killChan := make(chan *Shutdown)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- Scheduler(params, testState, NewPauser(), killChan)
+	}()
+
+	shutdown := NewShutdown(time.Second, time.Second)
+	killChan <- shutdown
+
+	select {
+	case result := <-shutdown.Done():
+		if !result.Clean() {
+			t.Errorf("Expected a clean shutdown: %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Scheduler did not shut down.")
+	}
+
+	if err := <-errChan; err != nil {
+		t.Errorf("Scheduler returned an error: %+v", err)
+	}
+}
@@ -14,13 +14,16 @@ import (
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/elixxir/registration/storage/round"
 	"time"
 )
 
-// startRound is a function which takes the info from createSimpleRound and updates the
-//  node and network states in order to begin the round
-func startRound(round protoRound, state *storage.NetworkState, roundTracker *RoundTracker) (*round.State, error) {
+// startRound is a function which takes the info from buildSecureRound and updates the
+//  node and network states in order to begin the round. If the round cannot be
+//  started, it is removed from the node and network states.
+func startRound(round protoRound, state *storage.NetworkState, roundTracker *RoundTracker) (
+	_ *round.State, err error) {
 	// Add the round to the manager
 	r, err := state.GetRoundMap().AddRound(round.ID, round.BatchSize, state.GetAddressSpaceSize(), round.ResourceQueueTimeout,
 		round.Topology)
@@ -28,6 +31,12 @@ func startRound(round protoRound, state *storage.NetworkState, roundTracker *Rou
 		err = errors.WithMessagef(err, "Failed to create new round %v", round.ID)
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			abortRound(state, r, round.NodeStateList)
+		}
+	}()
+	r.SetClass(round.Class)
 
 	// Move the round to precomputing
 	err = r.Update(states.PRECOMPUTING, time.Now())
@@ -58,7 +67,8 @@ func startRound(round protoRound, state *storage.NetworkState, roundTracker *Rou
 	roundTracker.AddActiveRound(r.GetRoundID())
 
 	//print the round to the log
-	roundPrnt := fmt.Sprintf("Scheduling round %d with nodes: ", round.ID)
+	roundPrnt := fmt.Sprintf("Scheduling %s round %d with nodes: ",
+		round.Class, round.ID)
 	for i := 0; i < round.Topology.Len(); i++ {
 		roundPrnt += fmt.Sprintf("\n\t (%d/%d) %s", i+1, round.Topology.Len(), round.Topology.GetNodeAtIndex(i))
 	}
@@ -66,3 +76,14 @@ func startRound(round protoRound, state *storage.NetworkState, roundTracker *Rou
 
 	return r, nil
 }
+
+// abortRound removes a round which could not be started from the nodes it was
+//  added to and from the round map
+func abortRound(state *storage.NetworkState, r *round.State, nodes []*node.State) {
+	for _, n := range nodes {
+		if hasRound, nodeRound := n.GetCurrentRound(); hasRound && nodeRound == r {
+			n.ClearRound()
+		}
+	}
+	state.GetRoundMap().DeleteRound(r.GetRoundID())
+}
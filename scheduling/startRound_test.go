@@ -13,11 +13,13 @@ import (
 	"gitlab.com/elixxir/registration/storage"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/crypto/signature/rsa"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/region"
 	mathRand "math/rand"
 	"testing"
+	"time"
 )
 
 // Happy path
@@ -71,9 +73,14 @@ func TestStartRound(t *testing.T) {
 	}
 	prng := mathRand.New(mathRand.NewSource(42))
 
-	testProtoRound, err := createSecureRound(testParams, testPool, int(testParams.Threshold*float64(testParams.TeamSize)), roundID, testState, prng)
+	team, err := pickTeam(testParams, testPool, int(testParams.Threshold*float64(testParams.TeamSize)),
+		testState.GetGeoBins(), newOperatorTeams())
 	if err != nil {
-		t.Errorf("Happy path of createSimpleRound failed: %v", err)
+		t.Fatalf("Happy path of pickTeam failed: %v", err)
+	}
+	testProtoRound, err := buildSecureRound(testParams, team, roundID, testState, prng)
+	if err != nil {
+		t.Errorf("Happy path of buildSecureRound failed: %v", err)
 	}
 
 	testTracker := NewRoundTracker()
@@ -148,9 +155,14 @@ func TestStartRound_BadState(t *testing.T) {
 	testState.GetRoundMap().AddRound_Testing(badState, t)
 	prng := mathRand.New(mathRand.NewSource(42))
 
-	testProtoRound, err := createSecureRound(testParams, testPool, int(testParams.Threshold*float64(testParams.TeamSize)), roundID, testState, prng)
+	team, err := pickTeam(testParams, testPool, int(testParams.Threshold*float64(testParams.TeamSize)),
+		testState.GetGeoBins(), newOperatorTeams())
+	if err != nil {
+		t.Fatalf("Happy path of pickTeam failed: %v", err)
+	}
+	testProtoRound, err := buildSecureRound(testParams, team, roundID, testState, prng)
 	if err != nil {
-		t.Errorf("Happy path of createSimpleRound failed: %v", err)
+		t.Errorf("Happy path of buildSecureRound failed: %v", err)
 	}
 
 	testTracker := NewRoundTracker()
@@ -216,9 +228,14 @@ func TestStartRound_BadNode(t *testing.T) {
 	badState := round.NewState_Testing(roundID, states.COMPLETED, nil, t)
 	prng := mathRand.New(mathRand.NewSource(42))
 
-	testProtoRound, err := createSecureRound(testParams, testPool, int(testParams.Threshold*float64(testParams.TeamSize)), roundID, testState, prng)
+	team, err := pickTeam(testParams, testPool, int(testParams.Threshold*float64(testParams.TeamSize)),
+		testState.GetGeoBins(), newOperatorTeams())
+	if err != nil {
+		t.Fatalf("Happy path of pickTeam failed: %v", err)
+	}
+	testProtoRound, err := buildSecureRound(testParams, team, roundID, testState, prng)
 	if err != nil {
-		t.Errorf("Happy path of createSimpleRound failed: %v", err)
+		t.Errorf("Happy path of buildSecureRound failed: %v", err)
 	}
 	// Manually set the round of a node
 	testProtoRound.NodeStateList[0].SetRound(badState)
@@ -232,3 +249,47 @@ func TestStartRound_BadNode(t *testing.T) {
 	}
 
 }
+
+// Tests that a round which cannot be started is removed from the nodes it was
+// added to and from the round map.
+func TestStartRound_Abort(t *testing.T) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testState := setupNodeMap(t)
+	nodeStateList := []*node.State{setupNode(t, testState, 0),
+		setupNode(t, testState, 1)}
+	nodeList := []*id.ID{nodeStateList[0].GetID(), nodeStateList[1].GetID()}
+
+	// The second node is already in a round, so it cannot be added
+	busy := round.NewState_Testing(7, 0, connect.NewCircuit(nodeList[1:]), t)
+	if err = nodeStateList[1].SetRound(busy); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+
+	testProtoRound := protoRound{
+		Topology:             connect.NewCircuit(nodeList),
+		ID:                   1,
+		NodeStateList:        nodeStateList,
+		BatchSize:            32,
+		ResourceQueueTimeout: time.Minute,
+	}
+
+	_, err = startRound(testProtoRound, testState, NewRoundTracker())
+	if err == nil {
+		t.Fatalf("Expected error starting round with a busy node.")
+	}
+
+	if hasRound, _ := nodeStateList[0].GetCurrentRound(); hasRound {
+		t.Errorf("Round of aborted start not cleared from node.")
+	}
+	if _, r := nodeStateList[1].GetCurrentRound(); r != busy {
+		t.Errorf("Round of busy node was changed.")
+	}
+	if _, exists := testState.GetRoundMap().GetRound(1); exists {
+		t.Errorf("Round of aborted start still in the round map.")
+	}
+}
@@ -0,0 +1,152 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/id"
+	"runtime/debug"
+)
+
+// supervisor.go classifies the errors the Scheduler encounters and recovers
+// from those confined to a single round or node, so that one bad round or
+// malformed node update does not stop the network. Errors which are not
+// classified break an invariant of the Scheduler, such as being unable to
+// issue round updates, and stop it.
+
+// roundFailure is an error confined to a round. The round is failed.
+type roundFailure struct {
+	round *round.State
+	err   error
+}
+
+func (e *roundFailure) Error() string { return e.err.Error() }
+func (e *roundFailure) Unwrap() error { return e.err }
+
+// nodeFailure is an error confined to a node. The node is quarantined.
+type nodeFailure struct {
+	node *id.ID
+	err  error
+}
+
+func (e *nodeFailure) Error() string { return e.err.Error() }
+func (e *nodeFailure) Unwrap() error { return e.err }
+
+// failRound marks the error as confined to the round.
+func failRound(r *round.State, err error) error {
+	return &roundFailure{round: r, err: err}
+}
+
+// failNode marks the error as confined to the node.
+func failNode(nid *id.ID, err error) error {
+	return &nodeFailure{node: nid, err: err}
+}
+
+// handleNodeUpdate handles the node update and recovers from any error or
+// panic confined to a round or node. Only fatal errors are returned.
+func (sc *stateChanger) handleNodeUpdate(update node.UpdateNotification) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			jww.ERROR.Printf("Recovered from panic handling update of node "+
+				"%s: %v\n%s", update.Node, p, debug.Stack())
+			err = sc.recoverError(failNode(update.Node, errors.Errorf(
+				"panic handling update from %s to %s: %v",
+				update.FromActivity, update.ToActivity, p)))
+		}
+	}()
+
+	return sc.recoverError(sc.HandleNodeUpdates(update))
+}
+
+// recoverError fails the round of an error confined to a round and quarantines
+// the node of an error confined to a node. Any other error is fatal and is
+// returned, as is an error encountered while recovering.
+func (sc *stateChanger) recoverError(err error) error {
+	var rf *roundFailure
+	var nf *nodeFailure
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rf):
+		jww.ERROR.Printf("Failing round %d: %+v", rf.round.GetRoundID(), err)
+		return sc.failRound(rf.round, err)
+	case errors.As(err, &nf):
+		jww.ERROR.Printf("Quarantining node %s: %+v", nf.node, err)
+		return sc.quarantineNode(nf.node, err)
+	default:
+		return err
+	}
+}
+
+// failRound kills the round with a signed error from permissioning unless it
+// has already ended. If the error cannot be signed, the round is killed with
+// the unsigned error so that only the round is affected.
+func (sc *stateChanger) failRound(r *round.State, cause error) error {
+	roundState := r.GetRoundState()
+	if roundState == states.COMPLETED || roundState == states.FAILED {
+		return nil
+	}
+
+	roundError := &pb.RoundError{
+		Id:     uint64(r.GetRoundID()),
+		NodeId: id.Permissioning.Marshal(),
+		Error: fmt.Sprintf("Round %d killed by the scheduler: %s",
+			r.GetRoundID(), cause),
+	}
+	err := signature.SignRsa(roundError, sc.state.GetPrivateKey())
+	if err != nil {
+		jww.ERROR.Printf("Failed to sign error message for failed round "+
+			"%d, killing it with an unsigned error: %+v", r.GetRoundID(), err)
+	}
+
+	sc.endFailedRound(r)
+	return killRound(sc.state, r, roundError, sc.roundTracker)
+}
+
+// quarantineNode fails the node's round, if it has one, and sets it inactive
+// in the offline pool. The node is not scheduled again until it resets by
+// polling as waiting, which returns it to the pool.
+func (sc *stateChanger) quarantineNode(nid *id.ID, cause error) error {
+	n := sc.state.GetNodeMap().GetNode(nid)
+	if n == nil {
+		jww.WARN.Printf("Cannot quarantine node %s, it is not in the "+
+			"node map", nid)
+		return nil
+	}
+
+	if hasRound, r := n.GetCurrentRound(); hasRound {
+		n.ClearRound()
+		err := sc.failRound(r, errors.WithMessagef(cause, "node %s was "+
+			"quarantined", nid))
+		if err != nil {
+			return err
+		}
+	}
+
+	n.SetInactive()
+	sc.pool.SetNodeToOffline(n)
+	return nil
+}
+
+// returnToPool puts the nodes of a team which could not be started back into
+// the pool so that they may be picked for another round.
+func returnToPool(pool *waitingPool, team []*node.State) {
+	for _, n := range team {
+		hasRound, _ := n.GetCurrentRound()
+		if !hasRound && n.GetStatus() == node.Active && n.IsSchedulable() {
+			pool.Add(n)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/primitives/current"
+	"gitlab.com/elixxir/primitives/states"
+	"gitlab.com/elixxir/registration/storage"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/elixxir/registration/storage/round"
+	"gitlab.com/xx_network/comms/connect"
+	"gitlab.com/xx_network/comms/signature"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// newSupervisorTestStateChanger creates a stateChanger over a network state
+// with the given number of nodes, all of which are in the pool.
+func newSupervisorTestStateChanger(t *testing.T, numNodes int) (
+	*stateChanger, []*node.State) {
+	var err error
+	storage.PermissioningDb, _, err = storage.NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+
+	testState := setupNodeMap(t)
+	testPool := NewWaitingPool()
+	nodes := make([]*node.State, numNodes)
+	for i := range nodes {
+		nodes[i] = setupNode(t, testState, uint64(i))
+		testPool.Add(nodes[i])
+	}
+
+	sc := &stateChanger{
+		pool:         testPool,
+		state:        testState,
+		roundTracker: NewRoundTracker(),
+		timeouts:     newTimeoutManager(time.Now, newStdTimer),
+		pacer:        newPacer(),
+		reputation:   newReputation(""),
+	}
+	return sc, nodes
+}
+
+// Tests that a node which reports a round it does not have is quarantined
+// rather than stopping the scheduler.
+func TestStateChanger_handleNodeUpdate_NodeFailure(t *testing.T) {
+	sc, nodes := newSupervisorTestStateChanger(t, 2)
+
+	update := node.UpdateNotification{
+		Node:         nodes[0].GetID(),
+		FromActivity: current.WAITING,
+		ToActivity:   current.PRECOMPUTING,
+	}
+	nodes[0].GetPollingLock().Lock()
+	if err := sc.handleNodeUpdate(update); err != nil {
+		t.Fatalf("Node failure was not recovered: %+v", err)
+	}
+
+	if nodes[0].GetStatus() != node.Inactive {
+		t.Errorf("Quarantined node is %s, expected %s", nodes[0].GetStatus(),
+			node.Inactive)
+	}
+	if sc.pool.Len() != 1 || sc.pool.OfflineLen() != 1 {
+		t.Errorf("Expected 1 online and 1 offline node, found %d and %d",
+			sc.pool.Len(), sc.pool.OfflineLen())
+	}
+}
+
+// Tests that an update for a node which is not in the node map is dropped.
+func TestStateChanger_handleNodeUpdate_UnknownNode(t *testing.T) {
+	sc, _ := newSupervisorTestStateChanger(t, 1)
+
+	update := node.UpdateNotification{
+		Node:         id.NewIdFromString("unknown", id.Node, t),
+		FromActivity: current.WAITING,
+		ToActivity:   current.PRECOMPUTING,
+	}
+	if err := sc.handleNodeUpdate(update); err != nil {
+		t.Errorf("Update for unknown node was not recovered: %+v", err)
+	}
+}
+
+// Tests that a round failure kills the round with an error signed by
+// permissioning.
+func TestStateChanger_recoverError_RoundFailure(t *testing.T) {
+	sc, nodes := newSupervisorTestStateChanger(t, 2)
+	topology := connect.NewCircuit([]*id.ID{nodes[0].GetID(), nodes[1].GetID()})
+	r := round.NewState_Testing(42, states.PRECOMPUTING, topology, t)
+
+	err := sc.recoverError(failRound(r, errors.New("test failure")))
+	if err != nil {
+		t.Fatalf("Round failure was not recovered: %+v", err)
+	}
+
+	if r.GetRoundState() != states.FAILED {
+		t.Fatalf("Round is %s, expected %s", r.GetRoundState(), states.FAILED)
+	}
+	roundErrors := r.BuildRoundInfo().Errors
+	if len(roundErrors) != 1 {
+		t.Fatalf("Expected 1 round error, found %d", len(roundErrors))
+	}
+	err = signature.VerifyRsa(roundErrors[0], sc.state.GetPrivateKey().GetPublic())
+	if err != nil {
+		t.Errorf("Failed to verify round error: %+v", err)
+	}
+
+	// A round which has already ended is left alone
+	if err = sc.recoverError(failRound(r, errors.New("second"))); err != nil {
+		t.Errorf("Failing an ended round returned an error: %+v", err)
+	}
+	if len(r.BuildRoundInfo().Errors) != 1 {
+		t.Errorf("Ended round was failed again.")
+	}
+}
+
+// Tests that quarantining a node with a round fails the round and clears it
+// from the node.
+func TestStateChanger_recoverError_NodeFailureWithRound(t *testing.T) {
+	sc, nodes := newSupervisorTestStateChanger(t, 2)
+	topology := connect.NewCircuit([]*id.ID{nodes[0].GetID(), nodes[1].GetID()})
+	r := round.NewState_Testing(42, states.PRECOMPUTING, topology, t)
+	if err := nodes[0].SetRound(r); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+
+	err := sc.recoverError(failNode(nodes[0].GetID(), errors.New("test failure")))
+	if err != nil {
+		t.Fatalf("Node failure was not recovered: %+v", err)
+	}
+
+	if hasRound, _ := nodes[0].GetCurrentRound(); hasRound {
+		t.Errorf("Quarantined node still has a round.")
+	}
+	if r.GetRoundState() != states.FAILED {
+		t.Errorf("Round is %s, expected %s", r.GetRoundState(), states.FAILED)
+	}
+	if nodes[0].GetStatus() != node.Inactive {
+		t.Errorf("Quarantined node is %s, expected %s", nodes[0].GetStatus(),
+			node.Inactive)
+	}
+}
+
+// Tests that errors which are not confined to a round or node are returned.
+func TestStateChanger_recoverError_Fatal(t *testing.T) {
+	sc, _ := newSupervisorTestStateChanger(t, 1)
+
+	fatal := errors.New("fatal")
+	if err := sc.recoverError(fatal); err != fatal {
+		t.Errorf("Fatal error not returned: %+v", err)
+	}
+	if err := sc.recoverError(nil); err != nil {
+		t.Errorf("Unexpected error for nil: %+v", err)
+	}
+}
+
+// Tests that returnToPool only returns nodes which may be scheduled.
+func Test_returnToPool(t *testing.T) {
+	sc, nodes := newSupervisorTestStateChanger(t, 3)
+	for _, n := range nodes {
+		sc.pool.Remove(n)
+	}
+
+	topology := connect.NewCircuit([]*id.ID{nodes[1].GetID()})
+	if err := nodes[1].SetRound(round.NewState_Testing(42, 0, topology, t)); err != nil {
+		t.Fatalf("Failed to set round: %+v", err)
+	}
+	nodes[2].SetInactive()
+
+	returnToPool(sc.pool, nodes)
+
+	if sc.pool.Len() != 1 {
+		t.Errorf("Expected 1 node returned to the pool, found %d",
+			sc.pool.Len())
+	}
+}
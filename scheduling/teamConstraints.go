@@ -0,0 +1,233 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/region"
+	"strings"
+)
+
+// teamConstraints.go contains the geographic constraints on the makeup of a
+// team and the logic to check candidate nodes against them.
+
+const (
+	// FallbackRelax picks an unconstrained random team when the pool cannot
+	// satisfy the constraints. This is the default.
+	FallbackRelax = "relax"
+	// FallbackWait does not form a team until the pool can satisfy the
+	// constraints.
+	FallbackWait = "wait"
+
+	// Number of random orders of the pool a team satisfying the constraints
+	// is looked for in before the pool is considered unable to satisfy them
+	teamPickAttempts = 10
+)
+
+// errTeamConstraints is returned when no team satisfying the constraints can
+// be picked from the pool.
+var errTeamConstraints = errors.New("waiting pool cannot satisfy the team " +
+	"constraints")
+
+// TeamConstraints restricts the geographic and operator makeup of a team. A
+// zero value for any field disables that constraint.
+type TeamConstraints struct {
+	// Maximum number of nodes from the same GeoBin in a team
+	MaxNodesPerBin uint32
+	// Minimum number of distinct countries in a team
+	MinCountries uint32
+	// Pairs of country codes whose nodes may never share a team
+	ExcludedCountryPairs [][2]string
+	// How nodes whose Applications share a Team are teamed, either
+	// OperatorTeamsFixed or OperatorTeamsSeparate. Never relaxed by the
+	// fallback
+	OperatorTeams string
+	// What to do when the pool cannot satisfy the constraints, either
+	// FallbackRelax or FallbackWait
+	Fallback string
+}
+
+// enabled returns true if any constraint is set.
+func (tc TeamConstraints) enabled() bool {
+	return tc.MaxNodesPerBin > 0 || tc.MinCountries > 0 ||
+		len(tc.ExcludedCountryPairs) > 0 || tc.OperatorTeams != ""
+}
+
+// relaxed returns the constraints which are kept when the pool cannot satisfy
+// them and the fallback is FallbackRelax.
+func (tc TeamConstraints) relaxed() TeamConstraints {
+	return TeamConstraints{OperatorTeams: tc.OperatorTeams}
+}
+
+// validate returns every problem with the constraints for the given team size.
+// Country codes are checked against the GeoBin table of the network, which is
+// skipped if geoBins is nil.
+func (tc TeamConstraints) validate(teamSize uint32,
+	geoBins map[string]region.GeoBin) []string {
+	var problems []string
+
+	if tc.MinCountries > teamSize {
+		problems = append(problems, fmt.Sprintf("TeamConstraints."+
+			"MinCountries %d is larger than TeamSize %d", tc.MinCountries,
+			teamSize))
+	}
+
+	for _, pair := range tc.ExcludedCountryPairs {
+		if geoBins == nil {
+			break
+		}
+		for _, country := range pair {
+			if _, exists := geoBins[strings.ToUpper(country)]; !exists {
+				problems = append(problems, fmt.Sprintf("TeamConstraints."+
+					"ExcludedCountryPairs contains unknown country code %q",
+					country))
+			}
+		}
+	}
+
+	switch tc.OperatorTeams {
+	case "", OperatorTeamsFixed, OperatorTeamsSeparate:
+	default:
+		problems = append(problems, fmt.Sprintf("TeamConstraints."+
+			"OperatorTeams %q must be either %q or %q", tc.OperatorTeams,
+			OperatorTeamsFixed, OperatorTeamsSeparate))
+	}
+
+	switch tc.Fallback {
+	case "", FallbackRelax, FallbackWait:
+	default:
+		problems = append(problems, fmt.Sprintf("TeamConstraints.Fallback "+
+			"%q must be either %q or %q", tc.Fallback, FallbackRelax,
+			FallbackWait))
+	}
+
+	return problems
+}
+
+// teamBuilder tracks a team as it is built and checks whether further nodes
+// can join it without breaking the constraints.
+type teamBuilder struct {
+	constraints TeamConstraints
+	geoBins     map[string]region.GeoBin
+	excluded    map[[2]string]bool
+	operators   *operatorTeams
+
+	team      []*node.State
+	binCount  map[region.GeoBin]uint32
+	countries map[string]bool
+	teams     map[string]bool
+}
+
+// newTeamBuilder creates an empty team for the constraints. Country codes are
+// looked up in the passed in GeoBin table and operator teams in operators,
+// which may be nil if the constraints do not use them.
+func newTeamBuilder(constraints TeamConstraints,
+	geoBins map[string]region.GeoBin, operators *operatorTeams) *teamBuilder {
+	excluded := make(map[[2]string]bool, 2*len(constraints.ExcludedCountryPairs))
+	for _, pair := range constraints.ExcludedCountryPairs {
+		a, b := strings.ToUpper(pair[0]), strings.ToUpper(pair[1])
+		excluded[[2]string{a, b}] = true
+		excluded[[2]string{b, a}] = true
+	}
+
+	return &teamBuilder{
+		constraints: constraints,
+		geoBins:     geoBins,
+		excluded:    excluded,
+		operators:   operators,
+		binCount:    make(map[region.GeoBin]uint32),
+		countries:   make(map[string]bool),
+		teams:       make(map[string]bool),
+	}
+}
+
+// fits returns true if the node can join the team without breaking the bin
+// limit, an excluded country pair, or the operator teams. Nodes whose country
+// is not in the GeoBin table are not counted against any bin.
+func (tb *teamBuilder) fits(n *node.State) bool {
+	country := strings.ToUpper(n.GetOrdering())
+	team := tb.operators.teamOf(n)
+
+	switch tb.constraints.OperatorTeams {
+	case OperatorTeamsFixed:
+		if team == "" || (len(tb.teams) > 0 && !tb.teams[team]) {
+			return false
+		}
+	case OperatorTeamsSeparate:
+		if team != "" && tb.teams[team] {
+			return false
+		}
+	}
+
+	if tb.constraints.MaxNodesPerBin > 0 {
+		if bin, exists := tb.geoBins[country]; exists &&
+			tb.binCount[bin] >= tb.constraints.MaxNodesPerBin {
+			return false
+		}
+	}
+
+	for member := range tb.countries {
+		if tb.excluded[[2]string{member, country}] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasCountry returns true if a node from the node's country is on the team.
+func (tb *teamBuilder) hasCountry(n *node.State) bool {
+	return tb.countries[strings.ToUpper(n.GetOrdering())]
+}
+
+// add puts the node on the team.
+func (tb *teamBuilder) add(n *node.State) {
+	country := strings.ToUpper(n.GetOrdering())
+	if bin, exists := tb.geoBins[country]; exists {
+		tb.binCount[bin]++
+	}
+	tb.countries[country] = true
+	if team := tb.operators.teamOf(n); team != "" {
+		tb.teams[team] = true
+	}
+	tb.team = append(tb.team, n)
+}
+
+// satisfied returns true if the team has n nodes from enough countries.
+func (tb *teamBuilder) satisfied(n int) bool {
+	return len(tb.team) == n &&
+		uint32(len(tb.countries)) >= tb.constraints.MinCountries
+}
+
+// fill adds nodes from the candidates, in order, until the team has n nodes.
+// While the team lacks the minimum number of countries only nodes from new
+// countries are taken. Returns true if the finished team is satisfied.
+func (tb *teamBuilder) fill(candidates []*node.State, n int) bool {
+	picked := make([]bool, len(candidates))
+
+	// First cover the minimum number of countries, then fill the team
+	for _, newCountriesOnly := range []bool{true, false} {
+		for i, ns := range candidates {
+			if len(tb.team) == n || (newCountriesOnly &&
+				uint32(len(tb.countries)) >= tb.constraints.MinCountries) {
+				break
+			}
+
+			if picked[i] || (newCountriesOnly && tb.hasCountry(ns)) || !tb.fits(ns) {
+				continue
+			}
+
+			tb.add(ns)
+			picked[i] = true
+		}
+	}
+
+	return tb.satisfied(n)
+}
@@ -0,0 +1,285 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package scheduling
+
+import (
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"strings"
+	"testing"
+)
+
+// Tests that no team picked holds more than MaxNodesPerBin nodes of a bin.
+func TestWaitingPool_PickNRandWithConstraints_MaxNodesPerBin(t *testing.T) {
+	constraints := TeamConstraints{MaxNodesPerBin: 2}
+	geoBins := region.GetCountryBins()
+
+	for i := 0; i < 10; i++ {
+		testPool := setupCountryPool(t, "US", "US", "US", "US", "DE", "DE", "DE", "DE")
+
+		team, err := testPool.PickNRandWithConstraints(4, 4, constraints, geoBins, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+
+		binCount := make(map[region.GeoBin]int)
+		for _, n := range team {
+			binCount[geoBins[n.GetOrdering()]]++
+		}
+		for bin, count := range binCount {
+			if count > 2 {
+				t.Errorf("Team has %d nodes in bin %s", count, bin)
+			}
+		}
+
+		if testPool.Len() != 4 {
+			t.Errorf("Picked nodes not removed from pool. Pool size: %d",
+				testPool.Len())
+		}
+	}
+}
+
+// Tests that every team picked has at least MinCountries countries.
+func TestWaitingPool_PickNRandWithConstraints_MinCountries(t *testing.T) {
+	constraints := TeamConstraints{MinCountries: 3}
+
+	for i := 0; i < 10; i++ {
+		testPool := setupCountryPool(t, "US", "US", "US", "US", "US", "DE", "JP")
+
+		team, err := testPool.PickNRandWithConstraints(
+			3, 3, constraints, region.GetCountryBins(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+
+		countries := make(map[string]bool)
+		for _, n := range team {
+			countries[n.GetOrdering()] = true
+		}
+		if len(countries) != 3 {
+			t.Errorf("Team has %d countries, expected 3: %v",
+				len(countries), countries)
+		}
+	}
+}
+
+// Tests that no team picked contains an excluded country pair.
+func TestWaitingPool_PickNRandWithConstraints_ExcludedCountryPairs(t *testing.T) {
+	constraints := TeamConstraints{
+		ExcludedCountryPairs: [][2]string{{"us", "ru"}},
+	}
+
+	for i := 0; i < 10; i++ {
+		testPool := setupCountryPool(t, "US", "US", "US", "RU", "RU", "RU")
+
+		team, err := testPool.PickNRandWithConstraints(
+			3, 3, constraints, region.GetCountryBins(), nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %+v", err)
+		}
+
+		for _, n := range team[1:] {
+			if n.GetOrdering() != team[0].GetOrdering() {
+				t.Fatalf("Team contains excluded pair %s and %s",
+					team[0].GetOrdering(), n.GetOrdering())
+			}
+		}
+	}
+}
+
+// Tests that a pool order in which a single pass cannot satisfy the constraints
+// is retried in a new order.
+func TestWaitingPool_PickNRandWithConstraints_Retry(t *testing.T) {
+	constraints := TeamConstraints{
+		MinCountries:         2,
+		ExcludedCountryPairs: [][2]string{{"US", "DE"}, {"US", "FR"}},
+	}
+	testPool := setupCountryPool(t, "US", "DE", "FR")
+
+	// Consider the US node first, which cannot be teamed with either other
+	// node, then last
+	var orders int
+	testPool.SetOrder(func(pooled []*node.State) []*node.State {
+		orders++
+		ordered := make([]*node.State, 0, len(pooled))
+		for _, country := range []string{"US", "DE", "FR"} {
+			for _, n := range pooled {
+				if n.GetOrdering() == country {
+					ordered = append(ordered, n)
+				}
+			}
+		}
+		if orders > 1 {
+			ordered = append(ordered[1:], ordered[0])
+		}
+		return ordered
+	})
+
+	team, err := testPool.PickNRandWithConstraints(
+		2, 2, constraints, region.GetCountryBins(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %+v", err)
+	}
+	if orders != 2 {
+		t.Errorf("Expected the pool to be ordered twice, ordered %d times",
+			orders)
+	}
+	for _, n := range team {
+		if n.GetOrdering() == "US" {
+			t.Errorf("Team contains an excluded pair: %v", team)
+		}
+	}
+}
+
+// Error path: the pool cannot satisfy the constraints and is left untouched.
+func TestWaitingPool_PickNRandWithConstraints_Unsatisfiable(t *testing.T) {
+	testPool := setupCountryPool(t, "US", "US", "US", "US")
+
+	_, err := testPool.PickNRandWithConstraints(3, 3,
+		TeamConstraints{MaxNodesPerBin: 2}, region.GetCountryBins(), nil)
+	if err != errTeamConstraints {
+		t.Errorf("Expected errTeamConstraints, received: %v", err)
+	}
+
+	if testPool.Len() != 4 {
+		t.Errorf("Pool modified on failure. Pool size: %d", testPool.Len())
+	}
+}
+
+// Tests that pickTeam falls back to an unconstrained team by default and
+// waits when the fallback is FallbackWait.
+func TestPickTeam_Fallback(t *testing.T) {
+	params := Params{
+		TeamSize:        3,
+		TeamConstraints: TeamConstraints{MaxNodesPerBin: 2},
+	}
+
+	testPool := setupCountryPool(t, "US", "US", "US", "US")
+	team, err := pickTeam(params, testPool, 3, region.GetCountryBins(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error with relaxed fallback: %+v", err)
+	}
+	if len(team) != 3 {
+		t.Errorf("Unexpected team size %d", len(team))
+	}
+
+	params.TeamConstraints.Fallback = FallbackWait
+	testPool = setupCountryPool(t, "US", "US", "US", "US")
+	_, err = pickTeam(params, testPool, 3, region.GetCountryBins(), nil)
+	if err != errTeamConstraints {
+		t.Errorf("Expected errTeamConstraints, received: %v", err)
+	}
+	if testPool.Len() != 4 {
+		t.Errorf("Pool modified while waiting. Pool size: %d", testPool.Len())
+	}
+}
+
+// Tests that Validate reports invalid team constraints.
+func TestParams_Validate_TeamConstraints(t *testing.T) {
+	params := Params{
+		TeamSize:              3,
+		BatchSize:             32,
+		ResourceQueueTimeout:  defaultResourceQueueTimeout,
+		PrecomputationTimeout: defaultPrecomputationTimeout,
+		RealtimeTimeout:       defaultRealtimeTimeout,
+		TeamConstraints: TeamConstraints{
+			MinCountries:         4,
+			ExcludedCountryPairs: [][2]string{{"US", "XX"}},
+			Fallback:             "sometimes",
+		},
+	}
+
+	err := params.Validate(0, region.GetCountryBins())
+	if err == nil {
+		t.Fatalf("Validate did not error on invalid team constraints")
+	}
+
+	for _, expected := range []string{"MinCountries", "\"XX\"", "Fallback"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Error does not report %s: %+v", expected, err)
+		}
+	}
+}
+
+// Tests that the country codes of the constraints are checked against the
+// passed in GeoBin table rather than the default one, and not at all without
+// a table.
+func TestTeamConstraints_validate_GeoBins(t *testing.T) {
+	tc := TeamConstraints{ExcludedCountryPairs: [][2]string{{"us", "DE"}}}
+	geoBins := map[string]region.GeoBin{
+		"US": region.NorthAmerica,
+		"ZZ": region.WesternEurope,
+	}
+
+	if problems := tc.validate(3, geoBins); len(problems) != 1 ||
+		!strings.Contains(problems[0], "\"DE\"") {
+		t.Errorf("Expected DE to be reported as unknown: %v", problems)
+	}
+
+	tc.ExcludedCountryPairs[0][1] = "zz"
+	if problems := tc.validate(3, geoBins); len(problems) != 0 {
+		t.Errorf("Country of the GeoBin table reported: %v", problems)
+	}
+
+	tc.ExcludedCountryPairs[0][1] = "XX"
+	if problems := tc.validate(3, nil); len(problems) != 0 {
+		t.Errorf("Countries checked without a GeoBin table: %v", problems)
+	}
+}
+
+// Tests that team constraints are parsed from the scheduling params JSON.
+func TestLoadParams_TeamConstraints(t *testing.T) {
+	serial := []byte(`{"TeamSize": 3, "BatchSize": 32, "TeamConstraints": ` +
+		`{"MaxNodesPerBin": 1, "MinCountries": 2, ` +
+		`"ExcludedCountryPairs": [["US", "RU"]], "Fallback": "wait"}}`)
+
+	params, err := LoadParams(serial, 0, nil)
+	if err != nil {
+		t.Fatalf("LoadParams returned an error: %+v", err)
+	}
+
+	tc := params.TeamConstraints
+	if tc.MaxNodesPerBin != 1 || tc.MinCountries != 2 ||
+		len(tc.ExcludedCountryPairs) != 1 ||
+		tc.ExcludedCountryPairs[0] != [2]string{"US", "RU"} ||
+		tc.Fallback != FallbackWait {
+		t.Errorf("Unexpected team constraints: %+v", tc)
+	}
+}
+
+// Tests that a builder without constraints accepts any node.
+func TestTeamBuilder_NoConstraints(t *testing.T) {
+	tb := newTeamBuilder(TeamConstraints{}, region.GetCountryBins(), nil)
+	testPool := setupCountryPool(t, "US", "US")
+	testPool.pool.Do(func(face interface{}) {
+		n := face.(*node.State)
+		if !tb.fits(n) {
+			t.Errorf("Node %s rejected without constraints", n.GetID())
+		}
+		tb.add(n)
+	})
+	if !tb.satisfied(2) {
+		t.Errorf("Team of 2 not satisfied without constraints")
+	}
+}
+
+// setupCountryPool builds a waiting pool with one node per passed in country.
+func setupCountryPool(t *testing.T, countries ...string) *waitingPool {
+	testState := setupNodeMap(t)
+	testPool := NewWaitingPool()
+	for i, country := range countries {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		err := testState.GetNodeMap().AddNode(nid, country, "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node to state: %v", err)
+		}
+		testPool.Add(testState.GetNodeMap().GetNode(nid))
+	}
+	return testPool
+}
@@ -24,7 +24,8 @@ import (
 // This isn't included in tests because it is hard to test and is only a data collector for logs.
 // It's used in live environment logs to check stability.
 func trackRounds(state *storage.NetworkState, pool *waitingPool,
-	roundTracker *RoundTracker, schedulerIteration *uint32) {
+	roundTracker *RoundTracker, timeouts *timeoutManager, pace *pacer,
+	schedulerIteration *uint32) {
 	// Period of polling the state map for logs
 	schedulingTicker := time.NewTicker(1 * time.Minute)
 
@@ -153,6 +154,19 @@ func trackRounds(state *storage.NetworkState, pool *waitingPool,
 		jww.INFO.Printf("Nodes in pool: %v", pool.Len())
 		jww.INFO.Printf("Nodes in offline pool: %v", pool.OfflineLen())
 		jww.INFO.Printf("")
+		spacing, queued, failureRate := pace.status(now)
+		if spacing > 0 {
+			jww.INFO.Printf("Realtime spacing: %s", spacing)
+		}
+		jww.INFO.Printf("Rounds queued for realtime: %v", queued)
+		jww.INFO.Printf("Recent realtime failure rate: %.2f", failureRate)
+		jww.INFO.Printf("")
+		nodeUpdates := state.GetNodeUpdateQueueStats()
+		jww.INFO.Printf("Node updates queued: %v", nodeUpdates.Queued)
+		jww.INFO.Printf("Node updates overflowed: %v (peak %v, total %v)",
+			nodeUpdates.Overflow, nodeUpdates.PeakOverflow,
+			nodeUpdates.TotalOverflowed)
+		jww.INFO.Printf("")
 		jww.INFO.Printf("Total Nodes: %v", len(nodeStates))
 		jww.INFO.Printf("Nodes without recent poll: %v", len(noPoll))
 		jww.INFO.Printf("Nodes without recent update: %v", len(notUpdating))
@@ -219,5 +233,17 @@ func trackRounds(state *storage.NetworkState, pool *waitingPool,
 			jww.INFO.Printf("\tNo Rounds active")
 		}
 		jww.INFO.Printf("")
+
+		deadlines := timeouts.upcoming()
+		jww.INFO.Printf("Upcoming Round Timeouts")
+		if len(deadlines) > 0 {
+			for _, d := range deadlines {
+				jww.INFO.Printf("\tRound %v %s times out in %s", d.round,
+					d.phase, d.deadline.Sub(now))
+			}
+		} else {
+			jww.INFO.Printf("\tNo round timeouts pending")
+		}
+		jww.INFO.Printf("")
 	}
 }
@@ -0,0 +1,161 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+)
+
+// applications.go contains the review status of Node Applications and the
+// signed public directory of approved Nodes built from them.
+
+// ApplicationStatus is the review status of an Application.
+type ApplicationStatus uint8
+
+const (
+	// Applications created before review existed, such as those populated
+	// from registration codes, are approved
+	ApplicationApproved ApplicationStatus = iota
+	ApplicationPending
+	ApplicationRejected
+)
+
+func (s ApplicationStatus) String() string {
+	switch s {
+	case ApplicationApproved:
+		return "approved"
+	case ApplicationPending:
+		return "pending"
+	case ApplicationRejected:
+		return "rejected"
+	default:
+		return fmt.Sprintf("UNKNOWN APPLICATION STATUS %d", uint8(s))
+	}
+}
+
+// MarshalText encodes the status as its name so that it is readable in JSON.
+func (s ApplicationStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the status from its name.
+func (s *ApplicationStatus) UnmarshalText(text []byte) error {
+	for _, status := range []ApplicationStatus{
+		ApplicationApproved, ApplicationPending, ApplicationRejected} {
+		if string(text) == status.String() {
+			*s = status
+			return nil
+		}
+	}
+	return errors.Errorf("unknown application status %q", text)
+}
+
+// DirectoryNode is the public information of an approved Node in the
+// NodeDirectory. Contact details which are not public are left out.
+type DirectoryNode struct {
+	// Node ID, once the Node has registered
+	Id []byte `json:",omitempty"`
+
+	Name     string
+	Url      string
+	Blurb    string
+	Location string
+	GeoBin   string
+	Team     string
+	Network  string
+
+	Forum     string
+	Twitter   string
+	Discord   string
+	Instagram string
+	Medium    string
+}
+
+// NodeDirectory is the signed public directory of approved Nodes.
+type NodeDirectory struct {
+	Nodes     []*DirectoryNode
+	Timestamp int64
+	Signature []byte `json:",omitempty"`
+}
+
+// NewNodeDirectory builds the directory from the approved Applications, in the
+// order passed in, at the timestamp in nanoseconds.
+func NewNodeDirectory(applications []*Application,
+	timestamp int64) *NodeDirectory {
+	d := &NodeDirectory{Nodes: []*DirectoryNode{}, Timestamp: timestamp}
+	for _, app := range applications {
+		if app.Status != ApplicationApproved {
+			continue
+		}
+
+		d.Nodes = append(d.Nodes, &DirectoryNode{
+			Id:        app.Node.Id,
+			Name:      app.Name,
+			Url:       app.Url,
+			Blurb:     app.Blurb,
+			Location:  app.Location,
+			GeoBin:    app.GeoBin,
+			Team:      app.Team,
+			Network:   app.Network,
+			Forum:     app.Forum,
+			Twitter:   app.Twitter,
+			Discord:   app.Discord,
+			Instagram: app.Instagram,
+			Medium:    app.Medium,
+		})
+	}
+	return d
+}
+
+// digest returns the hash of the Nodes, encoded as JSON, and the timestamp
+// that is signed.
+func (d *NodeDirectory) digest() ([]byte, error) {
+	nodes, err := json.Marshal(d.Nodes)
+	if err != nil {
+		return nil, errors.Errorf("Could not marshal directory: %+v", err)
+	}
+
+	h := crypto.SHA256.New()
+	h.Write(nodes)
+	tsBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(tsBytes, uint64(d.Timestamp))
+	h.Write(tsBytes)
+	return h.Sum(nil), nil
+}
+
+// Sign signs the directory with the key.
+func (d *NodeDirectory) Sign(key *rsa.PrivateKey) error {
+	digest, err := d.digest()
+	if err != nil {
+		return err
+	}
+	d.Signature, err = rsa.Sign(rand.Reader, key, crypto.SHA256, digest, nil)
+	if err != nil {
+		return errors.Errorf("Could not sign Node directory: %+v", err)
+	}
+	return nil
+}
+
+// Verify checks that the directory was signed by the key.
+func (d *NodeDirectory) Verify(key *rsa.PublicKey) error {
+	digest, err := d.digest()
+	if err != nil {
+		return err
+	}
+	err = rsa.Verify(key, crypto.SHA256, digest, d.Signature, nil)
+	if err != nil {
+		return errors.Errorf("invalid Node directory signature: %+v", err)
+	}
+	return nil
+}
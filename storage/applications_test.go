@@ -0,0 +1,162 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"testing"
+	"time"
+)
+
+// Tests that ApplicationStatus is encoded as its name in JSON.
+func TestApplicationStatus_JSON(t *testing.T) {
+	for _, status := range []ApplicationStatus{
+		ApplicationApproved, ApplicationPending, ApplicationRejected} {
+		data, err := json.Marshal(status)
+		if err != nil {
+			t.Fatalf("Failed to marshal %s: %+v", status, err)
+		}
+
+		var decoded ApplicationStatus
+		if err = json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %+v", data, err)
+		}
+		if decoded != status {
+			t.Errorf("Unexpected status.\nexpected: %s\nreceived: %s",
+				status, decoded)
+		}
+	}
+
+	var decoded ApplicationStatus
+	if err := json.Unmarshal([]byte(`"accepted"`), &decoded); err == nil {
+		t.Errorf("Expected error for unknown status.")
+	}
+}
+
+// Tests that pending Applications are given increasing IDs and only get a
+// Node once approved.
+func TestDatabaseImpl_ApproveApplication(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_ApproveApplication", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	err = d.InsertApplication(&Application{Id: 5}, &Node{Code: "EXISTING"})
+	if err != nil {
+		t.Fatalf("Failed to insert application: %+v", err)
+	}
+
+	first := &Application{Name: "first", Submitted: time.Now()}
+	second := &Application{Name: "second", Submitted: time.Now()}
+	for _, app := range []*Application{first, second} {
+		if err = d.InsertPendingApplication(app); err != nil {
+			t.Fatalf("Failed to insert pending application: %+v", err)
+		}
+	}
+	if first.Id != 6 || second.Id != 7 {
+		t.Errorf("Unexpected IDs %d and %d, expected 6 and 7", first.Id,
+			second.Id)
+	}
+
+	first.Url = "https://example.com"
+	if err = d.ApproveApplication(first, &Node{Code: "APPROVED"}); err != nil {
+		t.Fatalf("Failed to approve application: %+v", err)
+	}
+	second.Status = ApplicationRejected
+	if err = d.UpdateApplication(second); err != nil {
+		t.Fatalf("Failed to update application: %+v", err)
+	}
+
+	applications, err := d.GetApplications()
+	if err != nil {
+		t.Fatalf("Failed to get applications: %+v", err)
+	}
+	if len(applications) != 3 {
+		t.Fatalf("Unexpected number of applications: %d", len(applications))
+	}
+
+	expected := []struct {
+		status ApplicationStatus
+		code   string
+	}{
+		{ApplicationApproved, "EXISTING"},
+		{ApplicationApproved, "APPROVED"},
+		{ApplicationRejected, ""},
+	}
+	for i, app := range applications {
+		if app.Status != expected[i].status || app.Node.Code != expected[i].code {
+			t.Errorf("Unexpected application %d.\nexpected: %s %q"+
+				"\nreceived: %s %q", app.Id, expected[i].status,
+				expected[i].code, app.Status, app.Node.Code)
+		}
+	}
+	if applications[1].Url != first.Url {
+		t.Errorf("Application not updated on approval: %+v", applications[1])
+	}
+
+	node, err := d.GetNode("APPROVED")
+	if err != nil || node.ApplicationId != first.Id {
+		t.Errorf("Approved node not stored: %+v %+v", node, err)
+	}
+}
+
+// Tests that the Node directory only lists approved Applications and that its
+// signature covers the Nodes and timestamp.
+func TestNodeDirectory_Sign(t *testing.T) {
+	nid := id.NewIdFromString("node", id.Node, t)
+	applications := []*Application{
+		{Name: "approved", Email: "private@example.com",
+			Node: Node{Id: nid.Marshal()}},
+		{Name: "pending", Status: ApplicationPending},
+		{Name: "rejected", Status: ApplicationRejected},
+	}
+
+	directory := NewNodeDirectory(applications, time.Now().UnixNano())
+	if len(directory.Nodes) != 1 || directory.Nodes[0].Name != "approved" {
+		t.Fatalf("Unexpected directory nodes: %+v", directory.Nodes)
+	}
+	data, err := json.Marshal(directory)
+	if err != nil {
+		t.Fatalf("Failed to marshal directory: %+v", err)
+	}
+	var decoded map[string]interface{}
+	_ = json.Unmarshal(data, &decoded)
+	if node := decoded["Nodes"].([]interface{})[0].(map[string]interface{}); node["Email"] != nil {
+		t.Errorf("Directory contains private email: %s", data)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %+v", err)
+	}
+	if err = directory.Sign(key); err != nil {
+		t.Fatalf("Failed to sign directory: %+v", err)
+	}
+	if err = directory.Verify(key.GetPublic()); err != nil {
+		t.Errorf("Failed to verify directory: %+v", err)
+	}
+
+	directory.Timestamp++
+	if err = directory.Verify(key.GetPublic()); err == nil {
+		t.Errorf("Verified directory with a changed timestamp.")
+	}
+	directory.Timestamp--
+	directory.Nodes[0].Url = "https://evil.example.com"
+	if err = directory.Verify(key.GetPublic()); err == nil {
+		t.Errorf("Verified directory with a changed node.")
+	}
+}
@@ -93,7 +93,8 @@ func NewDatabase(username, password, database, address,
 	// WARNING: Order is important. Do not change without Database testing
 	models := []interface{}{
 		&State{}, &Application{}, &Node{}, roundMetricTable, &Topology{}, &NodeMetric{},
-		&RoundError{}, EphemeralLength{}, ActiveNode{}, GeoBin{},
+		&RoundError{}, &RoundBlame{}, &RoundClientError{}, EphemeralLength{}, ActiveNode{},
+		GeoBin{}, LatencyLink{}, WhitelistEntry{}, NodeReputation{},
 	}
 
 	for _, model := range models {
@@ -64,6 +64,18 @@ func generateDisabledNodes(path string, interval time.Duration,
 	return dnl, nil
 }
 
+// LoadDisabledNodes reads and parses the disabled Node list at the path without
+// tracking it. It is used to check the file before it is polled.
+func LoadDisabledNodes(path string) ([]*id.ID, error) {
+	fileBytes, err := utils.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("Error while accessing disabled Node list "+
+			"file: %v", err)
+	}
+
+	return getDisabledNodes(string(fileBytes))
+}
+
 // pollDisabledNodes initialises a disabled Node list from the specified file
 // and starts a thread that updates the list from the file at the specified
 // interval. The provided channel allows for external killing of the routine.
@@ -387,3 +387,37 @@ func generateIdLists(num int, x interface{}) (string, *node.StateMap, []*id.ID)
 
 	return fileData, stateMap, nodeList
 }
+
+// Tests that LoadDisabledNodes() returns the IDs in the file.
+func TestLoadDisabledNodes(t *testing.T) {
+	testData, _, expectedIDs := generateIdLists(3, t)
+	testPath := "testLoadDisabledNodesList.txt"
+	defer func() {
+		if err := os.RemoveAll(testPath); err != nil {
+			t.Fatalf("Error deleting test file %#v:\n%v", testPath, err)
+		}
+	}()
+
+	err := utils.WriteFile(testPath, []byte(testData), utils.FilePerms, utils.DirPerms)
+	if err != nil {
+		t.Fatalf("Error while creating test file: %v", err)
+	}
+
+	ids, err := LoadDisabledNodes(testPath)
+	if err != nil {
+		t.Fatalf("LoadDisabledNodes() produced an unexpected error: %+v", err)
+	}
+
+	if !reflect.DeepEqual(ids, expectedIDs) {
+		t.Errorf("LoadDisabledNodes() returned incorrect IDs."+
+			"\n\texpected: %v\n\treceived: %v", expectedIDs, ids)
+	}
+}
+
+// Tests that LoadDisabledNodes() errors when the file does not exist.
+func TestLoadDisabledNodes_FileError(t *testing.T) {
+	_, err := LoadDisabledNodes("does/not/exist.txt")
+	if err == nil {
+		t.Error("LoadDisabledNodes() did not error on a missing file.")
+	}
+}
@@ -0,0 +1,217 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+// Contains the implementations of EligibleNodeSource: the ActiveNode table, a
+// signed file, and an HTTP endpoint serving JSON.
+
+package storage
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Largest eligible Node list accepted from an HTTP endpoint
+const maxEligibleNodeListSize = 1 << 22
+
+// EligibleNodeList is the JSON format of the eligible Node file and HTTP
+// endpoint. The signature is only checked for the file.
+type EligibleNodeList struct {
+	Nodes     [][]byte
+	Timestamp int64
+	Signature []byte `json:",omitempty"`
+}
+
+// digest returns the hash of the Nodes and timestamp that is signed.
+func (l *EligibleNodeList) digest() []byte {
+	h := crypto.SHA256.New()
+	for _, nid := range l.Nodes {
+		h.Write(nid)
+	}
+	tsBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(tsBytes, uint64(l.Timestamp))
+	h.Write(tsBytes)
+	return h.Sum(nil)
+}
+
+// Sign signs the list with the key.
+func (l *EligibleNodeList) Sign(key *rsa.PrivateKey) error {
+	var err error
+	l.Signature, err = rsa.Sign(rand.Reader, key, crypto.SHA256, l.digest(), nil)
+	if err != nil {
+		return errors.Errorf("Could not sign eligible Node list: %+v", err)
+	}
+	return nil
+}
+
+// Verify checks that the list was signed by the key.
+func (l *EligibleNodeList) Verify(key *rsa.PublicKey) error {
+	err := rsa.Verify(key, crypto.SHA256, l.digest(), l.Signature, nil)
+	if err != nil {
+		return errors.Errorf("invalid eligible Node list signature: %+v", err)
+	}
+	return nil
+}
+
+// getIDs unmarshals the Node IDs in the list.
+func (l *EligibleNodeList) getIDs() (map[id.ID]bool, error) {
+	nodeIDs := make(map[id.ID]bool, len(l.Nodes))
+	for i, idBytes := range l.Nodes {
+		nid, err := id.Unmarshal(idBytes)
+		if err != nil {
+			return nil, errors.Errorf("failed to unmarshal eligible Node "+
+				"ID #%d: %+v", i, err)
+		}
+		nodeIDs[*nid] = true
+	}
+	return nodeIDs, nil
+}
+
+// databaseEligibleNodes loads the eligible Nodes from the ActiveNode table.
+type databaseEligibleNodes struct {
+	db Storage
+}
+
+// NewDatabaseEligibleNodes returns a source loading the eligible Nodes from
+// the ActiveNode table of the database.
+func NewDatabaseEligibleNodes(db Storage) EligibleNodeSource {
+	return &databaseEligibleNodes{db: db}
+}
+
+// GetEligibleNodes returns the IDs of every Node in the ActiveNode table.
+func (d *databaseEligibleNodes) GetEligibleNodes() (map[id.ID]bool, error) {
+	nodes, err := d.db.GetActiveNodes()
+	if err != nil {
+		return nil, errors.Errorf("failed to get active node list from "+
+			"database: %+v", err)
+	}
+
+	nodeIDs := make(map[id.ID]bool, len(nodes))
+	for i, n := range nodes {
+		nid, err := id.Unmarshal(n.Id)
+		if err != nil {
+			return nil, errors.Errorf("failed to unmarshal active node "+
+				"ID #%d: %+v", i, err)
+		}
+		nodeIDs[*nid] = true
+	}
+
+	return nodeIDs, nil
+}
+
+func (d *databaseEligibleNodes) String() string {
+	return "ActiveNode table"
+}
+
+// fileEligibleNodes loads the eligible Nodes from a signed JSON file. Lists
+// older than the last accepted one are rejected so that an old file cannot be
+// restored to roll back the set.
+type fileEligibleNodes struct {
+	path          string
+	key           *rsa.PublicKey
+	lastTimestamp int64
+	mux           sync.Mutex
+}
+
+// NewFileEligibleNodes returns a source loading the eligible Nodes from an
+// EligibleNodeList file at the path, which must be signed by the key.
+func NewFileEligibleNodes(path string, key *rsa.PublicKey) EligibleNodeSource {
+	return &fileEligibleNodes{path: path, key: key}
+}
+
+// GetEligibleNodes reads and verifies the file and returns the IDs in it.
+func (f *fileEligibleNodes) GetEligibleNodes() (map[id.ID]bool, error) {
+	data, err := utils.ReadFile(f.path)
+	if err != nil {
+		return nil, errors.Errorf("could not read eligible Node file: %+v",
+			err)
+	}
+
+	list := &EligibleNodeList{}
+	if err = json.Unmarshal(data, list); err != nil {
+		return nil, errors.Errorf("could not parse eligible Node file: %+v",
+			err)
+	}
+	if err = list.Verify(f.key); err != nil {
+		return nil, err
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if list.Timestamp < f.lastTimestamp {
+		return nil, errors.Errorf("eligible Node file timestamp %s is older "+
+			"than the last accepted list from %s",
+			time.Unix(0, list.Timestamp), time.Unix(0, f.lastTimestamp))
+	}
+
+	nodeIDs, err := list.getIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	f.lastTimestamp = list.Timestamp
+	return nodeIDs, nil
+}
+
+func (f *fileEligibleNodes) String() string {
+	return "file " + f.path
+}
+
+// httpEligibleNodes loads the eligible Nodes from an HTTP endpoint serving an
+// EligibleNodeList as JSON. It stands in for a validator set read from chain.
+type httpEligibleNodes struct {
+	url    string
+	client *http.Client
+}
+
+// NewHttpEligibleNodes returns a source loading the eligible Nodes from the
+// URL. Requests time out after the timeout.
+func NewHttpEligibleNodes(url string, timeout time.Duration) EligibleNodeSource {
+	return &httpEligibleNodes{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// GetEligibleNodes requests the list from the endpoint and returns the IDs in
+// it.
+func (h *httpEligibleNodes) GetEligibleNodes() (map[id.ID]bool, error) {
+	resp, err := h.client.Get(h.url)
+	if err != nil {
+		return nil, errors.Errorf("could not request eligible Nodes: %+v",
+			err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("eligible Node endpoint returned %s",
+			resp.Status)
+	}
+
+	list := &EligibleNodeList{}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxEligibleNodeListSize)).
+		Decode(list)
+	if err != nil {
+		return nil, errors.Errorf("could not parse eligible Nodes: %+v", err)
+	}
+
+	return list.getIDs()
+}
+
+func (h *httpEligibleNodes) String() string {
+	return "endpoint " + h.url
+}
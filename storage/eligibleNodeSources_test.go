@@ -0,0 +1,129 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"gitlab.com/xx_network/crypto/signature/rsa"
+	"gitlab.com/xx_network/primitives/id"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Tests that the database source returns the Nodes in the ActiveNode table.
+func TestDatabaseEligibleNodes_GetEligibleNodes(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	nid := id.NewIdFromUInt(3, id.Node, t)
+	err = PermissioningDb.GetDatabaseImpl(t).db.Create(
+		&ActiveNode{WalletAddress: "wallet", Id: nid.Marshal()}).Error
+	if err != nil {
+		t.Fatalf("Failed to insert active node: %+v", err)
+	}
+
+	nodes, err := NewDatabaseEligibleNodes(PermissioningDb).GetEligibleNodes()
+	if err != nil {
+		t.Fatalf("Failed to get eligible nodes: %+v", err)
+	}
+	if !reflect.DeepEqual(map[id.ID]bool{*nid: true}, nodes) {
+		t.Errorf("Unexpected eligible nodes: %v", nodes)
+	}
+}
+
+// writeEligibleNodeFile signs a list of the Nodes with the timestamp and
+// writes it to the path.
+func writeEligibleNodeFile(t *testing.T, path string, key *rsa.PrivateKey,
+	timestamp int64, nodeIDs ...*id.ID) {
+	list := &EligibleNodeList{Timestamp: timestamp}
+	for _, nid := range nodeIDs {
+		list.Nodes = append(list.Nodes, nid.Marshal())
+	}
+	if err := list.Sign(key); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("Failed to marshal list: %+v", err)
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write list: %+v", err)
+	}
+}
+
+// Tests that the file source accepts a signed list and rejects older lists and
+// lists signed by another key.
+func TestFileEligibleNodes_GetEligibleNodes(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %+v", err)
+	}
+	path := filepath.Join(t.TempDir(), "eligible.json")
+	nid := id.NewIdFromUInt(3, id.Node, t)
+	source := NewFileEligibleNodes(path, key.GetPublic())
+
+	writeEligibleNodeFile(t, path, key, 10, nid)
+	nodes, err := source.GetEligibleNodes()
+	if err != nil {
+		t.Fatalf("Failed to get eligible nodes: %+v", err)
+	}
+	if !reflect.DeepEqual(map[id.ID]bool{*nid: true}, nodes) {
+		t.Errorf("Unexpected eligible nodes: %v", nodes)
+	}
+
+	writeEligibleNodeFile(t, path, key, 5, nid)
+	if _, err = source.GetEligibleNodes(); err == nil {
+		t.Errorf("Older list was accepted.")
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %+v", err)
+	}
+	writeEligibleNodeFile(t, path, otherKey, 20, nid)
+	if _, err = source.GetEligibleNodes(); err == nil {
+		t.Errorf("List signed by another key was accepted.")
+	}
+}
+
+// Tests that the HTTP source returns the Nodes served by the endpoint and
+// errors on a failed request.
+func TestHttpEligibleNodes_GetEligibleNodes(t *testing.T) {
+	nid := id.NewIdFromUInt(3, id.Node, t)
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(
+				EligibleNodeList{Nodes: [][]byte{nid.Marshal()}})
+		}))
+	defer srv.Close()
+
+	source := NewHttpEligibleNodes(srv.URL, 0)
+	nodes, err := source.GetEligibleNodes()
+	if err != nil {
+		t.Fatalf("Failed to get eligible nodes: %+v", err)
+	}
+	if !reflect.DeepEqual(map[id.ID]bool{*nid: true}, nodes) {
+		t.Errorf("Unexpected eligible nodes: %v", nodes)
+	}
+
+	status = http.StatusInternalServerError
+	if _, err = source.GetEligibleNodes(); err == nil {
+		t.Errorf("Expected error for failed request.")
+	}
+}
@@ -0,0 +1,134 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+	"gitlab.com/xx_network/primitives/id"
+	"time"
+)
+
+// eligibleNodes.go contains the NetworkState's eligible Node set, which limits
+// the Nodes that can be scheduled into rounds. The set is loaded from an
+// EligibleNodeSource and the last loaded set is kept when the source fails.
+
+// EligibleNodeSource provides the set of Nodes that may be scheduled.
+type EligibleNodeSource interface {
+	// GetEligibleNodes returns the IDs of every eligible Node.
+	GetEligibleNodes() (map[id.ID]bool, error)
+
+	// String returns a description of the source for logging.
+	String() string
+}
+
+// IsEligible returns true if the Node is in the eligible Node set. Every Node
+// is eligible if no set has been loaded.
+func (s *NetworkState) IsEligible(nid *id.ID) bool {
+	s.eligibleNodesMux.RLock()
+	defer s.eligibleNodesMux.RUnlock()
+	return s.eligibleNodes == nil || s.eligibleNodes[*nid]
+}
+
+// RefreshEligibility marks the Node as ineligible if the loaded eligible Node
+// set excludes it, and returns whether it is eligible. It is called as the
+// Node is placed into the waiting pool, so that a Node which registered since
+// the set was last applied is not scheduled until the next reload.
+func (s *NetworkState) RefreshEligibility(n *node.State) bool {
+	if !s.IsEligible(n.GetID()) {
+		if _, changed := n.SetEligible(false); changed {
+			jww.INFO.Printf("Node %s eligibility set to false", n.GetID())
+		}
+	}
+	return n.IsEligible()
+}
+
+// UpdateEligibleNodes loads the eligible Node set from the source and applies
+// it to every Node. If the source fails or returns an empty set, the last
+// loaded set is applied instead so that Nodes added since then are still
+// covered, and the error is returned. Returns the number of Nodes whose
+// eligibility changed.
+func (s *NetworkState) UpdateEligibleNodes(source EligibleNodeSource) (int, error) {
+	eligible, err := source.GetEligibleNodes()
+	if err == nil && len(eligible) == 0 {
+		err = errors.New("source returned no eligible Nodes")
+	}
+
+	s.eligibleNodesMux.Lock()
+	if err == nil {
+		s.eligibleNodes = eligible
+	} else {
+		err = errors.WithMessagef(err, "could not load eligible Nodes from "+
+			"%s, keeping the last loaded set", source)
+	}
+	eligible = s.eligibleNodes
+	s.eligibleNodesMux.Unlock()
+
+	if eligible == nil {
+		return 0, err
+	}
+
+	return s.applyEligibleNodes(eligible), err
+}
+
+// applyEligibleNodes sets the eligibility of every Node from the set and
+// notifies the scheduler of each change so that the Node is removed from or
+// returned to the waiting pool. Returns the number of Nodes changed.
+func (s *NetworkState) applyEligibleNodes(eligible map[id.ID]bool) int {
+	changed := 0
+	for _, n := range s.nodes.GetNodeStates() {
+		isEligible := eligible[*n.GetID()]
+		if n.IsEligible() == isEligible {
+			continue
+		}
+
+		// Take the polling lock so the change is not interleaved with a poll.
+		// It is released by the scheduler once the update is handled.
+		n.GetPollingLock().Lock()
+		nun, updated := n.SetEligible(isEligible)
+		if !updated {
+			n.GetPollingLock().Unlock()
+			continue
+		}
+
+		s.SendUpdateNotification(nun)
+		jww.INFO.Printf("Node %s eligibility set to %t", n.GetID(), isEligible)
+		changed++
+	}
+
+	return changed
+}
+
+// PollEligibleNodes reloads the eligible Node set from the source at the
+// specified interval. onChange is called after any Node's eligibility changes.
+// The provided channel allows for external killing of the routine.
+func (s *NetworkState) PollEligibleNodes(source EligibleNodeSource,
+	interval time.Duration, onChange func(), quitChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jww.DEBUG.Printf("Starting eligible Node updater thread polling %s "+
+		"every %s", source, interval)
+
+	for {
+		select {
+		case <-quitChan:
+			jww.DEBUG.Printf("Killing eligible Node polling routine.")
+			return
+		case <-ticker.C:
+			changed, err := s.UpdateEligibleNodes(source)
+			if err != nil {
+				jww.WARN.Printf("Error while updating eligible Nodes: %+v", err)
+			}
+			if changed > 0 {
+				jww.INFO.Printf("Eligibility of %d Node(s) changed", changed)
+				onChange()
+			}
+		}
+	}
+}
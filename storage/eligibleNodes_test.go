@@ -0,0 +1,188 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	"gitlab.com/xx_network/primitives/id"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// mockEligibleNodes is an EligibleNodeSource returning a fixed set or error.
+type mockEligibleNodes struct {
+	nodes map[id.ID]bool
+	err   error
+}
+
+func (m *mockEligibleNodes) GetEligibleNodes() (map[id.ID]bool, error) {
+	return m.nodes, m.err
+}
+
+func (m *mockEligibleNodes) String() string {
+	return "mock"
+}
+
+// newEligibleTestState creates a NetworkState with the number of Nodes.
+func newEligibleTestState(t *testing.T, numNodes int) (*NetworkState, []*id.ID) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	nodeIDs := make([]*id.ID, numNodes)
+	for i := range nodeIDs {
+		nodeIDs[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		err = state.GetNodeMap().AddNode(nodeIDs[i], strconv.Itoa(i), "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	return state, nodeIDs
+}
+
+// receiveEligibilityChange checks that the scheduler was notified of the
+// Node's eligibility change and releases its polling lock as the scheduler
+// would.
+func receiveEligibilityChange(t *testing.T, state *NetworkState, nid *id.ID) {
+	select {
+	case nun := <-state.GetNodeUpdateChannel():
+		if !nun.EligibilityChange || !nun.Node.Cmp(nid) {
+			t.Errorf("Unexpected update notification: %+v", nun)
+		}
+		state.GetNodeMap().GetNode(nun.Node).GetPollingLock().Unlock()
+	default:
+		t.Errorf("No update notification sent for node %s.", nid)
+	}
+}
+
+// Tests that every Node is eligible when no set has been loaded.
+func TestNetworkState_IsEligible_NoSet(t *testing.T) {
+	state, nodeIDs := newEligibleTestState(t, 1)
+	if !state.IsEligible(nodeIDs[0]) {
+		t.Errorf("Node not eligible with no set loaded.")
+	}
+}
+
+// Tests that UpdateEligibleNodes marks the Nodes not in the set as ineligible
+// and notifies the scheduler.
+func TestNetworkState_UpdateEligibleNodes(t *testing.T) {
+	state, nodeIDs := newEligibleTestState(t, 2)
+	source := &mockEligibleNodes{nodes: map[id.ID]bool{*nodeIDs[0]: true}}
+
+	changed, err := state.UpdateEligibleNodes(source)
+	if err != nil {
+		t.Fatalf("Failed to update eligible nodes: %+v", err)
+	}
+	if changed != 1 {
+		t.Errorf("Expected 1 changed node, received %d.", changed)
+	}
+	receiveEligibilityChange(t, state, nodeIDs[1])
+
+	if !state.IsEligible(nodeIDs[0]) || state.IsEligible(nodeIDs[1]) {
+		t.Errorf("Unexpected eligibility of nodes in state.")
+	}
+	if state.GetNodeMap().GetNode(nodeIDs[1]).IsEligible() {
+		t.Errorf("Node %s not marked as ineligible.", nodeIDs[1])
+	}
+
+	// Applying the same set again changes nothing
+	if changed, _ = state.UpdateEligibleNodes(source); changed != 0 {
+		t.Errorf("Expected no changed nodes, received %d.", changed)
+	}
+}
+
+// Tests that RefreshEligibility marks a Node added since the set was applied
+// as ineligible if the set excludes it.
+func TestNetworkState_RefreshEligibility(t *testing.T) {
+	state, nodeIDs := newEligibleTestState(t, 1)
+	if !state.RefreshEligibility(state.GetNodeMap().GetNode(nodeIDs[0])) {
+		t.Errorf("Node not eligible with no set loaded.")
+	}
+
+	source := &mockEligibleNodes{nodes: map[id.ID]bool{*nodeIDs[0]: true}}
+	if _, err := state.UpdateEligibleNodes(source); err != nil {
+		t.Fatalf("Failed to update eligible nodes: %+v", err)
+	}
+
+	newID := id.NewIdFromUInt(1, id.Node, t)
+	if err := state.GetNodeMap().AddNode(newID, "1", "", "", 0); err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	n := state.GetNodeMap().GetNode(newID)
+	if !n.IsEligible() {
+		t.Fatalf("New node is not eligible before the set is applied.")
+	}
+	if state.RefreshEligibility(n) || n.IsEligible() {
+		t.Errorf("Node excluded by the set is still eligible.")
+	}
+	if !state.RefreshEligibility(state.GetNodeMap().GetNode(nodeIDs[0])) {
+		t.Errorf("Node in the set is not eligible.")
+	}
+}
+
+// Tests that UpdateEligibleNodes keeps the last loaded set when the source
+// fails or returns an empty set, and still applies it to new Nodes.
+func TestNetworkState_UpdateEligibleNodes_SourceError(t *testing.T) {
+	state, nodeIDs := newEligibleTestState(t, 1)
+	source := &mockEligibleNodes{nodes: map[id.ID]bool{*nodeIDs[0]: true}}
+	if _, err := state.UpdateEligibleNodes(source); err != nil {
+		t.Fatalf("Failed to update eligible nodes: %+v", err)
+	}
+
+	newID := id.NewIdFromUInt(10, id.Node, t)
+	err := state.GetNodeMap().AddNode(newID, "10", "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+
+	source.nodes, source.err = nil, errors.New("source down")
+	changed, err := state.UpdateEligibleNodes(source)
+	if err == nil {
+		t.Errorf("Expected error from failed source.")
+	}
+	if changed != 1 {
+		t.Errorf("Expected 1 changed node, received %d.", changed)
+	}
+	receiveEligibilityChange(t, state, newID)
+
+	source.nodes, source.err = map[id.ID]bool{}, nil
+	if _, err = state.UpdateEligibleNodes(source); err == nil {
+		t.Errorf("Expected error from empty set.")
+	}
+	if !state.IsEligible(nodeIDs[0]) {
+		t.Errorf("Last loaded set not kept.")
+	}
+}
+
+// Tests that PollEligibleNodes calls onChange when eligibility changes.
+func TestNetworkState_PollEligibleNodes(t *testing.T) {
+	state, nodeIDs := newEligibleTestState(t, 2)
+	source := &mockEligibleNodes{nodes: map[id.ID]bool{*nodeIDs[0]: true}}
+
+	changed := make(chan struct{}, 1)
+	quitChan := make(chan struct{})
+	go state.PollEligibleNodes(source, 10*time.Millisecond,
+		func() { changed <- struct{}{} }, quitChan)
+	defer close(quitChan)
+
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatalf("onChange not called.")
+	}
+	receiveEligibilityChange(t, state, nodeIDs[1])
+}
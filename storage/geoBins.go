@@ -0,0 +1,113 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"reflect"
+	"time"
+)
+
+// geoBins.go contains the NetworkState's Country -> GeoBin table, which is the
+// single source of GeoBins for NDF gateways and team ordering.
+
+// GetGeoBins returns the GeoBin map. The returned map is replaced, never
+// modified, when the table is reloaded, so it must not be modified by callers.
+func (s *NetworkState) GetGeoBins() map[string]region.GeoBin {
+	s.geoBinsMux.RLock()
+	defer s.geoBinsMux.RUnlock()
+	return s.geoBins
+}
+
+// GetGeoBin returns the GeoBin of the country code and true if it exists.
+func (s *NetworkState) GetGeoBin(countryCode string) (region.GeoBin, bool) {
+	s.geoBinsMux.RLock()
+	defer s.geoBinsMux.RUnlock()
+	bin, exists := s.geoBins[countryCode]
+	return bin, exists
+}
+
+// SetGeoBins replaces the GeoBin table. If it differs from the current table,
+// the gateways in the internal NDF are moved to their new bins; the change
+// reaches the output NDF on its next update. Returns true if the table changed.
+func (s *NetworkState) SetGeoBins(geoBins map[string]region.GeoBin) bool {
+	s.geoBinsMux.Lock()
+	if reflect.DeepEqual(s.geoBins, geoBins) {
+		s.geoBinsMux.Unlock()
+		return false
+	}
+	s.geoBins = geoBins
+	s.geoBinsMux.Unlock()
+
+	s.InternalNdfLock.Lock()
+	defer s.InternalNdfLock.Unlock()
+
+	newNdf := s.unprunedNdf.DeepCopy()
+	for i, gw := range newNdf.Gateways {
+		gwID, err := id.Unmarshal(gw.ID)
+		if err != nil {
+			jww.WARN.Printf("Failed to unmarshal gateway ID %v while "+
+				"updating GeoBins: %+v", gw.ID, err)
+			continue
+		}
+		nid := gwID.DeepCopy()
+		nid.SetType(id.Node)
+
+		n := s.nodes.GetNode(nid)
+		if n == nil {
+			continue
+		}
+
+		bin, exists := geoBins[n.GetOrdering()]
+		if !exists {
+			jww.WARN.Printf("Country %q of node %s has no GeoBin, keeping "+
+				"bin %s", n.GetOrdering(), nid, gw.Bin)
+			continue
+		}
+		newNdf.Gateways[i].Bin = bin
+	}
+	s.UpdateInternalNdf(newNdf)
+
+	return true
+}
+
+// PollGeoBins reloads the GeoBin table using the passed in function at the
+// specified interval. The provided channel allows for external killing of the
+// routine.
+func (s *NetworkState) PollGeoBins(load func() (map[string]region.GeoBin, error),
+	interval time.Duration, quitChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jww.DEBUG.Printf("Starting GeoBin updater thread polling every %s",
+		interval)
+
+	for {
+		select {
+		case <-quitChan:
+			jww.DEBUG.Printf("Killing GeoBin polling routine.")
+			return
+		case <-ticker.C:
+			geoBins, err := load()
+			if err != nil {
+				jww.WARN.Printf("Error while loading GeoBins: %+v", err)
+				continue
+			}
+			if len(geoBins) == 0 {
+				jww.WARN.Printf("Loaded an empty GeoBin table, keeping the " +
+					"current table")
+				continue
+			}
+
+			if s.SetGeoBins(geoBins) {
+				jww.INFO.Printf("Loaded %d updated GeoBins", len(geoBins))
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/ndf"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// Tests that GetGeoBin returns the bin from the state's table.
+func TestNetworkState_GetGeoBin(t *testing.T) {
+	state := &NetworkState{
+		geoBins: map[string]region.GeoBin{"US": region.EasternAsia},
+	}
+
+	bin, exists := state.GetGeoBin("US")
+	if !exists || bin != region.EasternAsia {
+		t.Errorf("GetGeoBin returned the wrong bin.\nexpected: %s (%t)"+
+			"\nreceived: %s (%t)", region.EasternAsia, true, bin, exists)
+	}
+
+	if _, exists = state.GetGeoBin("CA"); exists {
+		t.Errorf("GetGeoBin found a bin for a country not in the table")
+	}
+}
+
+// Tests that SetGeoBins replaces the table and moves the gateways in the
+// internal NDF to their new bins.
+func TestNetworkState_SetGeoBins(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	nid := id.NewIdFromUInt(5, id.Node, t)
+	err = state.GetNodeMap().AddNode(nid, "US", "", "", 0)
+	if err != nil {
+		t.Fatalf("Failed to add node: %+v", err)
+	}
+	gwID := nid.DeepCopy()
+	gwID.SetType(id.Gateway)
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes:    []ndf.Node{{ID: nid.Bytes()}},
+		Gateways: []ndf.Gateway{{ID: gwID.Bytes(), Bin: region.NorthAmerica}},
+	})
+
+	newBins := region.GetCountryBins()
+	newBins["US"] = region.EasternAsia
+	if !state.SetGeoBins(newBins) {
+		t.Errorf("SetGeoBins did not report a changed table")
+	}
+
+	if bin, _ := state.GetGeoBin("US"); bin != region.EasternAsia {
+		t.Errorf("Table not updated.\nexpected: %s\nreceived: %s",
+			region.EasternAsia, bin)
+	}
+
+	if bin := state.GetUnprunedNdf().Gateways[0].Bin; bin != region.EasternAsia {
+		t.Errorf("Gateway not moved to new bin.\nexpected: %s\nreceived: %s",
+			region.EasternAsia, bin)
+	}
+
+	if state.SetGeoBins(newBins) {
+		t.Errorf("SetGeoBins reported a change for an identical table")
+	}
+}
+
+// Tests that PollGeoBins applies loaded tables, ignores empty ones, and stops
+// on the quit channel.
+func TestNetworkState_PollGeoBins(t *testing.T) {
+	state := &NetworkState{
+		geoBins:     map[string]region.GeoBin{"US": region.NorthAmerica},
+		unprunedNdf: &ndf.NetworkDefinition{},
+	}
+
+	loaded := make(chan map[string]region.GeoBin, 2)
+	loaded <- map[string]region.GeoBin{}
+	loaded <- map[string]region.GeoBin{"US": region.EasternAsia}
+	load := func() (map[string]region.GeoBin, error) {
+		select {
+		case geoBins := <-loaded:
+			return geoBins, nil
+		default:
+			return map[string]region.GeoBin{"US": region.EasternAsia}, nil
+		}
+	}
+
+	quit := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		state.PollGeoBins(load, 10*time.Millisecond, quit)
+		close(done)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	quit <- struct{}{}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("PollGeoBins did not stop")
+	}
+
+	if bin, _ := state.GetGeoBin("US"); bin != region.EasternAsia {
+		t.Errorf("Loaded table not applied.\nexpected: %s\nreceived: %s",
+			region.EasternAsia, bin)
+	}
+}
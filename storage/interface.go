@@ -25,11 +25,28 @@ type database interface {
 	InsertNodeMetric(metric *NodeMetric) error
 	InsertRoundMetric(metric *RoundMetric, topology [][]byte) error
 	InsertRoundError(roundId id.Round, errStr string) error
+	InsertRoundBlames(roundId id.Round, blames []*RoundBlame) error
+	GetRoundBlames(roundId id.Round) ([]*RoundBlame, error)
+	GetNodeBlames(nodeId *id.ID, since time.Time) ([]*RoundBlame, error)
+	InsertClientErrors(roundId id.Round, clientErrors []*RoundClientError) error
+	GetRoundClientErrors(network string, roundId id.Round) ([]*RoundClientError, error)
+	GetClientErrors(network string, clientId []byte, since time.Time) ([]*RoundClientError, error)
+	DeleteClientErrors(network string, before time.Time) (int64, error)
 	GetLatestEphemeralLength() (*EphemeralLength, error)
 	GetEphemeralLengths() ([]*EphemeralLength, error)
 	InsertEphemeralLength(length *EphemeralLength) error
-	GetEarliestRound(cutoff time.Duration) (id.Round, time.Time, error)
+	GetEarliestRound(network string, cutoff time.Duration) (id.Round, time.Time, error)
+	GetRoundMetricsSince(network string, since time.Time, limit int) ([]*RoundMetric, error)
+	CountRoundMetricsSince(network string, since time.Time) (int, error)
+	GetCompletedRoundMetrics(network string, limit int) ([]*RoundMetric, error)
 	getBins() ([]*GeoBin, error)
+	UpsertLatencyLinks(links []*LatencyLink) error
+	GetLatencyLinks(network string) ([]*LatencyLink, error)
+	UpsertWhitelistEntry(entry *WhitelistEntry) error
+	DeleteWhitelistEntry(network, value string) error
+	GetWhitelistEntries(network string) ([]*WhitelistEntry, error)
+	UpsertNodeReputations(reputations []*NodeReputation) error
+	GetNodeReputations(network string) ([]*NodeReputation, error)
 
 	// Node methods
 	InsertApplication(application *Application, unregisteredNode *Node) error
@@ -44,6 +61,12 @@ type database interface {
 	GetNodeById(id *id.ID) (*Node, error)
 	GetNodesByStatus(status node.Status) ([]*Node, error)
 	GetActiveNodes() ([]*ActiveNode, error)
+	GetApplication(id uint64) (*Application, error)
+	GetApplicationTeams() (map[uint64]string, error)
+	GetApplications() ([]*Application, error)
+	InsertPendingApplication(application *Application) error
+	UpdateApplication(application *Application) error
+	ApproveApplication(application *Application, unregisteredNode *Node) error
 }
 
 // Struct implementing the Database Interface with an underlying Map
@@ -91,8 +114,15 @@ const (
 type Application struct {
 	// The Application's unique ID
 	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:false"`
-	// Each Application has one Node
-	Node Node `gorm:"foreignkey:ApplicationId"`
+	// Each Application has one Node, created once the Application is approved
+	Node Node `gorm:"foreignkey:ApplicationId" json:"-"`
+
+	// Review status of the Application
+	Status ApplicationStatus `gorm:"NOT NULL;default:0"`
+	// Reason given when the Application was reviewed
+	ReviewNote string
+	// Date/time that the Application was submitted
+	Submitted time.Time
 
 	// Node information
 	Name  string
@@ -132,6 +162,63 @@ type GeoBin struct {
 	Bin     uint8  `gorm:"NOT NULL"`
 }
 
+// Struct representing the LatencyLink table in the Database
+type LatencyLink struct {
+	// Composite primary key of the GeoBins the link goes from and to and the
+	// network it was measured on; empty for the primary network
+	FromBin uint8  `gorm:"primary_key;AUTO_INCREMENT:false"`
+	ToBin   uint8  `gorm:"primary_key;AUTO_INCREMENT:false"`
+	Network string `gorm:"primary_key;default:''"`
+
+	// Moving average of the one-way latency of the link
+	Latency time.Duration `gorm:"NOT NULL"`
+	// Number of samples in the average, capped at the averaging window
+	Samples uint64 `gorm:"NOT NULL"`
+	// Timestamp of the newest sample
+	LastUpdated time.Time `gorm:"NOT NULL"`
+}
+
+// Struct representing the WhitelistEntry table in the Database
+type WhitelistEntry struct {
+	// Base64 encoded ID, IP address, or CIDR block exempt from rate limiting
+	Value string `gorm:"primary_key"`
+	// Network the entry applies to; empty for the primary network
+	Network string `gorm:"primary_key;default:''"`
+	// Whether the value is an ID or an IP address
+	Type WhitelistType `gorm:"NOT NULL"`
+	// Time after which the entry no longer applies; zero if it never expires
+	Expiry time.Time
+	// Description of who the entry was granted to
+	Note string
+	// Time the entry was added or last changed
+	LastUpdated time.Time `gorm:"NOT NULL"`
+}
+
+// Struct representing the NodeReputation table in the Database
+type NodeReputation struct {
+	// ID of the Node the reputation is of
+	NodeId []byte `gorm:"primary_key"`
+	// Network the Node is scheduled on; empty for the primary network
+	Network string `gorm:"NOT NULL;INDEX;default:''"`
+
+	// Decayed counts of the Node's round outcomes
+	Completed float64 `gorm:"NOT NULL"`
+	Failed    float64 `gorm:"NOT NULL"`
+	// Decayed count of rounds which timed out waiting on the Node
+	Timeouts float64 `gorm:"NOT NULL"`
+	// Decayed count of times the Node stopped polling while waiting
+	Disconnects float64 `gorm:"NOT NULL"`
+
+	// Time the Node was first tracked, from which its uptime is measured
+	FirstSeen time.Time `gorm:"NOT NULL"`
+	// Total time the Node has spent offline
+	Downtime time.Duration `gorm:"NOT NULL"`
+	// Time the Node went offline; zero while it is online
+	OfflineSince time.Time
+	// Time of the newest change to the reputation
+	LastUpdated time.Time `gorm:"NOT NULL"`
+}
+
 // Struct representing the Node table in the Database
 type Node struct {
 	// Registration code acts as the primary key
@@ -206,11 +293,17 @@ type RoundMetric struct {
 	RoundEnd      time.Time `gorm:"NOT NULL;INDEX;default:to_timestamp(0)"` // Index for TPS calc
 	BatchSize     uint32    `gorm:"NOT NULL"`
 
+	// Network the round ran on; empty for the primary network
+	Network string `gorm:"NOT NULL;INDEX;default:''"`
+
 	// Each RoundMetric has many Nodes participating in each Round
 	Topologies []Topology `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
 
 	// Each RoundMetric can have many Errors in each Round
 	RoundErrors []RoundError `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
+
+	// Each failed RoundMetric can be blamed on many Nodes
+	RoundBlames []RoundBlame `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
 }
 
 // Struct representing Round Errors table in the Database
@@ -225,6 +318,52 @@ type RoundError struct {
 	Error string `gorm:"NOT NULL"`
 }
 
+// Struct representing Round Blames table in the Database
+type RoundBlame struct {
+	// Auto-incrementing primary key (Do not set)
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
+
+	// ID of the failed round for a given run of the network
+	RoundMetricId uint64 `gorm:"INDEX;NOT NULL;type:bigint REFERENCES round_metrics(Id)"`
+
+	// ID of the Node the failure is attributed to
+	NodeId []byte `gorm:"INDEX;NOT NULL"`
+	// Why the Node is blamed
+	Reason string `gorm:"NOT NULL"`
+	// Last activity the Node reported before the round failed
+	Activity string `gorm:"NOT NULL"`
+	// Times the Node last changed activity and last polled
+	LastUpdate time.Time
+	LastPoll   time.Time
+	// Time the round failed
+	Timestamp time.Time `gorm:"NOT NULL;INDEX"`
+}
+
+// Struct representing Round Client Errors table in the Database
+type RoundClientError struct {
+	// Auto-incrementing primary key (Do not set)
+	Id uint64 `gorm:"primary_key;AUTO_INCREMENT:true"`
+
+	// ID of the round the error was reported in. Not a foreign key because
+	// client errors are reported before the round's metrics are stored
+	RoundId uint64 `gorm:"INDEX;NOT NULL"`
+
+	// Network the round ran on; empty for the primary network
+	Network string `gorm:"NOT NULL;INDEX;default:''"`
+
+	// ID of the gateway which reported the error
+	GatewayId []byte `gorm:"INDEX;NOT NULL"`
+	// ID of the client which encountered the error
+	ClientId []byte `gorm:"INDEX"`
+	// Type of the error, used to aggregate errors with different details
+	ErrorType string `gorm:"INDEX;NOT NULL"`
+	// String of the error reported by the client, truncated to a fixed
+	// length
+	Error string `gorm:"NOT NULL"`
+	// Time the error was received
+	Timestamp time.Time `gorm:"NOT NULL;INDEX"`
+}
+
 // Struct represegnting the validity period of an ephemeral ID length
 type EphemeralLength struct {
 	Length    uint8     `gorm:"primary_key;AUTO_INCREMENT:false"`
@@ -246,11 +385,17 @@ type RoundMetricAlt struct {
 	RoundEnd      time.Time `gorm:"NOT NULL;INDEX;"` // Index for TPS calc
 	BatchSize     uint32    `gorm:"NOT NULL"`
 
+	// Network the round ran on; empty for the primary network
+	Network string `gorm:"NOT NULL;INDEX;default:''"`
+
 	// Each RoundMetric has many Nodes participating in each Round
 	Topologies []Topology `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
 
 	// Each RoundMetric can have many Errors in each Round
 	RoundErrors []RoundError `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
+
+	// Each failed RoundMetric can be blamed on many Nodes
+	RoundBlames []RoundBlame `gorm:"foreignkey:RoundMetricId;association_foreignkey:Id"`
 }
 
 // Interface method which overrides the name of the table when created with gorm
@@ -0,0 +1,310 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"sort"
+	"time"
+)
+
+// latency.go builds the GeoBin latency table used for team ordering from
+// measured round timings.
+
+// numGeoBins is the number of GeoBins in a latency table.
+const numGeoBins = 12
+
+// latencyMetricBatch is the maximum number of round metrics loaded at once when
+// updating the latency table.
+const latencyMetricBatch = 1000
+
+// latencyLookback is how far back a tracker without stored links starts
+// reading round metrics.
+const latencyLookback = 24 * time.Hour
+
+// measuredLatencyWeight is the weight of a measured link against the scaled
+// static table. Measurements are an average over the whole team, so they are
+// blended with the static table to keep the relative cost of each link.
+const measuredLatencyWeight = 0.5
+
+// LatencyTracker keeps a moving average of the one-way latency of every link
+// between two GeoBins.
+type LatencyTracker struct {
+	network string
+	links   map[[2]region.GeoBin]*LatencyLink
+
+	// Realtime end of the newest round metric added
+	lastRoundEnd time.Time
+
+	// Number of samples the moving average is taken over
+	window uint64
+}
+
+// NewLatencyTracker creates a LatencyTracker for the network averaging over the
+// given number of samples and loads the network's links persisted in storage.
+// Without stored links, it starts from the round metrics of the last
+// latencyLookback.
+func NewLatencyTracker(network string, window uint64) (*LatencyTracker, error) {
+	if window == 0 {
+		return nil, errors.New("latency averaging window must be greater than 0")
+	}
+
+	lt := &LatencyTracker{
+		network: network,
+		links:   make(map[[2]region.GeoBin]*LatencyLink),
+		window:  window,
+	}
+
+	links, err := PermissioningDb.GetLatencyLinks(network)
+	if err != nil {
+		return nil, errors.Errorf("Failed to load latency links: %+v", err)
+	}
+	for _, link := range links {
+		lt.links[[2]region.GeoBin{region.GeoBin(link.FromBin),
+			region.GeoBin(link.ToBin)}] = link
+		if link.LastUpdated.After(lt.lastRoundEnd) {
+			lt.lastRoundEnd = link.LastUpdated
+		}
+	}
+	if lt.lastRoundEnd.IsZero() {
+		lt.lastRoundEnd = time.Now().Add(-latencyLookback)
+	}
+
+	return lt, nil
+}
+
+// AddRoundMetric adds a latency sample to every link in the round's topology.
+// The realtime phase is dominated by the transfer of the batch around the
+// team, so its duration is split evenly across the links, including the link
+// from the last node back to the first. Round metrics have no per-node
+// timestamps, so every link of a round receives the same team-level average;
+// Table blends it with the static table rather than using it alone. Failed
+// rounds and rounds without a complete realtime are skipped. Returns the links
+// that were updated.
+func (lt *LatencyTracker) AddRoundMetric(metric *RoundMetric,
+	bins []region.GeoBin) []*LatencyLink {
+	if metric.RealtimeEnd.After(lt.lastRoundEnd) {
+		lt.lastRoundEnd = metric.RealtimeEnd
+	}
+
+	realtime := metric.RealtimeEnd.Sub(metric.RealtimeStart)
+	if len(metric.RoundErrors) > 0 || len(bins) == 0 || metric.RealtimeStart.IsZero() ||
+		realtime <= 0 {
+		return nil
+	}
+
+	perLink := realtime / time.Duration(len(bins))
+	updated := make([]*LatencyLink, 0, len(bins))
+	for i, from := range bins {
+		to := bins[(i+1)%len(bins)]
+		updated = append(updated, lt.addSample(from, to, perLink,
+			metric.RealtimeEnd))
+	}
+
+	return updated
+}
+
+// addSample updates the moving average of the link.
+func (lt *LatencyTracker) addSample(from, to region.GeoBin,
+	latency time.Duration, timestamp time.Time) *LatencyLink {
+	key := [2]region.GeoBin{from, to}
+	link, exists := lt.links[key]
+	if !exists {
+		link = &LatencyLink{FromBin: uint8(from), ToBin: uint8(to),
+			Network: lt.network}
+		lt.links[key] = link
+	}
+
+	if link.Samples < lt.window {
+		link.Samples++
+	}
+	link.Latency += (latency - link.Latency) / time.Duration(link.Samples)
+	if timestamp.After(link.LastUpdated) {
+		link.LastUpdated = timestamp
+	}
+
+	return link
+}
+
+// Table returns the latency table in milliseconds for use in team ordering.
+// Every link uses the static table scaled to the average ratio of measured
+// latency to static weight. Measured links, or links whose reverse link is
+// measured, blend the measurement into the scaled weight by
+// measuredLatencyWeight. With no measurements at all, the static table is
+// returned unchanged.
+func (lt *LatencyTracker) Table() [12][12]int {
+	static := region.CreateSetLatencyTableWeights(region.CreateLinkTable())
+
+	var measured [12][12]float64
+	var has [12][12]bool
+	ratioSum, ratioCount := 0.0, 0
+	for key, link := range lt.links {
+		from, to := int(key[0]), int(key[1])
+		if from >= numGeoBins || to >= numGeoBins || link.Samples == 0 {
+			continue
+		}
+		ms := float64(link.Latency) / float64(time.Millisecond)
+		measured[from][to] = ms
+		has[from][to] = true
+		if static[from][to] > 0 {
+			ratioSum += ms / float64(static[from][to])
+			ratioCount++
+		}
+	}
+
+	if ratioCount == 0 {
+		return static
+	}
+	scale := ratioSum / float64(ratioCount)
+
+	var table [12][12]int
+	for from := 0; from < numGeoBins; from++ {
+		for to := 0; to < numGeoBins; to++ {
+			latency := float64(static[from][to]) * scale
+			switch {
+			case has[from][to]:
+				latency += (measured[from][to] - latency) * measuredLatencyWeight
+			case has[to][from]:
+				latency += (measured[to][from] - latency) * measuredLatencyWeight
+			}
+			table[from][to] = int(latency + 0.5)
+		}
+	}
+
+	return table
+}
+
+// GetLatencyTable returns the latency table used for team ordering.
+func (s *NetworkState) GetLatencyTable() [12][12]int {
+	s.latencyMux.RLock()
+	defer s.latencyMux.RUnlock()
+	return s.latencyTable
+}
+
+// SetLatencyTable replaces the latency table used for team ordering.
+func (s *NetworkState) SetLatencyTable(table [12][12]int) {
+	s.latencyMux.Lock()
+	s.latencyTable = table
+	s.latencyMux.Unlock()
+}
+
+// UpdateLatencyTable adds the round metrics of the network stored since the
+// tracker's last update in batches of latencyMetricBatch, persists the changed links, and
+// replaces the latency table. Rounds with a node that cannot be placed in a
+// GeoBin are skipped, as dropping the node would attribute its links' latency
+// to the wrong pair of GeoBins.
+func (s *NetworkState) UpdateLatencyTable(lt *LatencyTracker) error {
+	if lt.network != s.network {
+		return errors.Errorf("latency tracker of network %q cannot update "+
+			"network %q", lt.network, s.network)
+	}
+
+	changed := make(map[*LatencyLink]struct{})
+	rounds := 0
+	for {
+		metrics, err := PermissioningDb.GetRoundMetricsSince(s.network,
+			lt.lastRoundEnd, latencyMetricBatch)
+		if err != nil {
+			return errors.Errorf("Failed to get round metrics: %+v", err)
+		}
+
+		for _, metric := range metrics {
+			bins, err := s.getTopologyBins(metric.Topologies)
+			if err != nil {
+				jww.DEBUG.Printf("Skipping round %d in the latency table: %+v",
+					metric.Id, err)
+				bins = nil
+			}
+
+			// Rounds without bins still advance the tracker past them
+			for _, link := range lt.AddRoundMetric(metric, bins) {
+				changed[link] = struct{}{}
+			}
+		}
+
+		rounds += len(metrics)
+		if len(metrics) < latencyMetricBatch {
+			break
+		}
+	}
+
+	if len(changed) > 0 {
+		links := make([]*LatencyLink, 0, len(changed))
+		for link := range changed {
+			links = append(links, link)
+		}
+		if err := PermissioningDb.UpsertLatencyLinks(links); err != nil {
+			return errors.Errorf("Failed to store latency links: %+v", err)
+		}
+	}
+
+	s.SetLatencyTable(lt.Table())
+	jww.DEBUG.Printf("Updated latency table from %d rounds, %d links changed",
+		rounds, len(changed))
+	return nil
+}
+
+// PollLatencyTable updates the latency table from the tracker at the specified
+// interval. The provided channel allows for external killing of the routine.
+func (s *NetworkState) PollLatencyTable(lt *LatencyTracker,
+	interval time.Duration, quitChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jww.DEBUG.Printf("Starting latency table updater thread polling every %s",
+		interval)
+
+	for {
+		select {
+		case <-quitChan:
+			jww.DEBUG.Printf("Killing latency table polling routine.")
+			return
+		case <-ticker.C:
+			if err := s.UpdateLatencyTable(lt); err != nil {
+				jww.WARN.Printf("Error while updating latency table: %+v", err)
+			}
+		}
+	}
+}
+
+// getTopologyBins returns the GeoBin of every node of the topology in round
+// order. Errors if any node is unknown or its country has no GeoBin.
+func (s *NetworkState) getTopologyBins(topologies []Topology) (
+	[]region.GeoBin, error) {
+	bins := make([]region.GeoBin, 0, len(topologies))
+	for _, topology := range orderTopologies(topologies) {
+		nid, err := id.Unmarshal(topology.NodeId)
+		if err != nil {
+			return nil, errors.Errorf("Failed to unmarshal node ID: %+v", err)
+		}
+		n := s.nodes.GetNode(nid)
+		if n == nil {
+			return nil, errors.Errorf("Node %s is not in the node map", nid)
+		}
+		bin, exists := s.GetGeoBin(n.GetOrdering())
+		if !exists {
+			return nil, errors.Errorf("Node %s has country %q without a GeoBin",
+				nid, n.GetOrdering())
+		}
+		bins = append(bins, bin)
+	}
+	return bins, nil
+}
+
+// orderTopologies returns the topology entries sorted by their order in the
+// round.
+func orderTopologies(topologies []Topology) []Topology {
+	ordered := make([]Topology, len(topologies))
+	copy(ordered, topologies)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Order < ordered[j].Order
+	})
+	return ordered
+}
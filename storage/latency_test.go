@@ -0,0 +1,340 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"fmt"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/region"
+	"testing"
+	"time"
+)
+
+// Tests that AddRoundMetric splits the realtime duration across every link in
+// the topology and skips failed rounds.
+func TestLatencyTracker_AddRoundMetric(t *testing.T) {
+	lt := &LatencyTracker{
+		links:  make(map[[2]region.GeoBin]*LatencyLink),
+		window: 100,
+	}
+
+	start := time.Now()
+	metric := &RoundMetric{
+		RealtimeStart: start,
+		RealtimeEnd:   start.Add(300 * time.Millisecond),
+	}
+	bins := []region.GeoBin{region.NorthAmerica, region.WesternEurope,
+		region.EasternAsia}
+
+	updated := lt.AddRoundMetric(metric, bins)
+	if len(updated) != 3 {
+		t.Fatalf("Expected 3 updated links, received %d", len(updated))
+	}
+
+	for _, key := range [][2]region.GeoBin{
+		{region.NorthAmerica, region.WesternEurope},
+		{region.WesternEurope, region.EasternAsia},
+		{region.EasternAsia, region.NorthAmerica},
+	} {
+		link, exists := lt.links[key]
+		if !exists {
+			t.Fatalf("No link from %s to %s", key[0], key[1])
+		}
+		if link.Latency != 100*time.Millisecond || link.Samples != 1 {
+			t.Errorf("Unexpected link from %s to %s: %+v", key[0], key[1], link)
+		}
+	}
+
+	if !lt.lastRoundEnd.Equal(metric.RealtimeEnd) {
+		t.Errorf("lastRoundEnd not updated.\nexpected: %s\nreceived: %s",
+			metric.RealtimeEnd, lt.lastRoundEnd)
+	}
+
+	failed := &RoundMetric{
+		RealtimeStart: start,
+		RealtimeEnd:   start.Add(time.Second),
+		RoundErrors:   []RoundError{{Error: "failed"}},
+	}
+	if updated = lt.AddRoundMetric(failed, bins); len(updated) != 0 {
+		t.Errorf("Failed round added %d samples", len(updated))
+	}
+}
+
+// Tests that the moving average is taken over at most the window of samples.
+func TestLatencyTracker_addSample_Window(t *testing.T) {
+	lt := &LatencyTracker{
+		links:  make(map[[2]region.GeoBin]*LatencyLink),
+		window: 2,
+	}
+
+	now := time.Now()
+	expected := []time.Duration{100, 150, 225}
+	for i, sample := range []time.Duration{100, 200, 300} {
+		link := lt.addSample(region.Oceania, region.Oceania,
+			sample*time.Millisecond, now)
+		if link.Latency != expected[i]*time.Millisecond {
+			t.Errorf("Unexpected average after sample %d."+
+				"\nexpected: %s\nreceived: %s", i,
+				expected[i]*time.Millisecond, link.Latency)
+		}
+	}
+}
+
+// Tests that Table returns the static table without measurements and fills
+// unmeasured links from the reverse link or the scaled static table.
+func TestLatencyTracker_Table(t *testing.T) {
+	static := region.CreateSetLatencyTableWeights(region.CreateLinkTable())
+	lt := &LatencyTracker{
+		links:  make(map[[2]region.GeoBin]*LatencyLink),
+		window: 100,
+	}
+
+	if lt.Table() != static {
+		t.Errorf("Table without measurements is not the static table")
+	}
+
+	from, to := region.NorthAmerica, region.WesternEurope
+	measured := time.Duration(static[from][to]) * 10 * time.Millisecond
+	lt.addSample(from, to, measured, time.Now())
+	table := lt.Table()
+
+	if table[from][to] != static[from][to]*10 {
+		t.Errorf("Measured link not used.\nexpected: %d\nreceived: %d",
+			static[from][to]*10, table[from][to])
+	}
+	if table[to][from] != static[from][to]*10 {
+		t.Errorf("Reverse link not used.\nexpected: %d\nreceived: %d",
+			static[from][to]*10, table[to][from])
+	}
+	if table[region.Oceania][region.Russia] != static[region.Oceania][region.Russia]*10 {
+		t.Errorf("Unmeasured link not scaled.\nexpected: %d\nreceived: %d",
+			static[region.Oceania][region.Russia]*10,
+			table[region.Oceania][region.Russia])
+	}
+}
+
+// Tests that Table blends measured links with the static table scaled to the
+// average ratio of all measurements.
+func TestLatencyTracker_Table_Blend(t *testing.T) {
+	static := region.CreateSetLatencyTableWeights(region.CreateLinkTable())
+	lt := &LatencyTracker{
+		links:  make(map[[2]region.GeoBin]*LatencyLink),
+		window: 100,
+	}
+
+	fast, slow := [2]region.GeoBin{region.NorthAmerica, region.WesternEurope},
+		[2]region.GeoBin{region.Oceania, region.Russia}
+	lt.addSample(fast[0], fast[1], time.Duration(static[fast[0]][fast[1]])*
+		10*time.Millisecond, time.Now())
+	lt.addSample(slow[0], slow[1], time.Duration(static[slow[0]][slow[1]])*
+		20*time.Millisecond, time.Now())
+	table := lt.Table()
+
+	// The scale is 15, so the blended links are 12.5 and 17.5 times static
+	for _, link := range []struct {
+		bins  [2]region.GeoBin
+		ratio float64
+	}{{fast, 12.5}, {slow, 17.5}} {
+		expected := int(float64(static[link.bins[0]][link.bins[1]])*
+			link.ratio + 0.5)
+		if table[link.bins[0]][link.bins[1]] != expected {
+			t.Errorf("Measured link %v not blended.\nexpected: %d\nreceived: %d",
+				link.bins, expected, table[link.bins[0]][link.bins[1]])
+		}
+	}
+}
+
+// Tests that UpdateLatencyTable builds the table from stored round metrics,
+// persists the links, and does not add the same rounds twice.
+func TestNetworkState_UpdateLatencyTable(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "TestNetworkState_UpdateLatencyTable", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	countries := []string{"US", "DE"}
+	topology := make([][]byte, len(countries))
+	for i, country := range countries {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		topology[i] = nid.Bytes()
+		err = PermissioningDb.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: fmt.Sprintf("CODE%d", i), Id: nid.Bytes()})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+		err = state.GetNodeMap().AddNode(nid, country, "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	start := time.Now().Add(-time.Minute).Round(time.Second)
+	err = PermissioningDb.InsertRoundMetric(&RoundMetric{
+		Id:            1,
+		PrecompStart:  start,
+		PrecompEnd:    start,
+		RealtimeStart: start,
+		RealtimeEnd:   start.Add(400 * time.Millisecond),
+		RoundEnd:      start.Add(400 * time.Millisecond),
+	}, topology)
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+
+	lt, err := NewLatencyTracker("", 100)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %+v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err = state.UpdateLatencyTable(lt); err != nil {
+			t.Fatalf("UpdateLatencyTable returned an error: %+v", err)
+		}
+	}
+
+	usBin, _ := state.GetGeoBin("US")
+	deBin, _ := state.GetGeoBin("DE")
+	if latency := state.GetLatencyTable()[usBin][deBin]; latency != 200 {
+		t.Errorf("Unexpected latency from US to DE.\nexpected: %d\nreceived: %d",
+			200, latency)
+	}
+
+	links, err := PermissioningDb.GetLatencyLinks("")
+	if err != nil {
+		t.Fatalf("Failed to get links: %+v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 stored links, received %d", len(links))
+	}
+	for _, link := range links {
+		if link.Samples != 1 {
+			t.Errorf("Round added more than once: %+v", link)
+		}
+	}
+
+	// A new tracker picks up the persisted links
+	lt, err = NewLatencyTracker("", 100)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %+v", err)
+	}
+	if len(lt.links) != 2 || lt.Table() != state.GetLatencyTable() {
+		t.Errorf("New tracker did not load the stored links")
+	}
+}
+
+// Tests that UpdateLatencyTable skips a round with a node whose country has no
+// GeoBin, rather than adding a sample for the remaining nodes' links.
+func TestNetworkState_UpdateLatencyTable_UnbinnedNode(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "",
+		"TestNetworkState_UpdateLatencyTable_UnbinnedNode", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	countries := []string{"US", "DE", "XX"}
+	topology := make([][]byte, len(countries))
+	for i, country := range countries {
+		nid := id.NewIdFromUInt(uint64(i), id.Node, t)
+		topology[i] = nid.Bytes()
+		err = PermissioningDb.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: fmt.Sprintf("CODE%d", i), Id: nid.Bytes()})
+		if err != nil {
+			t.Fatalf("Failed to insert node: %+v", err)
+		}
+		err = state.GetNodeMap().AddNode(nid, country, "", "", 0)
+		if err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+
+	start := time.Now().Add(-time.Minute).Round(time.Second)
+	end := start.Add(600 * time.Millisecond)
+	err = PermissioningDb.InsertRoundMetric(&RoundMetric{
+		Id:            1,
+		PrecompStart:  start,
+		PrecompEnd:    start,
+		RealtimeStart: start,
+		RealtimeEnd:   end,
+		RoundEnd:      end,
+	}, topology)
+	if err != nil {
+		t.Fatalf("Failed to insert round metric: %+v", err)
+	}
+
+	lt, err := NewLatencyTracker("", 100)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %+v", err)
+	}
+	if err = state.UpdateLatencyTable(lt); err != nil {
+		t.Fatalf("UpdateLatencyTable returned an error: %+v", err)
+	}
+
+	if len(lt.links) != 0 {
+		t.Errorf("Round with an unbinned node added samples: %d links",
+			len(lt.links))
+	}
+	if !lt.lastRoundEnd.Equal(end) {
+		t.Errorf("Tracker did not advance past the skipped round."+
+			"\nexpected: %s\nreceived: %s", end, lt.lastRoundEnd)
+	}
+}
+
+// Tests that a tracker without stored links starts reading round metrics from
+// the lookback rather than from the first round.
+func TestNewLatencyTracker_Lookback(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "TestNewLatencyTracker_Lookback", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	before := time.Now().Add(-latencyLookback)
+	lt, err := NewLatencyTracker("", 100)
+	if err != nil {
+		t.Fatalf("Failed to create tracker: %+v", err)
+	}
+
+	if lt.lastRoundEnd.Before(before) ||
+		lt.lastRoundEnd.After(time.Now().Add(-latencyLookback)) {
+		t.Errorf("Tracker did not start at the lookback: %s", lt.lastRoundEnd)
+	}
+}
+
+// Error path: a zero window is rejected.
+func TestNewLatencyTracker_ZeroWindow(t *testing.T) {
+	if _, err := NewLatencyTracker("", 0); err == nil {
+		t.Errorf("NewLatencyTracker did not error on a zero window")
+	}
+}
+
+// Error path: a tracker cannot update the latency table of another network.
+func TestNetworkState_UpdateLatencyTable_OtherNetwork(t *testing.T) {
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	lt := &LatencyTracker{network: "testnet",
+		links: make(map[[2]region.GeoBin]*LatencyLink), window: 100}
+	if err = state.UpdateLatencyTable(lt); err == nil {
+		t.Errorf("UpdateLatencyTable did not error on a tracker of " +
+			"another network")
+	}
+}
@@ -95,6 +95,14 @@ type State struct {
 	// has port forwarding
 	connectivity *uint32
 
+	// True when the Node has been drained for maintenance and should not be
+	// scheduled into new rounds
+	draining bool
+
+	// True when the Node is not in the eligible Node set and should not be
+	// scheduled into new rounds
+	ineligible bool
+
 	ed25519 nike.PublicKey
 }
 
@@ -159,6 +167,88 @@ func (n *State) Ban() (UpdateNotification, error) {
 	return nun, nil
 }
 
+// SetDraining puts the Node into or takes it out of drain mode and returns an
+// update notification for signaling the scheduler. A draining Node finishes its
+// current round but is not placed into new ones. Errors if the Node is banned
+// or is already in the requested mode.
+func (n *State) SetDraining(draining bool) (UpdateNotification, error) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if n.status == Banned {
+		return UpdateNotification{}, errors.New("cannot change drain mode " +
+			"of a banned Node")
+	}
+
+	if n.draining == draining {
+		if draining {
+			return UpdateNotification{}, errors.New("Node is already draining")
+		}
+		return UpdateNotification{}, errors.New("Node is not draining")
+	}
+
+	n.draining = draining
+
+	nun := UpdateNotification{
+		Node:         n.id,
+		FromStatus:   n.status,
+		ToStatus:     n.status,
+		FromActivity: n.activity,
+		ToActivity:   n.activity,
+		DrainChange:  true,
+	}
+
+	return nun, nil
+}
+
+// IsDraining returns true if the Node is in drain mode.
+func (n *State) IsDraining() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return n.draining
+}
+
+// SetEligible marks whether the Node is in the eligible Node set. If this
+// changes the Node's eligibility, it returns true and an update notification
+// for signaling the scheduler. An ineligible Node finishes its current round
+// but is not placed into new ones. Banned Nodes are never changed.
+func (n *State) SetEligible(eligible bool) (UpdateNotification, bool) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+
+	if n.status == Banned || n.ineligible == !eligible {
+		return UpdateNotification{}, false
+	}
+
+	n.ineligible = !eligible
+
+	nun := UpdateNotification{
+		Node:              n.id,
+		FromStatus:        n.status,
+		ToStatus:          n.status,
+		FromActivity:      n.activity,
+		ToActivity:        n.activity,
+		EligibilityChange: true,
+	}
+
+	return nun, true
+}
+
+// IsEligible returns true if the Node is in the eligible Node set.
+func (n *State) IsEligible() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return !n.ineligible
+}
+
+// IsSchedulable returns true if the Node may be scheduled into new rounds,
+// which requires it to be eligible and not draining.
+func (n *State) IsSchedulable() bool {
+	n.mux.RLock()
+	defer n.mux.RUnlock()
+	return !n.ineligible && !n.draining
+}
+
 // updates to the passed in activity if it is different from the known activity
 // returns true if the state changed and the state was it was regardless
 func (n *State) Update(newActivity current.Activity) (bool, UpdateNotification, error) {
@@ -287,8 +377,8 @@ func (n *State) SetConnectivity(c uint32) {
 
 // Designates the node as offline
 func (n *State) SetInactive() {
-	n.mux.RLock()
-	defer n.mux.RUnlock()
+	n.mux.Lock()
+	defer n.mux.Unlock()
 	n.status = Inactive
 }
 
@@ -491,6 +581,13 @@ func (n *State) SetLastPoll(lastPoll time.Time, t *testing.T) {
 	n.lastPoll = lastPoll
 }
 
+func (n *State) SetLastUpdate(lastUpdate time.Time, t *testing.T) {
+	if t == nil {
+		panic("Cannot directly set node.State's last update outside of testing")
+	}
+	n.lastUpdate = lastUpdate
+}
+
 func (n *State) GetGatewayAddress() string {
 	return n.gatewayAddress
 }
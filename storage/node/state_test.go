@@ -584,6 +584,119 @@ func TestState_IsBanned(t *testing.T) {
 	}
 }
 
+// Tests that SetDraining toggles drain mode and returns a drain notification.
+func TestState_SetDraining(t *testing.T) {
+	testID := id.NewIdFromUInt(50, id.Node, t)
+	ns := State{
+		id:       testID,
+		activity: current.WAITING,
+		status:   Active,
+	}
+
+	nun, err := ns.SetDraining(true)
+	if err != nil {
+		t.Fatalf("Failed to drain node: %+v", err)
+	}
+	if !ns.IsDraining() {
+		t.Errorf("Node not draining after SetDraining(true).")
+	}
+
+	expected := UpdateNotification{
+		Node:         testID,
+		FromStatus:   Active,
+		ToStatus:     Active,
+		FromActivity: current.WAITING,
+		ToActivity:   current.WAITING,
+		DrainChange:  true,
+	}
+	if !reflect.DeepEqual(expected, nun) {
+		t.Errorf("Unexpected update notification."+
+			"\nexpected: %+v\nreceived: %+v", expected, nun)
+	}
+
+	if _, err = ns.SetDraining(true); err == nil {
+		t.Errorf("Draining an already draining node should error.")
+	}
+
+	if _, err = ns.SetDraining(false); err != nil {
+		t.Errorf("Failed to return node from drain mode: %+v", err)
+	}
+	if ns.IsDraining() {
+		t.Errorf("Node still draining after SetDraining(false).")
+	}
+
+	if _, err = ns.SetDraining(false); err == nil {
+		t.Errorf("Readying a node that is not draining should error.")
+	}
+}
+
+// Tests that a banned node cannot be drained.
+func TestState_SetDraining_Banned(t *testing.T) {
+	ns := State{
+		id:     id.NewIdFromUInt(50, id.Node, t),
+		status: Banned,
+	}
+
+	if _, err := ns.SetDraining(true); err == nil {
+		t.Errorf("Draining a banned node should error.")
+	}
+}
+
+// Tests that SetEligible only returns an update notification when the Node's
+// eligibility changes.
+func TestState_SetEligible(t *testing.T) {
+	testID := id.NewIdFromUInt(50, id.Node, t)
+	ns := State{
+		id:       testID,
+		activity: current.WAITING,
+		status:   Active,
+	}
+
+	if _, changed := ns.SetEligible(true); changed {
+		t.Errorf("SetEligible(true) changed an eligible node.")
+	}
+
+	nun, changed := ns.SetEligible(false)
+	if !changed {
+		t.Fatalf("SetEligible(false) did not change an eligible node.")
+	}
+	if ns.IsEligible() || ns.IsSchedulable() {
+		t.Errorf("Node eligible after SetEligible(false).")
+	}
+
+	expected := UpdateNotification{
+		Node:              testID,
+		FromStatus:        Active,
+		ToStatus:          Active,
+		FromActivity:      current.WAITING,
+		ToActivity:        current.WAITING,
+		EligibilityChange: true,
+	}
+	if !reflect.DeepEqual(expected, nun) {
+		t.Errorf("Unexpected update notification."+
+			"\nexpected: %+v\nreceived: %+v", expected, nun)
+	}
+
+	if _, changed = ns.SetEligible(true); !changed {
+		t.Errorf("SetEligible(true) did not change an ineligible node.")
+	}
+	if !ns.IsSchedulable() {
+		t.Errorf("Eligible node not schedulable.")
+	}
+}
+
+// Tests that SetEligible does not change a banned Node.
+func TestState_SetEligible_Banned(t *testing.T) {
+	ns := State{
+		id:     id.NewIdFromUInt(50, id.Node, t),
+		status: Banned,
+	}
+
+	if _, changed := ns.SetEligible(false); changed {
+		t.Errorf("SetEligible changed a banned node.")
+	}
+}
+
 // Happy path
 func TestState_UpdateInactive(t *testing.T) {
 	testID := id.NewIdFromUInt(50, id.Node, t)
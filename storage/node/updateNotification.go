@@ -25,4 +25,8 @@ type UpdateNotification struct {
 	ToActivity   current.Activity
 	Error        *mixmessages.RoundError
 	ClientErrors []*mixmessages.ClientError
+	// Set when the Node's drain mode changed rather than its activity
+	DrainChange bool
+	// Set when the Node's eligibility changed rather than its activity
+	EligibilityChange bool
 }
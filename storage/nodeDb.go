@@ -10,6 +10,7 @@
 package storage
 
 import (
+	"github.com/jinzhu/gorm"
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	"gitlab.com/elixxir/registration/storage/node"
@@ -119,6 +120,81 @@ func (d *DatabaseImpl) GetNodesByStatus(status node.Status) ([]*Node, error) {
 	return nodes, err
 }
 
+// Returns the Application with the given ID from Storage
+func (d *DatabaseImpl) GetApplication(id uint64) (*Application, error) {
+	application := &Application{}
+	err := d.db.Take(application, "id = ?", id).Error
+	return application, err
+}
+
+// Returns the Team of every Application in Storage which has one, keyed on
+// the Application ID
+func (d *DatabaseImpl) GetApplicationTeams() (map[uint64]string, error) {
+	var applications []*Application
+	err := d.db.Select("id, team").Where("team <> ''").
+		Find(&applications).Error
+	if err != nil {
+		return nil, err
+	}
+
+	teams := make(map[uint64]string, len(applications))
+	for _, application := range applications {
+		teams[application.Id] = application.Team
+	}
+	return teams, nil
+}
+
+// Returns every Application in Storage, ordered by ID, with its Node if it
+// has one
+func (d *DatabaseImpl) GetApplications() ([]*Application, error) {
+	var applications []*Application
+	err := d.db.Preload("Node").Order("id ASC").Find(&applications).Error
+	return applications, err
+}
+
+// Insert a pending Application without a Node. The Application is given the
+// ID after the largest in Storage
+func (d *DatabaseImpl) InsertPendingApplication(application *Application) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		var maxId struct{ Id uint64 }
+		err := tx.Model(&Application{}).Select("COALESCE(MAX(id), 0) AS id").
+			Scan(&maxId).Error
+		if err != nil {
+			return err
+		}
+
+		application.Id = maxId.Id + 1
+		application.Status = ApplicationPending
+		return tx.Set("gorm:save_associations", false).
+			Create(application).Error
+	})
+}
+
+// Update every field of the Application, without changing its Node
+func (d *DatabaseImpl) UpdateApplication(application *Application) error {
+	return d.db.Set("gorm:save_associations", false).Save(application).Error
+}
+
+// Mark the Application as approved and insert its unregistered Node
+func (d *DatabaseImpl) ApproveApplication(application *Application,
+	unregisteredNode *Node) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		application.Status = ApplicationApproved
+		err := tx.Set("gorm:save_associations", false).
+			Save(application).Error
+		if err != nil {
+			return err
+		}
+
+		unregisteredNode.ApplicationId = application.Id
+		if err = tx.Create(unregisteredNode).Error; err != nil {
+			return err
+		}
+		application.Node = *unregisteredNode
+		return nil
+	})
+}
+
 // Return all ActiveNodes in Storage
 func (d *DatabaseImpl) GetActiveNodes() ([]*ActiveNode, error) {
 	var activeNodes []*ActiveNode
@@ -9,10 +9,12 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 	"github.com/jinzhu/gorm"
 	"gitlab.com/elixxir/registration/storage/node"
 	"gitlab.com/xx_network/primitives/id"
 	"gitlab.com/xx_network/primitives/region"
+	"reflect"
 	"testing"
 )
 
@@ -231,6 +233,38 @@ func TestDatabaseImpl_GetNode(t *testing.T) {
 	}
 }
 
+// Happy path: only Applications with a Team are returned
+func TestDatabaseImpl_GetApplicationTeams(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetApplicationTeams", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	for i, team := range []string{"alpha", "", "alpha", "beta"} {
+		err = d.InsertApplication(&Application{Id: uint64(i + 1), Team: team},
+			&Node{Code: fmt.Sprintf("TEST%d", i)})
+		if err != nil {
+			t.Fatalf("Failed to insert application: %+v", err)
+		}
+	}
+
+	teams, err := d.GetApplicationTeams()
+	if err != nil {
+		t.Fatalf("Failed to get application teams: %+v", err)
+	}
+	expected := map[uint64]string{1: "alpha", 3: "alpha", 4: "beta"}
+	if !reflect.DeepEqual(expected, teams) {
+		t.Errorf("Unexpected teams.\nexpected: %v\nreceived: %v",
+			expected, teams)
+	}
+}
+
 // Error path: Nonexistent registration code
 func TestDatabaseImpl_GetNode_Invalid(t *testing.T) {
 	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetNode_Invalid", "", "")
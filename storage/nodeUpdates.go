@@ -0,0 +1,116 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/elixxir/registration/storage/node"
+	"sync"
+)
+
+// nodeUpdates.go contains the queue which delivers Node update notifications
+// to the scheduler.
+
+// NodeUpdateQueueStats describes the depth of the Node update queue.
+type NodeUpdateQueueStats struct {
+	// Notifications waiting to be read by the scheduler
+	Queued int
+	// Notifications of those queued which did not fit in the channel
+	Overflow int
+	// Largest overflow seen
+	PeakOverflow int
+	// Total notifications which have overflowed the channel
+	TotalOverflowed uint64
+}
+
+// nodeUpdateQueue delivers Node update notifications to the scheduler in the
+// order they are sent without ever dropping one. Notifications are sent on the
+// channel while it has space; once it is full they are held in an overflow
+// queue which is moved onto the channel as the scheduler reads from it.
+//
+// Every sender holds the Node's polling lock until the scheduler has handled
+// the notification, so no more than one notification per Node is queued and
+// the overflow is bounded by the number of Nodes.
+type nodeUpdateQueue struct {
+	c chan node.UpdateNotification
+
+	mux      sync.Mutex
+	overflow []node.UpdateNotification
+	// True while the overflow is being moved onto the channel. New
+	// notifications join the overflow until it finishes so that order is kept.
+	draining bool
+
+	peakOverflow    int
+	totalOverflowed uint64
+}
+
+// newNodeUpdateQueue creates a queue whose channel holds size notifications.
+func newNodeUpdateQueue(size int) *nodeUpdateQueue {
+	return &nodeUpdateQueue{c: make(chan node.UpdateNotification, size)}
+}
+
+// send queues the notification. It never blocks.
+func (q *nodeUpdateQueue) send(nun node.UpdateNotification) {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if !q.draining {
+		select {
+		case q.c <- nun:
+			return
+		default:
+		}
+	}
+
+	q.overflow = append(q.overflow, nun)
+	q.totalOverflowed++
+	if len(q.overflow) > q.peakOverflow {
+		q.peakOverflow = len(q.overflow)
+	}
+
+	if !q.draining {
+		jww.WARN.Printf("Node update channel is full with %d "+
+			"notifications, holding new notifications until the scheduler "+
+			"catches up", cap(q.c))
+		q.draining = true
+		go q.drain()
+	}
+}
+
+// drain moves the overflow onto the channel, in order, as the scheduler reads
+// from it.
+func (q *nodeUpdateQueue) drain() {
+	for {
+		q.mux.Lock()
+		if len(q.overflow) == 0 {
+			q.draining = false
+			q.mux.Unlock()
+			jww.INFO.Printf("Node update overflow has been delivered to " +
+				"the scheduler")
+			return
+		}
+		nun := q.overflow[0]
+		q.overflow[0] = node.UpdateNotification{}
+		q.overflow = q.overflow[1:]
+		q.mux.Unlock()
+
+		q.c <- nun
+	}
+}
+
+// stats returns the current depth of the queue.
+func (q *nodeUpdateQueue) stats() NodeUpdateQueueStats {
+	q.mux.Lock()
+	defer q.mux.Unlock()
+	return NodeUpdateQueueStats{
+		Queued:          len(q.c) + len(q.overflow),
+		Overflow:        len(q.overflow),
+		PeakOverflow:    q.peakOverflow,
+		TotalOverflowed: q.totalOverflowed,
+	}
+}
@@ -69,6 +69,94 @@ func (d *DatabaseImpl) InsertRoundError(roundId id.Round, errStr string) error {
 	return d.db.Create(roundErr).Error
 }
 
+// Insert the Nodes a failed round is blamed on into Storage
+func (d *DatabaseImpl) InsertRoundBlames(roundId id.Round,
+	blames []*RoundBlame) error {
+	jww.TRACE.Printf("Attempting to insert %d RoundBlames for round %d "+
+		"into DB", len(blames), roundId)
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, blame := range blames {
+			blame.RoundMetricId = uint64(roundId)
+			if err := tx.Create(blame).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the Nodes the given round is blamed on from Storage
+func (d *DatabaseImpl) GetRoundBlames(roundId id.Round) ([]*RoundBlame, error) {
+	var result []*RoundBlame
+	err := d.db.Where("round_metric_id = ?", uint64(roundId)).
+		Find(&result).Error
+	jww.TRACE.Printf("Obtained RoundBlames from DB: %+v", result)
+	return result, err
+}
+
+// Returns the failed rounds blamed on the given Node since the given time from
+// Storage, newest first
+func (d *DatabaseImpl) GetNodeBlames(nodeId *id.ID, since time.Time) (
+	[]*RoundBlame, error) {
+	var result []*RoundBlame
+	err := d.db.Where("node_id = ? AND timestamp >= ?", nodeId.Marshal(),
+		since).Order("timestamp desc").Find(&result).Error
+	jww.TRACE.Printf("Obtained RoundBlames from DB: %+v", result)
+	return result, err
+}
+
+// Insert the client errors reported in the given round into Storage
+func (d *DatabaseImpl) InsertClientErrors(roundId id.Round,
+	clientErrors []*RoundClientError) error {
+	jww.TRACE.Printf("Attempting to insert %d RoundClientErrors for round "+
+		"%d into DB", len(clientErrors), roundId)
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, clientError := range clientErrors {
+			clientError.RoundId = uint64(roundId)
+			if err := tx.Create(clientError).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns the client errors reported in the given round of the network from
+// Storage
+func (d *DatabaseImpl) GetRoundClientErrors(network string, roundId id.Round) (
+	[]*RoundClientError, error) {
+	var result []*RoundClientError
+	err := d.db.Where("network = ? AND round_id = ?", network,
+		uint64(roundId)).Find(&result).Error
+	jww.TRACE.Printf("Obtained RoundClientErrors from DB: %+v", result)
+	return result, err
+}
+
+// Returns the client errors reported on the network since the given time from
+// Storage, oldest first. If a client ID is given, only its errors are returned
+func (d *DatabaseImpl) GetClientErrors(network string, clientId []byte,
+	since time.Time) ([]*RoundClientError, error) {
+	var result []*RoundClientError
+	query := d.db.Where("network = ? AND timestamp >= ?", network, since)
+	if clientId != nil {
+		query = query.Where("client_id = ?", clientId)
+	}
+	err := query.Order("timestamp asc").Find(&result).Error
+	jww.TRACE.Printf("Obtained %d RoundClientErrors from DB", len(result))
+	return result, err
+}
+
+// Deletes the client errors reported on the network before the given time from
+// Storage, returning the number deleted
+func (d *DatabaseImpl) DeleteClientErrors(network string, before time.Time) (
+	int64, error) {
+	result := d.db.Where("network = ? AND timestamp < ?", network, before).
+		Delete(&RoundClientError{})
+	jww.TRACE.Printf("Deleted %d RoundClientErrors from DB",
+		result.RowsAffected)
+	return result.RowsAffected, result.Error
+}
+
 // Insert new RoundMetric object with associated topology into Storage
 func (d *DatabaseImpl) InsertRoundMetric(metric *RoundMetric, topology [][]byte) error {
 
@@ -113,11 +201,12 @@ func (d *DatabaseImpl) InsertEphemeralLength(length *EphemeralLength) error {
 	return d.db.Create(length).Error
 }
 
-// Get the first round that is timestamped after the given cutoff
-func (d *DatabaseImpl) GetEarliestRound(cutoff time.Duration) (id.Round, time.Time, error) {
+// Get the first round on the network that is timestamped after the given cutoff
+func (d *DatabaseImpl) GetEarliestRound(network string, cutoff time.Duration) (id.Round, time.Time, error) {
 	var result RoundMetric
 	cutoffTs := time.Now().Add(-cutoff)
-	err := d.db.Where("? <= realtime_end", cutoffTs).Order("realtime_end ASC").Take(&result).Error
+	err := d.db.Where("network = ? AND ? <= realtime_end", network, cutoffTs).
+		Order("realtime_end ASC").Take(&result).Error
 	if err != nil {
 		return 0, time.Time{}, err
 	}
@@ -132,3 +221,116 @@ func (d *DatabaseImpl) getBins() ([]*GeoBin, error) {
 	err := d.db.Find(&result).Error
 	return result, err
 }
+
+// Returns up to limit RoundMetric of the network with their Topologies and
+// RoundErrors whose realtime ended after the given time, oldest first
+func (d *DatabaseImpl) GetRoundMetricsSince(network string, since time.Time,
+	limit int) ([]*RoundMetric, error) {
+	var result []*RoundMetric
+	err := d.db.Preload("Topologies").Preload("RoundErrors").
+		Where("network = ? AND realtime_end > ?", network, since).
+		Order("realtime_end ASC, id ASC").Limit(limit).
+		Find(&result).Error
+	jww.TRACE.Printf("Obtained %d RoundMetrics since %s", len(result), since)
+	return result, err
+}
+
+// Returns the number of RoundMetric of the network whose round ended after the
+// given time
+func (d *DatabaseImpl) CountRoundMetricsSince(network string, since time.Time) (int, error) {
+	count := 0
+	err := d.db.Model(&RoundMetric{}).
+		Where("network = ? AND round_end > ?", network, since).
+		Count(&count).Error
+	return count, err
+}
+
+// Returns the most recent limit RoundMetric of the network whose realtime
+// completed, with their Topologies, oldest first
+func (d *DatabaseImpl) GetCompletedRoundMetrics(network string, limit int) ([]*RoundMetric, error) {
+	var result []*RoundMetric
+	err := d.db.Preload("Topologies").
+		Where("network = ? AND realtime_end > realtime_start", network).
+		Order("realtime_end DESC").Limit(limit).
+		Find(&result).Error
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	jww.TRACE.Printf("Obtained %d completed RoundMetrics", len(result))
+	return result, err
+}
+
+// Inserts the given LatencyLinks into Storage, replacing any existing links
+// between the same GeoBins on the same network
+func (d *DatabaseImpl) UpsertLatencyLinks(links []*LatencyLink) error {
+	jww.TRACE.Printf("Attempting to upsert %d LatencyLinks into DB", len(links))
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, link := range links {
+			if err := tx.Save(link).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns all LatencyLink of the network from Storage
+func (d *DatabaseImpl) GetLatencyLinks(network string) ([]*LatencyLink, error) {
+	var result []*LatencyLink
+	err := d.db.Where("network = ?", network).Find(&result).Error
+	jww.TRACE.Printf("Obtained LatencyLinks from DB: %+v", result)
+	return result, err
+}
+
+// Inserts the given NodeReputations into Storage, replacing any existing
+// reputations of the same Nodes
+func (d *DatabaseImpl) UpsertNodeReputations(reputations []*NodeReputation) error {
+	jww.TRACE.Printf("Attempting to upsert %d NodeReputations into DB",
+		len(reputations))
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, reputation := range reputations {
+			if err := tx.Save(reputation).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Returns all NodeReputation of the network from Storage
+func (d *DatabaseImpl) GetNodeReputations(network string) ([]*NodeReputation, error) {
+	var result []*NodeReputation
+	err := d.db.Where("network = ?", network).Find(&result).Error
+	jww.TRACE.Printf("Obtained NodeReputations from DB: %+v", result)
+	return result, err
+}
+
+// Inserts the given WhitelistEntry into Storage, replacing any existing entry
+// with the same value on the same network
+func (d *DatabaseImpl) UpsertWhitelistEntry(entry *WhitelistEntry) error {
+	jww.TRACE.Printf("Attempting to upsert WhitelistEntry into DB: %+v", entry)
+	return d.db.Save(entry).Error
+}
+
+// Deletes the WhitelistEntry of the network with the given value from Storage
+func (d *DatabaseImpl) DeleteWhitelistEntry(network, value string) error {
+	jww.TRACE.Printf("Attempting to delete WhitelistEntry %q from DB", value)
+	result := d.db.Where("network = ? AND value = ?", network, value).
+		Delete(&WhitelistEntry{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Returns all WhitelistEntry of the network from Storage
+func (d *DatabaseImpl) GetWhitelistEntries(network string) ([]*WhitelistEntry, error) {
+	var result []*WhitelistEntry
+	err := d.db.Where("network = ?", network).Order("value ASC").
+		Find(&result).Error
+	jww.TRACE.Printf("Obtained %d WhitelistEntries from DB", len(result))
+	return result, err
+}
@@ -8,6 +8,7 @@
 package storage
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/jinzhu/gorm"
@@ -363,6 +364,194 @@ func TestDatabaseImpl_InsertRoundError(t *testing.T) {
 	}
 }
 
+// Tests that the Nodes blamed for a round are stored and can be retrieved by
+// round and by Node.
+func TestDatabaseImpl_InsertRoundBlames(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_InsertRoundBlames", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	failed := time.Now()
+	nodes := make([]*id.ID, 2)
+	for i, roundId := range []id.Round{1, 2} {
+		nodes[i] = id.NewIdFromBytes([]byte(fmt.Sprintf("Node%d", i)), t)
+		err = d.InsertApplication(&Application{Id: uint64(i + 1)},
+			&Node{Code: fmt.Sprintf("TEST%d", i), Id: nodes[i].Bytes()})
+		if err != nil {
+			t.Fatalf("Failed to insert node for test: %+v", err)
+		}
+		err = d.InsertRoundMetric(&RoundMetric{Id: uint64(roundId),
+			RoundEnd: failed}, [][]byte{nodes[i].Bytes()})
+		if err != nil {
+			t.Fatalf("Unable to insert round metric: %+v", err)
+		}
+	}
+
+	err = d.InsertRoundBlames(1, []*RoundBlame{
+		{NodeId: nodes[0].Marshal(), Reason: "unresponsive",
+			Activity: "PRECOMPUTING", Timestamp: failed},
+		{NodeId: nodes[1].Marshal(), Reason: "behind",
+			Activity: "PRECOMPUTING", Timestamp: failed},
+	})
+	if err != nil {
+		t.Fatalf("Unable to insert round blames: %+v", err)
+	}
+	err = d.InsertRoundBlames(2, []*RoundBlame{{NodeId: nodes[0].Marshal(),
+		Reason: "reported error", Activity: "ERROR",
+		Timestamp: failed.Add(time.Second)}})
+	if err != nil {
+		t.Fatalf("Unable to insert round blames: %+v", err)
+	}
+
+	blames, err := d.GetRoundBlames(1)
+	if err != nil {
+		t.Fatalf("Failed to get round blames: %+v", err)
+	}
+	if len(blames) != 2 || blames[0].RoundMetricId != 1 {
+		t.Errorf("Unexpected round blames: %+v", blames)
+	}
+
+	blames, err = d.GetNodeBlames(nodes[0], failed.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get node blames: %+v", err)
+	}
+	if len(blames) != 2 || blames[0].RoundMetricId != 2 ||
+		blames[1].RoundMetricId != 1 {
+		t.Errorf("Unexpected node blames: %+v", blames)
+	}
+
+	blames, err = d.GetNodeBlames(nodes[0], failed.Add(time.Millisecond))
+	if err != nil {
+		t.Fatalf("Failed to get node blames: %+v", err)
+	}
+	if len(blames) != 1 {
+		t.Errorf("Expected 1 blame since the cutoff, found %d", len(blames))
+	}
+}
+
+// Tests that client errors are stored without a round metric and can be
+// retrieved by round and by client within their network.
+func TestDatabaseImpl_InsertClientErrors(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_InsertClientErrors", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	received := time.Now()
+	gateway := id.NewIdFromString("Gateway", id.Gateway, t)
+	clients := []*id.ID{id.NewIdFromString("Client0", id.User, t),
+		id.NewIdFromString("Client1", id.User, t)}
+	for i, roundId := range []id.Round{1, 2} {
+		err = d.InsertClientErrors(roundId, []*RoundClientError{{
+			GatewayId: gateway.Marshal(), ClientId: clients[i].Marshal(),
+			ErrorType: "failed to decrypt", Error: "failed to decrypt: bad key",
+			Timestamp: received.Add(time.Duration(i) * time.Second)}})
+		if err != nil {
+			t.Fatalf("Unable to insert client errors: %+v", err)
+		}
+	}
+	err = d.InsertClientErrors(2, []*RoundClientError{{Network: "testnet",
+		GatewayId: gateway.Marshal(), ClientId: clients[0].Marshal(),
+		ErrorType: "failed", Error: "failed", Timestamp: received}})
+	if err != nil {
+		t.Fatalf("Unable to insert client errors: %+v", err)
+	}
+
+	clientErrors, err := d.GetRoundClientErrors("", 2)
+	if err != nil {
+		t.Fatalf("Failed to get round client errors: %+v", err)
+	}
+	if len(clientErrors) != 1 || clientErrors[0].RoundId != 2 ||
+		!bytes.Equal(clientErrors[0].ClientId, clients[1].Marshal()) {
+		t.Errorf("Unexpected round client errors: %+v", clientErrors)
+	}
+
+	clientErrors, err = d.GetClientErrors("", nil, received.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get client errors: %+v", err)
+	}
+	if len(clientErrors) != 2 || clientErrors[0].RoundId != 1 ||
+		clientErrors[1].RoundId != 2 {
+		t.Errorf("Unexpected client errors: %+v", clientErrors)
+	}
+
+	clientErrors, err = d.GetClientErrors("", clients[0].Marshal(),
+		received.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get client errors: %+v", err)
+	}
+	if len(clientErrors) != 1 || clientErrors[0].RoundId != 1 {
+		t.Errorf("Unexpected errors of client: %+v", clientErrors)
+	}
+
+	clientErrors, err = d.GetRoundClientErrors("testnet", 2)
+	if err != nil {
+		t.Fatalf("Failed to get round client errors: %+v", err)
+	}
+	if len(clientErrors) != 1 || clientErrors[0].Network != "testnet" {
+		t.Errorf("Unexpected client errors of network: %+v", clientErrors)
+	}
+}
+
+// Tests that DeleteClientErrors only deletes the errors of the network
+// reported before the given time.
+func TestDatabaseImpl_DeleteClientErrors(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_DeleteClientErrors", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	now := time.Now()
+	gateway := id.NewIdFromString("Gateway", id.Gateway, t)
+	err = d.InsertClientErrors(1, []*RoundClientError{
+		{GatewayId: gateway.Marshal(), ErrorType: "old", Error: "old",
+			Timestamp: now.Add(-2 * time.Hour)},
+		{GatewayId: gateway.Marshal(), ErrorType: "new", Error: "new",
+			Timestamp: now},
+		{Network: "testnet", GatewayId: gateway.Marshal(), ErrorType: "old",
+			Error: "old", Timestamp: now.Add(-2 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("Unable to insert client errors: %+v", err)
+	}
+
+	deleted, err := d.DeleteClientErrors("", now.Add(-time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("Unexpected return from DeleteClientErrors: %d %+v",
+			deleted, err)
+	}
+
+	clientErrors, err := d.GetClientErrors("", nil, time.Time{})
+	if err != nil || len(clientErrors) != 1 ||
+		clientErrors[0].ErrorType != "new" {
+		t.Errorf("Unexpected client errors left: %+v %+v", clientErrors, err)
+	}
+	clientErrors, err = d.GetClientErrors("testnet", nil, time.Time{})
+	if err != nil || len(clientErrors) != 1 {
+		t.Errorf("Client errors of another network deleted: %+v %+v",
+			clientErrors, err)
+	}
+}
+
 // Happy path
 func TestDatabaseImpl_InsertEphemeralLength(t *testing.T) {
 	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_InsertEphemeralLength", "", "")
@@ -551,7 +740,7 @@ func TestDatabaseImpl_GetEarliestRound(t *testing.T) {
 	}()
 
 	cutoff := 20 * time.Minute
-	roundId, _, err := d.GetEarliestRound(cutoff)
+	roundId, _, err := d.GetEarliestRound("", cutoff)
 	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) || int(roundId) != 0 {
 		t.Errorf("Invalid return for empty roundMetrics: (%d) %+v", roundId, err)
 	}
@@ -583,6 +772,16 @@ func TestDatabaseImpl_GetEarliestRound(t *testing.T) {
 			RoundEnd:      time.Now(),
 			BatchSize:     420,
 		},
+		{
+			Id:            4,
+			PrecompStart:  time.Now(),
+			PrecompEnd:    time.Now(),
+			RealtimeStart: time.Now(),
+			RealtimeEnd:   time.Now().Add(-15 * time.Minute),
+			RoundEnd:      time.Now(),
+			BatchSize:     420,
+			Network:       "testnet",
+		},
 	}
 	newTopology := make([][]byte, 1)
 	for i := 0; i < len(newTopology); i++ {
@@ -603,10 +802,156 @@ func TestDatabaseImpl_GetEarliestRound(t *testing.T) {
 		}
 	}
 
-	roundId, _, err = d.GetEarliestRound(cutoff)
+	roundId, _, err = d.GetEarliestRound("", cutoff)
 	if err != nil || uint64(roundId) != 3 {
 		t.Errorf("Invalid return for GetEarliestRound: %d %+v", roundId, err)
 	}
+
+	// Rounds on other networks are not returned
+	roundId, _, err = d.GetEarliestRound("testnet", cutoff)
+	if err != nil || uint64(roundId) != 4 {
+		t.Errorf("Invalid return for GetEarliestRound on testnet: %d %+v",
+			roundId, err)
+	}
+}
+
+// Tests that CountRoundMetricsSince only counts rounds of the network which
+// ended after the given time.
+func TestDatabaseImpl_CountRoundMetricsSince(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_CountRoundMetricsSince", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	nid := id.NewIdFromString("count", id.Node, t)
+	err = d.InsertApplication(&Application{Id: 1}, &Node{Code: "TEST", Id: nid.Bytes()})
+	if err != nil {
+		t.Fatalf("Failed to insert node for test: %+v", err)
+	}
+
+	now := time.Now()
+	metrics := []*RoundMetric{
+		{Id: 1, RoundEnd: now.Add(-time.Hour)},
+		{Id: 2, RoundEnd: now.Add(-time.Minute)},
+		{Id: 3, RoundEnd: now},
+		{Id: 4, RoundEnd: now, Network: "testnet"},
+	}
+	for _, metric := range metrics {
+		err = d.InsertRoundMetric(metric, [][]byte{nid.Bytes()})
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	count, err := d.CountRoundMetricsSince("", now.Add(-10*time.Minute))
+	if err != nil || count != 2 {
+		t.Errorf("Invalid return for CountRoundMetricsSince: %d %+v", count, err)
+	}
+	count, err = d.CountRoundMetricsSince("testnet", now.Add(-10*time.Minute))
+	if err != nil || count != 1 {
+		t.Errorf("Invalid return for CountRoundMetricsSince on testnet: %d %+v",
+			count, err)
+	}
+}
+
+// Tests that GetRoundMetricsSince only returns rounds of the network whose
+// realtime ended after the given time, with their topologies.
+func TestDatabaseImpl_GetRoundMetricsSince(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetRoundMetricsSince", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	nid := id.NewIdFromString("since", id.Node, t)
+	err = d.InsertApplication(&Application{Id: 1}, &Node{Code: "TEST", Id: nid.Bytes()})
+	if err != nil {
+		t.Fatalf("Failed to insert node for test: %+v", err)
+	}
+
+	now := time.Now()
+	metrics := []*RoundMetric{
+		{Id: 1, RealtimeEnd: now.Add(-time.Hour), RoundEnd: now},
+		{Id: 2, RealtimeEnd: now, RoundEnd: now},
+		{Id: 3, RealtimeEnd: now, RoundEnd: now, Network: "testnet"},
+	}
+	for _, metric := range metrics {
+		err = d.InsertRoundMetric(metric, [][]byte{nid.Bytes()})
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	for network, expected := range map[string]uint64{"": 2, "testnet": 3} {
+		result, err := d.GetRoundMetricsSince(network, now.Add(-time.Minute), 10)
+		if err != nil || len(result) != 1 || result[0].Id != expected ||
+			len(result[0].Topologies) != 1 {
+			t.Errorf("Invalid return for GetRoundMetricsSince on %q: %+v %+v",
+				network, result, err)
+		}
+	}
+
+	result, err := d.GetRoundMetricsSince("", now.Add(-2*time.Hour), 1)
+	if err != nil || len(result) != 1 || result[0].Id != 1 {
+		t.Errorf("GetRoundMetricsSince did not return the oldest round within "+
+			"the limit: %+v %+v", result, err)
+	}
+}
+
+// Tests that GetCompletedRoundMetrics returns the most recent completed rounds
+// of the network, oldest first.
+func TestDatabaseImpl_GetCompletedRoundMetrics(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_GetCompletedRoundMetrics", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	nid := id.NewIdFromString("completed", id.Node, t)
+	err = d.InsertApplication(&Application{Id: 1}, &Node{Code: "TEST", Id: nid.Bytes()})
+	if err != nil {
+		t.Fatalf("Failed to insert node for test: %+v", err)
+	}
+
+	now := time.Now()
+	failed := time.Unix(0, 0)
+	metrics := []*RoundMetric{
+		{Id: 1, RealtimeStart: now.Add(-3 * time.Hour), RealtimeEnd: now.Add(-3 * time.Hour).Add(time.Second)},
+		{Id: 2, RealtimeStart: now.Add(-2 * time.Hour), RealtimeEnd: now.Add(-2 * time.Hour).Add(time.Second)},
+		{Id: 3, RealtimeStart: now.Add(-time.Hour), RealtimeEnd: now.Add(-time.Hour).Add(time.Second)},
+		{Id: 4, RealtimeStart: now, RealtimeEnd: failed},
+		{Id: 5, RealtimeStart: now, RealtimeEnd: now.Add(time.Second), Network: "testnet"},
+	}
+	for _, metric := range metrics {
+		metric.RoundEnd = now
+		err = d.InsertRoundMetric(metric, [][]byte{nid.Bytes()})
+		if err != nil {
+			t.Fatalf("Failed to insert round metric: %+v", err)
+		}
+	}
+
+	result, err := d.GetCompletedRoundMetrics("", 2)
+	if err != nil || len(result) != 2 || result[0].Id != 2 || result[1].Id != 3 ||
+		len(result[0].Topologies) != 1 {
+		t.Errorf("Invalid return for GetCompletedRoundMetrics: %+v %+v",
+			result, err)
+	}
 }
 
 // Test error path to ensure error message stays consistent
@@ -695,3 +1040,161 @@ func TestDatabaseImpl_GetBin(t *testing.T) {
 	}
 
 }
+
+// Tests that UpsertLatencyLinks inserts new links and replaces existing ones.
+func TestDatabaseImpl_UpsertLatencyLinks(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpsertLatencyLinks", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	link := &LatencyLink{FromBin: 1, ToBin: 2, Latency: time.Second,
+		Samples: 1, LastUpdated: time.Now()}
+	err = d.UpsertLatencyLinks([]*LatencyLink{link})
+	if err != nil {
+		t.Fatalf("Failed to insert links: %+v", err)
+	}
+
+	link.Latency = 2 * time.Second
+	link.Samples = 2
+	err = d.UpsertLatencyLinks([]*LatencyLink{link,
+		{FromBin: 2, ToBin: 1, Latency: time.Second, Samples: 1},
+		{FromBin: 1, ToBin: 2, Network: "testnet", Latency: time.Second,
+			Samples: 1}})
+	if err != nil {
+		t.Fatalf("Failed to upsert links: %+v", err)
+	}
+
+	links, err := d.GetLatencyLinks("")
+	if err != nil {
+		t.Fatalf("Failed to get links: %+v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("Expected 2 links, received %d", len(links))
+	}
+	for _, received := range links {
+		if received.FromBin == 1 && (received.Latency != 2*time.Second ||
+			received.Samples != 2) {
+			t.Errorf("Link not replaced: %+v", received)
+		}
+	}
+
+	links, err = d.GetLatencyLinks("testnet")
+	if err != nil || len(links) != 1 || links[0].Latency != time.Second {
+		t.Errorf("Links of another network not kept apart: %+v %+v",
+			links, err)
+	}
+}
+
+// Tests that UpsertNodeReputations inserts new reputations and replaces
+// existing ones.
+func TestDatabaseImpl_UpsertNodeReputations(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpsertNodeReputations", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	nid := id.NewIdFromString("node", id.Node, t)
+	reputation := &NodeReputation{NodeId: nid.Marshal(), Completed: 1,
+		FirstSeen: time.Now(), LastUpdated: time.Now()}
+	err = d.UpsertNodeReputations([]*NodeReputation{reputation})
+	if err != nil {
+		t.Fatalf("Failed to insert reputations: %+v", err)
+	}
+
+	reputation.Completed = 2
+	reputation.Timeouts = 1
+	other := id.NewIdFromString("other", id.Node, t)
+	otherNetwork := id.NewIdFromString("testnet", id.Node, t)
+	err = d.UpsertNodeReputations([]*NodeReputation{reputation,
+		{NodeId: other.Marshal(), Failed: 1},
+		{NodeId: otherNetwork.Marshal(), Network: "testnet", Failed: 1}})
+	if err != nil {
+		t.Fatalf("Failed to upsert reputations: %+v", err)
+	}
+
+	reputations, err := d.GetNodeReputations("")
+	if err != nil {
+		t.Fatalf("Failed to get reputations: %+v", err)
+	}
+	if len(reputations) != 2 {
+		t.Fatalf("Expected 2 reputations, received %d", len(reputations))
+	}
+	for _, received := range reputations {
+		if bytes.Equal(received.NodeId, nid.Marshal()) &&
+			(received.Completed != 2 || received.Timeouts != 1) {
+			t.Errorf("Reputation not replaced: %+v", received)
+		}
+	}
+
+	reputations, err = d.GetNodeReputations("testnet")
+	if err != nil || len(reputations) != 1 ||
+		!bytes.Equal(reputations[0].NodeId, otherNetwork.Marshal()) {
+		t.Errorf("Reputations of another network not kept apart: %+v %+v",
+			reputations, err)
+	}
+}
+
+// Tests that whitelist entries are replaced on upsert, that deleting a missing
+// entry returns gorm.ErrRecordNotFound, and that networks are kept apart.
+func TestDatabaseImpl_UpsertWhitelistEntry(t *testing.T) {
+	d, dc, err := NewDatabase("", "", "TestDatabaseImpl_UpsertWhitelistEntry", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := dc()
+		if err != nil {
+			t.Errorf("Failed to close database: %+v", err)
+		}
+	}()
+
+	entry := &WhitelistEntry{Value: "10.0.0.1", Type: WhitelistIp,
+		LastUpdated: time.Now()}
+	if err = d.UpsertWhitelistEntry(entry); err != nil {
+		t.Fatalf("Failed to insert entry: %+v", err)
+	}
+	entry.Note = "updated"
+	if err = d.UpsertWhitelistEntry(entry); err != nil {
+		t.Fatalf("Failed to upsert entry: %+v", err)
+	}
+	err = d.UpsertWhitelistEntry(&WhitelistEntry{Value: entry.Value,
+		Network: "testnet", Type: WhitelistIp, LastUpdated: time.Now()})
+	if err != nil {
+		t.Fatalf("Failed to insert entry of another network: %+v", err)
+	}
+
+	entries, err := d.GetWhitelistEntries("")
+	if err != nil {
+		t.Fatalf("Failed to get entries: %+v", err)
+	}
+	if len(entries) != 1 || entries[0].Note != "updated" {
+		t.Errorf("Entry not replaced: %+v", entries)
+	}
+
+	if err = d.DeleteWhitelistEntry("", entry.Value); err != nil {
+		t.Errorf("Failed to delete entry: %+v", err)
+	}
+	if err = d.DeleteWhitelistEntry("", entry.Value); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Unexpected error deleting missing entry."+
+			"\nexpected: %v\nreceived: %v", gorm.ErrRecordNotFound, err)
+	}
+
+	entries, err = d.GetWhitelistEntries("testnet")
+	if err != nil || len(entries) != 1 || entries[0].Note != "" {
+		t.Errorf("Entry of another network not kept apart: %+v %+v",
+			entries, err)
+	}
+}
@@ -55,6 +55,14 @@ func (rsm *StateMap) GetRound(id id.Round) (*State, bool) {
 	return s, exists
 }
 
+// Len returns the number of rounds in the map, which are the rounds that have
+// not yet completed or failed
+func (rsm *StateMap) Len() int {
+	rsm.mux.RLock()
+	defer rsm.mux.RUnlock()
+	return len(rsm.rounds)
+}
+
 // add a schedule to delete timestamp
 
 // DeleteRound cleans out rounds from round map.
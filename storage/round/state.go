@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	jww "github.com/spf13/jwalterweatherman"
 	pb "gitlab.com/elixxir/comms/mixmessages"
+	"gitlab.com/elixxir/primitives/current"
 	"gitlab.com/elixxir/primitives/states"
 	"gitlab.com/xx_network/comms/connect"
 	"gitlab.com/xx_network/primitives/id"
@@ -20,6 +21,20 @@ import (
 	"time"
 )
 
+// Blame attributes the failure of a round to a member of its team.
+type Blame struct {
+	// ID of the member
+	NodeID *id.ID
+	// Why the member is blamed
+	Reason string
+	// Last activity the member reported
+	Activity current.Activity
+	// Time the member last changed activity
+	LastUpdate time.Time
+	// Time the member last polled
+	LastPoll time.Time
+}
+
 // Tracks the current global state of a round
 type State struct {
 	// round info to be used to produce new round infos
@@ -34,16 +49,25 @@ type State struct {
 	// Number of nodes ready for the next transition
 	readyForTransition uint8
 
+	// Number of nodes which have left their resource queue and begun
+	// precomputing
+	precomputingNodes uint8
+
 	// List of round errors received from nodes
 	roundErrors []*pb.RoundError
 
 	// List of client errors received from nodes
 	clientErrors []*pb.ClientError
 
-	roundComplete chan struct{}
+	// Members the failure of the round is attributed to, once attributed
+	blame  []Blame
+	blamed bool
 
 	lastUpdate time.Time
 
+	// Name of the class of round, which sets its sizes and timeouts
+	class string
+
 	// Keep track of the ns timestamp when the last node in the round reported completed
 	// in order to get better granularity for when realtime finished
 	realtimeCompletedTs int64
@@ -64,8 +88,6 @@ func newState(id id.Round, batchsize, addressSpaceSize uint32, resourceQueueTime
 	timestamps := make([]uint64, states.NUM_STATES)
 	timestamps[states.PENDING] = uint64(pendingTs.Unix())
 
-	roundCompleteChan := make(chan struct{}, 1)
-
 	//build and return the round state object
 	return &State{
 		base: &pb.RoundInfo{
@@ -82,7 +104,6 @@ func newState(id id.Round, batchsize, addressSpaceSize uint32, resourceQueueTime
 		state:              states.PENDING,
 		readyForTransition: 0,
 		mux:                sync.RWMutex{},
-		roundComplete:      roundCompleteChan,
 	}
 }
 
@@ -92,8 +113,6 @@ func NewState_Testing(id id.Round, state states.Round, topology *connect.Circuit
 		jww.FATAL.Panic("Only for testing")
 	}
 
-	roundCompleteChan := make(chan struct{}, 1000)
-
 	//build and return the round state object
 	return &State{
 		base: &pb.RoundInfo{
@@ -103,7 +122,6 @@ func NewState_Testing(id id.Round, state states.Round, topology *connect.Circuit
 		state:              state,
 		readyForTransition: 0,
 		mux:                sync.RWMutex{},
-		roundComplete:      roundCompleteChan,
 		topology:           topology,
 	}
 }
@@ -122,6 +140,16 @@ func (s *State) NodeIsReadyForTransition() bool {
 	return false
 }
 
+// NodeBeganPrecomputing counts a node as having left its resource queue and
+// begun precomputing. Returns true when the last node of the topology does.
+func (s *State) NodeBeganPrecomputing() bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.precomputingNodes++
+	return int(s.precomputingNodes) == s.topology.Len()
+}
+
 // updates the round to a new state. states can only move forward, they cannot
 // go in reverse or replace the same state
 func (s *State) Update(state states.Round, stamp time.Time) error {
@@ -180,6 +208,20 @@ func (s *State) GetRoundID() id.Round {
 	return rid
 }
 
+// GetClass returns the name of the round's class.
+func (s *State) GetClass() string {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.class
+}
+
+// SetClass sets the name of the round's class.
+func (s *State) SetClass(class string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.class = class
+}
+
 // Return firstCompletedTs
 func (s *State) GetRealtimeCompletedTs() int64 {
 	return s.realtimeCompletedTs
@@ -204,6 +246,28 @@ func (s *State) AppendError(roundError *pb.RoundError) {
 	s.roundErrors = append(s.roundErrors, roundError)
 }
 
+// GetBlame returns the members the failure of the round is attributed to, and
+// whether it has been attributed.
+func (s *State) GetBlame() ([]Blame, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	return s.blame, s.blamed
+}
+
+// SetBlame attributes the failure of the round to the members, which may be
+// none. Only the first attribution is kept; returns false if the round's
+// failure was already attributed.
+func (s *State) SetBlame(blame []Blame) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.blamed {
+		return false
+	}
+	s.blame = blame
+	s.blamed = true
+	return true
+}
+
 // Append a round error to our list of stored rounderrors
 func (s *State) AppendClientErrors(clientErrors []*pb.ClientError) {
 	s.mux.Lock()
@@ -211,17 +275,3 @@ func (s *State) AppendClientErrors(clientErrors []*pb.ClientError) {
 
 	s.clientErrors = append(s.clientErrors, clientErrors...)
 }
-
-// returns the channel used to stop the round timeout
-func (s *State) GetRoundCompletedChan() <-chan struct{} {
-	return s.roundComplete
-}
-
-// DenoteRoundCompleted sends on the round complete channel to the
-// timeout thread to notify it that the round has completed
-func (s *State) DenoteRoundCompleted() {
-	select {
-	case s.roundComplete <- struct{}{}:
-	default:
-	}
-}
@@ -157,6 +157,23 @@ func TestState_NodeIsReadyForTransition(t *testing.T) {
 	}
 }
 
+// Tests that NodeBeganPrecomputing returns true only for the last node of the
+// topology, without affecting the transition counter.
+func TestState_NodeBeganPrecomputing(t *testing.T) {
+	const numNodes = 3
+	topology := buildMockTopology(numNodes, t)
+	ns := newState(42, 32, 8, 5*time.Minute, topology, time.Now())
+
+	for i := 0; i < numNodes; i++ {
+		if began := ns.NodeBeganPrecomputing(); began != (i == numNodes-1) {
+			t.Errorf("Unexpected result for node %d: %t", i, began)
+		}
+	}
+	if ns.readyForTransition != 0 {
+		t.Errorf("Transition counter changed: %d", ns.readyForTransition)
+	}
+}
+
 //test the state update increments properly when given a valid input
 func TestState_Update_Forward(t *testing.T) {
 	rid := id.Round(42)
@@ -377,3 +394,40 @@ func TestState_GetTopology(t *testing.T) {
 		t.Errorf("retruned topology did not match passed topology")
 	}
 }
+
+//tests that SetClass sets the class returned by GetClass
+func TestState_GetClass(t *testing.T) {
+	rs := State{}
+
+	if rs.GetClass() != "" {
+		t.Errorf("new round has class %q", rs.GetClass())
+	}
+
+	rs.SetClass("large")
+
+	if rs.GetClass() != "large" {
+		t.Errorf("returned class %q did not match set class", rs.GetClass())
+	}
+}
+
+//tests that only the first blame set on the round is kept
+func TestState_SetBlame(t *testing.T) {
+	rs := State{}
+
+	if _, blamed := rs.GetBlame(); blamed {
+		t.Errorf("new round is blamed")
+	}
+
+	nid := id.NewIdFromUInt(0, id.Node, t)
+	if !rs.SetBlame([]Blame{{NodeID: nid, Reason: "behind"}}) {
+		t.Errorf("failed to set blame")
+	}
+	if rs.SetBlame(nil) {
+		t.Errorf("blame replaced")
+	}
+
+	blame, blamed := rs.GetBlame()
+	if !blamed || len(blame) != 1 || !blame[0].NodeID.Cmp(nid) {
+		t.Errorf("returned blame %+v did not match set blame", blame)
+	}
+}
@@ -47,7 +47,14 @@ type NetworkState struct {
 	rounds       *round.StateMap
 	roundUpdates *dataStructures.Updates
 	roundData    *dataStructures.Data
-	update       chan node.UpdateNotification // For triggering updates to top level
+	update       *nodeUpdateQueue // For triggering updates to top level
+
+	// Round updates which have been issued but not yet added to roundUpdates
+	pendingRoundUpdates sync.WaitGroup
+
+	// Channels signalled when round updates are added to roundUpdates
+	roundUpdateSubs    map[chan struct{}]struct{}
+	roundUpdateSubsMux sync.Mutex
 
 	// Node NetworkState
 	nodes     *node.StateMap
@@ -56,8 +63,17 @@ type NetworkState struct {
 	// List of states of Nodes to be disabled
 	disabledNodesStates *disabledNodes
 
+	// Set of Nodes which may be scheduled; nil if every Node may be
+	eligibleNodes    map[id.ID]bool
+	eligibleNodesMux sync.RWMutex
+
 	// Keep track of Country -> Bin mapping
-	geoBins map[string]region.GeoBin
+	geoBins    map[string]region.GeoBin
+	geoBinsMux sync.RWMutex
+
+	// GeoBin latency table used for team ordering
+	latencyTable [12][12]int
+	latencyMux   sync.RWMutex
 
 	// NDF state
 	InternalNdfLock sync.RWMutex
@@ -86,12 +102,33 @@ type NetworkState struct {
 	// round states
 	roundID  id.Round
 	updateID uint64
+
+	// Last round ID the network may use; zero if it is unbounded
+	lastRoundID id.Round
+
+	// Name of the network the state tracks; empty for the primary network
+	network string
 }
 
-// NewState returns a new NetworkState object.
+// NewState returns a new NetworkState object for the primary network.
 func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 	fullNdfOutputPath string, signedPartialNdfOutputPath string,
 	geoBins map[string]region.GeoBin) (*NetworkState, error) {
+	return NewNetworkState("", 1, 0, rsaPrivKey, addressSpaceSize,
+		fullNdfOutputPath, signedPartialNdfOutputPath, geoBins)
+}
+
+// NewNetworkState returns a new NetworkState object for the named network.
+// Its round ID, update ID, and elliptic key are kept under their own keys in
+// the State table and, if the network has no stored round ID, rounds start at
+// firstRoundID. Round IDs share a table with every other network, so each
+// network is given a range from firstRoundID to lastRoundID, or without end if
+// lastRoundID is zero, which must not overlap with the others. An error is
+// returned if the stored round ID is outside of the range.
+func NewNetworkState(network string, firstRoundID, lastRoundID id.Round,
+	rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
+	fullNdfOutputPath string, signedPartialNdfOutputPath string,
+	geoBins map[string]region.GeoBin) (*NetworkState, error) {
 
 	fullNdf, err := dataStructures.NewNdf(&ndf.NetworkDefinition{})
 	if err != nil {
@@ -105,7 +142,8 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 	state := &NetworkState{
 		rounds:                     round.NewStateMap(),
 		roundUpdates:               dataStructures.NewUpdates(),
-		update:                     make(chan node.UpdateNotification, updateBufferLength),
+		roundUpdateSubs:            make(map[chan struct{}]struct{}),
+		update:                     newNodeUpdateQueue(updateBufferLength),
 		nodes:                      node.NewStateMap(),
 		fullNdf:                    fullNdf,
 		partialNdf:                 partialNdf,
@@ -117,6 +155,9 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 		signedPartialNdfOutputPath: signedPartialNdfOutputPath,
 		roundUpdatesToAddCh:        make(chan *dataStructures.Round, 500),
 		geoBins:                    geoBins,
+		latencyTable:               region.CreateSetLatencyTableWeights(region.CreateLinkTable()),
+		lastRoundID:                lastRoundID,
+		network:                    network,
 	}
 
 	//begin the thread that reads and adds round updates
@@ -134,6 +175,12 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 		!strings.Contains(err.Error(), gorm.ErrRecordNotFound.Error()) {
 		return nil, err
 	}
+	if state.roundID != 0 && (state.roundID < firstRoundID ||
+		(lastRoundID != 0 && state.roundID > lastRoundID+1)) {
+		return nil, errors.Errorf("stored round ID %d is outside of the "+
+			"range %d to %d of network %q", state.roundID, firstRoundID,
+			lastRoundID, network)
+	}
 
 	ellipticKey, err := state.getEcKey()
 	if err != nil &&
@@ -182,9 +229,9 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 		}
 	}
 	if state.roundID == 0 {
-		state.roundID = 1
-		// Set round Id to start at 1
-		err = state.setId(RoundIdKey, 1)
+		state.roundID = firstRoundID
+		// Set round Id to start at the network's first round
+		err = state.setId(RoundIdKey, uint64(firstRoundID))
 		if err != nil {
 			return nil, err
 		}
@@ -193,6 +240,26 @@ func NewState(rsaPrivKey *rsa.PrivateKey, addressSpaceSize uint32,
 	return state, nil
 }
 
+// GetNetwork returns the name of the network; empty for the primary network.
+func (s *NetworkState) GetNetwork() string {
+	return s.network
+}
+
+// stateKey returns the key in the State table for the network. The primary
+// network uses the key as is.
+func (s *NetworkState) stateKey(key string) string {
+	if s.network == "" {
+		return key
+	}
+	return key + "/" + s.network
+}
+
+// GetStateKey returns the key in the State table under which the network keeps
+// the value of the given key.
+func (s *NetworkState) GetStateKey(key string) string {
+	return s.stateKey(key)
+}
+
 // CountActiveNodes returns a count of active nodes in the state
 func (s *NetworkState) CountActiveNodes() int {
 	s.pruneListMux.Lock()
@@ -269,11 +336,6 @@ func (s *NetworkState) GetPartialNdf() *dataStructures.Ndf {
 	return s.partialNdf
 }
 
-// GetGeoBin returns the GeoBin map.
-func (s *NetworkState) GetGeoBins() map[string]region.GeoBin {
-	return s.geoBins
-}
-
 // GetUpdates returns all of the updates after the given ID.
 func (s *NetworkState) GetUpdates(id int) ([]*pb.RoundInfo, error) {
 	return s.roundUpdates.GetUpdates(id), nil
@@ -293,6 +355,7 @@ func (s *NetworkState) AddRoundUpdate(r *pb.RoundInfo) error {
 
 	roundCopy.UpdateID = updateID
 
+	s.pendingRoundUpdates.Add(1)
 	go func() {
 		err = signature.SignRsa(roundCopy, s.rsaPrivateKey)
 		if err != nil {
@@ -344,6 +407,8 @@ func (s *NetworkState) RoundAdderRoutine() {
 			if err != nil {
 				jww.FATAL.Panicf("%+v", err)
 			}
+			s.pendingRoundUpdates.Done()
+			s.notifyRoundUpdateSubscribers()
 			continue
 		}
 
@@ -361,10 +426,68 @@ func (s *NetworkState) RoundAdderRoutine() {
 			if err != nil {
 				jww.FATAL.Panicf("%+v", err)
 			}
+			s.pendingRoundUpdates.Done()
 			// Clean up processed round
 			delete(futureRoundUpdates, nextID)
 			nextID++
 		}
+		s.notifyRoundUpdateSubscribers()
+	}
+}
+
+// GetLastUpdateID returns the ID of the newest round update returned by
+// GetUpdates, or -1 if there are none.
+func (s *NetworkState) GetLastUpdateID() int {
+	return s.roundUpdates.GetLastUpdateID()
+}
+
+// SubscribeRoundUpdates returns a channel which is signalled when round
+// updates are added to those returned by GetUpdates, and a function which
+// unsubscribes it. Signals are not queued; a subscriber which is busy receives
+// a single signal for every update added in the meantime and must retrieve
+// them all with GetUpdates, so a slow subscriber never blocks round updates.
+func (s *NetworkState) SubscribeRoundUpdates() (<-chan struct{}, func()) {
+	c := make(chan struct{}, 1)
+
+	s.roundUpdateSubsMux.Lock()
+	s.roundUpdateSubs[c] = struct{}{}
+	s.roundUpdateSubsMux.Unlock()
+
+	return c, func() {
+		s.roundUpdateSubsMux.Lock()
+		delete(s.roundUpdateSubs, c)
+		s.roundUpdateSubsMux.Unlock()
+	}
+}
+
+// notifyRoundUpdateSubscribers signals every subscriber which has not yet
+// been signalled.
+func (s *NetworkState) notifyRoundUpdateSubscribers() {
+	s.roundUpdateSubsMux.Lock()
+	defer s.roundUpdateSubsMux.Unlock()
+	for c := range s.roundUpdateSubs {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// FlushRoundUpdates waits for every issued round update to be signed and added
+// to the round updates served to nodes and gateways, or for the timeout to
+// elapse. Returns true if all updates were added.
+func (s *NetworkState) FlushRoundUpdates(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.pendingRoundUpdates.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
 }
 
@@ -413,6 +536,10 @@ func (s *NetworkState) UpdateOutputNdf() (err error) {
 			} else {
 				newNdf.Nodes[i].Status = ndf.Stale
 			}
+		} else if n := s.nodes.GetNode(nid); n != nil && !n.IsSchedulable() {
+			// Draining and ineligible nodes stay in the NDF but are marked as
+			// stale so that clients and gateways stop relying on them
+			newNdf.Nodes[i].Status = ndf.Stale
 		} else {
 			newNdf.Nodes[i].Status = ndf.Active
 		}
@@ -515,26 +642,29 @@ func (s *NetworkState) SetAddressSpaceSize(size uint32) {
 	atomic.StoreUint32(s.addressSpaceSize, size)
 }
 
-// NodeUpdateNotification sends a notification to the control thread of an
-// update to a nodes state.
-func (s *NetworkState) SendUpdateNotification(nun node.UpdateNotification) error {
-	select {
-	case s.update <- nun:
-		return nil
-	default:
-		return errors.New("Could not send update notification")
-	}
+// SendUpdateNotification sends a notification to the control thread of an
+// update to a nodes state. It never blocks and the notification is never
+// dropped; if the channel is full it is delivered once the control thread
+// catches up.
+func (s *NetworkState) SendUpdateNotification(nun node.UpdateNotification) {
+	s.update.send(nun)
 }
 
 // GetNodeUpdateChannel returns a channel to receive node updates on.
 func (s *NetworkState) GetNodeUpdateChannel() <-chan node.UpdateNotification {
-	return s.update
+	return s.update.c
+}
+
+// GetNodeUpdateQueueStats returns the depth of the queue of node updates
+// waiting for the control thread.
+func (s *NetworkState) GetNodeUpdateQueueStats() NodeUpdateQueueStats {
+	return s.update.stats()
 }
 
 // Helper to set the roundId or updateId value
 func (s *NetworkState) setId(key string, newVal uint64) error {
 	err := PermissioningDb.UpsertState(&State{
-		Key:   key,
+		Key:   s.stateKey(key),
 		Value: strconv.FormatUint(newVal, 10),
 	})
 	if err != nil {
@@ -545,7 +675,7 @@ func (s *NetworkState) setId(key string, newVal uint64) error {
 
 // Helper to return the RoundId or UpdateId depending on the given key
 func (s *NetworkState) get(key string) (uint64, error) {
-	roundIdStr, err := PermissioningDb.GetStateValue(key)
+	roundIdStr, err := PermissioningDb.GetStateValue(s.stateKey(key))
 	if err != nil {
 		return 0, errors.Errorf("Unable to obtain current %s: %+v", key, err)
 	}
@@ -559,7 +689,7 @@ func (s *NetworkState) get(key string) (uint64, error) {
 
 // Helper to return the RoundId or UpdateId depending on the given key
 func (s *NetworkState) getEcKey() (string, error) {
-	ellipticKey, err := PermissioningDb.GetStateValue(EllipticKey)
+	ellipticKey, err := PermissioningDb.GetStateValue(s.stateKey(EllipticKey))
 	if err != nil {
 		return "", errors.Errorf("Unable to obtain current %s: %+v", EllipticKey, err)
 	}
@@ -570,7 +700,7 @@ func (s *NetworkState) getEcKey() (string, error) {
 // Helper to set the elliptic key into the state table
 func (s *NetworkState) storeEcKey(newVal string) error {
 	err := PermissioningDb.UpsertState(&State{
-		Key:   EllipticKey,
+		Key:   s.stateKey(EllipticKey),
 		Value: newVal,
 	})
 	if err != nil {
@@ -579,10 +709,15 @@ func (s *NetworkState) storeEcKey(newVal string) error {
 	return nil
 }
 
-// IncrementRoundID increments the round ID
+// IncrementRoundID increments the round ID. Returns an error once every round
+// ID in the network's range has been used.
 // THIS IS NOT THREAD SAFE. IT IS INTENDED TO ONLY BE CALLED BY THE SERIAL
 // SCHEDULING THREAD
 func (s *NetworkState) IncrementRoundID() (id.Round, error) {
+	if s.lastRoundID != 0 && s.roundID > s.lastRoundID {
+		return 0, errors.Errorf("network %q has used every round ID up to "+
+			"%d", s.network, s.lastRoundID)
+	}
 	oldRoundID := s.roundID
 	s.roundID = s.roundID + 1
 	return oldRoundID, s.setId(RoundIdKey, uint64(s.roundID))
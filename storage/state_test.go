@@ -30,7 +30,6 @@ import (
 	mrand "math/rand"
 	"os"
 	"reflect"
-	"strings"
 	"testing"
 	"time"
 )
@@ -108,6 +107,94 @@ func TestNewState(t *testing.T) {
 	}
 }
 
+// Tests that NewNetworkState keeps the round and update IDs of each network
+// under separate keys and starts a new network at its first round ID.
+func TestNewNetworkState(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "TestNewNetworkState", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("Failed to generate private key: %+v", err)
+	}
+
+	primary, err := NewState(privateKey, 8, "", "", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create primary state: %+v", err)
+	}
+	testnet, err := NewNetworkState("testnet", 1000, 0, privateKey, 8, "", "",
+		region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to create testnet state: %+v", err)
+	}
+
+	if primary.GetNetwork() != "" || testnet.GetNetwork() != "testnet" {
+		t.Errorf("Unexpected networks: %q and %q", primary.GetNetwork(),
+			testnet.GetNetwork())
+	}
+
+	if _, err = primary.IncrementRoundID(); err != nil {
+		t.Fatalf("Failed to increment round ID: %+v", err)
+	}
+
+	primaryRound, err := primary.GetRoundID()
+	if err != nil || primaryRound != 2 {
+		t.Errorf("Unexpected primary round ID %d: %+v", primaryRound, err)
+	}
+	testnetRound, err := testnet.GetRoundID()
+	if err != nil || testnetRound != 1000 {
+		t.Errorf("Unexpected testnet round ID %d: %+v", testnetRound, err)
+	}
+
+	value, err := PermissioningDb.GetStateValue(RoundIdKey + "/testnet")
+	if err != nil || value != "1000" {
+		t.Errorf("Testnet round ID not stored under its own key: %q %+v",
+			value, err)
+	}
+
+	if bytes.Equal(primary.GetEllipticPublicKey().Marshal(),
+		testnet.GetEllipticPublicKey().Marshal()) {
+		t.Errorf("Networks share an elliptic key.")
+	}
+
+	// A restarted network resumes from its stored round ID
+	testnet, err = NewNetworkState("testnet", 1000, 1001, privateKey, 8, "",
+		"", region.GetCountryBins())
+	if err != nil {
+		t.Fatalf("Failed to recreate testnet state: %+v", err)
+	}
+	if testnet.roundID != 1000 {
+		t.Errorf("Restarted network did not resume from its round ID."+
+			"\nexpected: %d\nreceived: %d", 1000, testnet.roundID)
+	}
+
+	// Round IDs past the end of the range are not given out
+	for _, expected := range []id.Round{1000, 1001} {
+		roundID, err := testnet.IncrementRoundID()
+		if err != nil || roundID != expected {
+			t.Errorf("Unexpected round ID %d: %+v", roundID, err)
+		}
+	}
+	if _, err = testnet.IncrementRoundID(); err == nil {
+		t.Errorf("Expected error for round ID past the end of the range.")
+	}
+
+	// A stored round ID outside of the network's range is rejected
+	_, err = NewNetworkState("testnet", 2000, 0, privateKey, 8, "", "",
+		region.GetCountryBins())
+	if err == nil {
+		t.Errorf("Expected error for stored round ID below the range.")
+	}
+	_, err = NewNetworkState("testnet", 1, 999, privateKey, 8, "", "",
+		region.GetCountryBins())
+	if err == nil {
+		t.Errorf("Expected error for stored round ID above the range.")
+	}
+}
+
 // Tests that GetFullNdf() returns the correct NDF for a newly created
 // NetworkState.
 func TestNetworkState_GetFullNdf(t *testing.T) {
@@ -255,6 +342,43 @@ func TestNetworkState_AddRoundUpdate(t *testing.T) {
 	}
 }
 
+// Tests that FlushRoundUpdates() waits for issued round updates to be added.
+func TestNetworkState_FlushRoundUpdates(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = state.AddRoundUpdate(&pb.RoundInfo{
+			ID:         uint64(i),
+			Timestamps: make([]uint64, states.FAILED),
+		})
+		if err != nil {
+			t.Fatalf("AddRoundUpdate() produced an error: %+v", err)
+		}
+	}
+
+	if !state.FlushRoundUpdates(5 * time.Second) {
+		t.Fatalf("FlushRoundUpdates() timed out.")
+	}
+
+	roundInfoArr, err := state.GetUpdates(0)
+	if err != nil {
+		t.Fatalf("GetUpdates() produced an error: %+v", err)
+	}
+	if len(roundInfoArr) != 3 {
+		t.Errorf("Expected 3 round updates after flush, found %d",
+			len(roundInfoArr))
+	}
+}
+
 // Tests that UpdateInternalNdf() updates fullNdf and partialNdf correctly.
 func TestNetworkState_UpdateOutputNdf(t *testing.T) {
 	// Expected values
@@ -345,6 +469,54 @@ func TestNetworkState_UpdateOutputNdf(t *testing.T) {
 	}
 }
 
+// Tests that UpdateOutputNdf() marks draining nodes as stale without removing
+// them from the NDF.
+func TestNetworkState_UpdateOutputNdf_Draining(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	drainingID := id.NewIdFromUInt(0, id.Node, t)
+	activeID := id.NewIdFromUInt(1, id.Node, t)
+	for _, nid := range []*id.ID{drainingID, activeID} {
+		if err = state.GetNodeMap().AddNode(nid, "", "", "", 0); err != nil {
+			t.Fatalf("Failed to add node: %+v", err)
+		}
+	}
+	if _, err = state.GetNodeMap().GetNode(drainingID).SetDraining(true); err != nil {
+		t.Fatalf("Failed to drain node: %+v", err)
+	}
+
+	state.UpdateInternalNdf(&ndf.NetworkDefinition{
+		Nodes: []ndf.Node{{ID: drainingID.Bytes()}, {ID: activeID.Bytes()}},
+		Gateways: []ndf.Gateway{
+			{ID: drainingID.Bytes()}, {ID: activeID.Bytes()}},
+	})
+	if err = state.UpdateOutputNdf(); err != nil {
+		t.Fatalf("UpdateOutputNdf() unexpectedly produced an error: %+v", err)
+	}
+
+	nodes := state.GetFullNdf().Get().Nodes
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes in the NDF, found %d", len(nodes))
+	}
+	if nodes[0].Status != ndf.Stale {
+		t.Errorf("Draining node has status %s, expected %s",
+			nodes[0].Status, ndf.Stale)
+	}
+	if nodes[1].Status != ndf.Active {
+		t.Errorf("Active node has status %s, expected %s",
+			nodes[1].Status, ndf.Active)
+	}
+}
+
 // Tests that UpdateInternalNdf() generates an error when injected with invalid private
 // key.
 func TestNetworkState_UpdateOutputNdf_SignError(t *testing.T) {
@@ -446,13 +618,7 @@ func TestNetworkState_NodeUpdateNotification(t *testing.T) {
 		t.Fatalf("%+v", err)
 	}
 
-	go func() {
-		err = state.SendUpdateNotification(testNun)
-		if err != nil {
-			t.Errorf("NodeUpdateNotification() produced an unexpected error:"+
-				"\n%+v", err)
-		}
-	}()
+	go state.SendUpdateNotification(testNun)
 
 	nodeUpdateNotifier := state.GetNodeUpdateChannel()
 
@@ -468,38 +634,44 @@ func TestNetworkState_NodeUpdateNotification(t *testing.T) {
 	}
 }
 
-// Tests that NodeUpdateNotification() correctly produces and error when the
-// channel buffer is already filled.
-func TestNetworkState_NodeUpdateNotification_Error(t *testing.T) {
-	// Test values
-	testNun := node.UpdateNotification{
-		Node:         id.NewIdFromUInt(mrand.Uint64(), id.Node, t),
-		FromActivity: current.NOT_STARTED,
-		ToActivity:   current.WAITING,
-	}
-	expectedError := errors.New("Could not send update notification")
-
+// Tests that NodeUpdateNotification() does not drop notifications sent once
+// the channel buffer is full and delivers them in order as it is read.
+func TestNetworkState_NodeUpdateNotification_Overflow(t *testing.T) {
 	// Generate new NetworkState
 	state, _, err := generateTestNetworkState()
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
 
-	// Fill buffer
-	for i := 0; i < updateBufferLength; i++ {
-		state.update <- testNun
+	// Fill buffer and overflow it
+	const overflow = 5
+	nodes := make([]*id.ID, updateBufferLength+overflow)
+	for i := range nodes {
+		nodes[i] = id.NewIdFromUInt(uint64(i), id.Node, t)
+		state.SendUpdateNotification(node.UpdateNotification{Node: nodes[i]})
 	}
 
-	go func() {
-		err = state.SendUpdateNotification(testNun)
-		if strings.Compare(err.Error(), expectedError.Error()) != 0 {
-			t.Errorf("NodeUpdateNotification() did not produce an error "+
-				"when the channel buffer is full.\n\texpected: %v\n\treceived: %v",
-				expectedError, err)
+	stats := state.GetNodeUpdateQueueStats()
+	if stats.Queued != len(nodes) || stats.Overflow != overflow ||
+		stats.PeakOverflow != overflow || stats.TotalOverflowed != overflow {
+		t.Errorf("Unexpected queue stats with a full buffer: %+v", stats)
+	}
+
+	for i := range nodes {
+		select {
+		case nun := <-state.GetNodeUpdateChannel():
+			if !nun.Node.Cmp(nodes[i]) {
+				t.Fatalf("Received notification %d out of order.", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Notification %d was not delivered.", i)
 		}
-	}()
+	}
 
-	time.Sleep(1 * time.Second)
+	stats = state.GetNodeUpdateQueueStats()
+	if stats.Queued != 0 || stats.Overflow != 0 || stats.PeakOverflow != overflow {
+		t.Errorf("Unexpected queue stats after reading: %+v", stats)
+	}
 }
 
 // generateTestNetworkState returns a newly generated NetworkState and private
@@ -523,6 +695,59 @@ func generateTestNetworkState() (*NetworkState, *rsa.PrivateKey, error) {
 	return state, privKey, nil
 }
 
+// Tests that SubscribeRoundUpdates() signals subscribers once round updates are
+// returned by GetUpdates() and stops after unsubscribing.
+func TestNetworkState_SubscribeRoundUpdates(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf("Failed to create database: %+v", err)
+	}
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	updates, unsubscribe := state.SubscribeRoundUpdates()
+	lastID := state.GetLastUpdateID()
+
+	// Several updates added while the subscriber is busy result in a single
+	// signal
+	for i := 0; i < 3; i++ {
+		err = state.AddRoundUpdate(&pb.RoundInfo{ID: uint64(i),
+			Timestamps: make([]uint64, states.FAILED)})
+		if err != nil {
+			t.Fatalf("AddRoundUpdate() produced an error: %+v", err)
+		}
+	}
+	if !state.FlushRoundUpdates(time.Second) {
+		t.Fatalf("Round updates were not added.")
+	}
+
+	select {
+	case <-updates:
+	case <-time.After(time.Second):
+		t.Fatalf("Subscriber was not signalled.")
+	}
+	received, _ := state.GetUpdates(lastID)
+	if len(received) != 3 || state.GetLastUpdateID() != int(received[2].UpdateID) {
+		t.Errorf("Unexpected updates after signal: %d", len(received))
+	}
+
+	unsubscribe()
+	err = state.AddRoundUpdate(&pb.RoundInfo{ID: 3,
+		Timestamps: make([]uint64, states.FAILED)})
+	if err != nil {
+		t.Fatalf("AddRoundUpdate() produced an error: %+v", err)
+	}
+	state.FlushRoundUpdates(time.Second)
+	select {
+	case <-updates:
+		t.Errorf("Subscriber signalled after unsubscribing.")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 // Tests that IncrementRoundID() increments the ID correctly.
 func TestNetworkState_IncrementRoundID(t *testing.T) {
 	testID := uint64(9843)
@@ -0,0 +1,319 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	jww "github.com/spf13/jwalterweatherman"
+	"gitlab.com/xx_network/primitives/id"
+	"gitlab.com/xx_network/primitives/utils"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// whitelist.go contains the whitelist of IDs and IP addresses that gateways
+// exempt from rate limiting. Entries are kept in storage and may also be listed
+// in files; the merged list is published in the NDF.
+
+// WhitelistType is the kind of value in a WhitelistEntry.
+type WhitelistType uint8
+
+const (
+	WhitelistId WhitelistType = iota
+	WhitelistIp
+)
+
+func (t WhitelistType) String() string {
+	switch t {
+	case WhitelistId:
+		return "ID"
+	case WhitelistIp:
+		return "IP"
+	default:
+		return fmt.Sprintf("UNKNOWN WHITELIST TYPE %d", uint8(t))
+	}
+}
+
+// MarshalText encodes the type as its name so that it is readable in JSON.
+func (t WhitelistType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes the type from its name.
+func (t *WhitelistType) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case WhitelistId.String():
+		*t = WhitelistId
+	case WhitelistIp.String():
+		*t = WhitelistIp
+	default:
+		return errors.Errorf("unknown whitelist type %q", text)
+	}
+	return nil
+}
+
+// ParseWhitelistValue checks that the value is a base64 encoded ID, an IP
+// address, or a CIDR block and returns its type and canonical form.
+func ParseWhitelistValue(value string) (WhitelistType, string, error) {
+	value = strings.TrimSpace(value)
+
+	if ip := net.ParseIP(value); ip != nil {
+		return WhitelistIp, ip.String(), nil
+	}
+	if _, ipNet, err := net.ParseCIDR(value); err == nil {
+		return WhitelistIp, ipNet.String(), nil
+	}
+
+	idBytes, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return 0, "", errors.Errorf("%q is not an IP address, CIDR block, "+
+			"or base64 encoded ID", value)
+	}
+	nid, err := id.Unmarshal(idBytes)
+	if err != nil {
+		return 0, "", errors.Errorf("%q is not a valid ID: %+v", value, err)
+	}
+
+	return WhitelistId, base64.StdEncoding.EncodeToString(nid.Marshal()), nil
+}
+
+// NewWhitelistEntry creates an entry of the network for the value, which is
+// checked with ParseWhitelistValue. A zero expiry never expires.
+func NewWhitelistEntry(network, value string, expiry time.Time, note string,
+	now time.Time) (*WhitelistEntry, error) {
+	t, value, err := ParseWhitelistValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if !expiry.IsZero() && !expiry.After(now) {
+		return nil, errors.Errorf("expiry %s is not in the future", expiry)
+	}
+
+	return &WhitelistEntry{
+		Value:       value,
+		Network:     network,
+		Type:        t,
+		Expiry:      expiry,
+		Note:        note,
+		LastUpdated: now,
+	}, nil
+}
+
+// IsExpired returns true if the entry has an expiry at or before now.
+func (e *WhitelistEntry) IsExpired(now time.Time) bool {
+	return !e.Expiry.IsZero() && !now.Before(e.Expiry)
+}
+
+// ParseWhitelist parses a JSON list of whitelist values which must all be of
+// the given type. Every invalid value is reported in the returned error.
+func ParseWhitelist(data []byte, t WhitelistType) ([]string, error) {
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.Errorf("could not unmarshal whitelist: %+v", err)
+	}
+
+	values := make([]string, 0, len(list))
+	var errs []string
+	for i, value := range list {
+		valueType, value, err := ParseWhitelistValue(value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("index %d: %v", i, err))
+		} else if valueType != t {
+			errs = append(errs, fmt.Sprintf("index %d: %q is an %s, "+
+				"expected an %s", i, value, valueType, t))
+		} else {
+			values = append(values, value)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Errorf("invalid whitelist entries:\n\t%s",
+			strings.Join(errs, "\n\t"))
+	}
+	return values, nil
+}
+
+// Whitelist builds the whitelist of a network from its entries in storage and
+// the optional ID and IP address files.
+type Whitelist struct {
+	network string
+	idsPath string
+	ipsPath string
+
+	// Contents of the files when they were last loaded
+	fileIds []string
+	fileIps []string
+	mux     sync.Mutex
+}
+
+// NewWhitelist creates a Whitelist of the network using the files at the paths,
+// which may be empty if not used. Errors if a file cannot be read or contains
+// an invalid entry.
+func NewWhitelist(network, idsPath, ipsPath string) (*Whitelist, error) {
+	w := &Whitelist{network: network, idsPath: idsPath, ipsPath: ipsPath}
+
+	var err error
+	w.fileIds, err = loadWhitelistFile(idsPath, WhitelistId)
+	if err != nil {
+		return nil, errors.WithMessage(err, "whitelisted IDs")
+	}
+	w.fileIps, err = loadWhitelistFile(ipsPath, WhitelistIp)
+	if err != nil {
+		return nil, errors.WithMessage(err, "whitelisted IP addresses")
+	}
+
+	return w, nil
+}
+
+// loadWhitelistFile reads and parses the whitelist file at the path. Returns
+// nothing if the path is empty.
+func loadWhitelistFile(path string, t WhitelistType) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := utils.ReadFile(path)
+	if err != nil {
+		return nil, errors.Errorf("could not read %s: %+v", path, err)
+	}
+
+	values, err := ParseWhitelist(data, t)
+	if err != nil {
+		return nil, errors.WithMessage(err, path)
+	}
+	return values, nil
+}
+
+// Get returns the sorted IDs and IP addresses currently whitelisted. Expired
+// entries in storage are skipped. The files are reloaded and, if one can no
+// longer be loaded, a warning is printed and its last loaded contents are used.
+func (w *Whitelist) Get(now time.Time) (ids, ips []string, err error) {
+	entries, err := PermissioningDb.GetWhitelistEntries(w.network)
+	if err != nil {
+		return nil, nil, errors.Errorf("could not load whitelist entries: "+
+			"%+v", err)
+	}
+
+	w.mux.Lock()
+	if fileIds, err := loadWhitelistFile(w.idsPath, WhitelistId); err != nil {
+		jww.WARN.Printf("Keeping last loaded whitelisted IDs: %+v", err)
+	} else {
+		w.fileIds = fileIds
+	}
+	if fileIps, err := loadWhitelistFile(w.ipsPath, WhitelistIp); err != nil {
+		jww.WARN.Printf("Keeping last loaded whitelisted IP addresses: %+v",
+			err)
+	} else {
+		w.fileIps = fileIps
+	}
+
+	idSet := make(map[string]bool, len(w.fileIds))
+	for _, value := range w.fileIds {
+		idSet[value] = true
+	}
+	ipSet := make(map[string]bool, len(w.fileIps))
+	for _, value := range w.fileIps {
+		ipSet[value] = true
+	}
+	w.mux.Unlock()
+
+	for _, entry := range entries {
+		if entry.IsExpired(now) {
+			continue
+		}
+		if entry.Type == WhitelistIp {
+			ipSet[entry.Value] = true
+		} else {
+			idSet[entry.Value] = true
+		}
+	}
+
+	return sortedKeys(idSet), sortedKeys(ipSet), nil
+}
+
+// sortedKeys returns the keys of the set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SetWhitelist replaces the whitelisted IDs and IP addresses in the internal
+// NDF; the change reaches the output NDF on its next update. Returns true if
+// the whitelist changed.
+func (s *NetworkState) SetWhitelist(ids, ips []string) bool {
+	s.InternalNdfLock.Lock()
+	defer s.InternalNdfLock.Unlock()
+
+	if equalStrings(s.unprunedNdf.WhitelistedIds, ids) &&
+		equalStrings(s.unprunedNdf.WhitelistedIpAddresses, ips) {
+		return false
+	}
+
+	newNdf := s.unprunedNdf.DeepCopy()
+	newNdf.WhitelistedIds = ids
+	newNdf.WhitelistedIpAddresses = ips
+	s.UpdateInternalNdf(newNdf)
+
+	return true
+}
+
+// equalStrings returns true if both lists have the same values in the same
+// order. Nil and empty lists are equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// PollWhitelist rebuilds the whitelist at the specified interval so that
+// expired entries and changes to storage or the files reach the NDF. onChange
+// is called after the whitelist changes. The provided channel allows for
+// external killing of the routine.
+func (s *NetworkState) PollWhitelist(w *Whitelist, interval time.Duration,
+	onChange func(), quitChan chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	jww.DEBUG.Printf("Starting whitelist updater thread polling every %s",
+		interval)
+
+	for {
+		select {
+		case <-quitChan:
+			jww.DEBUG.Printf("Killing whitelist polling routine.")
+			return
+		case <-ticker.C:
+			ids, ips, err := w.Get(time.Now())
+			if err != nil {
+				jww.WARN.Printf("Error while loading whitelist: %+v", err)
+				continue
+			}
+
+			if s.SetWhitelist(ids, ips) {
+				jww.INFO.Printf("Whitelist updated to %d IDs and %d IP "+
+					"addresses", len(ids), len(ips))
+				onChange()
+			}
+		}
+	}
+}
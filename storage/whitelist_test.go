@@ -0,0 +1,230 @@
+////////////////////////////////////////////////////////////////////////////////
+// Copyright © 2022 xx foundation                                             //
+//                                                                            //
+// Use of this source code is governed by a license that can be found in the  //
+// LICENSE file.                                                              //
+////////////////////////////////////////////////////////////////////////////////
+
+package storage
+
+import (
+	"encoding/base64"
+	"gitlab.com/xx_network/primitives/id"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Tests that ParseWhitelistValue returns the type and canonical form of IDs,
+// IP addresses, and CIDR blocks and rejects anything else.
+func TestParseWhitelistValue(t *testing.T) {
+	nid := id.NewIdFromString("client", id.User, t)
+	encoded := base64.StdEncoding.EncodeToString(nid.Marshal())
+
+	tests := []struct {
+		value, expected string
+		t               WhitelistType
+	}{
+		{"192.168.1.1", "192.168.1.1", WhitelistIp},
+		{" 10.0.0.1 ", "10.0.0.1", WhitelistIp},
+		{"2001:0db8::0001", "2001:db8::1", WhitelistIp},
+		{"10.0.0.7/24", "10.0.0.0/24", WhitelistIp},
+		{encoded, encoded, WhitelistId},
+	}
+
+	for i, tt := range tests {
+		valueType, value, err := ParseWhitelistValue(tt.value)
+		if err != nil {
+			t.Errorf("Failed to parse %q (%d): %+v", tt.value, i, err)
+		} else if valueType != tt.t || value != tt.expected {
+			t.Errorf("Unexpected result for %q (%d)."+
+				"\nexpected: %s %s\nreceived: %s %s",
+				tt.value, i, tt.t, tt.expected, valueType, value)
+		}
+	}
+
+	for i, value := range []string{"", "10.0.0.256", "10.0.0.0/33",
+		"not an id", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, _, err := ParseWhitelistValue(value); err == nil {
+			t.Errorf("Expected error for %q (%d).", value, i)
+		}
+	}
+}
+
+// Tests that ParseWhitelist parses a list of a single type and reports every
+// invalid or mistyped value.
+func TestParseWhitelist(t *testing.T) {
+	values, err := ParseWhitelist([]byte(`["10.0.0.1", "10.1.0.0/16"]`),
+		WhitelistIp)
+	if err != nil {
+		t.Fatalf("Failed to parse whitelist: %+v", err)
+	}
+	expected := []string{"10.0.0.1", "10.1.0.0/16"}
+	if !reflect.DeepEqual(expected, values) {
+		t.Errorf("Unexpected values.\nexpected: %v\nreceived: %v",
+			expected, values)
+	}
+
+	nid := id.NewIdFromString("client", id.User, t)
+	encoded := base64.StdEncoding.EncodeToString(nid.Marshal())
+	for i, data := range []string{`["10.0.0.1", "` + encoded + `"]`,
+		`["10.0.0.1", "bad"]`, `"10.0.0.1"`} {
+		if _, err = ParseWhitelist([]byte(data), WhitelistIp); err == nil {
+			t.Errorf("Expected error for %s (%d).", data, i)
+		}
+	}
+}
+
+// Tests that NewWhitelistEntry canonicalizes the value and rejects an expiry
+// that is not in the future.
+func TestNewWhitelistEntry(t *testing.T) {
+	now := time.Now()
+	entry, err := NewWhitelistEntry("", "10.0.0.7/8", now.Add(time.Hour), "note",
+		now)
+	if err != nil {
+		t.Fatalf("Failed to create entry: %+v", err)
+	}
+	expected := &WhitelistEntry{
+		Value:       "10.0.0.0/8",
+		Type:        WhitelistIp,
+		Expiry:      now.Add(time.Hour),
+		Note:        "note",
+		LastUpdated: now,
+	}
+	if !reflect.DeepEqual(expected, entry) {
+		t.Errorf("Unexpected entry.\nexpected: %+v\nreceived: %+v",
+			expected, entry)
+	}
+
+	if _, err = NewWhitelistEntry("", "10.0.0.1", now, "", now); err == nil {
+		t.Errorf("Expected error for expiry that is not in the future.")
+	}
+	if _, err = NewWhitelistEntry("", "bad", time.Time{}, "", now); err == nil {
+		t.Errorf("Expected error for invalid value.")
+	}
+}
+
+// Tests that WhitelistEntry.IsExpired only expires entries with an expiry.
+func TestWhitelistEntry_IsExpired(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		expiry  time.Time
+		expired bool
+	}{
+		{time.Time{}, false},
+		{now.Add(time.Second), false},
+		{now, true},
+		{now.Add(-time.Second), true},
+	}
+
+	for i, tt := range tests {
+		entry := &WhitelistEntry{Expiry: tt.expiry}
+		if entry.IsExpired(now) != tt.expired {
+			t.Errorf("Unexpected expiry for %s (%d).\nexpected: %t\nreceived: %t",
+				tt.expiry, i, tt.expired, !tt.expired)
+		}
+	}
+}
+
+// Tests that Whitelist.Get merges the files with the unexpired entries in
+// storage and keeps the last contents of a file that becomes invalid.
+func TestWhitelist_Get(t *testing.T) {
+	var err error
+	PermissioningDb, _, err = NewDatabase("", "", "", "", "")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ipsPath := "testWhitelistedIps.json"
+	defer func() { _ = os.RemoveAll(ipsPath) }()
+	if err = os.WriteFile(ipsPath, []byte(`["10.0.0.1"]`), 0644); err != nil {
+		t.Fatalf("Failed to write whitelist file: %+v", err)
+	}
+
+	w, err := NewWhitelist("", "", ipsPath)
+	if err != nil {
+		t.Fatalf("Failed to create whitelist: %+v", err)
+	}
+
+	now := time.Now()
+	nid := id.NewIdFromString("client", id.User, t)
+	encoded := base64.StdEncoding.EncodeToString(nid.Marshal())
+	for _, e := range []struct {
+		value  string
+		expiry time.Time
+	}{
+		{encoded, time.Time{}},
+		{"10.2.0.0/16", now.Add(time.Hour)},
+		{"10.3.0.1", now.Add(time.Minute)},
+	} {
+		entry, err := NewWhitelistEntry("", e.value, e.expiry, "", now)
+		if err != nil {
+			t.Fatalf("Failed to create entry: %+v", err)
+		}
+		if err = PermissioningDb.UpsertWhitelistEntry(entry); err != nil {
+			t.Fatalf("Failed to store entry: %+v", err)
+		}
+	}
+
+	// Invalidate the file so that its last contents are used
+	if err = os.WriteFile(ipsPath, []byte(`["bad"]`), 0644); err != nil {
+		t.Fatalf("Failed to write whitelist file: %+v", err)
+	}
+
+	ids, ips, err := w.Get(now.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to get whitelist: %+v", err)
+	}
+
+	if !reflect.DeepEqual([]string{encoded}, ids) {
+		t.Errorf("Unexpected IDs.\nexpected: %v\nreceived: %v",
+			[]string{encoded}, ids)
+	}
+	expectedIps := []string{"10.0.0.1", "10.2.0.0/16"}
+	if !reflect.DeepEqual(expectedIps, ips) {
+		t.Errorf("Unexpected IP addresses.\nexpected: %v\nreceived: %v",
+			expectedIps, ips)
+	}
+}
+
+// Tests that NewWhitelist errors when a file is invalid.
+func TestNewWhitelist_InvalidFile(t *testing.T) {
+	idsPath := "testWhitelistedIds.json"
+	defer func() { _ = os.RemoveAll(idsPath) }()
+	if err := os.WriteFile(idsPath, []byte(`["10.0.0.1"]`), 0644); err != nil {
+		t.Fatalf("Failed to write whitelist file: %+v", err)
+	}
+
+	if _, err := NewWhitelist("", idsPath, ""); err == nil {
+		t.Errorf("Expected error for IP address in ID whitelist.")
+	}
+	if _, err := NewWhitelist("", "", "doesNotExist.json"); err == nil {
+		t.Errorf("Expected error for missing file.")
+	}
+}
+
+// Tests that NetworkState.SetWhitelist only updates the NDF on a change.
+func TestNetworkState_SetWhitelist(t *testing.T) {
+	state, _, err := generateTestNetworkState()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	ips := []string{"10.0.0.1"}
+	if !state.SetWhitelist(nil, ips) {
+		t.Errorf("Expected whitelist to change.")
+	}
+	if !reflect.DeepEqual(ips, state.GetUnprunedNdf().WhitelistedIpAddresses) {
+		t.Errorf("Unexpected NDF whitelist.\nexpected: %v\nreceived: %v",
+			ips, state.GetUnprunedNdf().WhitelistedIpAddresses)
+	}
+
+	timestamp := state.GetUnprunedNdf().Timestamp
+	if state.SetWhitelist([]string{}, []string{"10.0.0.1"}) {
+		t.Errorf("Expected whitelist to be unchanged.")
+	}
+	if !timestamp.Equal(state.GetUnprunedNdf().Timestamp) {
+		t.Errorf("NDF updated when the whitelist did not change.")
+	}
+}